@@ -0,0 +1,178 @@
+// api.go
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiSorts are the orders GET /bookmarks can list in, ascending; "-" in
+// front makes them descending.
+var apiSorts = []string{"name", "url", "added", "opens", "frecency"}
+
+// bookmarksRequest is what a GET /bookmarks asks for.
+type bookmarksRequest struct {
+	filter        bookmarkFilter
+	query         string
+	sort          string
+	desc          bool
+	limit, offset int
+	fields        []string
+}
+
+// =============================================================================
+// == 🔌 COLLECTION API
+// =============================================================================
+//
+// serve answers GET /bookmarks with the collection, or the part of it the
+// parameters select, so another instance can seed itself with import remote
+// and clients like the web UI can fetch a page of results at a time:
+//
+//	q=<query>  tag=<t> (repeatable)  domain=<d>  limit=<n>  offset=<n>
+//	sort=<name|url|added|opens|frecency> (ascending; -added for newest first)
+//	fields=<name,url,...>
+//
+// The total before limit and offset is in the X-Total-Count header.
+
+// parseBookmarksRequest reads the parameters of a GET /bookmarks.
+func parseBookmarksRequest(v url.Values) (bookmarksRequest, error) {
+	var req bookmarksRequest
+	q, err := parseQuery(v.Get("q"))
+	if err != nil {
+		return req, fmt.Errorf("invalid q: %w", err)
+	}
+	req.filter = bookmarkFilter{query: q, text: v.Get("q"), domain: v.Get("domain")}
+	for _, t := range v["tag"] {
+		req.filter.tags = append(req.filter.tags, normalizeTag(t))
+	}
+	req.query = v.Get("q")
+	req.sort, req.desc = strings.CutPrefix(v.Get("sort"), "-")
+	if req.sort == "" {
+		req.sort = "name"
+	}
+	if !slices.Contains(apiSorts, req.sort) {
+		return req, fmt.Errorf("invalid sort %q (have %s)", req.sort, strings.Join(apiSorts, ", "))
+	}
+	for name, n := range map[string]*int{"limit": &req.limit, "offset": &req.offset} {
+		if text := v.Get(name); text != "" {
+			if *n, err = strconv.Atoi(text); err != nil || *n < 0 {
+				return req, fmt.Errorf("invalid %s %q", name, text)
+			}
+		}
+	}
+	if f := v.Get("fields"); f != "" {
+		req.fields = strings.Split(f, ",")
+	}
+	return req, nil
+}
+
+// sortForAPI sorts bookmarks (already by name) in the order req asks for.
+func (s *AppState) sortForAPI(bookmarks []Bookmark, req bookmarksRequest) {
+	now := time.Now()
+	key := map[string]func(Bookmark) float64{
+		"added":    func(b Bookmark) float64 { return float64(b.addedAt().Unix()) },
+		"opens":    func(b Bookmark) float64 { return float64(b.OpenCount) },
+		"frecency": func(b Bookmark) float64 { return s.frecency(b, now) },
+	}[req.sort]
+	switch {
+	case req.sort == "url":
+		slices.SortStableFunc(bookmarks, func(a, b Bookmark) int { return strings.Compare(a.URL, b.URL) })
+	case key != nil:
+		slices.SortStableFunc(bookmarks, func(a, b Bookmark) int { return cmp.Compare(key(a), key(b)) })
+	}
+	if req.desc {
+		slices.Reverse(bookmarks)
+	}
+}
+
+// apiSortValues lists what the sort parameter takes: each of apiSorts, and
+// each with "-" in front.
+func apiSortValues() []string {
+	var values []string
+	for _, s := range apiSorts {
+		values = append(values, s, "-"+s)
+	}
+	return values
+}
+
+// listForAPI returns the page of bookmarks req asks for, and how many there
+// are in all.
+func (s *AppState) listForAPI(req bookmarksRequest) ([]Bookmark, int) {
+	now := time.Now()
+	bookmarks := slices.DeleteFunc(s.filterBookmarks(req.filter), func(b Bookmark) bool {
+		return !b.visible(req.query, now)
+	})
+	s.sortForAPI(bookmarks, req)
+	total := len(bookmarks)
+	bookmarks = bookmarks[min(req.offset, total):]
+	if req.limit > 0 {
+		bookmarks = bookmarks[:min(req.limit, len(bookmarks))]
+	}
+	return bookmarks, total
+}
+
+// handleBookmarks answers GET /bookmarks.
+func (srv *apiServer) handleBookmarks(w http.ResponseWriter, r *http.Request) {
+	req, err := parseBookmarksRequest(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var bookmarks []Bookmark
+	var total int
+	err = srv.withState(func(s *AppState) error {
+		bookmarks, total = s.listForAPI(req)
+		return nil
+	})
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if req.fields == nil {
+		writeJSON(w, append([]Bookmark{}, bookmarks...), err)
+		return
+	}
+	sparse := make([]map[string]any, len(bookmarks))
+	for i, b := range bookmarks {
+		all := bookmarkFields(b)
+		sparse[i] = map[string]any{}
+		for _, f := range req.fields {
+			if v, ok := all[f]; ok {
+				sparse[i][f] = v
+			}
+		}
+	}
+	writeJSON(w, sparse, err)
+}
+
+// runImportRemote runs `import remote <host:port> <token> [query]`: it adds
+// the bookmarks of another instance's serve, or those the query selects,
+// whose URLs are not bookmarked yet.
+func (s *AppState) runImportRemote(args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: import remote <host:port> <token> [query]")
+	}
+	addr := strings.TrimSuffix(args[0], "/")
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	query := strings.Join(args[2:], " ")
+	if _, err := parseQuery(query); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	var bookmarks []Bookmark
+	if err := apiRequest(syncTarget{url: addr, token: args[1]}, http.MethodGet, "/bookmarks?q="+url.QueryEscape(query), nil, &bookmarks); err != nil {
+		return err
+	}
+	initialCount := len(s.Bookmarks)
+	s.beginImport()
+	for _, b := range bookmarks {
+		s.importOne(b, "remote", addr)
+	}
+	s.finishImport(initialCount)
+	return nil
+}
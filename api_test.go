@@ -0,0 +1,46 @@
+// api_test.go
+package main
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSortForAPI(t *testing.T) {
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := &AppState{}
+	bookmarks := []Bookmark{
+		{ID: 1, Name: "a", URL: "https://c.example", AddedAt: day.AddDate(0, 0, 2), OpenCount: 1},
+		{ID: 2, Name: "b", URL: "https://a.example", AddedAt: day, OpenCount: 5},
+		{ID: 3, Name: "c", URL: "https://b.example", AddedAt: day.AddDate(0, 0, 1), OpenCount: 3},
+	}
+	tests := map[string][]int{
+		"":       {1, 2, 3},
+		"-name":  {3, 2, 1},
+		"url":    {2, 3, 1},
+		"added":  {2, 3, 1},
+		"-added": {1, 3, 2},
+		"opens":  {1, 3, 2},
+		"-opens": {2, 3, 1},
+	}
+	for sort, want := range tests {
+		req, err := parseBookmarksRequest(url.Values{"sort": {sort}})
+		if err != nil {
+			t.Fatalf("sort=%s: %v", sort, err)
+		}
+		sorted := slices.Clone(bookmarks)
+		s.sortForAPI(sorted, req)
+		var got []int
+		for _, b := range sorted {
+			got = append(got, b.ID)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("sort=%s: IDs %v, want %v", sort, got, want)
+		}
+	}
+	if _, err := parseBookmarksRequest(url.Values{"sort": {"size"}}); err == nil {
+		t.Error("sort=size: no error")
+	}
+}
@@ -0,0 +1,176 @@
+// archive.go
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotsDir       = "snapshots"
+	maxSnapshotBytes   = 20 << 20
+	waybackSaveURL     = "https://web.archive.org/save/"
+	defaultArchiveDays = 7
+)
+
+// ArchiveInfo records where a bookmark has been preserved.
+type ArchiveInfo struct {
+	WaybackURL string    `json:"wayback_url,omitempty"`
+	WaybackAt  time.Time `json:"wayback_at,omitzero"`
+	Snapshot   string    `json:"snapshot,omitempty"`
+	SnapshotAt time.Time `json:"snapshot_at,omitzero"`
+}
+
+// ArchiveConfig drives the daemon's archive job.
+type ArchiveConfig struct {
+	Wayback  bool `json:"wayback"`
+	Snapshot bool `json:"snapshot"`
+	// Tags restricts the job to bookmarks carrying one of these tags.
+	Tags []string `json:"tags,omitempty"`
+	// MaxAgeDays only considers bookmarks added this recently (default 7), so
+	// turning the job on doesn't submit a whole collection at once.
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+}
+
+// =============================================================================
+// == 🏛️ ARCHIVING (WAYBACK MACHINE & LOCAL SNAPSHOTS)
+// =============================================================================
+
+// submitToWayback asks the Wayback Machine to capture url and returns the
+// address of the capture.
+func submitToWayback(client *http.Client, target string) (string, error) {
+	resp, err := client.Get(waybackSaveURL + target)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("wayback machine answered %s", resp.Status)
+	}
+	if loc := resp.Header.Get("Content-Location"); loc != "" {
+		return "https://web.archive.org" + loc, nil
+	}
+	if final := resp.Request.URL; strings.HasPrefix(final.Path, "/web/") {
+		return final.String(), nil
+	}
+	return "https://web.archive.org/web/" + url.PathEscape(target), nil
+}
+
+// takeSnapshot stores the page at b.URL under snapshots/<uuid>.html, or
+// <uuid>.html.gz gzipped when compress is set.
+func takeSnapshot(client *http.Client, b Bookmark, compress bool) (string, error) {
+	resp, err := client.Get(b.URL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("server answered %s", resp.Status)
+	}
+	if err := os.MkdirAll(dataPath(snapshotsDir), 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(snapshotsDir, b.UUID+".html")
+	stale := path + gzipExt
+	if compress {
+		path, stale = stale, path
+	}
+	f, err := os.Create(dataPath(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	body := io.LimitReader(resp.Body, maxSnapshotBytes)
+	if compress {
+		gz := gzip.NewWriter(f)
+		if _, err := io.Copy(gz, body); err != nil {
+			return "", err
+		}
+		if err := gz.Close(); err != nil {
+			return "", err
+		}
+	} else if _, err := io.Copy(f, body); err != nil {
+		return "", err
+	}
+	// A snapshot taken with the other setting would linger otherwise.
+	os.Remove(dataPath(stale))
+	return path, f.Close()
+}
+
+// archiveBookmark preserves the bookmark at index i as requested.
+func (s *AppState) archiveBookmark(client *http.Client, i int, wayback, snapshot bool) error {
+	b := &s.Bookmarks[i]
+	// Journal whatever succeeded, even if the other half then fails.
+	defer s.journalBookmarks(i)
+	if b.Archive == nil {
+		b.Archive = &ArchiveInfo{}
+	}
+	if wayback {
+		capture, err := submitToWayback(client, b.URL)
+		if err != nil {
+			return fmt.Errorf("wayback: %w", err)
+		}
+		b.Archive.WaybackURL, b.Archive.WaybackAt = capture, time.Now()
+	}
+	if snapshot {
+		path, err := takeSnapshot(client, *b, s.Config.Compress)
+		if err != nil {
+			return fmt.Errorf("snapshot: %w", err)
+		}
+		b.Archive.Snapshot, b.Archive.SnapshotAt = path, time.Now()
+	}
+	return nil
+}
+
+// archivePending runs the archive job: every recently added web bookmark
+// (matching the configured tags, if any) that hasn't been preserved yet the
+// configured ways.
+func (s *AppState) archivePending() (archived int) {
+	cfg := s.Config.Archive
+	if !cfg.Wayback && !cfg.Snapshot {
+		logger.Warn("archiving is not configured; set config.archive.wayback and/or .snapshot")
+		return 0
+	}
+	days := cfg.MaxAgeDays
+	if days <= 0 {
+		days = defaultArchiveDays
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+	clientFor := s.clientChooser()
+	for i, b := range s.Bookmarks {
+		if !isWebURL(b.URL) || b.addedAt().Before(cutoff) || !b.hasAnyTag(cfg.Tags) {
+			continue
+		}
+		needWayback := cfg.Wayback && (b.Archive == nil || b.Archive.WaybackURL == "")
+		needSnapshot := cfg.Snapshot && (b.Archive == nil || b.Archive.Snapshot == "")
+		if !needWayback && !needSnapshot {
+			continue
+		}
+		if err := s.archiveBookmark(clientFor(b), i, needWayback, needSnapshot); err != nil {
+			logger.Warn("could not archive", "bookmark", b.Name, "err", err)
+			continue
+		}
+		archived++
+	}
+	return archived
+}
+
+// hasAnyTag reports whether b carries one of tags; an empty list matches all.
+func (b Bookmark) hasAnyTag(tags []string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, t := range tags {
+		if b.hasTag(normalizeTag(t)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+// audit.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const historyFile = "history.jsonl"
+
+// AuditEntry is one line of the audit log. Old and New hold only the fields
+// that changed (for edits), or the whole bookmark (for adds and deletes).
+type AuditEntry struct {
+	Time   time.Time      `json:"time"`
+	Who    string         `json:"who"`
+	Op     string         `json:"op"`
+	ID     int            `json:"id,omitempty"`
+	UUID   string         `json:"uuid,omitempty"`
+	Name   string         `json:"name,omitempty"`
+	Detail string         `json:"detail,omitempty"`
+	Old    map[string]any `json:"old,omitempty"`
+	New    map[string]any `json:"new,omitempty"`
+}
+
+// =============================================================================
+// == 📜 AUDIT LOG
+// =============================================================================
+
+// editBookmark applies fn to the bookmark at index i, then logs what changed
+// and announces the edit. Every user-visible edit should go through here.
+func (s *AppState) editBookmark(i int, fn func(b *Bookmark)) {
+	before := bookmarkFields(s.Bookmarks[i])
+	old := s.Bookmarks[i]
+	fn(&s.Bookmarks[i])
+	if b := s.Bookmarks[i]; b.ID != old.ID || b.UUID != old.UUID || b.URL != old.URL {
+		s.index = nil
+	}
+	after := bookmarkFields(s.Bookmarks[i])
+	oldVals, newVals := make(map[string]any), make(map[string]any)
+	for k := range mergedKeys(before, after) {
+		if !reflect.DeepEqual(before[k], after[k]) {
+			oldVals[k], newVals[k] = before[k], after[k]
+		}
+	}
+	if len(oldVals) == 0 {
+		return
+	}
+	s.Bookmarks[i].stampFields(newVals, time.Now())
+	b := s.Bookmarks[i]
+	s.writeAudit(AuditEntry{Op: eventEdit, ID: b.ID, UUID: b.UUID, Name: b.Name, Old: oldVals, New: newVals})
+	s.emit(eventEdit, b)
+}
+
+// auditEvent logs adds, deletes and imports as they are emitted.
+func (s *AppState) auditEvent(event string, payload any) {
+	switch event {
+	case eventAdd, eventDelete:
+		b, ok := payload.(Bookmark)
+		if !ok {
+			return
+		}
+		e := AuditEntry{Op: event, ID: b.ID, UUID: b.UUID, Name: b.Name}
+		if event == eventAdd {
+			e.New = bookmarkFields(b)
+		} else {
+			e.Old = bookmarkFields(b)
+		}
+		s.writeAudit(e)
+	case eventImport:
+		added, _ := payload.([]Bookmark)
+		for _, b := range added {
+			s.writeAudit(AuditEntry{Op: event, ID: b.ID, UUID: b.UUID, Name: b.Name, New: bookmarkFields(b)})
+		}
+	}
+}
+
+// bookmarkFields snapshots a bookmark as a field map, the form stored in the log.
+func bookmarkFields(b Bookmark) map[string]any {
+	var m map[string]any
+	data, _ := json.Marshal(b)
+	json.Unmarshal(data, &m)
+	return m
+}
+
+func mergedKeys(a, b map[string]any) map[string]bool {
+	keys := make(map[string]bool, len(a)+len(b))
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	return keys
+}
+
+// auditUser identifies who made a change: user@host.
+func auditUser() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// writeAudit appends an entry to the audit log.
+func (s *AppState) writeAudit(e AuditEntry) {
+	e.Time, e.Who = time.Now(), auditUser()
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(dataPath(historyFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Warn("could not write audit log", "err", err)
+		return
+	}
+	defer f.Close()
+	f.Write(append(data, '\n'))
+}
+
+// readAudit returns the logged entries, optionally only those about the
+// bookmark with the given UUID.
+func readAudit(uuid string) ([]AuditEntry, error) {
+	f, err := os.Open(dataPath(historyFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []AuditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 16<<20)
+	for sc.Scan() {
+		var e AuditEntry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		if uuid == "" || e.UUID == uuid {
+			entries = append(entries, e)
+		}
+	}
+	return entries, sc.Err()
+}
+
+// describeChange renders an entry's changes as "field: old → new" pairs.
+func describeChange(e AuditEntry) string {
+	switch {
+	case e.Detail != "":
+		return e.Detail
+	case e.Old != nil && e.New != nil:
+		keys := make([]string, 0, len(e.New))
+		for k := range mergedKeys(e.Old, e.New) {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, fmt.Sprintf("%s: %v → %v", k, auditValue(e.Old[k]), auditValue(e.New[k])))
+		}
+		return strings.Join(parts, "; ")
+	case e.New != nil:
+		return fmt.Sprintf("%v", e.New["url"])
+	case e.Old != nil:
+		return fmt.Sprintf("%v", e.Old["url"])
+	}
+	return ""
+}
+
+func auditValue(v any) string {
+	if v == nil {
+		return "(none)"
+	}
+	return fmt.Sprint(v)
+}
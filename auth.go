@@ -0,0 +1,135 @@
+// auth.go
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DomainAuth holds what to send to one site (and its subdomains) so pages
+// behind a login can be fetched.
+type DomainAuth struct {
+	// Cookies is a cookies.txt file in the Netscape format browser
+	// extensions export; only the cookies for the requested host are sent.
+	Cookies string `json:"cookies,omitempty"`
+	// Headers are sent as they are, e.g. "Authorization": "Bearer ...".
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// netscapeCookie is one line of a cookies.txt file.
+type netscapeCookie struct {
+	domain     string
+	subdomains bool
+	path       string
+	secure     bool
+	expires    time.Time
+	name       string
+	value      string
+}
+
+// cookieFiles caches parsed cookies.txt files by path for the process.
+var (
+	cookieFilesMu sync.Mutex
+	cookieFiles   = map[string][]netscapeCookie{}
+)
+
+// =============================================================================
+// == 🔑 PER-DOMAIN CREDENTIALS
+// =============================================================================
+
+// addDomainAuth adds the headers and cookies configured for req's host. It
+// runs on every request, redirects included, so credentials never follow a
+// redirect to another site.
+func addDomainAuth(req *http.Request) {
+	host := strings.ToLower(req.URL.Hostname())
+	for domain, auth := range netConfig.Auth {
+		if !hostMatches(host, strings.ToLower(strings.TrimPrefix(domain, "."))) {
+			continue
+		}
+		for name, value := range auth.Headers {
+			req.Header.Set(name, value)
+		}
+		if auth.Cookies == "" {
+			continue
+		}
+		cookies, err := loadCookieFile(auth.Cookies)
+		if err != nil {
+			logger.Warn("could not read cookies", "domain", domain, "err", err)
+			continue
+		}
+		for _, c := range cookies {
+			if c.matches(req) {
+				req.AddCookie(&http.Cookie{Name: c.name, Value: c.value})
+			}
+		}
+	}
+}
+
+// matches reports whether c should be sent with req.
+func (c netscapeCookie) matches(req *http.Request) bool {
+	host := strings.ToLower(req.URL.Hostname())
+	domain := strings.TrimPrefix(c.domain, ".")
+	if host != domain && !(c.subdomains && strings.HasSuffix(host, "."+domain)) {
+		return false
+	}
+	if c.secure && req.URL.Scheme != "https" {
+		return false
+	}
+	if !c.expires.IsZero() && c.expires.Before(time.Now()) {
+		return false
+	}
+	path := req.URL.Path
+	if path == "" {
+		path = "/"
+	}
+	return strings.HasPrefix(path, c.path)
+}
+
+// loadCookieFile parses a cookies.txt file once per process.
+func loadCookieFile(path string) ([]netscapeCookie, error) {
+	cookieFilesMu.Lock()
+	defer cookieFilesMu.Unlock()
+	if cookies, ok := cookieFiles[path]; ok {
+		return cookies, nil
+	}
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cookies []netscapeCookie
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			continue
+		}
+		c := netscapeCookie{
+			domain:     strings.ToLower(fields[0]),
+			subdomains: strings.EqualFold(fields[1], "TRUE"),
+			path:       fields[2],
+			secure:     strings.EqualFold(fields[3], "TRUE"),
+			name:       fields[5],
+			value:      fields[6],
+		}
+		// 0 marks a session cookie, which never expires here.
+		if sec, err := strconv.ParseInt(fields[4], 10, 64); err == nil && sec > 0 {
+			c.expires = time.Unix(sec, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	cookieFiles[path] = cookies
+	return cookies, nil
+}
@@ -0,0 +1,216 @@
+// backup.go
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupDir    = "backups"
+	backupPrefix = "bibliothermes-"
+	backupSuffix = ".tar.gz"
+	// backupConfigName is the config file's name in an archive: it lives
+	// outside the data directory, at configPath().
+	backupConfigName = "config.yaml"
+)
+
+// =============================================================================
+// == 🗄️ BACKUP & RESTORE
+// =============================================================================
+
+// backupSources lists the files and directories of the data directory that
+// make up a full backup; the config file is added to them.
+func backupSources() []string {
+	return []string{bookmarksFile, historyFile, snapshotsDir}
+}
+
+// createBackup writes a timestamped .tar.gz of every backup source to dest.
+// An empty dest or a directory puts the archive in it with a generated name.
+func createBackup(dest string) (string, error) {
+	name := backupPrefix + time.Now().Format("20060102-150405") + backupSuffix
+	if dest == "" {
+		if err := os.MkdirAll(dataPath(backupDir), 0755); err != nil {
+			return "", fmt.Errorf("could not create backup directory: %w", err)
+		}
+		dest = dataPath(backupDir)
+	}
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		dest = filepath.Join(dest, name)
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return "", fmt.Errorf("could not create archive: %w", err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, src := range backupSources() {
+		if err := addToArchive(tw, src); err != nil {
+			return "", err
+		}
+	}
+	if info, err := os.Stat(configPath()); err == nil {
+		if err := archiveEntry(tw, configPath(), backupConfigName, info); err != nil {
+			return "", err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return "", fmt.Errorf("could not finish archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return "", fmt.Errorf("could not finish archive: %w", err)
+	}
+	return dest, f.Close()
+}
+
+// addToArchive adds the data file or directory root, named as in the data
+// directory.
+func addToArchive(tw *tar.Writer, root string) error {
+	return filepath.WalkDir(dataPath(root), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dataPath("."), path)
+		if err != nil {
+			return err
+		}
+		return archiveEntry(tw, path, filepath.ToSlash(name), info)
+	})
+}
+
+// archiveEntry adds the file or directory at path to the archive as name.
+func archiveEntry(tw *tar.Writer, path, name string, info fs.FileInfo) error {
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("could not archive %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(tw, f)
+	return err
+}
+
+// restoreBackup extracts an archive made by createBackup over the current
+// files, the config file included.
+func restoreBackup(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open archive: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("not a backup archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read archive: %w", err)
+		}
+		name := filepath.FromSlash(hdr.Name)
+		if filepath.IsAbs(name) || strings.HasPrefix(filepath.Clean(name), "..") {
+			return fmt.Errorf("refusing to restore unsafe path %q", hdr.Name)
+		}
+		name, perm := dataPath(name), os.FileMode(0644)
+		if hdr.Name == backupConfigName {
+			// It may hold tokens: saveConfig writes it for the user only.
+			name, perm = configPath(), 0600
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(name, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+			if err != nil {
+				return fmt.Errorf("could not restore %s: %w", name, err)
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return fmt.Errorf("could not restore %s: %w", name, err)
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readLatestBackup returns the named file from the newest archive in backupDir.
+// Archive names embed their timestamp, so lexical order is chronological.
+func readLatestBackup(name string) ([]byte, string, error) {
+	entries, err := os.ReadDir(dataPath(backupDir))
+	if err != nil {
+		return nil, "", fmt.Errorf("no backups available: %w", err)
+	}
+	var archives []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), backupSuffix) {
+			archives = append(archives, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(archives)))
+	for _, a := range archives {
+		path := filepath.Join(dataPath(backupDir), a)
+		if data, err := readFromArchive(path, name); err == nil {
+			return data, path, nil
+		}
+	}
+	return nil, "", fmt.Errorf("no backup contains %s", name)
+}
+
+func readFromArchive(path, name string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == filepath.ToSlash(name) {
+			return io.ReadAll(tr)
+		}
+	}
+}
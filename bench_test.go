@@ -0,0 +1,148 @@
+// bench_test.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+// benchSize is how many bookmarks the benchmarks generate. Common operations
+// should stay under 50ms per op at this size, so the prompt feels instant.
+const benchSize = 100000
+
+// benchWords make up the names, tags and descriptions of generated bookmarks.
+var benchWords = strings.Fields("golang sqlite tutorial kubernetes docker rust python recipe travel music " +
+	"guide notes paper video design history review release security database network cooking " +
+	"garden science startup finance linux editor terminal browser archive search")
+
+// benchState returns a throwaway state holding n generated bookmarks. Nothing
+// is read from or written to the data directory.
+func benchState(b *testing.B, n int) *AppState {
+	b.Helper()
+	rng := rand.New(rand.NewSource(1))
+	word := func() string { return benchWords[rng.Intn(len(benchWords))] }
+	s := &AppState{nextID: 1}
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		s.Bookmarks = append(s.Bookmarks, Bookmark{
+			ID:          s.nextID,
+			UUID:        newUUID(),
+			Name:        fmt.Sprintf("%s %s %s %d", word(), word(), word(), i),
+			URL:         fmt.Sprintf("https://%s.example/%s/%d", word(), word(), i),
+			Description: word() + " " + word() + " " + word(),
+			Tags:        []string{word(), word()},
+			AddedAt:     start.Add(-time.Duration(i) * time.Minute),
+		})
+		s.nextID++
+	}
+	return s
+}
+
+// benchIndex returns a full-text index of the bookmarks of s.
+func benchIndex(s *AppState) *searchIndex {
+	idx := newSearchIndex()
+	for _, b := range s.Bookmarks {
+		idx.add(b.UUID, indexDoc{Fingerprint: indexFingerprint(b), Terms: bookmarkTerms(b)})
+	}
+	idx.sortVocabulary()
+	return idx
+}
+
+func BenchmarkBuildLookupIndex(b *testing.B) {
+	s := benchState(b, benchSize)
+	for b.Loop() {
+		s.index = nil
+		s.positions()
+	}
+}
+
+func BenchmarkLookupByID(b *testing.B) {
+	s := benchState(b, benchSize)
+	target := s.Bookmarks[benchSize/2]
+	s.positions()
+	for b.Loop() {
+		if s.indexOfID(target.ID) < 0 {
+			b.Fatal("bookmark not found by ID")
+		}
+	}
+}
+
+func BenchmarkLookupByURL(b *testing.B) {
+	s := benchState(b, benchSize)
+	target := s.Bookmarks[benchSize/2]
+	s.positions()
+	for b.Loop() {
+		if s.indexOfURL(target.URL) < 0 {
+			b.Fatal("bookmark not found by URL")
+		}
+	}
+}
+
+func BenchmarkAddBookmark(b *testing.B) {
+	s := benchState(b, benchSize)
+	s.positions()
+	for b.Loop() {
+		url := fmt.Sprintf("https://bench.example/new/%d", s.nextID)
+		if s.indexOfURL(url) >= 0 {
+			b.Fatal("new URL already bookmarked")
+		}
+		s.Bookmarks = append(s.Bookmarks, Bookmark{ID: s.nextID, UUID: newUUID(), Name: "new", URL: url, AddedAt: time.Now()})
+		s.appended()
+		s.nextID++
+	}
+}
+
+func BenchmarkBuildSearchIndex(b *testing.B) {
+	s := benchState(b, benchSize)
+	for b.Loop() {
+		benchIndex(s)
+	}
+}
+
+func BenchmarkSearchWithTypos(b *testing.B) {
+	idx := benchIndex(benchState(b, benchSize))
+	for b.Loop() {
+		idx.lookup("golag sqlte", true)
+	}
+}
+
+func BenchmarkFilterByQuery(b *testing.B) {
+	s := benchState(b, benchSize)
+	q, err := parseQueryCase("tag:rust name:guide", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		s.filterBookmarks(bookmarkFilter{query: q})
+	}
+}
+
+func BenchmarkSortByName(b *testing.B) {
+	s := benchState(b, benchSize)
+	for b.Loop() {
+		sortByName(append([]Bookmark(nil), s.Bookmarks...))
+	}
+}
+
+func BenchmarkEncodeState(b *testing.B) {
+	s := benchState(b, benchSize)
+	for b.Loop() {
+		if _, err := json.MarshalIndent(s, "", "  "); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDetectUnchangedSave(b *testing.B) {
+	data, err := json.MarshalIndent(benchState(b, benchSize), "", "  ")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		contentHash(data)
+	}
+}
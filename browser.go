@@ -0,0 +1,306 @@
+// browser.go
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// browserSystem as Config.DefaultBrowserCmd opens links in the OS default
+// browser, found each time from the system settings. Anything after it
+// ("system --new-window") is passed to that browser as flags.
+const browserSystem = "system"
+
+// knownBrowsers are the browser commands the setup looks for on PATH.
+var knownBrowsers = []string{
+	"firefox", "librewolf", "chromium", "chromium-browser", "google-chrome", "brave-browser",
+	"microsoft-edge", "vivaldi", "opera", "epiphany", "qutebrowser",
+}
+
+// macBrowsers maps the applications the setup looks for on macOS to their
+// bundle identifiers.
+var macBrowsers = map[string]string{
+	"Safari":         "com.apple.Safari",
+	"Firefox":        "org.mozilla.firefox",
+	"Google Chrome":  "com.google.Chrome",
+	"Chromium":       "org.chromium.Chromium",
+	"Brave Browser":  "com.brave.Browser",
+	"Microsoft Edge": "com.microsoft.edgemac",
+	"Vivaldi":        "com.vivaldi.Vivaldi",
+}
+
+// =============================================================================
+// == 🧭 SYSTEM DEFAULT BROWSER
+// =============================================================================
+
+// installedBrowsers returns a browser command for each browser found:
+// executables on PATH, or applications on macOS.
+func installedBrowsers() []string {
+	var found []string
+	if runtime.GOOS == "darwin" {
+		for _, app := range slices.Sorted(maps.Keys(macBrowsers)) {
+			if _, err := os.Stat(filepath.Join("/Applications", app+".app")); err == nil {
+				found = append(found, "open -b "+macBrowsers[app])
+			}
+		}
+		return found
+	}
+	for _, name := range knownBrowsers {
+		if _, err := exec.LookPath(name); err == nil {
+			found = append(found, name)
+		}
+	}
+	return found
+}
+
+// openInBrowser opens a URL with the configured browser command.
+func (s *AppState) openInBrowser(url string) error {
+	parts := strings.Fields(s.Config.DefaultBrowserCmd)
+	if len(parts) == 0 || parts[0] != browserSystem {
+		return startCommand(s.Config.DefaultBrowserCmd, url)
+	}
+	cmd, err := systemBrowserCommand(parts[1:], url)
+	if err != nil {
+		// The OS opener still reaches the default browser, minus the flags.
+		logger.Warn("could not find the default browser", "using", defaultBrowserCmd(), "err", err)
+		return startCommand(defaultBrowserCmd(), url)
+	}
+	return exec.Command(cmd[0], cmd[1:]...).Start()
+}
+
+// systemBrowserCommand returns the command line that opens url with flags in
+// the OS default browser.
+func systemBrowserCommand(flags []string, url string) ([]string, error) {
+	switch runtime.GOOS {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		exe, err := xdgDefaultBrowser()
+		if err != nil {
+			return nil, err
+		}
+		return append(append(exe, flags...), url), nil
+	case "darwin":
+		bundle, err := macDefaultBrowser()
+		if err != nil {
+			return nil, err
+		}
+		if len(flags) == 0 {
+			return []string{"open", "-b", bundle, url}, nil
+		}
+		return append(append([]string{"open", "-n", "-b", bundle, "--args"}, flags...), url), nil
+	case "windows":
+		exe, err := windowsDefaultBrowser()
+		if err != nil {
+			return nil, err
+		}
+		return append(append(exe, flags...), url), nil
+	}
+	return nil, fmt.Errorf("unsupported system %s", runtime.GOOS)
+}
+
+// describeBrowser explains what the browser setting resolves to, for
+// set-browser without arguments.
+func (s *AppState) describeBrowser() string {
+	parts := strings.Fields(s.Config.DefaultBrowserCmd)
+	if len(parts) == 0 || parts[0] != browserSystem {
+		return ""
+	}
+	cmd, err := systemBrowserCommand(parts[1:], "<url>")
+	if err != nil {
+		return "not found: " + err.Error()
+	}
+	return strings.Join(cmd, " ")
+}
+
+// xdgDefaultBrowser asks xdg-settings for the default browser's .desktop
+// entry and returns its Exec line without the field codes.
+func xdgDefaultBrowser() ([]string, error) {
+	out, err := exec.Command("xdg-settings", "get", "default-web-browser").Output()
+	if err != nil {
+		return nil, fmt.Errorf("xdg-settings: %w", err)
+	}
+	desktop := strings.TrimSpace(string(out))
+	if desktop == "" {
+		return nil, errors.New("no default browser set")
+	}
+	for _, dir := range xdgApplicationDirs() {
+		exe, err := desktopExec(filepath.Join(dir, desktop))
+		if err == nil {
+			return exe, nil
+		}
+	}
+	return nil, fmt.Errorf("could not find %s", desktop)
+}
+
+// xdgApplicationDirs lists where .desktop files live, most specific first,
+// including flatpak exports.
+func xdgApplicationDirs() []string {
+	home, _ := os.UserHomeDir()
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local/share")
+	}
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	dirs := []string{filepath.Join(dataHome, "applications")}
+	for _, d := range strings.Split(dataDirs, ":") {
+		dirs = append(dirs, filepath.Join(d, "applications"))
+	}
+	return append(dirs,
+		filepath.Join(dataHome, "flatpak/exports/share/applications"),
+		"/var/lib/flatpak/exports/share/applications",
+		"/var/lib/snapd/desktop/applications")
+}
+
+// desktopExec reads the Exec key of the [Desktop Entry] group of a .desktop
+// file, dropping field codes like %u.
+func desktopExec(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	group := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			group = line
+			continue
+		}
+		if group != "[Desktop Entry]" || !strings.HasPrefix(line, "Exec=") {
+			continue
+		}
+		var exe []string
+		for _, arg := range splitCommandLine(strings.TrimPrefix(line, "Exec=")) {
+			if len(arg) == 2 && arg[0] == '%' && arg != "%%" {
+				continue
+			}
+			exe = append(exe, strings.ReplaceAll(arg, "%%", "%"))
+		}
+		if len(exe) == 0 {
+			break
+		}
+		return exe, nil
+	}
+	return nil, fmt.Errorf("no Exec line in %s", path)
+}
+
+// Patterns for the LSHandlers entries in `defaults read` output.
+var (
+	lsHandlerPattern = regexp.MustCompile(`\{[^{}]*\}`)
+	lsHTTPSPattern   = regexp.MustCompile(`LSHandlerURLScheme = "?https"?;`)
+	lsRolePattern    = regexp.MustCompile(`LSHandlerRoleAll = "?([\w.-]+)"?;`)
+)
+
+// macDefaultBrowser returns the bundle identifier that LaunchServices uses
+// for https links, e.g. "org.mozilla.firefox".
+func macDefaultBrowser() (string, error) {
+	out, err := exec.Command("defaults", "read", "com.apple.LaunchServices/com.apple.launchservices.secure", "LSHandlers").Output()
+	if err != nil {
+		return "", fmt.Errorf("could not read LaunchServices settings: %w", err)
+	}
+	for _, entry := range lsHandlerPattern.FindAllString(string(out), -1) {
+		if !lsHTTPSPattern.MatchString(entry) {
+			continue
+		}
+		if m := lsRolePattern.FindStringSubmatch(entry); m != nil {
+			return m[1], nil
+		}
+	}
+	// Without an explicit choice, macOS uses Safari.
+	return "com.apple.safari", nil
+}
+
+// windowsDefaultBrowser looks up the https handler the user chose in the
+// registry and returns its open command without the %1 placeholder.
+func windowsDefaultBrowser() ([]string, error) {
+	progID, err := regQuery(`HKCU\Software\Microsoft\Windows\Shell\Associations\UrlAssociations\https\UserChoice`, "ProgId")
+	if err != nil {
+		return nil, err
+	}
+	command, err := regQuery(`HKCR\`+progID+`\shell\open\command`, "")
+	if err != nil {
+		return nil, err
+	}
+	var exe []string
+	for _, arg := range splitCommandLine(command) {
+		// --single-argument tells Chromium the rest is one URL; flags
+		// follow, so the URL is appended after them instead.
+		if arg == "%1" || arg == "--single-argument" || arg == "-osint" || arg == "-url" {
+			continue
+		}
+		exe = append(exe, arg)
+	}
+	if len(exe) == 0 {
+		return nil, fmt.Errorf("empty open command for %s", progID)
+	}
+	return exe, nil
+}
+
+// winEnvPattern matches %VARIABLE% references in REG_EXPAND_SZ values.
+var winEnvPattern = regexp.MustCompile(`%\w+%`)
+
+// regQuery returns a registry value using reg.exe; an empty name reads the
+// key's default value.
+func regQuery(key, name string) (string, error) {
+	args := []string{"query", key}
+	if name == "" {
+		args = append(args, "/ve")
+	} else {
+		args = append(args, "/v", name)
+	}
+	out, err := exec.Command("reg", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("could not read %s: %w", key, err)
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if _, value, ok := strings.Cut(line, "REG_SZ"); ok {
+			return strings.TrimSpace(value), nil
+		}
+		if _, value, ok := strings.Cut(line, "REG_EXPAND_SZ"); ok {
+			return winEnvPattern.ReplaceAllStringFunc(strings.TrimSpace(value), func(v string) string {
+				return os.Getenv(strings.Trim(v, "%"))
+			}), nil
+		}
+	}
+	return "", fmt.Errorf("no value in %s", key)
+}
+
+// splitCommandLine splits a command line on spaces, keeping double-quoted
+// arguments together.
+func splitCommandLine(line string) []string {
+	var args []string
+	var cur strings.Builder
+	quoted, inArg := false, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inArg = true
+		case r == ' ' && !quoted:
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args
+}
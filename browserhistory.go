@@ -0,0 +1,205 @@
+// browserhistory.go
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultMinVisits is how often a page must have been visited before
+	// suggest-from-history offers it.
+	defaultMinVisits = 5
+	// historySuggestions caps how many pages one run offers.
+	historySuggestions = 20
+)
+
+// historyEntry is a page from a browser's history, merged across browsers.
+type historyEntry struct {
+	URL       string
+	Title     string
+	Visits    int
+	LastVisit time.Time
+	Browser   string
+	Path      string
+}
+
+// =============================================================================
+// == 🕘 SUGGESTIONS FROM BROWSER HISTORY
+// =============================================================================
+
+// readChromeHistory reads the pages visited at least minVisits times from a
+// Chromium "History" database.
+func readChromeHistory(browser, path string, minVisits int) ([]historyEntry, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_immutable=1", path))
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s history: %w", browser, err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT url, title, visit_count, last_visit_time FROM urls WHERE visit_count >= ? AND hidden = 0`, minVisits)
+	if err != nil {
+		return nil, fmt.Errorf("could not query %s history: %w", browser, err)
+	}
+	defer rows.Close()
+	// Chromium counts microseconds since 1601-01-01.
+	epoch := time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+	var out []historyEntry
+	for rows.Next() {
+		var e historyEntry
+		var last int64
+		if err := rows.Scan(&e.URL, &e.Title, &e.Visits, &last); err != nil {
+			continue
+		}
+		e.LastVisit = epoch.Add(time.Duration(last) * time.Microsecond)
+		e.Browser, e.Path = browser, path
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// readFirefoxHistory reads the pages visited at least minVisits times from the
+// places.sqlite database of Firefox or a fork.
+func readFirefoxHistory(browser, path string, minVisits int) ([]historyEntry, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_immutable=1", path))
+	if err != nil {
+		return nil, fmt.Errorf("could not open %s history: %w", browser, err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT url, COALESCE(title, ''), visit_count, COALESCE(last_visit_date, 0) FROM moz_places WHERE visit_count >= ? AND hidden = 0`, minVisits)
+	if err != nil {
+		return nil, fmt.Errorf("could not query %s history: %w", browser, err)
+	}
+	defer rows.Close()
+	var out []historyEntry
+	for rows.Next() {
+		var e historyEntry
+		var last int64
+		if err := rows.Scan(&e.URL, &e.Title, &e.Visits, &last); err != nil {
+			continue
+		}
+		e.LastVisit = time.UnixMicro(last)
+		e.Browser, e.Path = browser, path
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// browserHistory gathers the often visited pages of every known browser,
+// merging the same URL seen in several of them.
+func browserHistory(minVisits int) []historyEntry {
+	chromeLikePaths, firefoxDirs := getBrowserPaths()
+	var all []historyEntry
+	for browser, paths := range chromeLikePaths {
+		for _, path := range paths {
+			history := filepath.Join(filepath.Dir(path), "History")
+			if _, err := os.Stat(history); err != nil {
+				continue
+			}
+			entries, err := readChromeHistory(browser, history, minVisits)
+			if err != nil {
+				logger.Warn("could not read history", "err", err)
+			}
+			all = append(all, entries...)
+		}
+	}
+	for browser, dirs := range firefoxDirs {
+		for _, dir := range dirs {
+			for _, path := range firefoxPlaces(dir) {
+				entries, err := readFirefoxHistory(browser, path, minVisits)
+				if err != nil {
+					logger.Warn("could not read history", "err", err)
+				}
+				all = append(all, entries...)
+			}
+		}
+	}
+	byURL := map[string]*historyEntry{}
+	var merged []*historyEntry
+	for _, e := range all {
+		key := asciiURL(e.URL)
+		m, ok := byURL[key]
+		if !ok {
+			e := e
+			byURL[key] = &e
+			merged = append(merged, &e)
+			continue
+		}
+		m.Visits += e.Visits
+		if m.Title == "" {
+			m.Title = e.Title
+		}
+		if e.LastVisit.After(m.LastVisit) {
+			m.LastVisit = e.LastVisit
+		}
+	}
+	out := make([]historyEntry, len(merged))
+	for i, m := range merged {
+		out[i] = *m
+	}
+	return out
+}
+
+// historyCandidates returns the web pages from history that aren't
+// bookmarked yet, most visited first.
+func (s *AppState) historyCandidates(minVisits int) []historyEntry {
+	bookmarked := map[string]bool{}
+	for _, b := range s.Bookmarks {
+		bookmarked[asciiURL(b.URL)] = true
+	}
+	var out []historyEntry
+	for _, e := range browserHistory(minVisits) {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || bookmarked[asciiURL(e.URL)] {
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Visits != out[j].Visits {
+			return out[i].Visits > out[j].Visits
+		}
+		return out[i].LastVisit.After(out[j].LastVisit)
+	})
+	if len(out) > historySuggestions {
+		out = out[:historySuggestions]
+	}
+	return out
+}
+
+// suggestFromHistory offers the most visited unbookmarked pages one at a
+// time; a single key adds, skips or stops.
+func (s *AppState) suggestFromHistory(minVisits int) {
+	candidates := s.historyCandidates(minVisits)
+	if len(candidates) == 0 {
+		fmt.Printf("No unbookmarked pages with at least %d visits in browser history.\n", minVisits)
+		return
+	}
+	added := 0
+	for i, e := range candidates {
+		name := strings.TrimSpace(e.Title)
+		if name == "" {
+			name = e.URL
+		}
+		fmt.Printf("%s[%d/%d]%s %s %s(%d visits, last %s)%s\n    %s\n", style(Bold+Cyan), i+1, len(candidates), style(Reset),
+			name, style(Gray), e.Visits, e.LastVisit.Local().Format("2006-01-02"), style(Reset), displayURL(e.URL))
+		key := askKey("Add it? [y]es, [n]o, [q]uit: ")
+		if key == "" || key == "q" {
+			break
+		}
+		if key != "y" {
+			continue
+		}
+		src := &Source{Browser: e.Browser, Profile: filepath.Base(filepath.Dir(e.Path)), Path: e.Path, ImportedAt: time.Now()}
+		if s.addBookmark(name, e.URL, src) {
+			s.emit(eventAdd, s.Bookmarks[len(s.Bookmarks)-1])
+			added++
+		}
+	}
+	fmt.Printf("Added %d bookmarks from history.\n", added)
+}
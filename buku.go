@@ -0,0 +1,99 @@
+// buku.go
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// bukuSchema is the table buku creates; URL is unique, tags are stored
+// between commas (",go,tools,"), metadata is the title.
+const bukuSchema = `CREATE TABLE IF NOT EXISTS bookmarks (
+	id integer PRIMARY KEY,
+	URL text NOT NULL UNIQUE,
+	metadata text default '',
+	tags text default ',',
+	desc text default '',
+	flags integer default 0)`
+
+// =============================================================================
+// == 🐦 BUKU EXPORT
+// =============================================================================
+//
+// export buku writes the bookmarks into a buku database, buku's own by
+// default, so scripts and phone workflows built around buku see them. The
+// database is added to, not replaced: URLs it already has are left as they
+// are, as buku itself does.
+
+// bukuDefaultDB returns where buku keeps its database.
+func bukuDefaultDB() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if runtime.GOOS == "windows" {
+		dataHome = os.Getenv("APPDATA")
+	}
+	if dataHome == "" {
+		home, _ := os.UserHomeDir()
+		dataHome = filepath.Join(home, ".local/share")
+	}
+	return filepath.Join(dataHome, "buku", "bookmarks.db")
+}
+
+// exportBuku adds bookmarks to the buku database at path, or buku's own if
+// path is empty.
+func exportBuku(path string, bookmarks []Bookmark) error {
+	if path == "" {
+		path = bukuDefaultDB()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return fmt.Errorf("could not open %s: %w", path, err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(bukuSchema); err != nil {
+		return fmt.Errorf("%s is not a buku database: %w", path, err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO bookmarks (URL, metadata, tags, desc) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("%s is not a buku database: %w", path, err)
+	}
+	defer stmt.Close()
+	added := 0
+	for _, b := range bookmarks {
+		res, err := stmt.Exec(b.URL, b.Name, bukuTags(b.Tags), b.Description)
+		if err != nil {
+			return fmt.Errorf("could not write '%s': %w", b.Name, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			added++
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d bookmarks to %s", added, path)
+	if skipped := len(bookmarks) - added; skipped > 0 {
+		fmt.Printf(" (%d already there)", skipped)
+	}
+	fmt.Println(".")
+	return nil
+}
+
+// bukuTags writes tags the way buku stores them: lower case, between commas.
+func bukuTags(tags []string) string {
+	if len(tags) == 0 {
+		return ","
+	}
+	return "," + strings.ToLower(strings.Join(tags, ",")) + ","
+}
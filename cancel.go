@@ -0,0 +1,27 @@
+// cancel.go
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// =============================================================================
+// == ✋ CANCELLING
+// =============================================================================
+//
+// Ctrl-C during check, refresh-titles, enrich or import in the REPL cancels
+// that operation and returns to the prompt, instead of quitting with the
+// session's unsaved changes. Requests in flight are dropped, the worker pools
+// stop taking bookmarks, and the results that came in are kept. At the prompt
+// and in one-shot commands Ctrl-C quits as before.
+
+// interruptible returns a context that Ctrl-C cancels, when in the REPL;
+// stop gives Ctrl-C back its usual meaning.
+func interruptible() (ctx context.Context, stop context.CancelFunc) {
+	if !interactive {
+		return context.WithCancel(context.Background())
+	}
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
@@ -0,0 +1,217 @@
+// check.go
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultConcurrency = 8
+	// quickCheckTimeout bounds the reachability check run by add --check.
+	quickCheckTimeout = 5 * time.Second
+)
+
+// LinkCheck is the outcome of the last reachability check of a bookmark.
+type LinkCheck struct {
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// Dead reports whether the check found the link broken.
+func (c *LinkCheck) Dead() bool {
+	return c != nil && (c.Error != "" || c.Status >= 400)
+}
+
+// =============================================================================
+// == 🩺 LINK CHECKING & TITLE REFRESH
+// =============================================================================
+
+// isWebURL reports whether url is something the checker can fetch.
+func isWebURL(url string) bool {
+	lower := strings.ToLower(url)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+func (s *AppState) concurrency() int {
+	if s.Config.Concurrency > 0 {
+		return s.Config.Concurrency
+	}
+	return defaultConcurrency
+}
+
+// forEachWebBookmark runs fn on every http(s) bookmark with bounded
+// parallelism, under a progress bar labelled label, until ctx is cancelled.
+// fn gets a copy; results are applied by the caller.
+func (s *AppState) forEachWebBookmark(ctx context.Context, label string, fn func(i int, b Bookmark)) {
+	total := 0
+	for _, b := range s.Bookmarks {
+		if isWebURL(b.URL) {
+			total++
+		}
+	}
+	bar := newProgress(label, total)
+	defer bar.finish()
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.concurrency())
+	for i, b := range s.Bookmarks {
+		if !isWebURL(b.URL) {
+			continue
+		}
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(i int, b Bookmark) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i, b)
+			bar.step(displayURL(b.URL))
+		}(i, b)
+	}
+	wg.Wait()
+}
+
+// checkURL asks for the headers first and falls back to a GET, since plenty
+// of servers answer HEAD with 403 or 405 while serving the page fine. If the
+// page is in the metadata cache the HEAD is conditional, and a 304 counts as
+// the cached status. It returns nil if ctx is cancelled first.
+func checkURL(ctx context.Context, client *http.Client, cache *metaCache, url string) *LinkCheck {
+	check := &LinkCheck{CheckedAt: time.Now()}
+	var resp *http.Response
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err == nil {
+		cached, ok := cache.get(url)
+		if ok && cached.Status < 400 && cached.hasValidators() {
+			cached.setValidators(req)
+		}
+		resp, err = client.Do(req)
+		if err == nil && resp.StatusCode == http.StatusNotModified {
+			resp.Body.Close()
+			check.Status = cached.Status
+			return check
+		}
+	}
+	if err == nil && resp.StatusCode >= 400 {
+		resp.Body.Close()
+		if req, err = http.NewRequestWithContext(ctx, http.MethodGet, url, nil); err == nil {
+			resp, err = client.Do(req)
+		}
+	}
+	if ctx.Err() != nil {
+		return nil // cancelled, not dead
+	}
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	resp.Body.Close()
+	check.Status = resp.StatusCode
+	check.ContentType = resp.Header.Get("Content-Type")
+	return check
+}
+
+// quickCheck checks a single URL or local file right away, for add.
+func quickCheck(url string) *LinkCheck {
+	if path, ok := localPath(url); ok {
+		return checkLocal(path)
+	}
+	if !isWebURL(url) {
+		return nil
+	}
+	client := newHTTPClient()
+	client.Timeout = quickCheckTimeout
+	return checkURL(context.Background(), client, loadMetaCache(), url)
+}
+
+// describe returns a short reason for a dead check: the error or the status.
+func (c *LinkCheck) describe() string {
+	if c.Error != "" {
+		return c.Error
+	}
+	return strconv.Itoa(c.Status)
+}
+
+// checkLinks checks every web bookmark and local file, and returns the ones that went from
+// working (or unchecked) to dead in this run. Cancelling ctx stops the
+// check, keeping the links checked so far.
+func (s *AppState) checkLinks(ctx context.Context) (checked int, dead []Bookmark, newlyDead []Bookmark) {
+	defer func(start time.Time) { recordCheck(time.Since(start)) }(time.Now())
+	clientFor, cache := s.clientChooser(), loadMetaCache()
+	results := make([]*LinkCheck, len(s.Bookmarks))
+	s.forEachWebBookmark(ctx, "Checking links", func(i int, b Bookmark) {
+		results[i] = checkURL(ctx, clientFor(b), cache, b.URL)
+	})
+	for i, b := range s.Bookmarks {
+		if path, ok := localPath(b.URL); ok {
+			results[i] = checkLocal(path)
+		}
+	}
+	var updated []int
+	for i, check := range results {
+		if check == nil {
+			continue
+		}
+		checked++
+		updated = append(updated, i)
+		wasDead := s.Bookmarks[i].Check.Dead()
+		s.Bookmarks[i].Check = check
+		if t := classify(s.Bookmarks[i].URL, check.ContentType); t != "" {
+			s.Bookmarks[i].Type = t
+		}
+		if check.Dead() {
+			dead = append(dead, s.Bookmarks[i])
+			if !wasDead {
+				newlyDead = append(newlyDead, s.Bookmarks[i])
+			}
+		}
+	}
+	s.journalBookmarks(updated...)
+	return checked, dead, newlyDead
+}
+
+// refreshTitles fetches page titles for bookmarks that have none (no name, or
+// the URL as name), or for every bookmark when all is set. It returns how many
+// names changed. Cancelling ctx stops it, keeping the titles fetched so far.
+func (s *AppState) refreshTitles(ctx context.Context, all bool) int {
+	clientFor, cache := s.clientChooser(), loadMetaCache()
+	metas := make([]*pageMeta, len(s.Bookmarks))
+	s.forEachWebBookmark(ctx, "Fetching titles", func(i int, b Bookmark) {
+		if !all && b.Name != "" && b.Name != b.URL {
+			return
+		}
+		if meta, err := fetchMeta(ctx, clientFor(b), cache, b.URL); err == nil {
+			metas[i] = &meta
+		}
+	})
+	if err := cache.save(); err != nil {
+		logger.Warn("could not save", "file", metaCacheFile, "err", err)
+	}
+	changed := 0
+	var updated []int
+	for i, meta := range metas {
+		if meta == nil {
+			continue
+		}
+		if meta.Lang != "" && meta.Lang != s.Bookmarks[i].Lang {
+			s.Bookmarks[i].Lang = meta.Lang
+			updated = append(updated, i)
+		}
+		if meta.Title != "" && meta.Title != s.Bookmarks[i].Name {
+			s.editBookmark(i, func(b *Bookmark) { b.Name = meta.Title })
+			changed++
+		}
+	}
+	s.journalBookmarks(updated...)
+	return changed
+}
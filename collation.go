@@ -0,0 +1,97 @@
+// collation.go
+package main
+
+import (
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Config.NameSort values.
+const (
+	nameSortNatural = "natural"
+	nameSortLexical = "lexical"
+)
+
+// =============================================================================
+// == 🔤 COLLATION
+// =============================================================================
+//
+// Names sort by the Unicode collation rules of a locale rather than by code
+// point: "Électronique" comes with the other E names instead of after Z, and
+// each language gets its own conventions (Swedish puts Ö after Z, German
+// next to O). Case is ignored, as it always was, and numbers compare by
+// value. Queries, for their part, ignore accents: "electronique" finds
+// "Électronique".
+
+// sortLocale returns the locale names sort in: Config.Locale, else the one
+// of the environment (LC_ALL, LC_COLLATE, LANG), else the root order, which
+// suits most languages.
+func sortLocale() language.Tag {
+	if netConfig.Locale != "" {
+		if tag, err := language.Parse(netConfig.Locale); err == nil {
+			return tag
+		}
+	}
+	for _, name := range []string{"LC_ALL", "LC_COLLATE", "LANG"} {
+		if tag, ok := posixLocale(os.Getenv(name)); ok {
+			return tag
+		}
+	}
+	return language.Und
+}
+
+// posixLocale reads a locale as the environment gives it, "fr_FR.UTF-8" or
+// "de_DE@euro"; C and POSIX have no language.
+func posixLocale(value string) (language.Tag, bool) {
+	value, _, _ = strings.Cut(value, ".")
+	value, _, _ = strings.Cut(value, "@")
+	if value == "" || value == "C" || value == "POSIX" {
+		return language.Und, false
+	}
+	tag, err := language.Parse(strings.ReplaceAll(value, "_", "-"))
+	return tag, err == nil
+}
+
+// nameSortKey returns a function giving the sort key of a name: its
+// collation key, natural so that "Chapter 2" comes before "Chapter 10", or
+// with Config.NameSort lexical the lowercased name itself, compared byte by
+// byte as names were before collation. The function holds a collator, which
+// is not safe for concurrent use: each sort makes its own.
+func nameSortKey() func(name string) []byte {
+	if netConfig.NameSort == nameSortLexical {
+		return func(name string) []byte { return []byte(strings.ToLower(name)) }
+	}
+	collator := collate.New(sortLocale(), collate.IgnoreCase, collate.Numeric)
+	var buf collate.Buffer
+	return func(name string) []byte { return collator.KeyFromString(&buf, name) }
+}
+
+// foldAccents drops the diacritics of s: "Électronique" becomes
+// "Electronique", "naïve" "naive".
+func foldAccents(s string) string {
+	if isASCII(s) {
+		return s
+	}
+	// A transformer keeps state: each call makes its own.
+	folded, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), s)
+	if err != nil {
+		return s
+	}
+	return folded
+}
+
+// matchForm returns s as queries compare it: without accents, unless
+// Config.MatchAccents.
+func matchForm(s string) string {
+	if netConfig.MatchAccents {
+		return s
+	}
+	return foldAccents(s)
+}
@@ -0,0 +1,266 @@
+// collection.go
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// Collection is an ordered list of bookmarks, curated by hand.
+type Collection struct {
+	// Items are bookmark UUIDs, in the collection's order.
+	Items []string `json:"items"`
+	// Next is the position coll open --next opens.
+	Next int `json:"next,omitempty"`
+	// ChangedAt is when the collection last changed, for merges.
+	ChangedAt time.Time `json:"changed_at"`
+	// Deleted marks a deleted collection, kept (without items) so that
+	// merges do not bring it back from a copy that still has it.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// =============================================================================
+// == 📚 COLLECTIONS
+// =============================================================================
+//
+// Collections are playlists of bookmarks: unlike tags they have an order, set
+// by hand, for courses, tutorials and reading sequences. coll open opens the
+// whole list in order, or with --next one bookmark at a time (see next.go).
+// Items are UUIDs, so a deleted bookmark drops out of every
+// collection without leaving a gap.
+
+// collCommand runs the coll subcommands.
+func (s *AppState) collCommand(args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		names := slices.DeleteFunc(slices.Sorted(maps.Keys(s.Collections)), func(name string) bool {
+			return s.Collections[name].Deleted
+		})
+		if len(names) == 0 {
+			fmt.Println("No collections. Create one with: coll create <name>")
+		}
+		for _, name := range names {
+			fmt.Printf("%s (%d)\n", name, len(s.collectionItems(name)))
+		}
+		return nil
+	}
+	switch args[0] {
+	case "create":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: coll create <name>")
+		}
+		if c, ok := s.Collections[args[1]]; ok && !c.Deleted {
+			return fmt.Errorf("there is already a collection named '%s'", args[1])
+		}
+		s.setCollection(args[1], Collection{})
+		fmt.Printf("Collection '%s' created. Add bookmarks with: coll add %s <id>...\n", args[1], args[1])
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: coll delete <name>")
+		}
+		if _, err := s.collection(args[1]); err != nil {
+			return err
+		}
+		s.setCollection(args[1], Collection{Deleted: true})
+		fmt.Printf("Collection '%s' deleted; its bookmarks are kept.\n", args[1])
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: coll add <name> <id>...")
+		}
+		c, err := s.collection(args[1])
+		if err != nil {
+			return err
+		}
+		c.Items = slices.Clone(c.Items)
+		for _, ref := range args[2:] {
+			i, ok := s.findBookmark(ref)
+			if !ok {
+				continue
+			}
+			b := s.Bookmarks[i]
+			if slices.Contains(c.Items, b.UUID) {
+				fmt.Printf("'%s' is already in %s.\n", b.Name, args[1])
+				continue
+			}
+			c.Items = append(c.Items, b.UUID)
+			fmt.Printf("Added '%s' to %s as #%d.\n", b.Name, args[1], len(c.Items))
+		}
+		s.setCollection(args[1], c)
+	case "rm":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: coll rm <name> <id>")
+		}
+		c, pos, err := s.collectionPosition(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		c.Items = slices.Delete(slices.Clone(c.Items), pos, pos+1)
+		if c.Next > pos {
+			c.Next--
+		}
+		s.setCollection(args[1], c)
+		fmt.Printf("Removed [%s] from %s.\n", args[2], args[1])
+	case "move":
+		if len(args) != 4 {
+			return fmt.Errorf("usage: coll move <name> <id> <position>")
+		}
+		c, pos, err := s.collectionPosition(args[1], args[2])
+		if err != nil {
+			return err
+		}
+		to, err := strconv.Atoi(args[3])
+		if err != nil || to < 1 || to > len(c.Items) {
+			return fmt.Errorf("position must be between 1 and %d", len(c.Items))
+		}
+		uuid := c.Items[pos]
+		c.Items = slices.Insert(slices.Delete(slices.Clone(c.Items), pos, pos+1), to-1, uuid)
+		s.setCollection(args[1], c)
+		fmt.Printf("Moved [%s] to #%d in %s.\n", args[2], to, args[1])
+	case "show":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: coll show <name>")
+		}
+		return s.showCollection(args[1])
+	case "open":
+		next := slices.Contains(args, "--next")
+		tor := slices.Contains(args, "--tor")
+		args = slices.DeleteFunc(args, func(a string) bool { return a == "--next" || a == "--tor" })
+		if len(args) != 2 {
+			return fmt.Errorf("usage: coll open [--next] [--tor] <name>")
+		}
+		if next {
+			return s.openCollectionNext(args[1], tor)
+		}
+		return s.openCollection(args[1], tor)
+	default:
+		return s.showCollection(args[0])
+	}
+	return nil
+}
+
+// collection returns the collection named name, without the bookmarks
+// deleted since it was saved.
+func (s *AppState) collection(name string) (Collection, error) {
+	c, ok := s.Collections[name]
+	if !ok || c.Deleted {
+		return Collection{}, fmt.Errorf("no collection named '%s'; coll list shows them", name)
+	}
+	items, next := make([]string, 0, len(c.Items)), c.Next
+	for n, uuid := range c.Items {
+		if s.indexOfUUID(uuid) >= 0 {
+			items = append(items, uuid)
+		} else if n < c.Next {
+			next--
+		}
+	}
+	c.Items, c.Next = items, next
+	return c, nil
+}
+
+// collectionPosition returns the collection named name and where the
+// bookmark ref is in it.
+func (s *AppState) collectionPosition(name, ref string) (Collection, int, error) {
+	c, err := s.collection(name)
+	if err != nil {
+		return c, 0, err
+	}
+	i, err := s.lookupBookmark(ref)
+	if err != nil {
+		return c, 0, fmt.Errorf("no bookmark %s", ref)
+	}
+	pos := slices.Index(c.Items, s.Bookmarks[i].UUID)
+	if pos < 0 {
+		return c, 0, fmt.Errorf("'%s' is not in %s", s.Bookmarks[i].Name, name)
+	}
+	return c, pos, nil
+}
+
+// setCollection saves c under name and journals it.
+func (s *AppState) setCollection(name string, c Collection) {
+	c.ChangedAt = time.Now()
+	if s.Collections == nil {
+		s.Collections = map[string]Collection{}
+	}
+	s.Collections[name] = c
+	writeJournal(journalEntry{Op: journalCollectionOp, Name: name, Collection: &c})
+}
+
+// collectionItems returns the indexes of the bookmarks in a collection, in
+// order.
+func (s *AppState) collectionItems(name string) []int {
+	c, _ := s.collection(name)
+	items := make([]int, len(c.Items))
+	for n, uuid := range c.Items {
+		items[n] = s.indexOfUUID(uuid)
+	}
+	return items
+}
+
+// showCollection lists a collection in order, marking where --next is.
+func (s *AppState) showCollection(name string) error {
+	c, err := s.collection(name)
+	if err != nil {
+		return err
+	}
+	if len(c.Items) == 0 {
+		fmt.Printf("%s is empty. Add bookmarks with: coll add %s <id>...\n", name, name)
+		return nil
+	}
+	for n, uuid := range c.Items {
+		marker := "  "
+		if n == c.Next && c.Next > 0 {
+			marker = "> "
+		}
+		fmt.Printf("%s%2d. ", marker, n+1)
+		printTreeLeaf(s.Bookmarks[s.indexOfUUID(uuid)], "")
+	}
+	return nil
+}
+
+// openCollection opens every bookmark of a collection, in order.
+func (s *AppState) openCollection(name string, tor bool) error {
+	if _, err := s.collection(name); err != nil {
+		return err
+	}
+	items := s.collectionItems(name)
+	if len(items) == 0 {
+		return fmt.Errorf("%s is empty", name)
+	}
+	fmt.Printf("Opening the %d bookmarks of %s...\n", len(items), name)
+	for _, i := range items {
+		if err := s.openAt(i, tor); err != nil {
+			return fmt.Errorf("could not open '%s': %w", s.Bookmarks[i].Name, err)
+		}
+	}
+	return nil
+}
+
+// mergeCollections takes from other the collections s lacks or changed
+// there last, deletions included, and returns their names. A deleted
+// collection stays deleted unless the other side changed it since; like
+// bookmark tombstones, deletions are forgotten after tombstoneTTL.
+func (s *AppState) mergeCollections(other *AppState, now time.Time) []string {
+	for name, c := range s.Collections {
+		if c.Deleted && now.Sub(c.ChangedAt) > tombstoneTTL {
+			delete(s.Collections, name)
+		}
+	}
+	var changed []string
+	for _, name := range slices.Sorted(maps.Keys(other.Collections)) {
+		oc := other.Collections[name]
+		if c, ok := s.Collections[name]; ok && !oc.ChangedAt.After(c.ChangedAt) {
+			continue
+		}
+		if _, ok := s.Collections[name]; !ok && oc.Deleted && now.Sub(oc.ChangedAt) > tombstoneTTL {
+			continue
+		}
+		if s.Collections == nil {
+			s.Collections = map[string]Collection{}
+		}
+		s.Collections[name] = oc
+		changed = append(changed, name)
+	}
+	return changed
+}
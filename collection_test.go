@@ -0,0 +1,48 @@
+// collection_test.go
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestMergeCollections(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	ours := &AppState{Collections: map[string]Collection{
+		"course":  {Deleted: true, ChangedAt: now.Add(-time.Hour)},
+		"recipes": {Items: []string{"a"}, ChangedAt: now.Add(-time.Hour)},
+		"ancient": {Deleted: true, ChangedAt: now.Add(-tombstoneTTL - time.Hour)},
+	}}
+	theirs := &AppState{Collections: map[string]Collection{
+		// Deleted here since it last changed there: stays deleted.
+		"course": {Items: []string{"a", "b"}, ChangedAt: now.Add(-2 * time.Hour)},
+		// Deleted there after it last changed here.
+		"recipes": {Deleted: true, ChangedAt: now.Add(-time.Minute)},
+		"reading": {Items: []string{"c"}, ChangedAt: now.Add(-time.Minute)},
+	}}
+	changed := ours.mergeCollections(theirs, now)
+	if want := []string{"reading", "recipes"}; !slices.Equal(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if !ours.Collections["course"].Deleted {
+		t.Error("course came back from the copy that still had it")
+	}
+	if !ours.Collections["recipes"].Deleted {
+		t.Error("recipes was not deleted")
+	}
+	if _, err := ours.collection("recipes"); err == nil {
+		t.Error("a deleted collection is still found")
+	}
+	if _, ok := ours.Collections["ancient"]; ok {
+		t.Error("a deletion older than tombstoneTTL was kept")
+	}
+
+	// Merging the other way round agrees.
+	theirs.mergeCollections(ours, now)
+	for _, name := range []string{"course", "recipes"} {
+		if !theirs.Collections[name].Deleted {
+			t.Errorf("%s is not deleted in the other copy", name)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// compress.go
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// gzipExt marks compressed snapshots. The data file keeps its name either way.
+const gzipExt = ".gz"
+
+// =============================================================================
+// == 🗜️ COMPRESSION
+// =============================================================================
+//
+// With config.compress on, bookmarks.json and new snapshots are gzipped.
+// Reading never depends on the setting: gzip data is recognized by its first
+// bytes, so turning compression on or off never makes old files unreadable.
+
+// isGzip reports whether data starts with the gzip magic number.
+func isGzip(data []byte) bool {
+	return len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b
+}
+
+// gunzipIfNeeded returns data decompressed if it is gzip, unchanged if not.
+func gunzipIfNeeded(data []byte) ([]byte, error) {
+	if !isGzip(data) {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readSnapshot returns the HTML of a snapshot, compressed or not.
+func readSnapshot(path string) ([]byte, error) {
+	data, err := os.ReadFile(dataPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return gunzipIfNeeded(data)
+}
+
+// setCompression turns compression on or off and rewrites the existing
+// snapshots to match, so the space is won (or the files made plain) now
+// rather than as pages are archived again. It returns how many it rewrote.
+func (s *AppState) setCompression(on bool) (int, error) {
+	s.Config.Compress = on
+	s.saveConfig()
+	converted := 0
+	for i, b := range s.Bookmarks {
+		if b.Archive == nil || b.Archive.Snapshot == "" || strings.HasSuffix(b.Archive.Snapshot, gzipExt) == on {
+			continue
+		}
+		path, err := convertSnapshot(b.Archive.Snapshot, on)
+		if err != nil {
+			return converted, fmt.Errorf("snapshot of '%s': %w", b.Name, err)
+		}
+		archive := *b.Archive
+		archive.Snapshot = path
+		s.Bookmarks[i].Archive = &archive
+		s.journalBookmarks(i)
+		converted++
+	}
+	return converted, nil
+}
+
+// convertSnapshot compresses or decompresses the snapshot at path into a
+// file named for it, removes the original and returns the new path.
+func convertSnapshot(path string, compress bool) (string, error) {
+	data, err := readSnapshot(path)
+	if err != nil {
+		return "", err
+	}
+	dest := strings.TrimSuffix(path, gzipExt)
+	if compress {
+		dest += gzipExt
+		if data, err = gzipBytes(data); err != nil {
+			return "", err
+		}
+	}
+	if err := os.WriteFile(dataPath(dest), data, 0644); err != nil {
+		return "", err
+	}
+	return dest, os.Remove(dataPath(path))
+}
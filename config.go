@@ -0,0 +1,737 @@
+// config.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/language"
+)
+
+// secretConfigKeys are not shown by config list and config get; "list[].key"
+// hides that key in each item of a list.
+var secretConfigKeys = []string{"server.token", "sync.token", "smtp.password", "backup.secret_access_key", "pinboard_token", "webhooks[].secret"}
+
+// =============================================================================
+// == ⚙️ CONFIG COMMAND
+// =============================================================================
+//
+// config get/set/unset/list edit the config file by key:
+// the JSON names of the fields, dotted into sections ("server.addr",
+// "log.level") and into maps ("jobs.check", "handlers.magnet"). Values are
+// read by the type of their key (text, number, true/false, or JSON for lists
+// and sections), and the config as a whole is checked before it changes.
+
+// runConfig runs `config list|get <key>|set <key> <value>|unset <key>`.
+func (s *AppState) runConfig(args []string) error {
+	usage := errors.New("usage: config list | config get <key> | config set <key> <value> | config unset <key>")
+	if len(args) == 0 {
+		return usage
+	}
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		s.listConfig()
+		return nil
+	case args[0] == "get" && len(args) == 2:
+		if _, err := configKeyType(args[1]); err != nil {
+			return err
+		}
+		v, _ := lookupConfig(configMap(s.Config), args[1])
+		fmt.Println(configText(args[1], v))
+		return nil
+	case args[0] == "set" && len(args) >= 3:
+		value, err := parseConfigValue(args[1], strings.Join(args[2:], " "))
+		if err != nil {
+			return err
+		}
+		if err := s.changeConfig(args[1], value); err != nil {
+			return err
+		}
+		fmt.Printf("%s set to %s.\n", args[1], configText(args[1], value))
+		return nil
+	case args[0] == "unset" && len(args) == 2:
+		if err := s.changeConfig(args[1], nil); err != nil {
+			return err
+		}
+		fmt.Printf("%s unset.\n", args[1])
+		return nil
+	}
+	return usage
+}
+
+// configKeyType returns the Go type of the config value at key.
+func configKeyType(key string) (reflect.Type, error) {
+	// profiles.<name>.<key> takes what <key> takes.
+	if rest, ok := strings.CutPrefix(key, "profiles."); ok {
+		if _, sub, ok := strings.Cut(rest, "."); ok {
+			if sub == "profiles" || strings.HasPrefix(sub, "profiles.") {
+				return nil, errors.New("profiles cannot have profiles")
+			}
+			if t, err := configKeyType(sub); err == nil {
+				return t, nil
+			}
+			return nil, fmt.Errorf("unknown config key %q; config list shows them", key)
+		}
+	}
+	t := reflect.TypeOf(Config{})
+	for _, part := range strings.Split(key, ".") {
+		switch t.Kind() {
+		case reflect.Struct:
+			field, ok := configField(t, part)
+			if !ok {
+				return nil, fmt.Errorf("unknown config key %q; config list shows them", key)
+			}
+			t = field.Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("unknown config key %q; config list shows them", key)
+		}
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+	}
+	return t, nil
+}
+
+// configField finds the field of struct type t with the JSON name name.
+func configField(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if tag, _, _ := strings.Cut(f.Tag.Get("json"), ","); f.IsExported() && tag == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// parseConfigValue reads text as a value for key.
+func parseConfigValue(key, text string) (any, error) {
+	t, err := configKeyType(key)
+	if err != nil {
+		return nil, err
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return text, nil
+	case reflect.Bool:
+		switch strings.ToLower(text) {
+		case "true", "yes", "on", "1":
+			return true, nil
+		case "false", "no", "off", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%s takes true or false", key)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s takes a whole number", key)
+		}
+		return n, nil
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s takes a number", key)
+		}
+		return f, nil
+	}
+	// Lists, maps and sections are given as JSON.
+	var v any
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		return nil, fmt.Errorf("%s takes JSON (%s): %w", key, t.Kind(), err)
+	}
+	return v, nil
+}
+
+// changeConfig sets key to value, or removes it if value is nil, if the
+// config that results is valid. The rest comes from the file as it is now,
+// so what others changed there since this session read it is kept.
+func (s *AppState) changeConfig(key string, value any) error {
+	if _, err := configKeyType(key); err != nil {
+		return err
+	}
+	file, found, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	m := configMap(file)
+	if !found {
+		m = fileConfigMap(s.Config)
+	}
+	setConfigValue(m, key, value)
+	base, err := decodeConfig(m)
+	if err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	if err := base.validate(); err != nil {
+		return err
+	}
+	c, overrides, err := effectiveConfig(base)
+	if err != nil {
+		return err
+	}
+	s.Config = c
+	configOverrides = overrides
+	netConfig = c
+	setupLogging(c.Log, false)
+	s.saveConfig()
+	if !loadIndex().current() {
+		if err := s.reindex(); err != nil {
+			logger.Warn("could not rebuild", "file", indexFile, "err", err)
+		}
+	}
+	s.writeAudit(AuditEntry{Op: "config", Detail: fmt.Sprintf("%s = %s", key, configText(key, value))})
+	for overridden, o := range configOverrides {
+		if overridden == key || strings.HasPrefix(overridden, key+".") {
+			logger.Warn(overridden + " is saved, but " + o.from + " sets it")
+		}
+	}
+	return nil
+}
+
+// configMap returns the config as generic JSON values.
+func configMap(c Config) map[string]any {
+	var m map[string]any
+	json.Unmarshal(jsonBytes(c), &m)
+	return m
+}
+
+// setConfigValue sets key to value in m, or removes it if value is nil.
+func setConfigValue(m map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[part] = next
+		}
+		m = next
+	}
+	if last := parts[len(parts)-1]; value == nil {
+		delete(m, last)
+	} else {
+		m[last] = value
+	}
+}
+
+// lookupConfig returns the value at key in m.
+func lookupConfig(m map[string]any, key string) (any, bool) {
+	var v any = m
+	for _, part := range strings.Split(key, ".") {
+		section, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = section[part]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// configText shows a value: text as is, the rest as JSON, secrets hidden.
+func configText(key string, v any) string {
+	switch {
+	case v == nil:
+		return "(not set)"
+	case slices.Contains(secretConfigKeys, key):
+		return "(hidden)"
+	}
+	if text, ok := v.(string); ok {
+		return text
+	}
+	return string(jsonBytes(hideItemSecrets(key, v)))
+}
+
+// hideItemSecrets returns a copy of the list v, the value of key, with the
+// "key[].field" secrets of its items hidden.
+func hideItemSecrets(key string, v any) any {
+	items, ok := v.([]any)
+	if !ok {
+		return v
+	}
+	for _, secret := range secretConfigKeys {
+		list, field, ok := strings.Cut(secret, "[].")
+		if !ok || list != key {
+			continue
+		}
+		hidden := make([]any, len(items))
+		for i, item := range items {
+			hidden[i] = item
+			if m, ok := item.(map[string]any); ok && m[field] != nil {
+				m = maps.Clone(m)
+				m[field] = "(hidden)"
+				hidden[i] = m
+			}
+		}
+		items = hidden
+	}
+	return items
+}
+
+// configKeys returns every key of the config, set or not: sections key by
+// key, maps and lists whole.
+func configKeys() []string {
+	var keys []string
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeOf(time.Time{}) {
+				walk(f.Type, prefix+name+".")
+				continue
+			}
+			keys = append(keys, prefix+name)
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "")
+	return keys
+}
+
+// listConfig prints every key of the config and its value, and the profile
+// or environment variable that set it, if one did.
+func (s *AppState) listConfig() {
+	m := configMap(s.Config)
+	for _, key := range configKeys() {
+		v, _ := lookupConfig(m, key)
+		fmt.Printf("%s%s%s = %s", style(Cyan), key, style(Reset), configText(key, v))
+		if o, ok := configOverrides[key]; ok {
+			fmt.Printf(" %s(from %s)%s", style(Gray), o.from, style(Reset))
+		}
+		fmt.Println()
+	}
+}
+
+// validate checks the settings that take one of a few values or a given
+// form.
+func (c Config) validate() error {
+	oneOf := func(key, v string, allowed ...string) error {
+		if v != "" && !slices.Contains(allowed, v) {
+			return fmt.Errorf("%s takes %s", key, strings.Join(allowed, ", "))
+		}
+		return nil
+	}
+	checks := []error{
+		oneOf("hyperlinks", c.Hyperlinks, hyperlinksAuto, hyperlinksAlways, hyperlinksNever),
+		oneOf("safe_save", c.SafeSave, safeSaveAuto, safeSaveAlways, safeSaveNever),
+		oneOf("name_sort", c.NameSort, nameSortNatural, nameSortLexical),
+		oneOf("search_case", c.SearchCase, caseIgnore, caseSmart, caseSensitive),
+		oneOf("import_conflicts", c.ImportConflicts, conflictAsk, conflictKeepMine, conflictTakeTheirs, conflictMerge),
+		oneOf("mirror_action", c.MirrorAction, mirrorKeep, mirrorTrash, mirrorShelve),
+		oneOf("log.level", strings.ToLower(c.Log.Level), "debug", "info", "warn", "error"),
+		oneOf("log.format", c.Log.Format, "text", "json"),
+	}
+	for name, spec := range c.Jobs {
+		if _, ok := daemonJobs[name]; !ok {
+			checks = append(checks, fmt.Errorf("unknown job %q", name))
+		} else if _, err := parseSchedule(spec); err != nil {
+			checks = append(checks, fmt.Errorf("jobs.%s: %w", name, err))
+		}
+	}
+	if c.Proxy != "" && c.Proxy != "direct" {
+		if u, err := url.Parse(c.Proxy); err != nil || u.Scheme == "" || u.Host == "" {
+			checks = append(checks, fmt.Errorf("proxy takes direct or a URL such as http://proxy:3128"))
+		}
+	}
+	if _, err := language.Parse(c.Locale); c.Locale != "" && err != nil {
+		checks = append(checks, fmt.Errorf("locale takes a language tag such as fr or pt-BR"))
+	}
+	if c.Concurrency < 0 {
+		checks = append(checks, errors.New("concurrency cannot be negative"))
+	}
+	for key, addr := range map[string]string{"server.addr": c.Server.Addr, "server.metrics_addr": c.Server.MetricsAddr} {
+		if _, _, err := net.SplitHostPort(addr); addr != "" && err != nil {
+			checks = append(checks, fmt.Errorf("%s takes host:port or :port", key))
+		}
+	}
+	if (c.Server.CertFile == "") != (c.Server.KeyFile == "") {
+		checks = append(checks, errors.New("server.cert_file and server.key_file go together"))
+	}
+	if c.Sync.Remote != "" {
+		if u, err := url.Parse(c.Sync.Remote); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			checks = append(checks, errors.New("sync.remote takes an http:// or https:// URL"))
+		}
+	}
+	if c.Backup.Remote != "" {
+		if _, _, err := parseS3URL(c.Backup.Remote); err != nil {
+			checks = append(checks, fmt.Errorf("backup.remote: %w", err))
+		}
+	}
+	if c.SMTP.Port < 0 || c.SMTP.Port > 65535 {
+		checks = append(checks, errors.New("smtp.port takes a port number"))
+	}
+	for name, profile := range c.Profiles {
+		if _, ok := profile["profiles"]; ok {
+			checks = append(checks, fmt.Errorf("profile %s: profiles cannot have profiles", name))
+		} else if _, err := decodeConfig(profile); err != nil {
+			checks = append(checks, fmt.Errorf("profile %s: %w", name, err))
+		} else if _, _, err := c.withProfile(name); err != nil {
+			checks = append(checks, err)
+		}
+	}
+	return errors.Join(checks...)
+}
+
+// configHeader starts a generated config file.
+const configHeader = `# bibliothermes settings. Edit this file, or use config set, which checks
+# the change first. Settings left at their default are commented out.
+# bibliothermes rewrites this file when a setting changes: comments of your
+# own are not kept.
+`
+
+// configDocs is the comment written above each setting in the config file.
+var configDocs = map[string]string{
+	"data_dir":            "Where bookmarks.json and the files next to it are; unset, the current\ndirectory.",
+	"default_browser_cmd": "The command that opens links: system for the OS default browser (flags\nmay follow, e.g. \"system --new-window\"), or a command such as firefox.",
+	"terminal_cmd":        "Opens ssh:// bookmarks in a new window, e.g. \"alacritty -e\"; unset, ssh\nruns in the current terminal.",
+	"handlers":            "How to open each URL scheme, e.g. magnet: \"transmission-remote -a {url}\".",
+	"tag_apps":            "The application that opens bookmarks with a tag, e.g. work: Firefox.",
+	"tag_icons":           "The icon list shows for bookmarks with a tag, e.g. papers: 📄.",
+	"hyperlinks":          "auto (the default), always or never: whether to print links as\nclickable terminal hyperlinks.",
+	"plain":               "true always runs as with --plain: no colors, hyperlinks or symbols.",
+	"search_case":         "How search, list, count and live search match case: ignore (the default),\nsmart (ignore unless a word has capitals) or sensitive; search -i and -s\nchoose each time. Other commands taking a query always ignore case.",
+	"match_accents":       "true makes queries tell accented letters apart: electronique no longer\nfinds Électronique.",
+	"locale":              "How names sort, e.g. fr, de or sv; unset, the locale of the environment.",
+	"import_conflicts":    "What an import does with a URL already here under another title or tags:\nask (the default), keep-mine, take-theirs or merge.",
+	"mirror_imports":      "Browsers, or profiles such as \"Firefox (work)\", whose deleted bookmarks\nimport offers to trash or shelve here too.",
+	"mirror_action":       "What imports away from a terminal, as in the daemon, do with bookmarks\ndeleted in mirrored browsers: keep (the default, only reporting them), trash or shelve.",
+	"pinboard_token":      "The Pinboard API token (user:HEX) push pinboard uses; PINBOARD_TOKEN wins.",
+	"name_sort":           "natural (the default: Chapter 2 before Chapter 10) or lexical (byte order, as before).",
+	"safe_save":           "auto (the default: careful saves in cloud-synced folders), always or never.",
+	"check_on_add":        "true makes every add check the URL, as add --check does.",
+	"hooks":               "Shell commands run on add, delete, edit, open, save and import, e.g.\nadd: [\"notify-send added\"].",
+	"webhooks":            "HTTP endpoints that get a POST for each event: url, secret, events.",
+	"notifications":       "true shows desktop notifications for background work.",
+	"jobs":                "What the daemon runs and when, e.g. check: \"0 3 * * *\", import: \"@every 6h\".\nJobs: check, refresh-titles, import, remind, expire, stale, sync, publish,\narchive, backup.",
+	"archive":             "What the archive job does: wayback, snapshot, tags, max_age_days.",
+	"frecency":            "Ranking of suggest and list --score: half_life_days (14),\nfavorite_weight (1), recent_add_boost (1), recent_add_days (7).",
+	"proxy":               "Routes every request, e.g. http://proxy:3128 or socks5://127.0.0.1:1080;\ndirect ignores HTTPS_PROXY and the like.",
+	"tor":                 "Bookmarks with one of tor.tags are fetched through tor.proxy\n(socks5h://127.0.0.1:9050) and opened with tor.browser_cmd.",
+	"user_agent":          "The User-Agent sent with every request.",
+	"headers":             "More headers sent with every request, e.g. Accept-Language: \"fr, en\".",
+	"auth":                "Cookies and headers that log in to a domain, for pages behind a login.",
+	"concurrency":         "How many network requests run at once (8).",
+	"plugin_dir":          "Searched for bibliothermes-<name> plugins before PATH.",
+	"enrich_on_import":    "true fetches descriptions and images of imported bookmarks.",
+	"smart_folders":       "Saved queries by name.",
+	"compress":            "true gzips bookmarks.json and snapshots (compress on|off).",
+	"stale":               "Which bookmarks stale shelves: after_days (180), max_opens.",
+	"smtp":                "The mail server share --email sends through: host, port (587),\nusername, password, from.",
+	"publish":             "The gists and branches publish keeps up to date.",
+	"backup":              "Where backups are uploaded: remote (s3://bucket/path), endpoint, region,\naccess_key_id, secret_access_key; keep and keep_days prune old ones.",
+	"log":                 "level: debug, info (the default), warn or error. format (text or json)\nand file apply to daemon and serve.",
+	"server":              "How serve listens: addr (127.0.0.1:8421), token, lan, peer_id, metrics_addr, cert_file and key_file for HTTPS.",
+	"sync":                "The server sync talks to: remote and token.",
+	"profiles":            "Named sets of settings that replace the ones above under --profile <name>,\ne.g. work: {data_dir: ~/work-bookmarks, default_browser_cmd: chromium}.",
+}
+
+// =============================================================================
+// == ⚙️ CONFIG FILE
+// =============================================================================
+//
+// Settings live in config.yaml in the platform config directory
+// (~/.config/bibliothermes on Linux), or wherever BIBLIOTHERMES_CONFIG
+// points, apart from the collection: bookmarks.json can then be synced or
+// shared without tokens and machine-specific commands. The file is written
+// whole, with a comment above each setting; older data files that still hold
+// a config section have it moved out the first time they are read.
+
+// configPath returns where the config file is.
+func configPath() string {
+	if path := os.Getenv("BIBLIOTHERMES_CONFIG"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "config.yaml"
+	}
+	return filepath.Join(dir, "bibliothermes", "config.yaml")
+}
+
+// defaultConfig is the config of a new installation.
+func defaultConfig() Config {
+	return Config{DefaultBrowserCmd: browserSystem}
+}
+
+// readConfigFile reads and checks the config file; found is false if there
+// is none.
+func readConfigFile() (c Config, found bool, err error) {
+	path := configPath()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Config{}, false, nil
+	} else if err != nil {
+		return Config{}, true, fmt.Errorf("could not read %s: %w", path, err)
+	}
+	var raw any
+	if err := unmarshalYAML(data, &raw); err != nil {
+		return Config{}, true, fmt.Errorf("%s: %w", path, err)
+	}
+	if raw == nil {
+		raw = map[string]any{}
+	}
+	if c, err = decodeConfig(raw); err == nil {
+		err = c.validate()
+	}
+	if err != nil {
+		return Config{}, true, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, true, nil
+}
+
+// decodeConfig turns generic JSON values into a Config, refusing unknown
+// keys.
+func decodeConfig(v any) (Config, error) {
+	var c Config
+	dec := json.NewDecoder(bytes.NewReader(jsonBytes(v)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		// The config is YAML to its reader: leave out where this comes from.
+		return c, errors.New(strings.TrimPrefix(err.Error(), "json: "))
+	}
+	return c, nil
+}
+
+// writeConfigFile writes c to the config file, every setting under its
+// comment, commented out if unset. Settings from a profile or the
+// environment keep the value they have in the file.
+func writeConfigFile(c Config) error {
+	m := fileConfigMap(c)
+	var buf bytes.Buffer
+	buf.WriteString(configHeader)
+	t := reflect.TypeOf(c)
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		buf.WriteString("\n")
+		for line := range strings.SplitSeq(configDocs[name], "\n") {
+			buf.WriteString("# " + line + "\n")
+		}
+		v, ok := m[name]
+		if !ok {
+			buf.WriteString("# " + name + ":\n")
+			continue
+		}
+		data, err := marshalYAML(map[string]any{name: v})
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	path := configPath()
+	// The file holds tokens and passwords: only its owner may read it.
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// saveConfig writes the config file after a setting changed. Unlike the
+// collection, which waits for save, it is written at once.
+func (s *AppState) saveConfig() {
+	if err := writeConfigFile(s.Config); err != nil {
+		logger.Warn("could not save", "file", configPath(), "err", err)
+	}
+}
+
+// loadConfig sets s.Config from the config file. Without one, it writes
+// legacy (the config section of an older data file) or the defaults to it.
+func (s *AppState) loadConfig(legacy *Config) error {
+	configOverrides = nil
+	c, found, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	switch {
+	case found:
+		s.Config = c
+	case legacy != nil:
+		s.Config = *legacy
+		if err := writeConfigFile(s.Config); err != nil {
+			return fmt.Errorf("could not move the config to %s: %w", configPath(), err)
+		}
+		fmt.Printf("Moved the config out of %s into %s.\n", bookmarksFile, configPath())
+	default:
+		s.Config = defaultConfig()
+		if err := writeConfigFile(s.Config); err != nil {
+			return fmt.Errorf("could not write %s: %w", configPath(), err)
+		}
+		fmt.Printf("Wrote the default config to %s.\n", configPath())
+	}
+	if s.Config, configOverrides, err = effectiveConfig(s.Config); err != nil {
+		return err
+	}
+	netConfig = s.Config
+	return nil
+}
+
+// configOverride is a setting given by the active profile or the
+// environment rather than the config file.
+type configOverride struct {
+	from string // "profile <name>" or the variable
+	base any    // the value in the file (nil if unset), which the file keeps
+}
+
+// activeProfile is the profile chosen with --profile or
+// BIBLIOTHERMES_PROFILE.
+var activeProfile string
+
+// configOverrides holds the settings of s.Config that the active profile or
+// the environment set.
+var configOverrides map[string]configOverride
+
+// dataDir is where the data files are, from data_dir; empty, the working
+// directory.
+var dataDir string
+
+// =============================================================================
+// == ⚙️ PROFILES AND ENVIRONMENT OVERRIDES
+// =============================================================================
+//
+// The config file can hold profiles: named sets of settings, under
+// profiles.<name>, that replace the ones of the file under --profile <name>
+// (or BIBLIOTHERMES_PROFILE) and inherit the rest. A profile with its own
+// data_dir is a separate collection with its own browser or sync server.
+//
+// Every key can also be set from the environment, which wins over both:
+// BIBLIOTHERMES_ and the key in capitals, dots as underscores
+// (BIBLIOTHERMES_SERVER_ADDR for server.addr). Values read as config set
+// reads them, JSON for maps and lists. Containers can then be configured
+// without a config file. What profiles and variables set is never written to
+// the file.
+
+// configEnvName returns the environment variable that sets key.
+func configEnvName(key string) string {
+	return "BIBLIOTHERMES_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// effectiveConfig returns c with the active profile and the environment
+// applied, and what they set.
+func effectiveConfig(c Config) (Config, map[string]configOverride, error) {
+	overrides := map[string]configOverride{}
+	var err error
+	if activeProfile != "" {
+		if _, ok := c.Profiles[activeProfile]; !ok {
+			return c, nil, fmt.Errorf("no profile %q in %s", activeProfile, configPath())
+		}
+		if c, overrides, err = c.withProfile(activeProfile); err != nil {
+			return c, nil, err
+		}
+	}
+	m := configMap(c)
+	for _, key := range configKeys() {
+		text, ok := os.LookupEnv(configEnvName(key))
+		if !ok {
+			continue
+		}
+		value, err := parseConfigValue(key, text)
+		if err != nil {
+			return c, nil, fmt.Errorf("%s: %w", configEnvName(key), err)
+		}
+		o, ok := overrides[key]
+		if !ok {
+			o.base, _ = lookupConfig(m, key)
+		}
+		o.from = configEnvName(key)
+		overrides[key] = o
+		setConfigValue(m, key, value)
+	}
+	if c, err = decodeConfig(m); err == nil {
+		err = c.validate()
+	}
+	if err != nil {
+		return c, nil, fmt.Errorf("the BIBLIOTHERMES_* variables: %w", err)
+	}
+	return c, overrides, nil
+}
+
+// withProfile returns c with the settings of profile name, and what it set.
+func (c Config) withProfile(name string) (Config, map[string]configOverride, error) {
+	profile := c.Profiles[name]
+	m := configMap(c)
+	overrides := map[string]configOverride{}
+	for _, key := range configKeys() {
+		v, ok := lookupConfig(profile, key)
+		if !ok || key == "profiles" {
+			continue
+		}
+		o := configOverride{from: "profile " + name}
+		o.base, _ = lookupConfig(m, key)
+		overrides[key] = o
+		setConfigValue(m, key, v)
+	}
+	delete(m, "profiles")
+	p, err := decodeConfig(m)
+	if err == nil {
+		err = p.validate()
+	}
+	if err != nil {
+		return c, nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	p.Profiles = c.Profiles
+	return p, overrides, nil
+}
+
+// fileConfigMap returns c as generic JSON values, as the config file has it:
+// without what the active profile and the environment set.
+func fileConfigMap(c Config) map[string]any {
+	m := configMap(c)
+	for key, o := range configOverrides {
+		setConfigValue(m, key, o.base)
+	}
+	return m
+}
+
+// setDataDir sets dataDir to the data_dir of the config, if set, before the
+// collection is read.
+func setDataDir() error {
+	c, _, err := readConfigFile()
+	if err != nil {
+		return err
+	}
+	if c, _, err = effectiveConfig(c); err != nil || c.DataDir == "" {
+		return err
+	}
+	dir := expandHome(c.DataDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("data_dir: %w", err)
+	}
+	dataDir = dir
+	return nil
+}
+
+// dataPath returns where the data file or directory name is: in dataDir,
+// unless name is absolute. Paths kept in the data, like snapshots/<uuid>.html,
+// stay relative to it, so the directory can move.
+func dataPath(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(dataDir, name)
+}
+
+// envFlag reads a true/false environment variable that stands in for a
+// command-line flag.
+func envFlag(name string) bool {
+	on, _ := strconv.ParseBool(os.Getenv(name))
+	return on
+}
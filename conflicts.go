@@ -0,0 +1,127 @@
+// conflicts.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// How an import treats a URL that is already bookmarked under another name
+// or with other tags (Config.ImportConflicts).
+const (
+	conflictAsk        = "ask"
+	conflictKeepMine   = "keep-mine"
+	conflictTakeTheirs = "take-theirs"
+	conflictMerge      = "merge"
+)
+
+// =============================================================================
+// == 🤝 IMPORT CONFLICTS
+// =============================================================================
+//
+// An import that meets a URL already in the collection used to drop what it
+// brought. When the incoming copy has another title, or tags the bookmark
+// lacks, the import now asks at a terminal what to do (keep mine, take
+// theirs, merge, for this one or all the rest), or applies the configured
+// policy. Elsewhere, as in the daemon, it keeps the collection's copy.
+
+// conflictPolicy returns how to settle the next conflict of this import.
+func (s *AppState) conflictPolicy() string {
+	if s.importPolicy != "" {
+		return s.importPolicy
+	}
+	policy := s.Config.ImportConflicts
+	if policy == "" {
+		policy = conflictAsk
+	}
+	if policy == conflictAsk && !interactive {
+		policy = conflictKeepMine
+	}
+	return policy
+}
+
+// conflicts reports whether the incoming copy of the bookmark at index i
+// brings a title or tags the bookmark does not have.
+func (s *AppState) conflicts(i int, theirs Bookmark) bool {
+	mine := s.Bookmarks[i]
+	if theirs.Name != "" && theirs.Name != theirs.URL && theirs.Name != mine.Name {
+		return true
+	}
+	return slices.ContainsFunc(theirs.Tags, func(t string) bool { return !mine.hasTag(normalizeTag(t)) })
+}
+
+// settleConflict applies the policy to a copy of the bookmark at index i
+// imported from from, asking first if need be.
+func (s *AppState) settleConflict(i int, theirs Bookmark, from string) {
+	if !s.conflicts(i, theirs) {
+		s.countImport(from, func(c *importSourceCount) { c.Duplicates++ })
+		return
+	}
+	policy := s.conflictPolicy()
+	if policy == conflictAsk {
+		policy = s.askConflict(i, theirs, from)
+	}
+	before := jsonBytes(s.Bookmarks[i])
+	switch policy {
+	case conflictTakeTheirs:
+		s.editBookmark(i, func(b *Bookmark) {
+			if theirs.Name != "" && theirs.Name != theirs.URL {
+				b.Name = theirs.Name
+			}
+			if len(theirs.Tags) > 0 {
+				b.Tags = nil
+				b.addTags(theirs.Tags...)
+			}
+			if theirs.Description != "" {
+				b.Description = theirs.Description
+			}
+		})
+	case conflictMerge:
+		s.editBookmark(i, func(b *Bookmark) {
+			if b.Name == b.URL && theirs.Name != "" {
+				b.Name = theirs.Name
+			}
+			b.Tags = slices.Clone(b.Tags)
+			b.addTags(theirs.Tags...)
+			if b.Description == "" {
+				b.Description = theirs.Description
+			}
+		})
+	}
+	updated := !bytes.Equal(before, jsonBytes(s.Bookmarks[i]))
+	s.countImport(from, func(c *importSourceCount) {
+		c.Conflicts++
+		if updated {
+			c.Updated++
+		}
+	})
+}
+
+// askConflict asks how to settle one conflict; an upper-case answer settles
+// the rest of the import the same way.
+func (s *AppState) askConflict(i int, theirs Bookmark, from string) string {
+	mine := s.Bookmarks[i]
+	describe := func(b Bookmark) string {
+		if len(b.Tags) == 0 {
+			return fmt.Sprintf("'%s'", b.Name)
+		}
+		return fmt.Sprintf("'%s' (%s)", b.Name, strings.Join(b.Tags, ", "))
+	}
+	clearProgress()
+	fmt.Printf("%s is already bookmarked as [%d] %s; %s has it as %s.\n", displayURL(mine.URL), mine.ID, describe(mine), from, describe(theirs))
+	for {
+		answer := askLine("Keep mine, take theirs or merge? [k/t/m, K/T/M for all the rest, Enter keeps mine] ")
+		policy, ok := map[string]string{
+			"": conflictKeepMine, "k": conflictKeepMine, "t": conflictTakeTheirs, "m": conflictMerge,
+		}[strings.ToLower(answer)]
+		if !ok {
+			continue
+		}
+		if answer != strings.ToLower(answer) {
+			s.importPolicy = policy
+		}
+		return policy
+	}
+}
@@ -0,0 +1,134 @@
+// contenttype.go
+package main
+
+import (
+	"maps"
+	"mime"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Bookmark types, stored in Bookmark.Type and matched by the type: query field.
+const (
+	typeArticle    = "article"
+	typeVideo      = "video"
+	typePDF        = "pdf"
+	typeRepository = "repository"
+	typeImage      = "image"
+	typeAudio      = "audio"
+)
+
+// typeIcons are the indicators shown in front of names in list.
+var typeIcons = map[string]string{
+	typeArticle:    "📄",
+	typeVideo:      "🎬",
+	typePDF:        "📕",
+	typeRepository: "📦",
+	typeImage:      "🖼️",
+	typeAudio:      "🎵",
+}
+
+// domainTypes maps hosts (and their subdomains) whose pages are all of one
+// kind, whatever Content-Type they are served with.
+var domainTypes = map[string]string{
+	"youtube.com":      typeVideo,
+	"youtu.be":         typeVideo,
+	"vimeo.com":        typeVideo,
+	"twitch.tv":        typeVideo,
+	"dailymotion.com":  typeVideo,
+	"soundcloud.com":   typeAudio,
+	"bandcamp.com":     typeAudio,
+	"open.spotify.com": typeAudio,
+	"imgur.com":        typeImage,
+	"flickr.com":       typeImage,
+}
+
+// repoHosts serve repositories at /<owner>/<repo>.
+var repoHosts = []string{"github.com", "gitlab.com", "codeberg.org", "bitbucket.org", "sr.ht"}
+
+// =============================================================================
+// == 🏷️ CONTENT TYPES
+// =============================================================================
+
+// detectType guesses what a URL points to from its host and path. It returns
+// "" when the URL alone says nothing; contentType may then decide.
+func detectType(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	host := bookmarkHost(rawURL)
+	for domain, t := range domainTypes {
+		if hostMatches(host, domain) {
+			return t
+		}
+	}
+	for _, domain := range repoHosts {
+		if hostMatches(host, domain) && len(strings.Split(strings.Trim(u.Path, "/"), "/")) >= 2 {
+			return typeRepository
+		}
+	}
+	if ext := strings.ToLower(path.Ext(u.Path)); ext != "" {
+		if ext == ".pdf" {
+			return typePDF
+		}
+		return mediaType(mime.TypeByExtension(ext))
+	}
+	return ""
+}
+
+// mediaType maps a Content-Type header to a bookmark type. HTML pages count
+// as articles.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch {
+	case mt == "application/pdf":
+		return typePDF
+	case mt == "text/html" || mt == "application/xhtml+xml":
+		return typeArticle
+	case strings.HasPrefix(mt, "image/"):
+		return typeImage
+	case strings.HasPrefix(mt, "video/"):
+		return typeVideo
+	case strings.HasPrefix(mt, "audio/"):
+		return typeAudio
+	}
+	return ""
+}
+
+// classify picks the type of b: URL heuristics win, since a YouTube page is
+// served as HTML, then the Content-Type seen by the last check.
+func classify(rawURL, contentType string) string {
+	if t := detectType(rawURL); t != "" {
+		return t
+	}
+	return mediaType(contentType)
+}
+
+// bookmarkIcon returns the list indicator for b: its own icon, else the one
+// Config.TagIcons gives its first tag (alphabetically) that has one, else
+// its type's.
+func (s *AppState) bookmarkIcon(b Bookmark) string {
+	if b.Icon != "" {
+		return decor(b.Icon + " ")
+	}
+	for _, t := range slices.Sorted(maps.Keys(s.Config.TagIcons)) {
+		if b.hasTag(t) {
+			return decor(s.Config.TagIcons[t] + " ")
+		}
+	}
+	return typeIcon(b.Type)
+}
+
+// typeIcon returns the list indicator for a bookmark type.
+func typeIcon(t string) string {
+	if icon, ok := typeIcons[t]; ok {
+		return decor(icon + " ")
+	}
+	return ""
+}
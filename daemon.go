@@ -0,0 +1,440 @@
+// daemon.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// daemonJobs are the maintenance jobs that can be scheduled in Config.Jobs.
+var daemonJobs = map[string]func(s *AppState){
+	"check": func(s *AppState) {
+		checked, dead, newlyDead := s.checkLinks(context.Background())
+		logger.Info("checked links", "checked", checked, "dead", len(dead), "newly_dead", len(newlyDead))
+		if len(newlyDead) > 0 {
+			s.notifyDesktop("Bibliothermes link check", deadLinksSummary(newlyDead))
+		}
+	},
+	"refresh-titles": func(s *AppState) {
+		logger.Info("refreshed titles", "count", s.refreshTitles(context.Background(), false))
+	},
+	"import": func(s *AppState) {
+		if n := s.importBookmarks(context.Background(), false); n > 0 {
+			s.notifyDesktop("Bibliothermes import", fmt.Sprintf("Imported %d new bookmarks.", n))
+		}
+	},
+	"remind": func(s *AppState) {
+		logger.Info("reminders came due", "count", s.fireReminders(time.Now()))
+	},
+	"expire": func(s *AppState) {
+		expired := s.expiredBookmarks(time.Now())
+		s.trashBookmarks(expired, time.Now())
+		logger.Info("moved expired bookmarks to the trash", "count", len(expired))
+		if len(expired) > 0 {
+			s.notifyDesktop("Bibliothermes", fmt.Sprintf("%d bookmarks expired and went to the trash.", len(expired)))
+		}
+	},
+	"stale": func(s *AppState) {
+		stale := s.staleBookmarks(time.Now())
+		s.shelve(stale, time.Now())
+		logger.Info("shelved stale bookmarks", "count", len(stale))
+	},
+	"sync": func(s *AppState) {
+		if remote := s.Config.Sync.Remote; remote != "" {
+			pushed, pulled, deleted, err := s.syncWith(syncTarget{key: remote, url: remote, token: s.Config.Sync.Token})
+			if err != nil {
+				logger.Error("could not sync", "remote", remote, "err", err)
+			} else {
+				logger.Info("synced", "remote", remote, "pushed", pushed, "pulled", pulled, "deleted", deleted)
+			}
+		}
+		if s.Config.Server.LAN {
+			results, err := s.syncPeers()
+			if err != nil {
+				logger.Error("could not sync with LAN peers", "err", err)
+			}
+			for _, r := range results {
+				if r.err != nil {
+					logger.Error("could not sync with peer", "peer", r.peer.Name, "addr", r.peer.Addr, "err", r.err)
+				} else {
+					logger.Info("synced with peer", "peer", r.peer.Name, "addr", r.peer.Addr, "pushed", r.pushed, "pulled", r.pulled, "deleted", r.deleted)
+				}
+			}
+		}
+	},
+	"publish": func(s *AppState) {
+		s.republish()
+	},
+	"archive": func(s *AppState) {
+		logger.Info("archived bookmarks", "count", s.archivePending())
+	},
+	"backup": func(s *AppState) {
+		path, err := createBackup("")
+		if err != nil {
+			logger.Error("could not back up", "err", err)
+			return
+		}
+		logger.Info("backup written", "file", path)
+		if remote := s.Config.Backup.Remote; remote != "" {
+			where, pruned, err := putBackup(s.Config.Backup, remote, path)
+			if err != nil {
+				logger.Error("could not upload the backup", "remote", remote, "err", err)
+				return
+			}
+			logger.Info("backup uploaded", "url", where, "pruned", pruned)
+		}
+	},
+}
+
+// deadLinksSummary names the first few dead bookmarks for a notification.
+func deadLinksSummary(dead []Bookmark) string {
+	names := make([]string, 0, 3)
+	for _, b := range dead {
+		if len(names) == 3 {
+			names = append(names, "…")
+			break
+		}
+		names = append(names, b.Name)
+	}
+	return fmt.Sprintf("%d links stopped working: %s", len(dead), strings.Join(names, ", "))
+}
+
+// =============================================================================
+// == 🕰️ DAEMON MODE
+// =============================================================================
+
+// configPollInterval is how often the daemon looks for changes to the config
+// file.
+const configPollInterval = 2 * time.Second
+
+// runDaemon runs the jobs configured in Config.Jobs on their schedules until
+// interrupted. Each job works on a fresh load of the data file and saves when
+// done, so REPL sessions and the daemon can take turns editing it. Changes to
+// the config file apply as soon as they are saved.
+func (s *AppState) runDaemon() error {
+	if len(s.Config.Jobs) == 0 && !s.Config.Server.LAN && s.Config.Server.MetricsAddr == "" {
+		return fmt.Errorf("no jobs configured; add e.g. jobs: {check: \"0 3 * * *\", import: \"@every 6h\"} to %s", configPath())
+	}
+	schedules, err := daemonSchedules(s.Config.Jobs)
+	if err != nil {
+		return err
+	}
+	// Jobs load the data file themselves: hand it what we have, unsaved
+	// edits of this session included, or the first job would drop them.
+	if err := s.saveState(); err != nil {
+		return err
+	}
+	if err := setupLogging(s.Config.Log, true); err != nil {
+		return err
+	}
+	defer func() { setupLogging(s.Config.Log, false) }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if s.Config.Server.LAN {
+		if err := s.startLANSync(ctx); err != nil {
+			return err
+		}
+	}
+	if addr := s.Config.Server.MetricsAddr; addr != "" {
+		startMetricsServer(ctx, addr)
+		logger.Info("serving metrics", "addr", addr)
+	}
+	nextRun := make(map[string]time.Time)
+	scheduleJobs := func() {
+		now := time.Now()
+		for name, sched := range schedules {
+			if _, ok := nextRun[name]; !ok {
+				nextRun[name] = sched.next(now)
+				logger.Info("job scheduled", "job", name, "next", nextRun[name].Format("2006-01-02 15:04"))
+			}
+		}
+	}
+	scheduleJobs()
+	configSeen := configModTime()
+	poll := time.NewTicker(configPollInterval)
+	defer poll.Stop()
+	for {
+		var due string
+		for name := range schedules {
+			if due == "" || nextRun[name].Before(nextRun[due]) || (nextRun[name].Equal(nextRun[due]) && name < due) {
+				due = name
+			}
+		}
+		var timer <-chan time.Time // none without jobs
+		if due != "" {
+			timer = time.After(time.Until(nextRun[due]))
+		}
+		select {
+		case <-ctx.Done():
+			logger.Info("daemon stopped")
+			return nil
+		case <-poll.C:
+			if mod := configModTime(); !mod.Equal(configSeen) {
+				configSeen = mod
+				changed, err := s.reloadConfig(schedules)
+				if err != nil {
+					logger.Error("config not reloaded", "file", configPath(), "err", err)
+					continue
+				}
+				for _, name := range changed {
+					delete(nextRun, name)
+				}
+				schedules, _ = daemonSchedules(s.Config.Jobs)
+				scheduleJobs()
+			}
+			continue
+		case <-timer:
+		}
+		logger.Info("running job", "job", due)
+		freshStateMu.Lock()
+		if fresh, err := loadState(); err != nil {
+			logger.Error("could not reload data", "err", err)
+		} else {
+			*s = *fresh
+			s.reload = loadState
+			start := time.Now()
+			daemonJobs[due](s)
+			recordJob(due, time.Since(start))
+			s.finishEnrichment()
+			if err := s.saveState(); err != nil {
+				logger.Error("could not save", "err", err)
+			}
+		}
+		freshStateMu.Unlock()
+		nextRun[due] = schedules[due].next(time.Now())
+	}
+}
+
+// whileUnlocked runs fn, which must leave s alone, with freshStateMu released
+// if a daemon job holds it: two daemons whose sync jobs call each other's
+// server would otherwise each wait for the other's lock. s is saved first and
+// loaded again after, with what others saved meanwhile.
+func (s *AppState) whileUnlocked(fn func() error) error {
+	if s.reload == nil {
+		return fn()
+	}
+	if err := s.saveState(); err != nil {
+		return err
+	}
+	freshStateMu.Unlock()
+	err := fn()
+	freshStateMu.Lock()
+	fresh, loadErr := s.reload()
+	if loadErr != nil {
+		return loadErr
+	}
+	fresh.reload = s.reload
+	*s = *fresh
+	return err
+}
+
+// daemonSchedules parses the schedules of jobs.
+func daemonSchedules(jobs map[string]string) (map[string]schedule, error) {
+	schedules := make(map[string]schedule)
+	for name, spec := range jobs {
+		if _, ok := daemonJobs[name]; !ok {
+			return nil, fmt.Errorf("unknown job %q", name)
+		}
+		sched, err := parseSchedule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", name, err)
+		}
+		schedules[name] = sched
+	}
+	return schedules, nil
+}
+
+// configModTime returns when the config file last changed, or the zero time.
+func configModTime() time.Time {
+	info, err := os.Stat(configPath())
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadConfig reads the config file again and applies it, returning the jobs
+// whose schedule changed or that were added or removed. A file that does not
+// read or check out changes nothing.
+func (s *AppState) reloadConfig(schedules map[string]schedule) ([]string, error) {
+	freshStateMu.Lock()
+	defer freshStateMu.Unlock()
+	c, found, err := readConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, errors.New("the file is gone; keeping the config")
+	}
+	var overrides map[string]configOverride
+	if c, overrides, err = effectiveConfig(c); err != nil {
+		return nil, err
+	}
+	old := s.Config
+	var changed []string
+	for name := range schedules {
+		if c.Jobs[name] != old.Jobs[name] {
+			changed = append(changed, name)
+		}
+	}
+	for name := range c.Jobs {
+		if _, ok := old.Jobs[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	if err := setupLogging(c.Log, true); err != nil {
+		return nil, err
+	}
+	s.Config = c
+	configOverrides = overrides
+	netConfig = c
+	logger.Info("config reloaded", "file", configPath(), "jobs_changed", len(changed))
+	if c.Server.LAN != old.Server.LAN || c.Server.Addr != old.Server.Addr || c.Server.MetricsAddr != old.Server.MetricsAddr {
+		logger.Warn("restart the daemon for changes to server settings to apply")
+	}
+	return changed, nil
+}
+
+// =============================================================================
+// == 📅 SCHEDULES
+// =============================================================================
+
+type schedule interface {
+	// next returns the first run time strictly after t.
+	next(t time.Time) time.Time
+}
+
+type everySchedule time.Duration
+
+func (e everySchedule) next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cronSchedule is a classic five-field cron expression, one bit per value.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+var scheduleAliases = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// parseSchedule accepts "@every <duration>", the @hourly/@daily/@weekly/@monthly
+// shorthands, or "minute hour day-of-month month day-of-week" with *, lists,
+// ranges and /steps.
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("invalid interval %q (minimum 1m)", rest)
+		}
+		return everySchedule(d), nil
+	}
+	if alias, ok := scheduleAliases[spec]; ok {
+		spec = alias
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: want 5 fields or @every <duration>", spec)
+	}
+	var c cronSchedule
+	var err error
+	if c.minute, _, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, _, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, c.domAny, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, _, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.dow, c.dowAny, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 { // 7 is Sunday too
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(field string, lo, hi int) (bits uint64, star bool, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if r, s, ok := strings.Cut(part, "/"); ok {
+			rangePart = r
+			if step, err = strconv.Atoi(s); err != nil || step < 1 {
+				return 0, false, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		start, end := lo, hi
+		switch {
+		case rangePart == "*":
+			star = star || step == 1
+		case strings.Contains(rangePart, "-"):
+			a, b, _ := strings.Cut(rangePart, "-")
+			start, err = strconv.Atoi(a)
+			if err == nil {
+				end, err = strconv.Atoi(b)
+			}
+		default:
+			start, err = strconv.Atoi(rangePart)
+			end = start
+			if strings.Contains(part, "/") {
+				end = hi
+			}
+		}
+		if err != nil || start < lo || end > hi || start > end {
+			return 0, false, fmt.Errorf("invalid field %q (allowed %d-%d)", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, star, nil
+}
+
+func (c cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return limit
+}
+
+// dayMatches follows cron: when both day fields are restricted, either may match.
+func (c cronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
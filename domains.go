@@ -0,0 +1,85 @@
+// domains.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// domainStats summarises the bookmarks pointing at one host.
+type domainStats struct {
+	host      string
+	count     int
+	dead      int
+	lastAdded time.Time
+}
+
+// =============================================================================
+// == 🌐 DOMAIN REPORT
+// =============================================================================
+
+// domainReport groups the bookmarks matching f by host, most bookmarked first.
+// Bookmarks without a host (local files, mailto: links) are grouped under "".
+func (s *AppState) domainReport(f bookmarkFilter) []domainStats {
+	byHost := map[string]*domainStats{}
+	for _, b := range s.Bookmarks {
+		if !f.match(b) {
+			continue
+		}
+		host := bookmarkHost(b.URL)
+		d, ok := byHost[host]
+		if !ok {
+			d = &domainStats{host: host}
+			byHost[host] = d
+		}
+		d.count++
+		if b.Check.Dead() {
+			d.dead++
+		}
+		if added := b.addedAt(); added.After(d.lastAdded) {
+			d.lastAdded = added
+		}
+	}
+	out := make([]domainStats, 0, len(byHost))
+	for _, d := range byHost {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].count != out[j].count {
+			return out[i].count > out[j].count
+		}
+		return out[i].host < out[j].host
+	})
+	return out
+}
+
+// printDomains prints the domain report as a table.
+func (s *AppState) printDomains(f bookmarkFilter) {
+	report := s.domainReport(f)
+	if len(report) == 0 {
+		fmt.Println("No bookmarks found.")
+		return
+	}
+	if !plainOutput {
+		fmt.Printf("%s%6s %6s  %-10s  %s%s\n", Bold, "COUNT", "DEAD", "LAST ADDED", "DOMAIN", Reset)
+	}
+	for _, d := range report {
+		host, last := displayHost(d.host), "-"
+		if host == "" {
+			host = "(no host)"
+		}
+		if !d.lastAdded.IsZero() {
+			last = d.lastAdded.Format("2006-01-02")
+		}
+		if plainOutput {
+			fmt.Printf("%s: %d bookmarks: %d dead: last added %s\n", host, d.count, d.dead, last)
+			continue
+		}
+		dead := fmt.Sprintf("%6d", d.dead)
+		if d.dead > 0 {
+			dead = Yellow + dead + Reset
+		}
+		fmt.Printf("%6d %s  %-10s  %s\n", d.count, dead, last, host)
+	}
+}
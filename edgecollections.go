@@ -0,0 +1,151 @@
+// edgecollections.go
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// =============================================================================
+// == 🧺 EDGE COLLECTIONS
+// =============================================================================
+
+// edgeCollectionsDB returns the Collections database of the browser profile
+// whose Bookmarks file is at bookmarksPath.
+func edgeCollectionsDB(bookmarksPath string) string {
+	return filepath.Join(filepath.Dir(bookmarksPath), "Collections", "collectionsSQLite")
+}
+
+// edgeCollectionItem is a page saved to an Edge collection.
+type edgeCollectionItem struct {
+	Collection string
+	Title      string
+	URL        string
+}
+
+// readEdgeCollections returns the web pages saved in the collections of the
+// database at path. Notes and images kept in collections have no URL and are
+// skipped.
+func readEdgeCollections(path string) ([]edgeCollectionItem, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_immutable=1", path))
+	if err != nil {
+		return nil, fmt.Errorf("could not open edge collections: %w", err)
+	}
+	defer db.Close()
+	rows, err := db.Query(`SELECT COALESCE(c.title, ''), COALESCE(i.title, ''), i.source
+		FROM collections_items_relationship AS r
+		JOIN collections AS c ON c.id = r.parent_id
+		JOIN items AS i ON i.id = r.item_id
+		ORDER BY c.title, r.position`)
+	if err != nil {
+		return nil, fmt.Errorf("could not query edge collections: %w", err)
+	}
+	defer rows.Close()
+	var out []edgeCollectionItem
+	for rows.Next() {
+		var it edgeCollectionItem
+		var source []byte
+		if err := rows.Scan(&it.Collection, &it.Title, &source); err != nil {
+			continue
+		}
+		if it.URL = collectionItemURL(source); it.URL != "" {
+			out = append(out, it)
+		}
+	}
+	return out, rows.Err()
+}
+
+// collectionItemURL extracts the page URL from an item's source column, a
+// JSON object such as {"url": "...", "websiteName": "..."}.
+func collectionItemURL(source []byte) string {
+	var src struct {
+		URL string `json:"url"`
+	}
+	if json.Unmarshal(source, &src) == nil {
+		return src.URL
+	}
+	if s := strings.TrimSpace(string(source)); strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+		return s
+	}
+	return ""
+}
+
+// collectionTag turns a collection title into a tag: "Trip to Lyon" becomes
+// "trip-to-lyon".
+func collectionTag(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// importEdgeCollections imports the collections database at path. Each
+// collection becomes a tag and a smart folder of the same name, the closest
+// thing to a folder here.
+func (s *AppState) importEdgeCollections(browser, path string) error {
+	items, err := readEdgeCollections(path)
+	if err != nil {
+		return err
+	}
+	src := newSource(browser, path)
+	src.Profile = filepath.Base(filepath.Dir(filepath.Dir(path)))
+	for _, it := range items {
+		tag := collectionTag(it.Collection)
+		name := it.Title
+		if name == "" {
+			name = it.URL
+		}
+		if s.addBookmark(name, it.URL, src) {
+			s.Bookmarks[len(s.Bookmarks)-1].addTags(tag)
+		} else if i := s.indexOfURL(it.URL); i >= 0 && tag != "" && !s.Bookmarks[i].hasTag(tag) {
+			s.editBookmark(i, func(b *Bookmark) { b.addTags(tag) })
+		}
+		if tag == "" {
+			continue
+		}
+		if _, ok := s.Config.SmartFolders[tag]; !ok {
+			if s.Config.SmartFolders == nil {
+				s.Config.SmartFolders = map[string]string{}
+			}
+			s.Config.SmartFolders[tag] = "tag:" + tag
+			s.countImport(sourceLabel(src), func(c *importSourceCount) { c.Folders++ })
+		}
+	}
+	return nil
+}
+
+// importAllEdgeCollections imports the collections of every browser profile
+// that has them, and reports whether any was found.
+func (s *AppState) importAllEdgeCollections(chromeLikePaths map[string][]string) bool {
+	found := false
+	for browser, paths := range chromeLikePaths {
+		for _, path := range paths {
+			db := edgeCollectionsDB(path)
+			if _, err := os.Stat(db); err != nil {
+				continue
+			}
+			if err := s.importEdgeCollections(browser, db); err != nil {
+				logger.Warn("failed to import collections", "browser", browser, "path", db, "err", err)
+				continue
+			}
+			logger.Info("checked for collections", "browser", browser, "path", db)
+			found = true
+		}
+	}
+	return found
+}
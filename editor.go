@@ -0,0 +1,337 @@
+// editor.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// editHeader explains the buffer edit --all opens, like git rebase -i does.
+const editHeader = `# Edit names and tags, or delete an entry to delete that bookmark.
+# Lines starting with # are ignored. An empty buffer changes nothing.
+`
+
+// =============================================================================
+// == 📝 EDITING IN $EDITOR
+// =============================================================================
+
+// editorCommand returns the user's editor: $VISUAL, then $EDITOR, then vi (or
+// notepad on Windows).
+func editorCommand() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if cmd := splitCommandLine(os.Getenv(env)); len(cmd) > 0 {
+			return cmd
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
+
+// editText opens text in the user's editor as a temporary file named after
+// pattern and returns what was saved.
+func editText(pattern, text string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(text); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	editor := editorCommand()
+	cmd := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w", editor[0], err)
+	}
+	data, err := os.ReadFile(f.Name())
+	return string(data), err
+}
+
+// editLine is one bookmark of the edit --all buffer.
+type editLine struct {
+	ID   int      `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+// editAll lets the user rename, retag and delete the bookmarks matching f
+// in one editor session.
+func (s *AppState) editAll(f bookmarkFilter) error {
+	bookmarks := s.filterBookmarks(f)
+	if len(bookmarks) == 0 {
+		fmt.Println("No bookmarks found.")
+		return nil
+	}
+	entries := make([]editLine, len(bookmarks))
+	for j, b := range bookmarks {
+		entries[j] = editLine{ID: b.ID, Name: b.Name, Tags: b.Tags}
+		if b.Tags == nil {
+			entries[j].Tags = []string{}
+		}
+	}
+	data, err := marshalYAML(entries)
+	if err != nil {
+		return err
+	}
+	edited, err := editText("bibliothermes-*.yaml", editHeader+string(data))
+	if err != nil {
+		return err
+	}
+	lines, err := parseEditBuffer(edited)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		fmt.Println("Empty buffer; nothing changed.")
+		return nil
+	}
+	var deleted []Bookmark
+	for _, b := range bookmarks {
+		if _, ok := lines[b.ID]; !ok {
+			deleted = append(deleted, b)
+		}
+	}
+	for id := range lines {
+		if !slices.ContainsFunc(bookmarks, func(b Bookmark) bool { return b.ID == id }) {
+			return fmt.Errorf("bookmark %d was not in the list; nothing changed", id)
+		}
+	}
+	if len(deleted) > 0 && askKey(fmt.Sprintf("Delete %d bookmarks? [y/n] ", len(deleted))) != "y" {
+		deleted = nil
+	}
+	renamed, retagged := 0, 0
+	for _, b := range bookmarks {
+		line, ok := lines[b.ID]
+		i, err := s.lookupBookmark(strconv.Itoa(b.ID))
+		if !ok || err != nil {
+			continue
+		}
+		if line.Name != b.Name {
+			renamed++
+		}
+		if !sameTags(line.Tags, b.Tags) {
+			retagged++
+		}
+		s.editBookmark(i, func(b *Bookmark) {
+			b.Name = line.Name
+			if !sameTags(line.Tags, b.Tags) {
+				b.Tags = nil
+				b.addTags(line.Tags...)
+			}
+		})
+	}
+	for _, b := range deleted {
+		if i, err := s.lookupBookmark(strconv.Itoa(b.ID)); err == nil {
+			s.removeBookmark(i)
+		}
+	}
+	fmt.Printf("Renamed %d, retagged %d and deleted %d bookmarks.\n", renamed, retagged, len(deleted))
+	return nil
+}
+
+// parseEditBuffer reads an edited edit --all buffer by ID.
+func parseEditBuffer(text string) (map[int]editLine, error) {
+	var entries []editLine
+	if err := unmarshalYAML([]byte(text), &entries); err != nil {
+		return nil, fmt.Errorf("%w; nothing changed", err)
+	}
+	lines := map[int]editLine{}
+	for _, e := range entries {
+		if e.ID == 0 {
+			return nil, fmt.Errorf("%q has no id; nothing changed", e.Name)
+		}
+		if _, dup := lines[e.ID]; dup {
+			return nil, fmt.Errorf("bookmark %d listed twice; nothing changed", e.ID)
+		}
+		if e.Name = strings.TrimSpace(e.Name); e.Name == "" {
+			return nil, fmt.Errorf("bookmark %d has an empty name; nothing changed", e.ID)
+		}
+		var tags []string
+		for _, t := range e.Tags {
+			if t = normalizeTag(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		e.Tags = tags
+		lines[e.ID] = e
+	}
+	return lines, nil
+}
+
+// sameTags reports whether a and b hold the same tags in the same order,
+// ignoring case.
+func sameTags(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// editFields are the fields edit <id> lets the user change, in buffer
+// order.
+type editFields struct {
+	Name        string   `json:"name"`
+	URL         string   `json:"url"`
+	Alias       string   `json:"alias"`
+	App         string   `json:"app"`
+	Icon        string   `json:"icon"`
+	Tags        []string `json:"tags"`
+	Description string   `json:"description"`
+	Image       string   `json:"image"`
+	Type        string   `json:"type"`
+	Lang        string   `json:"lang"`
+	Favorite    bool     `json:"favorite"`
+	Read        bool     `json:"read"`
+	Tor         bool     `json:"tor"`
+}
+
+// fieldsOf returns the editable fields of b, with the URL as displayed.
+func fieldsOf(b Bookmark) editFields {
+	tags := b.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return editFields{
+		Name: b.Name, URL: displayURL(b.URL), Alias: b.Alias, App: b.App, Icon: b.Icon, Tags: tags,
+		Description: b.Description, Image: b.Image, Type: b.Type, Lang: b.Lang,
+		Favorite: b.Favorite, Read: b.Read, Tor: b.Tor,
+	}
+}
+
+// editBookmarkYAML opens the bookmark at index i in the user's editor as YAML
+// and applies what was saved. A buffer that does not parse can be edited
+// again rather than losing the changes.
+func (s *AppState) editBookmarkYAML(i int) error {
+	text, err := bookmarkYAML(s.Bookmarks[i])
+	if err != nil {
+		return err
+	}
+	for {
+		edited, err := editText("bibliothermes-*.yaml", text)
+		if err != nil {
+			return err
+		}
+		if edited == text {
+			fmt.Println("No changes.")
+			return nil
+		}
+		fields, err := parseBookmarkFields(edited, s.Bookmarks[i])
+		if err == nil {
+			err = s.applyYAML(i, fields)
+		}
+		if err == nil {
+			fmt.Printf("Saved '%s'.\n", s.Bookmarks[i].Name)
+			return nil
+		}
+		fmt.Printf("Error: %v\n", err)
+		if askKey("Edit again? [y/n] ") != "y" {
+			fmt.Println("Nothing changed.")
+			return nil
+		}
+		text = edited
+	}
+}
+
+// bookmarkYAML renders the editable fields of b as YAML, with the read-only
+// ones in comments at the top.
+func bookmarkYAML(b Bookmark) (string, error) {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "# Bookmark %d (%s). Save to apply; comments are ignored.\n", b.ID, b.UUID)
+	if added := b.addedAt(); !added.IsZero() {
+		fmt.Fprintf(&buf, "# added: %s\n", added.Format(time.RFC3339))
+	}
+	if b.Source != nil {
+		fmt.Fprintf(&buf, "# source: %s %s\n", b.Source.Browser, b.Source.Path)
+	}
+	if b.Check != nil {
+		fmt.Fprintf(&buf, "# checked: %s (%s)\n", b.Check.CheckedAt.Format(time.RFC3339), b.Check.describe())
+	}
+	if b.Archive != nil && b.Archive.Snapshot != "" {
+		fmt.Fprintf(&buf, "# snapshot: %s\n", b.Archive.Snapshot)
+	}
+	data, err := marshalYAML(fieldsOf(b))
+	if err != nil {
+		return "", err
+	}
+	buf.Write(data)
+	return buf.String(), nil
+}
+
+// parseBookmarkFields reads an edited edit <id> buffer over the fields of
+// b, so that fields left out keep their value.
+func parseBookmarkFields(text string, b Bookmark) (editFields, error) {
+	var keys map[string]any
+	if err := unmarshalYAML([]byte(text), &keys); err != nil {
+		return editFields{}, err
+	}
+	for key := range keys {
+		if yamlFieldType(reflect.TypeFor[editFields](), key) == nil {
+			return editFields{}, fmt.Errorf("unknown field %q", key)
+		}
+	}
+	fields := fieldsOf(b)
+	err := unmarshalYAML([]byte(text), &fields)
+	return fields, err
+}
+
+// applyYAML checks the edited fields and writes them to the bookmark at
+// index i. A new URL gets its type detected again, unless the type was
+// edited too.
+func (s *AppState) applyYAML(i int, f editFields) error {
+	b := s.Bookmarks[i]
+	name := strings.TrimSpace(f.Name)
+	if name == "" {
+		return fmt.Errorf("name cannot be empty")
+	}
+	url := b.URL
+	if v := strings.TrimSpace(f.URL); v != displayURL(b.URL) {
+		if v == "" {
+			return fmt.Errorf("url cannot be empty")
+		}
+		url = asciiURL(normalizeBookmarkURL(v))
+		if j := s.indexOfURL(url); j >= 0 && j != i {
+			return fmt.Errorf("%s is already bookmarked as [%d]", v, s.Bookmarks[j].ID)
+		}
+	}
+	retyped := f.Type != b.Type
+	s.editBookmark(i, func(b *Bookmark) {
+		if b.URL != url {
+			b.URL, b.Type = url, detectType(url)
+		}
+		b.Name = name
+		b.Alias = strings.TrimSpace(f.Alias)
+		b.App = strings.TrimSpace(f.App)
+		b.Icon = strings.TrimSpace(f.Icon)
+		b.Description = f.Description
+		b.Image = strings.TrimSpace(f.Image)
+		if retyped {
+			b.Type = strings.ToLower(strings.TrimSpace(f.Type))
+		}
+		b.Lang = primaryLang(f.Lang)
+		b.Favorite, b.Read, b.Tor = f.Favorite, f.Read, f.Tor
+		if !sameTags(f.Tags, b.Tags) {
+			b.Tags = nil
+			b.addTags(f.Tags...)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,48 @@
+// editor_test.go
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestApplyYAMLType edits the URL of a bookmark, then its URL and type.
+func TestApplyYAMLType(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BIBLIOTHERMES_CONFIG", filepath.Join(dir, "config.yaml"))
+	saved := dataDir
+	dataDir = dir
+	t.Cleanup(func() { dataDir = saved })
+
+	tests := []struct {
+		name, from, to, want string
+	}{
+		{"url only", "type: article", "type: article", typeVideo},
+		{"url and type", "type: article", "type: audio", "audio"},
+	}
+	for _, tt := range tests {
+		s := &AppState{nextID: 2, Bookmarks: []Bookmark{{
+			ID: 1, UUID: "aaaa", Name: "Post", URL: "https://example.com/post", Type: "article",
+		}}}
+		text, err := bookmarkYAML(s.Bookmarks[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(text, tt.from) {
+			t.Fatalf("%s: buffer has no %q:\n%s", tt.name, tt.from, text)
+		}
+		text = strings.Replace(text, "url: https://example.com/post", "url: https://www.youtube.com/watch?v=1", 1)
+		text = strings.Replace(text, tt.from, tt.to, 1)
+		fields, err := parseBookmarkFields(text, s.Bookmarks[0])
+		if err == nil {
+			err = s.applyYAML(0, fields)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := s.Bookmarks[0]; got.URL != "https://www.youtube.com/watch?v=1" || got.Type != tt.want {
+			t.Errorf("%s: URL %s, type %q; want type %q", tt.name, got.URL, got.Type, tt.want)
+		}
+	}
+}
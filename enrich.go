@@ -0,0 +1,113 @@
+// enrich.go
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+type enrichResult struct {
+	uuid string
+	meta pageMeta
+}
+
+var (
+	// enrichResults collects metadata fetched by background passes until the
+	// main goroutine applies it, so the state is never touched concurrently.
+	enrichMu       sync.Mutex
+	enrichResults  []enrichResult
+	pendingEnrichs sync.WaitGroup
+)
+
+// =============================================================================
+// == 🖼️ OPEN GRAPH ENRICHMENT
+// =============================================================================
+
+// startEnrichment fetches og:description and og:image for the given bookmarks
+// in the background. Results are picked up by applyEnrichment. watch shows a
+// progress bar, for a caller that waits. Cancelling ctx stops the pass.
+func (s *AppState) startEnrichment(ctx context.Context, bookmarks []Bookmark, watch bool) {
+	var targets []Bookmark
+	for _, b := range bookmarks {
+		if isWebURL(b.URL) {
+			targets = append(targets, b)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	var bar *progress
+	if watch {
+		bar = newProgress("Fetching descriptions", len(targets))
+	}
+	pendingEnrichs.Add(1)
+	go func() {
+		defer pendingEnrichs.Done()
+		defer bar.finish()
+		clientFor, cache := s.clientChooser(), loadMetaCache()
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, s.concurrency())
+		for _, b := range targets {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go func(b Bookmark) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				defer bar.step(displayURL(b.URL))
+				meta, err := fetchMeta(ctx, clientFor(b), cache, b.URL)
+				if err != nil {
+					return
+				}
+				enrichMu.Lock()
+				enrichResults = append(enrichResults, enrichResult{uuid: b.UUID, meta: meta})
+				enrichMu.Unlock()
+			}(b)
+		}
+		wg.Wait()
+		if err := cache.save(); err != nil {
+			logger.Warn("could not save", "file", metaCacheFile, "err", err)
+		}
+	}()
+}
+
+// applyEnrichment copies finished background results onto the bookmarks and
+// returns how many changed. Like link checks, this is metadata rather than an
+// edit, so it is not written to the history.
+func (s *AppState) applyEnrichment() int {
+	enrichMu.Lock()
+	results := enrichResults
+	enrichResults = nil
+	enrichMu.Unlock()
+	changed := 0
+	var updated []int
+	for _, r := range results {
+		i, err := s.lookupBookmark(r.uuid)
+		if err != nil {
+			continue // deleted meanwhile
+		}
+		updated = append(updated, i)
+		b := &s.Bookmarks[i]
+		desc, image := r.meta.OG["og:description"], r.meta.OG["og:image"]
+		if desc != b.Description || image != b.Image {
+			b.Description, b.Image = desc, image
+			changed++
+		}
+		if r.meta.Lang != "" {
+			b.Lang = r.meta.Lang
+		}
+	}
+	s.journalBookmarks(updated...)
+	return changed
+}
+
+// finishEnrichment waits for background passes and applies their results.
+func (s *AppState) finishEnrichment() int {
+	pendingEnrichs.Wait()
+	return s.applyEnrichment()
+}
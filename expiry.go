@@ -0,0 +1,138 @@
+// expiry.go
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// == ⏳ EXPIRING BOOKMARKS & THE TRASH
+// =============================================================================
+//
+// A bookmark can carry an expiry date (a CFP deadline, the end of a sale).
+// Once it passes, list flags it and the daemon's expire job moves it to the
+// trash. Trashed bookmarks are out of every listing until restored with
+// untrash or deleted for good by trash empty.
+
+func (b Bookmark) expired(now time.Time) bool {
+	return !b.ExpiresAt.IsZero() && !now.Before(b.ExpiresAt)
+}
+
+func (b Bookmark) trashed() bool {
+	return !b.TrashedAt.IsZero()
+}
+
+// hidesTrashed reports whether a listing for query leaves trashed bookmarks
+// out: always, unless the query asks about them.
+func hidesTrashed(query string) bool {
+	return !strings.Contains(strings.ToLower(query), "trashed:")
+}
+
+// parseWhen reads a moment in the future: a delay from now ("30d", "2w",
+// "6m" for months, "1y", or a Go duration like "36h"), "tomorrow", a date
+// (YYYY-MM-DD) or a date and time (YYYY-MM-DDTHH:MM). day is set when only a
+// day was given; t is then its start.
+func parseWhen(v string, now time.Time) (t time.Time, day bool, err error) {
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", v, time.Local); err == nil {
+		return t, false, nil
+	}
+	if v == "tomorrow" {
+		y, m, d := now.AddDate(0, 0, 1).Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.Local), true, nil
+	}
+	if n, err := strconv.Atoi(v[:max(0, len(v)-1)]); err == nil && n > 0 {
+		switch v[len(v)-1] {
+		case 'd':
+			return now.AddDate(0, 0, n), false, nil
+		case 'w':
+			return now.AddDate(0, 0, 7*n), false, nil
+		case 'm':
+			return now.AddDate(0, n, 0), false, nil
+		case 'y':
+			return now.AddDate(n, 0, 0), false, nil
+		}
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return now.Add(d), false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("want a delay like 30d, 2w, 6m, 1y or 36h, tomorrow, or a date YYYY-MM-DD[THH:MM], got %q", v)
+}
+
+// parseExpiry reads when a bookmark expires (see parseWhen). A bookmark
+// expiring on a day lasts until the end of it.
+func parseExpiry(v string, now time.Time) (time.Time, error) {
+	t, day, err := parseWhen(v, now)
+	if day {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, err
+}
+
+// expiredBookmarks returns the indexes of expired bookmarks not yet trashed.
+func (s *AppState) expiredBookmarks(now time.Time) []int {
+	var expired []int
+	for i, b := range s.Bookmarks {
+		if b.expired(now) && !b.trashed() {
+			expired = append(expired, i)
+		}
+	}
+	return expired
+}
+
+// trashBookmarks moves the bookmarks at the given indexes to the trash.
+func (s *AppState) trashBookmarks(indexes []int, now time.Time) {
+	for _, i := range indexes {
+		s.editBookmark(i, func(b *Bookmark) { b.TrashedAt = now })
+	}
+}
+
+// runTrash runs `trash`: list the trash, `trash <id>` to move a bookmark
+// there, `trash empty` to delete its contents for good.
+func (s *AppState) runTrash(args []string) {
+	switch {
+	case len(args) == 0:
+		count := 0
+		for _, b := range s.Bookmarks {
+			if b.trashed() {
+				fmt.Printf("%s[%d]%s %s %s(trashed %s)%s\n", style(Bold+Cyan), b.ID, style(Reset), b.Name, style(Gray), b.TrashedAt.Format("2006-01-02"), style(Reset))
+				count++
+			}
+		}
+		if count == 0 {
+			fmt.Println("The trash is empty.")
+		}
+	case args[0] == "empty":
+		var uuids []string
+		for _, b := range s.Bookmarks {
+			if b.trashed() {
+				uuids = append(uuids, b.UUID)
+			}
+		}
+		if len(uuids) == 0 {
+			fmt.Println("The trash is empty.")
+			return
+		}
+		if askKey(fmt.Sprintf("Delete the %d bookmarks in the trash for good? [y/n] ", len(uuids))) != "y" {
+			return
+		}
+		for _, uuid := range uuids {
+			if i, err := s.lookupBookmark(uuid); err == nil {
+				s.removeBookmark(i)
+			}
+		}
+		fmt.Printf("Deleted %d bookmarks.\n", len(uuids))
+	default:
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return
+		}
+		s.trashBookmarks([]int{i}, time.Now())
+		fmt.Printf("Moved '%s' to the trash.\n", s.Bookmarks[i].Name)
+	}
+}
@@ -0,0 +1,449 @@
+// export.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxJSONLine caps one line of a JSON Lines import; snapshots are not
+// inlined, so real bookmarks are far smaller.
+const maxJSONLine = 16 << 20
+
+// exporters write a list of bookmarks in one format each.
+var exporters = map[string]func(w io.Writer, bookmarks []Bookmark) error{
+	"json":  exportJSON,
+	"md":    exportMarkdown,
+	"html":  exportNetscapeHTML,
+	"csv":   exportCSV,
+	"yaml":  exportYAML,
+	"jsonl": exportJSONLines,
+	"ics":   exportICS,
+}
+
+// importers read back the formats that keep every field, handing each
+// bookmark to add as soon as it is decoded.
+var importers = map[string]func(r io.Reader, add func(Bookmark)) error{
+	"json":  importJSON,
+	"yaml":  importYAML,
+	"jsonl": importJSONLines,
+}
+
+// =============================================================================
+// == 🔎 FILTERS
+// =============================================================================
+
+// bookmarkFilter selects a slice of the collection from command-line style
+// flags: --tag (repeatable), --domain, --since, --source, --fav and --query.
+// Trashed bookmarks are left out unless text, the query as typed, asks for
+// them (see hidesTrashed).
+type bookmarkFilter struct {
+	tags    []string
+	domain  string
+	since   time.Time
+	source  string
+	favOnly bool
+	query   queryNode
+	text    string
+}
+
+// filterFlags are the flags parseFilterArgs and exportBookmarks understand.
+var filterFlags = []string{"--tag", "--domain", "--since", "--source", "--fav", "--query", "-o", "--out"}
+
+// parseFilterArgs pulls the filter flags out of args and returns the rest.
+func parseFilterArgs(args []string) (bookmarkFilter, []string, error) {
+	var f bookmarkFilter
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		flag := args[i]
+		needsValue := flag == "--tag" || flag == "--domain" || flag == "--since" || flag == "--source" || flag == "--query"
+		if needsValue && i+1 >= len(args) {
+			return f, nil, fmt.Errorf("%s needs a value", flag)
+		}
+		switch flag {
+		case "--tag":
+			i++
+			f.tags = append(f.tags, normalizeTag(args[i]))
+		case "--domain":
+			i++
+			f.domain = strings.ToLower(strings.TrimPrefix(args[i], "www."))
+		case "--since":
+			i++
+			since, err := time.ParseInLocation("2006-01-02", args[i], time.Local)
+			if err != nil {
+				return f, nil, fmt.Errorf("invalid --since date %q (want YYYY-MM-DD)", args[i])
+			}
+			f.since = since
+		case "--source":
+			i++
+			f.source = args[i]
+		case "--fav":
+			f.favOnly = true
+		case "--query":
+			// The query runs up to the next flag, since the command line
+			// arrives already split on spaces.
+			end := i + 1
+			for end < len(args) && !slices.Contains(filterFlags, args[end]) {
+				end++
+			}
+			f.text = strings.Join(args[i+1:end], " ")
+			q, err := parseQuery(f.text)
+			i = end - 1
+			if err != nil {
+				return f, nil, fmt.Errorf("invalid --query: %w", err)
+			}
+			f.query = q
+		default:
+			rest = append(rest, flag)
+		}
+	}
+	return f, rest, nil
+}
+
+// match reports whether b passes every filter that is set.
+func (f bookmarkFilter) match(b Bookmark) bool {
+	if b.trashed() && hidesTrashed(f.text) {
+		return false
+	}
+	if f.favOnly && !b.Favorite {
+		return false
+	}
+	for _, t := range f.tags {
+		if !b.hasTag(t) {
+			return false
+		}
+	}
+	if f.domain != "" && !hostMatches(bookmarkHost(b.URL), f.domain) {
+		return false
+	}
+	if !f.since.IsZero() && b.addedAt().Before(f.since) {
+		return false
+	}
+	if f.source != "" && !b.fromSource(f.source) {
+		return false
+	}
+	if f.query != nil && !f.query.match(b) {
+		return false
+	}
+	return true
+}
+
+// bookmarkHost returns the lowercased punycode host of a URL, without "www.".
+func bookmarkHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(hostToASCII(u.Hostname()), "www.")
+}
+
+// hostMatches reports whether host is domain or one of its subdomains;
+// domain may be given in Unicode or punycode.
+func hostMatches(host, domain string) bool {
+	domain = hostToASCII(domain)
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// filterBookmarks returns the bookmarks matching f, sorted by name.
+func (s *AppState) filterBookmarks(f bookmarkFilter) []Bookmark {
+	var out []Bookmark
+	for _, b := range s.Bookmarks {
+		if f.match(b) {
+			out = append(out, b)
+		}
+	}
+	sortByName(out)
+	return out
+}
+
+// =============================================================================
+// == 📤 EXPORT
+// =============================================================================
+
+// exportBookmarks runs `export <format> [-o path] [filters]`, or
+// `export buku [path] [filters]`.
+func (s *AppState) exportBookmarks(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: export <%s> [-o path] [--tag t] [--domain d] [--since YYYY-MM-DD] [--source b] [--fav] [--query q]", strings.Join(exportFormats(), "|"))
+	}
+	if _, ok := exporters[args[0]]; !ok && args[0] != "buku" {
+		return fmt.Errorf("unknown format %q (have %s)", args[0], strings.Join(exportFormats(), ", "))
+	}
+	filter, rest, err := parseFilterArgs(args[1:])
+	if err != nil {
+		return err
+	}
+	out := ""
+	for i := 0; i < len(rest); i++ {
+		if (rest[i] == "-o" || rest[i] == "--out") && i+1 < len(rest) {
+			out = rest[i+1]
+			i++
+		} else if args[0] == "buku" && out == "" {
+			out = rest[i]
+		} else {
+			return fmt.Errorf("unexpected argument %q", rest[i])
+		}
+	}
+	return exportTo(args[0], out, s.filterBookmarks(filter))
+}
+
+// exportTo writes bookmarks in format to the file out, or to stdout if out
+// is empty (for buku, to buku's database).
+func exportTo(format, out string, bookmarks []Bookmark) error {
+	if format == "buku" {
+		return exportBuku(out, bookmarks)
+	}
+	write, ok := exporters[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q (have %s)", format, strings.Join(exportFormats(), ", "))
+	}
+	if out == "" {
+		return write(os.Stdout, bookmarks)
+	}
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := write(f, bookmarks); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d bookmarks to %s.\n", len(bookmarks), out)
+	return nil
+}
+
+func exportFormats() []string {
+	names := make([]string, 0, len(exporters))
+	for name := range exporters {
+		names = append(names, name)
+	}
+	names = append(names, "buku")
+	sort.Strings(names)
+	return names
+}
+
+func exportJSON(w io.Writer, bookmarks []Bookmark) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bookmarks)
+}
+
+// exportJSONLines writes one compact JSON bookmark per line, for jq, grep and
+// other line-oriented tools.
+func exportJSONLines(w io.Writer, bookmarks []Bookmark) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, b := range bookmarks {
+		if err := enc.Encode(b); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func exportMarkdown(w io.Writer, bookmarks []Bookmark) error {
+	if _, err := fmt.Fprint(w, "# Bookmarks\n\n"); err != nil {
+		return err
+	}
+	for _, b := range bookmarks {
+		line := fmt.Sprintf("- [%s](%s)", strings.NewReplacer("[", `\[`, "]", `\]`).Replace(b.Name), b.URL)
+		for _, t := range b.Tags {
+			line += " `" + t + "`"
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportNetscapeHTML writes the bookmark file format every browser imports.
+func exportNetscapeHTML(w io.Writer, bookmarks []Bookmark) error {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	sb.WriteString(`<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">` + "\n")
+	sb.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+	for _, b := range bookmarks {
+		fmt.Fprintf(&sb, `    <DT><A HREF="%s"`, html.EscapeString(b.URL))
+		if added := b.addedAt(); !added.IsZero() {
+			fmt.Fprintf(&sb, ` ADD_DATE="%d"`, added.Unix())
+		}
+		if len(b.Tags) > 0 {
+			fmt.Fprintf(&sb, ` TAGS="%s"`, html.EscapeString(strings.Join(b.Tags, ",")))
+		}
+		fmt.Fprintf(&sb, ">%s</A>\n", html.EscapeString(b.Name))
+	}
+	sb.WriteString("</DL><p>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func exportCSV(w io.Writer, bookmarks []Bookmark) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "name", "url", "tags", "favorite", "added_at"})
+	for _, b := range bookmarks {
+		added := ""
+		if t := b.addedAt(); !t.IsZero() {
+			added = t.Format(time.RFC3339)
+		}
+		cw.Write([]string{strconv.Itoa(b.ID), b.Name, b.URL, strings.Join(b.Tags, " "), strconv.FormatBool(b.Favorite), added})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// =============================================================================
+// == 📥 IMPORT FROM FILES
+// =============================================================================
+
+// importFile adds the bookmarks in path ("-" for stdin), written in format,
+// whose URLs are not bookmarked yet. Their fields are kept, except that each
+// gets a new ID, and a new UUID if its own is taken.
+func (s *AppState) importFile(format, path string) error {
+	read, ok := importers[format]
+	if !ok {
+		return fmt.Errorf("unknown format %q (have %s)", format, strings.Join(importFormats(), ", "))
+	}
+	r := io.Reader(os.Stdin)
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	initialCount := len(s.Bookmarks)
+	s.beginImport()
+	if path == "-" && s.conflictPolicy() == conflictAsk {
+		// The answers would be read from the import itself.
+		s.importPolicy = conflictKeepMine
+	}
+	err := read(r, func(b Bookmark) { s.importOne(b, format, path) })
+	if err != nil {
+		fmt.Printf("Error: could not read %s: %v\n", path, err)
+	}
+	s.finishImport(initialCount)
+	return nil
+}
+
+// importOne adds one bookmark read from a file unless its URL is taken, in
+// which case the conflict policy decides what it changes.
+func (s *AppState) importOne(b Bookmark, format, path string) bool {
+	if b.URL == "" {
+		return false
+	}
+	b.URL = asciiURL(b.URL)
+	if i := s.indexOfURL(b.URL); i >= 0 {
+		logger.Debug("skipped: already bookmarked", "url", b.URL, "as", s.Bookmarks[i].ID)
+		s.settleConflict(i, b, fileLabel(path))
+		return false
+	}
+	b.ID = s.nextID
+	s.nextID++
+	if _, err := s.lookupBookmark(b.UUID); b.UUID == "" || err == nil {
+		b.UUID = newUUID()
+	}
+	if b.Name == "" {
+		b.Name = b.URL
+	}
+	if b.Type == "" {
+		b.Type = detectType(b.URL)
+	}
+	if b.AddedAt.IsZero() {
+		b.AddedAt = time.Now()
+	}
+	if b.Source == nil {
+		b.Source = &Source{Browser: strings.ToUpper(format), Path: path, ImportedAt: time.Now()}
+	}
+	s.Bookmarks = append(s.Bookmarks, b)
+	s.appended()
+	s.countImport(fileLabel(path), func(c *importSourceCount) { c.New++ })
+	return true
+}
+
+// fileLabel names an imported file in the import report.
+func fileLabel(path string) string {
+	if path == "-" {
+		return "stdin"
+	}
+	return path
+}
+
+func importFormats() []string {
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// importJSON decodes the array export json writes one element at a time.
+func importJSON(r io.Reader, add func(Bookmark)) error {
+	dec := json.NewDecoder(r)
+	if tok, err := dec.Token(); err != nil {
+		return err
+	} else if tok != json.Delim('[') {
+		return fmt.Errorf("want a list of bookmarks")
+	}
+	for dec.More() {
+		var b Bookmark
+		if err := dec.Decode(&b); err != nil {
+			return err
+		}
+		add(b)
+	}
+	_, err := dec.Token()
+	return err
+}
+
+func importYAML(r io.Reader, add func(Bookmark)) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var bookmarks []Bookmark
+	if err := unmarshalYAML(data, &bookmarks); err != nil {
+		return err
+	}
+	for _, b := range bookmarks {
+		add(b)
+	}
+	return nil
+}
+
+// importJSONLines reads one bookmark per line. A line that does not decode
+// is reported and skipped, so one bad record does not stop a long stream.
+func importJSONLines(r io.Reader, add func(Bookmark)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLine)
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var b Bookmark
+		if err := json.Unmarshal(line, &b); err != nil {
+			logger.Warn("skipped line", "line", n, "err", err)
+			continue
+		}
+		add(b)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,270 @@
+// fsck.go
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// fsckProblem is one inconsistency found by fsck. fix says what repair does;
+// problems without a repair need a decision from the user.
+type fsckProblem struct {
+	what   string
+	fix    string
+	repair func() error
+}
+
+// =============================================================================
+// == 🩺 INTEGRITY CHECK
+// =============================================================================
+
+// runFsck checks the collection, prints what it found and repairs what can be
+// repaired safely, asking first unless repair is set. The exit status is 1 if
+// problems remain.
+func (s *AppState) runFsck(repair bool) {
+	problems := s.fsck()
+	if len(problems) == 0 {
+		fmt.Printf("%sChecked %d bookmarks: no problems found.\n", decor("✅ "), len(s.Bookmarks))
+		return
+	}
+	fixable := 0
+	fmt.Printf("Checked %d bookmarks: %d problems.\n", len(s.Bookmarks), len(problems))
+	for _, p := range problems {
+		fmt.Printf("  %s%s\n", decor("⚠️  "), p.what)
+		if p.repair != nil {
+			fmt.Printf("     %sfix: %s%s\n", style(Gray), p.fix, style(Reset))
+			fixable++
+		}
+	}
+	exitStatus = 1
+	if fixable == 0 {
+		fmt.Println("None of them can be repaired automatically.")
+		return
+	}
+	if !repair && askKey(fmt.Sprintf("Repair %d of them? [y/n] ", fixable)) != "y" {
+		return
+	}
+	repaired := 0
+	for _, p := range problems {
+		if p.repair == nil {
+			continue
+		}
+		if err := p.repair(); err != nil {
+			fmt.Printf("Error: %s: %v\n", p.what, err)
+			continue
+		}
+		repaired++
+	}
+	fmt.Printf("Repaired %d problems.\n", repaired)
+	if repaired == len(problems) {
+		exitStatus = 0
+	}
+}
+
+// fsck returns every problem found, in a stable order: identity, text, URLs,
+// snapshots, then the search index.
+func (s *AppState) fsck() []fsckProblem {
+	var problems []fsckProblem
+	problems = append(problems, s.fsckIdentity()...)
+	problems = append(problems, s.fsckEncoding()...)
+	problems = append(problems, s.fsckURLs()...)
+	problems = append(problems, s.fsckSnapshots()...)
+	problems = append(problems, s.fsckIndex()...)
+	return problems
+}
+
+// fsckIdentity finds missing or repeated IDs and UUIDs. Later copies get a
+// new one; the first keeps it, so existing references stay right.
+func (s *AppState) fsckIdentity() []fsckProblem {
+	var problems []fsckProblem
+	ids, uuids := map[int]int{}, map[string]int{}
+	for i, b := range s.Bookmarks {
+		if first, dup := ids[b.ID]; dup || b.ID <= 0 {
+			what := fmt.Sprintf("'%s' has the invalid ID %d", b.Name, b.ID)
+			if dup {
+				what = fmt.Sprintf("'%s' has the same ID %d as '%s'", b.Name, b.ID, s.Bookmarks[first].Name)
+			}
+			problems = append(problems, fsckProblem{what: what, fix: "give it a new ID", repair: func() error {
+				s.editBookmark(i, func(b *Bookmark) { b.ID = s.nextID })
+				s.nextID++
+				return nil
+			}})
+		} else {
+			ids[b.ID] = i
+		}
+		if first, dup := uuids[b.UUID]; dup || len(b.UUID) != 36 {
+			what := fmt.Sprintf("[%d] '%s' has the invalid UUID %q", b.ID, b.Name, b.UUID)
+			if dup {
+				what = fmt.Sprintf("[%d] '%s' has the same UUID as [%d] '%s'", b.ID, b.Name, s.Bookmarks[first].ID, s.Bookmarks[first].Name)
+			}
+			problems = append(problems, fsckProblem{what: what, fix: "give it a new UUID", repair: func() error {
+				s.editBookmark(i, func(b *Bookmark) { b.UUID = newUUID() })
+				return nil
+			}})
+		} else {
+			uuids[b.UUID] = i
+		}
+	}
+	return problems
+}
+
+// fsckEncoding checks that bookmarks.json is valid UTF-8. Invalid bytes were
+// read as U+FFFD, so rewriting the file keeps what is readable.
+func (s *AppState) fsckEncoding() []fsckProblem {
+	data, err := os.ReadFile(dataPath(bookmarksFile))
+	if err == nil {
+		data, err = gunzipIfNeeded(data)
+	}
+	if err != nil || utf8.Valid(data) {
+		return nil
+	}
+	var affected []string
+	for _, b := range s.Bookmarks {
+		if strings.ContainsRune(fmt.Sprint(b.Name, b.URL, b.Description, b.Tags), utf8.RuneError) {
+			affected = append(affected, fmt.Sprintf("[%d]", b.ID))
+		}
+	}
+	what := bookmarksFile + " contains invalid UTF-8"
+	if len(affected) > 0 {
+		what += " (in " + strings.Join(affected, " ") + ")"
+	}
+	return []fsckProblem{{what: what, fix: "save it with the invalid bytes replaced by �", repair: func() error {
+		s.savedHash = 0
+		return nil
+	}}}
+}
+
+// fsckURLs reports bookmarks whose URLs differ only in ways that do not
+// matter (case of the host, a trailing slash, the fragment). Which to keep is
+// the user's call.
+func (s *AppState) fsckURLs() []fsckProblem {
+	byKey := map[string][]Bookmark{}
+	var keys []string
+	for _, b := range s.Bookmarks {
+		key := urlKey(b.URL)
+		if len(byKey[key]) == 0 {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], b)
+	}
+	var problems []fsckProblem
+	for _, key := range keys {
+		if same := byKey[key]; len(same) > 1 {
+			var refs []string
+			for _, b := range same {
+				refs = append(refs, fmt.Sprintf("[%d] '%s'", b.ID, b.Name))
+			}
+			problems = append(problems, fsckProblem{what: fmt.Sprintf("%s is bookmarked %d times: %s; delete the extra ones", key, len(same), strings.Join(refs, ", "))})
+		}
+	}
+	return problems
+}
+
+// urlKey normalizes a URL for comparison: punycode and lowercase host,
+// lowercase scheme, no fragment and no trailing slash.
+func urlKey(rawURL string) string {
+	u, err := url.Parse(asciiURL(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme, u.Host, u.Fragment = strings.ToLower(u.Scheme), strings.ToLower(u.Host), ""
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return u.String()
+}
+
+// fsckSnapshots finds snapshot references to missing files, which are
+// dropped, and files in snapshots/ that no bookmark refers to, which are
+// only reported: deleting a file is left to the user.
+func (s *AppState) fsckSnapshots() []fsckProblem {
+	var problems []fsckProblem
+	referenced := map[string]bool{}
+	for i, b := range s.Bookmarks {
+		if b.Archive == nil || b.Archive.Snapshot == "" {
+			continue
+		}
+		referenced[filepath.Clean(b.Archive.Snapshot)] = true
+		if _, err := os.Stat(dataPath(b.Archive.Snapshot)); os.IsNotExist(err) {
+			problems = append(problems, fsckProblem{
+				what: fmt.Sprintf("[%d] '%s' refers to the missing snapshot %s", b.ID, b.Name, b.Archive.Snapshot),
+				fix:  "forget the snapshot (archive <id> takes a new one)",
+				repair: func() error {
+					s.editBookmark(i, func(b *Bookmark) {
+						archive := *b.Archive
+						archive.Snapshot, archive.SnapshotAt = "", time.Time{}
+						b.Archive = &archive
+					})
+					return nil
+				},
+			})
+		}
+	}
+	entries, _ := os.ReadDir(dataPath(snapshotsDir))
+	var orphans []string
+	for _, e := range entries {
+		if path := filepath.Join(snapshotsDir, e.Name()); !e.IsDir() && !referenced[path] {
+			orphans = append(orphans, path)
+		}
+	}
+	sort.Strings(orphans)
+	for _, path := range orphans {
+		problems = append(problems, fsckProblem{what: fmt.Sprintf("%s belongs to no bookmark; delete it if it is not needed", path)})
+	}
+	return problems
+}
+
+// fsckIndex compares the full-text index with the bookmarks: entries for
+// deleted bookmarks, words pointing at no entry, and bookmarks missing or
+// out of date. Rebuilding the index fixes all of them.
+func (s *AppState) fsckIndex() []fsckProblem {
+	if len(s.Bookmarks) == 0 {
+		return nil
+	}
+	var orphaned, dangling, missing, stale int
+	if _, err := os.Stat(dataPath(indexFile)); os.IsNotExist(err) {
+		missing = len(s.Bookmarks)
+	} else {
+		idx := loadIndex()
+		textIndexMu.Lock()
+		known := make(map[string]bool, len(s.Bookmarks))
+		for _, b := range s.Bookmarks {
+			known[b.UUID] = true
+			if doc, ok := idx.Docs[b.UUID]; !ok {
+				missing++
+			} else if doc.Fingerprint != indexFingerprint(b) {
+				stale++
+			}
+		}
+		for uuid := range idx.Docs {
+			if !known[uuid] {
+				orphaned++
+			}
+		}
+		for _, uuids := range idx.Postings {
+			for uuid := range uuids {
+				if _, ok := idx.Docs[uuid]; !ok {
+					dangling++
+				}
+			}
+		}
+		textIndexMu.Unlock()
+	}
+	var found []string
+	for _, c := range []struct {
+		n    int
+		what string
+	}{{orphaned, "entries for deleted bookmarks"}, {dangling, "words pointing at no entry"}, {missing, "bookmarks not indexed"}, {stale, "bookmarks indexed out of date"}} {
+		if c.n > 0 {
+			found = append(found, fmt.Sprintf("%s: %d", c.what, c.n))
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	return []fsckProblem{{what: indexFile + " is out of step (" + strings.Join(found, ", ") + ")", fix: "rebuild the index", repair: s.reindex}}
+}
@@ -1,5 +1,11 @@
 module bibliothermes
 
-go 1.24.0
+go 1.25.0
 
-require github.com/mattn/go-sqlite3 v1.14.32
+require (
+	github.com/mattn/go-sqlite3 v1.14.32
+	go.starlark.net v0.0.0-20260908191801-89a6a09411d5
+	golang.org/x/text v0.40.0
+)
+
+require golang.org/x/sys v0.42.0 // indirect
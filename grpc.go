@@ -0,0 +1,376 @@
+// grpc.go
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// grpcService is the full name of the gRPC service in bibliothermes.proto.
+const grpcService = "bibliothermes.v1.Bookmarks"
+
+// gRPC status codes used here.
+const (
+	grpcOK              = 0
+	grpcInvalidArgument = 3
+	grpcAlreadyExists   = 6
+	grpcInternal        = 13
+)
+
+// grpcMethods are the methods of the service.
+var grpcMethods = map[string]func(*apiServer, *grpcCall) error{
+	"List":   (*apiServer).grpcList,
+	"Search": (*apiServer).grpcSearch,
+	"Add":    (*apiServer).grpcAdd,
+	"Watch":  (*apiServer).grpcWatch,
+}
+
+// grpcError is an error with a gRPC status code.
+type grpcError struct {
+	code int
+	msg  string
+}
+
+func (e *grpcError) Error() string { return e.msg }
+
+// grpcCall is one call to a method: its request message and where to send
+// the answers.
+type grpcCall struct {
+	w   http.ResponseWriter
+	r   *http.Request
+	req []byte
+}
+
+// =============================================================================
+// == 📡 GRPC
+// =============================================================================
+//
+// serve speaks gRPC next to the REST API, on the same port and with the same
+// token, over HTTP/2 without TLS (h2c). bibliothermes.proto describes the
+// service; messages are encoded by hand with the few protobuf wire types
+// they need. Watch streams every change to the collection, for widgets.
+
+// handleGRPC serves POST /bibliothermes.v1.Bookmarks/<method>.
+func (srv *apiServer) handleGRPC(w http.ResponseWriter, r *http.Request) {
+	method, ok := grpcMethods[r.PathValue("method")]
+	if r.ProtoMajor != 2 || !ok {
+		http.Error(w, "gRPC method not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	call := &grpcCall{w: w, r: r}
+	err := call.read()
+	if err == nil {
+		err = method(srv, call)
+	}
+	code, msg := grpcOK, ""
+	if err != nil {
+		code, msg = grpcInternal, err.Error()
+		var ge *grpcError
+		if errors.As(err, &ge) {
+			code = ge.code
+		}
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	w.Header().Set("Grpc-Message", url.PathEscape(msg))
+}
+
+// read reads the request message.
+func (c *grpcCall) read() error {
+	var head [5]byte
+	if _, err := io.ReadFull(c.r.Body, head[:]); err != nil {
+		return &grpcError{grpcInvalidArgument, "missing request message"}
+	}
+	size := binary.BigEndian.Uint32(head[1:])
+	if head[0] != 0 {
+		return &grpcError{grpcInvalidArgument, "compressed messages are not supported"}
+	}
+	if size > maxJSONLine {
+		return &grpcError{grpcInvalidArgument, "request message too large"}
+	}
+	c.req = make([]byte, size)
+	_, err := io.ReadFull(c.r.Body, c.req)
+	return err
+}
+
+// send sends one answer message.
+func (c *grpcCall) send(msg []byte) error {
+	head := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	if _, err := c.w.Write(append(head, msg...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(c.w).Flush()
+}
+
+// grpcList answers List: the REST API's GET /bookmarks, without fields.
+func (srv *apiServer) grpcList(c *grpcCall) error {
+	v := url.Values{}
+	err := protoFields(c.req, func(field int, n uint64, b []byte) {
+		name := map[int]string{1: "q", 2: "tag", 3: "domain", 4: "sort", 5: "limit", 6: "offset"}[field]
+		switch field {
+		case 1, 2, 3, 4:
+			v.Add(name, string(b))
+		case 5, 6:
+			v.Set(name, strconv.FormatUint(n, 10))
+		}
+	})
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	req, err := parseBookmarksRequest(v)
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	var msg []byte
+	err = srv.withState(func(s *AppState) error {
+		bookmarks, total := s.listForAPI(req)
+		msg = protoBookmarks(bookmarks, total)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.send(msg)
+}
+
+// grpcSearch answers Search: the matches of a query, best first, as search
+// ranks them.
+func (srv *apiServer) grpcSearch(c *grpcCall) error {
+	var query string
+	var limit int
+	err := protoFields(c.req, func(field int, n uint64, b []byte) {
+		switch field {
+		case 1:
+			query = string(b)
+		case 2:
+			limit = int(n)
+		}
+	})
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	q, err := parseQuery(query)
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, "invalid query: " + err.Error()}
+	}
+	var msg []byte
+	err = srv.withState(func(s *AppState) error {
+		var matches []Bookmark
+		now := time.Now()
+		for _, b := range s.Bookmarks {
+			if q.match(b) && b.visible(query, now) {
+				matches = append(matches, b)
+			}
+		}
+		sortByName(matches)
+		newSearchRanker(query).rank(matches)
+		total := len(matches)
+		if limit > 0 {
+			matches = matches[:min(limit, total)]
+		}
+		msg = protoBookmarks(matches, total)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.send(msg)
+}
+
+// grpcAdd answers Add with the new bookmark.
+func (srv *apiServer) grpcAdd(c *grpcCall) error {
+	var rawURL, name string
+	var tags []string
+	err := protoFields(c.req, func(field int, n uint64, b []byte) {
+		switch field {
+		case 1:
+			rawURL = string(b)
+		case 2:
+			name = string(b)
+		case 3:
+			tags = append(tags, string(b))
+		}
+	})
+	if err != nil || rawURL == "" {
+		return &grpcError{grpcInvalidArgument, "give the url to add"}
+	}
+	var msg []byte
+	err = srv.withState(func(s *AppState) error {
+		if name == "" {
+			name = rawURL
+		}
+		if !s.addBookmark(name, normalizeBookmarkURL(rawURL), nil) {
+			return &grpcError{grpcAlreadyExists, "that URL is already bookmarked"}
+		}
+		b := &s.Bookmarks[len(s.Bookmarks)-1]
+		b.addTags(tags...)
+		s.emit(eventAdd, *b)
+		msg = protoBookmark(*b)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.send(msg)
+}
+
+// grpcWatch answers Watch with a stream of changes: everything changed after
+// the since cursor (0 for the whole collection), then every change as it
+// happens, until the client hangs up.
+func (srv *apiServer) grpcWatch(c *grpcCall) error {
+	var cursor int64
+	err := protoFields(c.req, func(field int, n uint64, b []byte) {
+		if field == 1 {
+			cursor = int64(n)
+		}
+	})
+	if err != nil {
+		return &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	var seen time.Time
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		// The data file changes when anything does, here or in another
+		// process: look again only then.
+		if info, err := os.Stat(dataPath(bookmarksFile)); err != nil || !info.ModTime().Equal(seen) {
+			if err == nil {
+				seen = info.ModTime()
+			}
+			var changes syncChanges
+			err := srv.withState(func(s *AppState) error {
+				s.numberChanges()
+				changes = s.changesSince(cursor)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, b := range changes.Bookmarks {
+				var m protoMessage
+				m.varint(1, uint64(changes.Cursor))
+				m.bytes(2, protoBookmark(b))
+				if err := c.send(m); err != nil {
+					return nil
+				}
+			}
+			for uuid := range changes.Tombstones {
+				var m protoMessage
+				m.varint(1, uint64(changes.Cursor))
+				m.string(3, uuid)
+				if err := c.send(m); err != nil {
+					return nil
+				}
+			}
+			cursor = changes.Cursor
+		}
+		select {
+		case <-c.r.Context().Done():
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// =============================================================================
+// == 📡 PROTOBUF ENCODING
+// =============================================================================
+
+// protoMessage is a protobuf message being encoded. Like protobuf, it leaves
+// out fields with zero values.
+type protoMessage []byte
+
+func (m *protoMessage) varint(field int, v uint64) {
+	if v != 0 {
+		*m = binary.AppendUvarint(binary.AppendUvarint(*m, uint64(field)<<3), v)
+	}
+}
+
+func (m *protoMessage) bytes(field int, b []byte) {
+	*m = binary.AppendUvarint(binary.AppendUvarint(*m, uint64(field)<<3|2), uint64(len(b)))
+	*m = append(*m, b...)
+}
+
+func (m *protoMessage) string(field int, s string) {
+	if s != "" {
+		m.bytes(field, []byte(s))
+	}
+}
+
+// protoBookmark encodes a Bookmark message.
+func protoBookmark(b Bookmark) []byte {
+	var m protoMessage
+	m.varint(1, uint64(b.ID))
+	m.string(2, b.UUID)
+	m.string(3, b.Name)
+	m.string(4, b.URL)
+	for _, t := range b.Tags {
+		m.string(5, t)
+	}
+	if b.Favorite {
+		m.varint(6, 1)
+	}
+	if t := b.addedAt(); !t.IsZero() {
+		m.varint(7, uint64(t.Unix()))
+	}
+	m.string(8, b.Description)
+	m.string(9, b.Type)
+	m.varint(10, uint64(b.OpenCount))
+	return m
+}
+
+// protoBookmarks encodes a BookmarkList message.
+func protoBookmarks(bookmarks []Bookmark, total int) []byte {
+	var m protoMessage
+	for _, b := range bookmarks {
+		m.bytes(1, protoBookmark(b))
+	}
+	m.varint(2, uint64(total))
+	return m
+}
+
+// protoFields calls fn with each field of msg: its number, and its value as
+// a varint or as bytes. Fixed-size fields are skipped.
+func protoFields(msg []byte, fn func(field int, n uint64, b []byte)) error {
+	for len(msg) > 0 {
+		key, size := binary.Uvarint(msg)
+		if size <= 0 {
+			return errors.New("malformed message")
+		}
+		msg = msg[size:]
+		field := int(key >> 3)
+		switch key & 7 {
+		case 0:
+			n, size := binary.Uvarint(msg)
+			if size <= 0 {
+				return errors.New("malformed varint")
+			}
+			fn(field, n, nil)
+			msg = msg[size:]
+		case 1, 5:
+			skip := map[uint64]int{1: 8, 5: 4}[key&7]
+			if len(msg) < skip {
+				return errors.New("truncated message")
+			}
+			msg = msg[skip:]
+		case 2:
+			n, size := binary.Uvarint(msg)
+			if size <= 0 || uint64(len(msg)-size) < n {
+				return errors.New("truncated message")
+			}
+			fn(field, 0, msg[size:size+int(n)])
+			msg = msg[size+int(n):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+	}
+	return nil
+}
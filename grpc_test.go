@@ -0,0 +1,225 @@
+// grpc_test.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// decodeBookmark decodes a Bookmark message as a client of
+// bibliothermes.proto would.
+func decodeBookmark(t *testing.T, msg []byte) Bookmark {
+	t.Helper()
+	var b Bookmark
+	err := protoFields(msg, func(field int, n uint64, v []byte) {
+		switch field {
+		case 1:
+			b.ID = int(n)
+		case 2:
+			b.UUID = string(v)
+		case 3:
+			b.Name = string(v)
+		case 4:
+			b.URL = string(v)
+		case 5:
+			b.Tags = append(b.Tags, string(v))
+		case 6:
+			b.Favorite = n != 0
+		case 7:
+			b.AddedAt = time.Unix(int64(n), 0).UTC()
+		case 8:
+			b.Description = string(v)
+		case 9:
+			b.Type = string(v)
+		case 10:
+			b.OpenCount = int(n)
+		}
+	})
+	if err != nil {
+		t.Fatalf("decoding Bookmark: %v", err)
+	}
+	return b
+}
+
+// decodeBookmarks decodes a BookmarkList message.
+func decodeBookmarks(t *testing.T, msg []byte) ([]Bookmark, int) {
+	t.Helper()
+	var bookmarks []Bookmark
+	var total int
+	var raw [][]byte
+	err := protoFields(msg, func(field int, n uint64, v []byte) {
+		switch field {
+		case 1:
+			raw = append(raw, v)
+		case 2:
+			total = int(n)
+		}
+	})
+	if err != nil {
+		t.Fatalf("decoding BookmarkList: %v", err)
+	}
+	for _, v := range raw {
+		bookmarks = append(bookmarks, decodeBookmark(t, v))
+	}
+	return bookmarks, total
+}
+
+// TestProtoWireFormat checks the encoding against the examples of the
+// protobuf encoding guide.
+func TestProtoWireFormat(t *testing.T) {
+	var m protoMessage
+	m.varint(1, 150)
+	if want := []byte{0x08, 0x96, 0x01}; !bytes.Equal(m, want) {
+		t.Errorf("varint field = % x, want % x", []byte(m), want)
+	}
+	m = nil
+	m.string(2, "testing")
+	if want := []byte{0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g'}; !bytes.Equal(m, want) {
+		t.Errorf("string field = % x, want % x", []byte(m), want)
+	}
+	m = nil
+	m.varint(1, 0)
+	m.string(2, "")
+	if len(m) != 0 {
+		t.Errorf("zero values encoded as % x, want nothing", []byte(m))
+	}
+}
+
+func TestProtoBookmarkRoundTrip(t *testing.T) {
+	bookmarks := []Bookmark{
+		{
+			ID:          300,
+			UUID:        "8d5e2b9c-0000-4000-8000-000000000001",
+			Name:        "Go — the language",
+			URL:         "https://go.dev/",
+			Tags:        []string{"golang", "docs"},
+			Favorite:    true,
+			AddedAt:     time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+			Description: "Home of the Go project",
+			Type:        "article",
+			OpenCount:   1 << 20,
+		},
+		{ID: 1, Name: "bare", URL: "https://example.com"},
+	}
+	for _, want := range bookmarks {
+		if got := decodeBookmark(t, protoBookmark(want)); !reflect.DeepEqual(got, want) {
+			t.Errorf("round trip of %q:\n got %+v\nwant %+v", want.Name, got, want)
+		}
+	}
+	got, total := decodeBookmarks(t, protoBookmarks(bookmarks, 42))
+	if total != 42 || !reflect.DeepEqual(got, bookmarks) {
+		t.Errorf("BookmarkList round trip = %d bookmarks, total %d", len(got), total)
+	}
+}
+
+func TestProtoFieldsMalformed(t *testing.T) {
+	tests := map[string][]byte{
+		"key cut short":    {0x80},
+		"varint cut short": {0x08, 0x96},
+		"bytes cut short":  {0x12, 0x07, 't', 'e'},
+		"fixed64 cut":      {0x09, 1, 2, 3},
+		"group wire type":  {0x0b},
+	}
+	for name, msg := range tests {
+		if err := protoFields(msg, func(int, uint64, []byte) {}); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+	// Fixed-size fields are skipped, not rejected.
+	var fields []int
+	msg := []byte{0x0d, 1, 2, 3, 4, 0x10, 0x05}
+	if err := protoFields(msg, func(field int, n uint64, b []byte) { fields = append(fields, field) }); err != nil || !slices.Equal(fields, []int{2}) {
+		t.Errorf("fields = %v, %v; want [2]", fields, err)
+	}
+}
+
+// grpcFrame frames msg as gRPC does: uncompressed, with its length.
+func grpcFrame(msg []byte) []byte {
+	return append(binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg))), msg...)
+}
+
+// TestGRPCOverH2C calls Add and List over HTTP/2 without TLS, against a
+// collection in a temporary directory.
+func TestGRPCOverH2C(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BIBLIOTHERMES_CONFIG", filepath.Join(dir, "config.yaml"))
+	saved := dataDir
+	dataDir = dir
+	t.Cleanup(func() { dataDir = saved })
+
+	srv := &apiServer{token: "secret"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /"+grpcService+"/{method}", srv.auth(srv.handleGRPC))
+	ts := httptest.NewUnstartedServer(mux)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	defer ts.Close()
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	call := func(method string, req []byte) (status string, answers [][]byte) {
+		t.Helper()
+		r, err := http.NewRequest(http.MethodPost, ts.URL+"/"+grpcService+"/"+method, bytes.NewReader(grpcFrame(req)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Content-Type", "application/grpc")
+		r.Header.Set("Authorization", "Bearer secret")
+		resp, err := client.Do(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			t.Fatalf("%s answered over %s", method, resp.Proto)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for len(body) >= 5 {
+			size := binary.BigEndian.Uint32(body[1:5])
+			answers = append(answers, body[5:5+size])
+			body = body[5+size:]
+		}
+		return resp.Trailer.Get("Grpc-Status"), answers
+	}
+
+	var add protoMessage
+	add.string(1, "https://go.dev")
+	add.string(2, "Go")
+	add.string(3, "golang")
+	status, answers := call("Add", add)
+	if status != "0" || len(answers) != 1 {
+		t.Fatalf("Add: status %q, %d answers", status, len(answers))
+	}
+	added := decodeBookmark(t, answers[0])
+	if added.Name != "Go" || !slices.Contains(added.Tags, "golang") || added.UUID == "" {
+		t.Errorf("Add answered %+v", added)
+	}
+	if status, _ := call("Add", add); status != "6" {
+		t.Errorf("adding again: status %q, want ALREADY_EXISTS (6)", status)
+	}
+
+	var list protoMessage
+	list.string(2, "golang")
+	status, answers = call("List", list)
+	if status != "0" || len(answers) != 1 {
+		t.Fatalf("List: status %q, %d answers", status, len(answers))
+	}
+	got, total := decodeBookmarks(t, answers[0])
+	if total != 1 || len(got) != 1 || got[0].UUID != added.UUID {
+		t.Errorf("List = %+v (total %d), want the added bookmark", got, total)
+	}
+}
@@ -0,0 +1,83 @@
+// hooks.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+// Events emitted on changes. Each maps to a list of shell commands in
+// Config.Hooks, and can be subscribed to by webhooks.
+const (
+	eventAdd    = "add"
+	eventDelete = "delete"
+	eventOpen   = "open"
+	eventSave   = "save"
+	eventImport = "import"
+	eventEdit   = "edit"
+)
+
+// =============================================================================
+// == 🪝 EVENT HOOKS
+// =============================================================================
+
+// emit announces an event to everything listening: the journal, the audit
+// log, hook commands and webhooks. Edits are logged by editBookmark, which
+// knows the old values.
+func (s *AppState) emit(event string, payload any) {
+	s.journalEvent(event, payload)
+	s.auditEvent(event, payload)
+	s.runHooks(event, payload)
+	s.sendWebhooks(event, payload)
+}
+
+// runHooks runs every command configured for event. The payload is written to
+// the command's stdin as JSON; for single-bookmark events the bookmark is also
+// exposed through BIBLIOTHERMES_* environment variables.
+func (s *AppState) runHooks(event string, payload any) {
+	cmds := s.Config.Hooks[event]
+	if len(cmds) == 0 {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logger.Warn("could not encode hook payload", "event", event, "err", err)
+		return
+	}
+	env := append(os.Environ(), "BIBLIOTHERMES_EVENT="+event)
+	if abs, err := filepath.Abs(dataPath(bookmarksFile)); err == nil {
+		env = append(env, "BIBLIOTHERMES_DATA_FILE="+abs)
+	}
+	if b, ok := payload.(Bookmark); ok {
+		env = append(env,
+			"BIBLIOTHERMES_ID="+strconv.Itoa(b.ID),
+			"BIBLIOTHERMES_UUID="+b.UUID,
+			"BIBLIOTHERMES_NAME="+b.Name,
+			"BIBLIOTHERMES_URL="+b.URL,
+		)
+	}
+	for _, command := range cmds {
+		cmd := shellCommand(command)
+		cmd.Env = env
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			logger.Warn("hook failed", "event", event, "command", command, "err", err)
+		}
+	}
+}
+
+// shellCommand runs a user-supplied command line through the platform shell,
+// so hooks can use pipes and redirections.
+func shellCommand(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
@@ -0,0 +1,88 @@
+// ics.go
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+)
+
+// icsLineOctets is the longest content line iCalendar allows before folding.
+const icsLineOctets = 75
+
+// =============================================================================
+// == 📅 ICALENDAR EXPORT
+// =============================================================================
+
+// exportICS writes the reminders and expiry dates of bookmarks as iCalendar
+// events, for calendar apps to import or subscribe to. Reminders come with an
+// alarm; bookmarks with neither date are left out.
+func exportICS(w io.Writer, bookmarks []Bookmark) error {
+	bw := bufio.NewWriter(w)
+	line := func(l string) {
+		// Fold long lines, without splitting a UTF-8 sequence.
+		for len(l) > icsLineOctets {
+			cut := icsLineOctets
+			for cut > 0 && l[cut]&0xc0 == 0x80 {
+				cut--
+			}
+			bw.WriteString(l[:cut] + "\r\n")
+			l = " " + l[cut:]
+		}
+		bw.WriteString(l + "\r\n")
+	}
+	stamp := icsTime(time.Now())
+	event := func(uid, summary string, at time.Time, b Bookmark, alarm bool) {
+		line("BEGIN:VEVENT")
+		line("UID:" + uid + "@bibliothermes")
+		line("DTSTAMP:" + stamp)
+		line("DTSTART:" + icsTime(at))
+		line("DURATION:PT15M")
+		line("SUMMARY:" + icsText(summary))
+		line("URL:" + b.URL)
+		description := b.URL
+		if b.Description != "" {
+			description += "\n\n" + b.Description
+		}
+		line("DESCRIPTION:" + icsText(description))
+		if len(b.Tags) > 0 {
+			tags := make([]string, len(b.Tags))
+			for i, t := range b.Tags {
+				tags[i] = icsText(t)
+			}
+			line("CATEGORIES:" + strings.Join(tags, ","))
+		}
+		if alarm {
+			line("BEGIN:VALARM")
+			line("ACTION:DISPLAY")
+			line("DESCRIPTION:" + icsText(summary))
+			line("TRIGGER:PT0M")
+			line("END:VALARM")
+		}
+		line("END:VEVENT")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//bibliothermes//bookmarks//EN")
+	line("X-WR-CALNAME:Bookmarks")
+	for _, b := range bookmarks {
+		if !b.RemindAt.IsZero() {
+			event(b.UUID+"-remind", "Revisit: "+b.Name, b.RemindAt, b, true)
+		}
+		if !b.ExpiresAt.IsZero() {
+			event(b.UUID+"-expires", "Expires: "+b.Name, b.ExpiresAt, b, false)
+		}
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+func icsTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// icsText escapes a TEXT value.
+func icsText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,329 @@
+// idn.go
+package main
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// =============================================================================
+// == 🈳 INTERNATIONALIZED DOMAIN NAMES
+// =============================================================================
+//
+// Bookmarks store hosts in their ASCII (punycode) form, so "münchen.de" and
+// "xn--mnchen-3ya.de" are the same bookmark and domain: queries match both.
+// list and domains show the Unicode form, except for hosts that look like an
+// attempt to impersonate another domain, which stay in punycode and are flagged.
+
+// Punycode parameters from RFC 3492.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+	acePrefix       = "xn--"
+)
+
+var errBadPunycode = errors.New("invalid punycode")
+
+func punyAdapt(delta, numPoints int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
+
+func punyThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punyTMin
+	case k >= bias+punyTMax:
+		return punyTMax
+	}
+	return k - bias
+}
+
+func punyDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+// punyEncode encodes one label, without the "xn--" prefix.
+func punyEncode(label string) string {
+	input := []rune(label)
+	var out strings.Builder
+	for _, r := range input {
+		if r < 0x80 {
+			out.WriteRune(r)
+		}
+	}
+	basic := out.Len()
+	if basic > 0 {
+		out.WriteByte('-')
+	}
+	n, delta, bias := punyInitialN, 0, punyInitialBias
+	for h := basic; h < len(input); {
+		m := int(unicode.MaxRune) + 1
+		for _, r := range input {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (h + 1)
+		n = m
+		for _, r := range input {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punyBase; ; k += punyBase {
+				t := punyThreshold(k, bias)
+				if q < t {
+					break
+				}
+				out.WriteByte(punyDigit(t + (q-t)%(punyBase-t)))
+				q = (q - t) / (punyBase - t)
+			}
+			out.WriteByte(punyDigit(q))
+			bias = punyAdapt(delta, h+1, h == basic)
+			delta = 0
+			h++
+		}
+		delta++
+		n++
+	}
+	return out.String()
+}
+
+// punyDecode decodes one label, without the "xn--" prefix.
+func punyDecode(label string) (string, error) {
+	var out []rune
+	pos := 0
+	if i := strings.LastIndexByte(label, '-'); i >= 0 {
+		for _, r := range label[:i] {
+			if r >= 0x80 {
+				return "", errBadPunycode
+			}
+			out = append(out, r)
+		}
+		pos = i + 1
+	}
+	n, i, bias := punyInitialN, 0, punyInitialBias
+	for pos < len(label) {
+		oldI, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(label) {
+				return "", errBadPunycode
+			}
+			c := label[pos]
+			pos++
+			var digit int
+			switch {
+			case c >= 'a' && c <= 'z':
+				digit = int(c - 'a')
+			case c >= 'A' && c <= 'Z':
+				digit = int(c - 'A')
+			case c >= '0' && c <= '9':
+				digit = int(c-'0') + 26
+			default:
+				return "", errBadPunycode
+			}
+			i += digit * w
+			t := punyThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punyBase - t
+			if i > unicode.MaxRune || w > unicode.MaxRune {
+				return "", errBadPunycode
+			}
+		}
+		bias = punyAdapt(i-oldI, len(out)+1, oldI == 0)
+		n += i / (len(out) + 1)
+		i %= len(out) + 1
+		if n > unicode.MaxRune {
+			return "", errBadPunycode
+		}
+		out = append(out[:i], append([]rune{rune(n)}, out[i:]...)...)
+		i++
+	}
+	return string(out), nil
+}
+
+// hostToASCII returns the punycode form of a hostname, lowercased.
+func hostToASCII(host string) string {
+	labels := strings.Split(strings.ToLower(host), ".")
+	for i, l := range labels {
+		if !isASCII(l) {
+			labels[i] = acePrefix + punyEncode(l)
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// hostToUnicode returns the Unicode form of a punycode hostname; labels that
+// don't decode are kept as they are.
+func hostToUnicode(host string) string {
+	labels := strings.Split(host, ".")
+	for i, l := range labels {
+		if len(l) > len(acePrefix) && strings.EqualFold(l[:len(acePrefix)], acePrefix) {
+			if u, err := punyDecode(l[len(acePrefix):]); err == nil {
+				labels[i] = u
+			}
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
+
+// asciiURL rewrites a URL with an internationalized host to its punycode
+// form, the form bookmarks are stored and compared in.
+func asciiURL(rawURL string) string {
+	if isASCII(rawURL) && !strings.Contains(rawURL, "%") {
+		// Nothing to convert, and no escape that could decode to Unicode.
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || isASCII(u.Host) {
+		return rawURL
+	}
+	host := hostToASCII(u.Hostname())
+	if port := u.Port(); port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+	return u.String()
+}
+
+// displayURL is the URL as list shows it: punycode hosts in Unicode, unless
+// they look like a lookalike domain.
+func displayURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.Contains(strings.ToLower(u.Host), acePrefix) {
+		return rawURL
+	}
+	if lookalikeReason(u.Hostname()) != "" {
+		return rawURL
+	}
+	return strings.Replace(rawURL, u.Hostname(), hostToUnicode(u.Hostname()), 1)
+}
+
+// displayHost is displayURL for a bare host.
+func displayHost(host string) string {
+	if lookalikeReason(host) != "" {
+		return host
+	}
+	return hostToUnicode(host)
+}
+
+// scriptTables are the scripts told apart when looking for mixed-script labels.
+var scriptTables = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Greek", unicode.Greek},
+	{"Armenian", unicode.Armenian},
+	{"Hebrew", unicode.Hebrew},
+	{"Arabic", unicode.Arabic},
+	{"Devanagari", unicode.Devanagari},
+	{"Thai", unicode.Thai},
+	{"Georgian", unicode.Georgian},
+	{"Cherokee", unicode.Cherokee},
+	{"Han", unicode.Han},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+}
+
+// latinLookalikes are Cyrillic and Greek letters that render like Latin ones;
+// a label spelled only with them (like "аррӏе") imitates a Latin domain.
+const latinLookalikes = "аеорсухіјӏѕԁԛԝһοικνρυ"
+
+// lookalikeReason explains why host looks like an impersonation of another
+// domain (one label mixing scripts, or spelled entirely with letters that
+// imitate Latin ones), or returns "" when it doesn't. Under a non-Latin
+// top-level domain such as .рф, all-Cyrillic names are expected.
+func lookalikeReason(host string) string {
+	labels := strings.Split(hostToUnicode(host), ".")
+	latinTLD := isASCII(labels[len(labels)-1])
+	for _, label := range labels {
+		if isASCII(label) {
+			continue
+		}
+		var scripts []string
+		onlyLookalikes := true
+		for _, r := range label {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			if !strings.ContainsRune(latinLookalikes, unicode.ToLower(r)) {
+				onlyLookalikes = false
+			}
+			for _, s := range scriptTables {
+				if unicode.Is(s.table, r) {
+					if !slices.Contains(scripts, s.name) {
+						scripts = append(scripts, s.name)
+					}
+					break
+				}
+			}
+		}
+		if len(scripts) > 1 && !cjkMix(scripts) {
+			return "mixes " + strings.Join(scripts, " and ") + " letters"
+		}
+		if onlyLookalikes && latinTLD && len(scripts) == 1 {
+			return "uses " + scripts[0] + " letters that look Latin"
+		}
+	}
+	return ""
+}
+
+// cjkMix reports whether scripts is a combination that is normal in Chinese,
+// Japanese or Korean names (Han with kana or Hangul, optionally with Latin).
+func cjkMix(scripts []string) bool {
+	cjk := false
+	for _, s := range scripts {
+		switch s {
+		case "Han", "Hiragana", "Katakana", "Hangul":
+			cjk = true
+		case "Latin":
+		default:
+			return false
+		}
+	}
+	return cjk
+}
+
+// lookalike reports whether b points at a possible lookalike domain.
+func (b Bookmark) lookalike() bool {
+	return lookalikeReason(bookmarkHost(b.URL)) != ""
+}
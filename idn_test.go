@@ -0,0 +1,89 @@
+// idn_test.go
+package main
+
+import "testing"
+
+// punycodeSamples are the sample strings of RFC 3492, section 7.1.
+var punycodeSamples = []struct {
+	name, unicode, punycode string
+}{
+	{"(A) Arabic (Egyptian)", "ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+	{"(B) Chinese (simplified)", "他们为什么不说中文", "ihqwcrb4cv8a8dqg056pqjye"},
+	{"(C) Chinese (traditional)", "他們爲什麽不說中文", "ihqwctvzc91f659drss3x8bo0yb"},
+	{"(D) Czech", "Pročprostěnemluvíčesky", "Proprostnemluvesky-uyb24dma41a"},
+	{"(J) Spanish", "PorquénopuedensimplementehablarenEspañol", "PorqunopuedensimplementehablarenEspaol-fmd56a"},
+	{"(K) Vietnamese", "TạisaohọkhôngthểchỉnóitiếngViệt", "TisaohkhngthchnitingVit-kjcr8268qyxafd2f1b9g"},
+	{"(L) 3<nen>B<gumi><kinpachi><sensei>", "3年B組金八先生", "3B-ww4c5e180e575a65lsy2b"},
+	{"(M) <amuro><namie>-with-SUPER-MONKEYS", "安室奈美恵-with-SUPER-MONKEYS", "-with-SUPER-MONKEYS-pc58ag80a8qai00g7n9n"},
+	{"(N) Hello-Another-Way-<sorezore><no><basho>", "Hello-Another-Way-それぞれの場所", "Hello-Another-Way--fc4qua05auwb3674vfr0b"},
+	{"(O) <hitotsu><yane><no><shita>2", "ひとつ屋根の下2", "2-u9tlzr9756bt3uc0v"},
+	{"(P) Maji<de>Koi<suru>5<byou><mae>", "MajiでKoiする5秒前", "MajiKoi5-783gue6qz075azm5e"},
+	{"(Q) <pafii>de<runba>", "パフィーdeルンバ", "de-jg4avhby1noc0d"},
+	{"(R) <sono><supiido><de>", "そのスピードで", "d9juau41awczczp"},
+	{"(S) -> $1.00 <-", "-> $1.00 <-", "-> $1.00 <--"},
+}
+
+func TestPunyEncode(t *testing.T) {
+	for _, tt := range punycodeSamples {
+		if got := punyEncode(tt.unicode); got != tt.punycode {
+			t.Errorf("%s: punyEncode = %q, want %q", tt.name, got, tt.punycode)
+		}
+	}
+}
+
+func TestPunyDecode(t *testing.T) {
+	for _, tt := range punycodeSamples {
+		got, err := punyDecode(tt.punycode)
+		if err != nil || got != tt.unicode {
+			t.Errorf("%s: punyDecode = %q, %v; want %q", tt.name, got, err, tt.unicode)
+		}
+	}
+	// Digits are case-insensitive: the sample for Russian carries a
+	// mixed-case annotation.
+	if got, err := punyDecode("b1abfaaepdrnnbgefbaDotcwatmq2g4l"); err != nil || got != "почемужеонинеговорятпорусски" {
+		t.Errorf("(I) Russian: punyDecode = %q, %v", got, err)
+	}
+	for _, bad := range []string{"ü-abc", "abc!", "99999999999"} {
+		if got, err := punyDecode(bad); err == nil {
+			t.Errorf("punyDecode(%q) = %q, want an error", bad, got)
+		}
+	}
+}
+
+func TestHostConversion(t *testing.T) {
+	tests := []struct{ unicode, ascii string }{
+		{"münchen.de", "xn--mnchen-3ya.de"},
+		{"bücher.example.com", "xn--bcher-kva.example.com"},
+		{"例え.テスト", "xn--r8jz45g.xn--zckzah"},
+		{"example.org", "example.org"},
+	}
+	for _, tt := range tests {
+		if got := hostToASCII(tt.unicode); got != tt.ascii {
+			t.Errorf("hostToASCII(%q) = %q, want %q", tt.unicode, got, tt.ascii)
+		}
+		if got := hostToUnicode(tt.ascii); got != tt.unicode {
+			t.Errorf("hostToUnicode(%q) = %q, want %q", tt.ascii, got, tt.unicode)
+		}
+	}
+	if got := hostToASCII("MÜNCHEN.DE"); got != "xn--mnchen-3ya.de" {
+		t.Errorf("hostToASCII lowercases to %q", got)
+	}
+	if got := asciiURL("https://münchen.de:8080/straße?q=1"); got != "https://xn--mnchen-3ya.de:8080/stra%C3%9Fe?q=1" {
+		t.Errorf("asciiURL = %q", got)
+	}
+}
+
+func TestLookalikeReason(t *testing.T) {
+	tests := map[string]bool{
+		"münchen.de":         false,
+		"xn--80ak6aa92e.com": true, // аррӏе.com, in Cyrillic
+		"раураl.com":         true, // mixes Cyrillic and Latin
+		"пример.рф":          false,
+		"例え.テスト":             false,
+	}
+	for host, flagged := range tests {
+		if reason := lookalikeReason(hostToASCII(host)); (reason != "") != flagged {
+			t.Errorf("lookalikeReason(%q) = %q", host, reason)
+		}
+	}
+}
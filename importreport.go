@@ -0,0 +1,132 @@
+// importreport.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// importReportFile keeps the summary of the last import, for import report.
+const importReportFile = "import-report.json"
+
+// importReport sums up one import, source by source.
+type importReport struct {
+	At      time.Time            `json:"at"`
+	Sources []*importSourceCount `json:"sources"`
+}
+
+// importSourceCount is what an import found in one source: a browser
+// profile, a file or a remote instance.
+type importSourceCount struct {
+	Source string `json:"source"`
+	New    int    `json:"new"`
+	// Duplicates were already bookmarked, with the same title and tags.
+	Duplicates int `json:"duplicates"`
+	// Conflicts were already bookmarked under another title or with other
+	// tags; Updated of them changed the bookmark (see conflicts.go).
+	Conflicts int `json:"conflicts"`
+	Updated   int `json:"updated"`
+	// Folders are the smart folders created, for Edge collections.
+	Folders int `json:"folders,omitempty"`
+	// Gone were imported before but are no longer in the browser, when it
+	// is mirrored (see mirror.go).
+	Gone int `json:"gone,omitempty"`
+}
+
+// =============================================================================
+// == 🧾 IMPORT REPORT
+// =============================================================================
+//
+// Every import ends with a summary per source: how many bookmarks were new,
+// how many were already there, how many conflicted and were updated, and the
+// folders it created. The summary is kept in import-report.json, where import
+// report finds it again.
+
+// beginImport starts counting an import.
+func (s *AppState) beginImport() {
+	s.importStats = &importReport{At: time.Now()}
+	s.importPolicy = ""
+}
+
+// countImport adds to the counts of source, if an import is under way.
+func (s *AppState) countImport(source string, add func(c *importSourceCount)) {
+	if s.importStats == nil {
+		return
+	}
+	for _, c := range s.importStats.Sources {
+		if c.Source == source {
+			add(c)
+			return
+		}
+	}
+	c := &importSourceCount{Source: source}
+	add(c)
+	s.importStats.Sources = append(s.importStats.Sources, c)
+}
+
+// sourceLabel names where src comes from in the report.
+func sourceLabel(src *Source) string {
+	if src.Profile != "" && src.Profile != "." {
+		return fmt.Sprintf("%s (%s)", src.Browser, src.Profile)
+	}
+	return src.Browser
+}
+
+// endImport prints the report of the import under way, saves it and stops
+// counting.
+func (s *AppState) endImport() {
+	report := s.importStats
+	s.importStats, s.importPolicy = nil, ""
+	if report == nil || len(report.Sources) == 0 {
+		return
+	}
+	fmt.Println("Import summary:")
+	report.print()
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err == nil {
+		err = os.WriteFile(dataPath(importReportFile), data, 0644)
+	}
+	if err != nil {
+		logger.Warn("could not write", "file", importReportFile, "err", err)
+	}
+}
+
+func (r *importReport) print() {
+	width := 0
+	for _, c := range r.Sources {
+		width = max(width, len(c.Source))
+	}
+	for _, c := range r.Sources {
+		line := fmt.Sprintf("  %-*s  %d new, %d already there", width, c.Source, c.New, c.Duplicates)
+		if c.Conflicts > 0 {
+			line += fmt.Sprintf(", %d with another title or tags (%d updated)", c.Conflicts, c.Updated)
+		}
+		if c.Folders > 0 {
+			line += fmt.Sprintf(", %d folders created", c.Folders)
+		}
+		if c.Gone > 0 {
+			line += fmt.Sprintf(", %d gone from the browser", c.Gone)
+		}
+		fmt.Println(line)
+	}
+}
+
+// showImportReport runs `import report`: the summary of the last import.
+func showImportReport() error {
+	data, err := os.ReadFile(dataPath(importReportFile))
+	if os.IsNotExist(err) {
+		fmt.Println("No import yet.")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	var report importReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return fmt.Errorf("could not parse %s: %w", importReportFile, err)
+	}
+	fmt.Printf("Last import, %s:\n", report.At.Local().Format("2006-01-02 15:04"))
+	report.print()
+	return nil
+}
@@ -0,0 +1,259 @@
+// index.go
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// indexFile holds the full-text index, rebuilt from bookmarks.json and
+	// the snapshots whenever it is missing.
+	indexFile = "index.gob"
+	// maxIndexText caps how much visible text of a snapshot is indexed.
+	maxIndexText = 200000
+	// minTermLen drops one-letter words, which match almost everything.
+	minTermLen = 2
+	// indexVersion changes when terms are made differently, so older
+	// indexes are rebuilt: 1 drops accents.
+	indexVersion = 1
+)
+
+// searchIndex is an inverted index from words to the bookmarks (by UUID)
+// whose name, URL, tags, description or snapshot text contain them.
+type searchIndex struct {
+	// Version is the indexVersion the index was built with.
+	Version int
+	// Accents tells whether the terms keep their accents (match_accents).
+	Accents  bool
+	Docs     map[string]indexDoc
+	Postings map[string]map[string]struct{}
+	// vocabulary is the sorted list of terms, for prefix lookups.
+	vocabulary []string
+}
+
+// indexDoc is what the index knows about one bookmark: a fingerprint of the
+// indexed fields, to tell when it changed, and its terms, to remove them.
+type indexDoc struct {
+	Fingerprint uint64
+	Terms       []string
+}
+
+var (
+	textIndexMu sync.Mutex
+	textIndex   *searchIndex
+)
+
+// =============================================================================
+// == 🔎 FULL-TEXT INDEX
+// =============================================================================
+
+// loadIndex returns the index, reading index.gob the first time. A missing or
+// unreadable file gives an empty index that the next save fills in.
+func loadIndex() *searchIndex {
+	textIndexMu.Lock()
+	defer textIndexMu.Unlock()
+	if textIndex != nil {
+		return textIndex
+	}
+	textIndex = newSearchIndex()
+	data, err := os.ReadFile(dataPath(indexFile))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Warn("could not read", "file", indexFile, "err", err)
+		}
+		return textIndex
+	}
+	var idx searchIndex
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&idx); err != nil {
+		logger.Warn("index is damaged and will be rebuilt", "file", indexFile, "err", err)
+		return textIndex
+	}
+	if idx.Docs != nil && idx.Postings != nil {
+		textIndex = &idx
+	}
+	textIndex.sortVocabulary()
+	return textIndex
+}
+
+// syncIndex brings the index up to date with the bookmarks: changed ones are
+// reindexed, deleted ones dropped, and the file is only rewritten if anything
+// moved.
+func (s *AppState) syncIndex() error {
+	idx := loadIndex()
+	textIndexMu.Lock()
+	defer textIndexMu.Unlock()
+	changed := false
+	seen := make(map[string]bool, len(s.Bookmarks))
+	for _, b := range s.Bookmarks {
+		seen[b.UUID] = true
+		fp := indexFingerprint(b)
+		if doc, ok := idx.Docs[b.UUID]; ok && doc.Fingerprint == fp {
+			continue
+		}
+		idx.remove(b.UUID)
+		idx.add(b.UUID, indexDoc{Fingerprint: fp, Terms: bookmarkTerms(b)})
+		changed = true
+	}
+	for uuid := range idx.Docs {
+		if !seen[uuid] {
+			idx.remove(uuid)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	idx.sortVocabulary()
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(idx); err != nil {
+		return fmt.Errorf("could not encode index: %w", err)
+	}
+	return os.WriteFile(dataPath(indexFile), buf.Bytes(), 0644)
+}
+
+// reindex throws the index away and builds it again from scratch.
+func (s *AppState) reindex() error {
+	textIndexMu.Lock()
+	textIndex = newSearchIndex()
+	textIndexMu.Unlock()
+	return s.syncIndex()
+}
+
+// current tells whether the index was built the way terms are made now;
+// otherwise it is rebuilt.
+func (idx *searchIndex) current() bool {
+	return idx.Version == indexVersion && idx.Accents == netConfig.MatchAccents
+}
+
+// newSearchIndex returns an empty index.
+func newSearchIndex() *searchIndex {
+	return &searchIndex{Version: indexVersion, Accents: netConfig.MatchAccents, Docs: map[string]indexDoc{}, Postings: map[string]map[string]struct{}{}}
+}
+
+func (idx *searchIndex) add(uuid string, doc indexDoc) {
+	idx.Docs[uuid] = doc
+	for _, t := range doc.Terms {
+		if idx.Postings[t] == nil {
+			idx.Postings[t] = map[string]struct{}{}
+		}
+		idx.Postings[t][uuid] = struct{}{}
+	}
+}
+
+func (idx *searchIndex) remove(uuid string) {
+	doc, ok := idx.Docs[uuid]
+	if !ok {
+		return
+	}
+	for _, t := range doc.Terms {
+		delete(idx.Postings[t], uuid)
+		if len(idx.Postings[t]) == 0 {
+			delete(idx.Postings, t)
+		}
+	}
+	delete(idx.Docs, uuid)
+}
+
+func (idx *searchIndex) sortVocabulary() {
+	idx.vocabulary = idx.vocabulary[:0]
+	for t := range idx.Postings {
+		idx.vocabulary = append(idx.vocabulary, t)
+	}
+	sort.Strings(idx.vocabulary)
+}
+
+// lookup returns the UUIDs of the bookmarks that have, for every term of
+// text, a word starting with it ("kube" finds "kubernetes"), or with fuzzy
+// set a word it is a typo of ("golag" finds "golang").
+func (idx *searchIndex) lookup(text string, fuzzy bool) map[string]bool {
+	textIndexMu.Lock()
+	defer textIndexMu.Unlock()
+	var result map[string]bool
+	for _, term := range tokenize(text) {
+		hits := map[string]bool{}
+		collect := func(word string) {
+			for uuid := range idx.Postings[word] {
+				if result == nil || result[uuid] {
+					hits[uuid] = true
+				}
+			}
+		}
+		for i := sort.SearchStrings(idx.vocabulary, term); i < len(idx.vocabulary) && strings.HasPrefix(idx.vocabulary[i], term); i++ {
+			collect(idx.vocabulary[i])
+		}
+		if edits := maxEdits(term); fuzzy && edits > 0 {
+			for _, word := range idx.vocabulary {
+				if !strings.HasPrefix(word, term) && withinEdits(term, word, edits) {
+					collect(word)
+				}
+			}
+		}
+		result = hits
+		if len(result) == 0 {
+			break
+		}
+	}
+	return result
+}
+
+// indexFingerprint hashes the indexed fields of b, including when the
+// snapshot was taken. It does not touch the disk, so syncing a large
+// collection costs no more than hashing it.
+func indexFingerprint(b Bookmark) uint64 {
+	h := fnv.New64a()
+	for _, f := range append([]string{b.Name, b.URL, b.Description}, b.Tags...) {
+		h.Write([]byte(f))
+		h.Write([]byte{0})
+	}
+	if b.Archive != nil && b.Archive.Snapshot != "" {
+		fmt.Fprintf(h, "%s\x00%d", b.Archive.Snapshot, b.Archive.SnapshotAt.UnixNano())
+	}
+	return h.Sum64()
+}
+
+// bookmarkTerms returns the distinct words of b's indexed fields.
+func bookmarkTerms(b Bookmark) []string {
+	text := []string{b.Name, displayURL(b.URL), b.URL, b.Description, strings.Join(b.Tags, " ")}
+	if b.Archive != nil && b.Archive.Snapshot != "" {
+		text = append(text, snapshotText(b.Archive.Snapshot))
+	}
+	return tokenize(strings.Join(text, " "))
+}
+
+// snapshotText returns the visible text of a saved page.
+func snapshotText(path string) string {
+	page, err := readSnapshot(path)
+	if err != nil {
+		return ""
+	}
+	text := html.UnescapeString(string(tagRe.ReplaceAll(scriptRe.ReplaceAll(page, nil), []byte(" "))))
+	if len(text) > maxIndexText {
+		text = text[:maxIndexText]
+	}
+	return text
+}
+
+// tokenize splits text into distinct lowercase words of letters and digits.
+func tokenize(text string) []string {
+	seen := map[string]bool{}
+	var terms []string
+	for _, w := range strings.FieldsFunc(matchForm(strings.ToLower(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len([]rune(w)) < minTermLen || seen[w] {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+	}
+	return terms
+}
@@ -0,0 +1,176 @@
+// journal.go
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+const journalFile = "journal.jsonl"
+
+// Journal operations.
+const (
+	journalPutOp    = "put"
+	journalDeleteOp = "delete"
+	// journalCollectionOp carries a collection as it now is; older versions
+	// journaled none for a deleted one.
+	journalCollectionOp = "collection"
+	journalConfigOp     = "config" // only in journals of older versions
+)
+
+// journalEntry is one line of the journal: a bookmark as it now is, the
+// UUID of a deleted one, or a collection (a deleted one marked Deleted).
+// Older versions journaled the whole config too; it is now saved to its own
+// file at once. Entries hold final values rather than changes, so replaying
+// them twice does no harm.
+type journalEntry struct {
+	Op       string    `json:"op"`
+	Bookmark *Bookmark `json:"bookmark,omitempty"`
+	UUID     string    `json:"uuid,omitempty"`
+	// At is when a bookmark was deleted.
+	At     time.Time `json:"at,omitzero"`
+	Config *Config   `json:"config,omitempty"`
+	// Name and Collection are a collection and its name.
+	Name       string      `json:"name,omitempty"`
+	Collection *Collection `json:"collection,omitempty"`
+}
+
+// =============================================================================
+// == 📓 CHANGE JOURNAL
+// =============================================================================
+//
+// Every change is appended to journal.jsonl (and synced) as it happens;
+// bookmarks.json is only rewritten on save and exit, which then empties the
+// journal. If a session dies before saving, the next start replays it.
+
+// writeJournal appends entries to the journal and syncs it to disk.
+func writeJournal(entries ...journalEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	f, err := os.OpenFile(dataPath(journalFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		logger.Warn("could not write", "file", journalFile, "err", err)
+		return
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		data, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err := w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		logger.Warn("could not write", "file", journalFile, "err", err)
+	}
+}
+
+// journalBookmarks records the current state of the bookmarks at the given
+// indexes.
+func (s *AppState) journalBookmarks(indexes ...int) {
+	entries := make([]journalEntry, 0, len(indexes))
+	for _, i := range indexes {
+		b := s.Bookmarks[i]
+		entries = append(entries, journalEntry{Op: journalPutOp, Bookmark: &b})
+	}
+	writeJournal(entries...)
+}
+
+// journalEvent journals the bookmarks an event is about, as they are now.
+// Opens are journaled by recordOpen.
+func (s *AppState) journalEvent(event string, payload any) {
+	switch event {
+	case eventAdd, eventEdit:
+		if b, ok := payload.(Bookmark); ok {
+			if i, err := s.lookupBookmark(b.UUID); err == nil {
+				s.journalBookmarks(i)
+			}
+		}
+	case eventDelete:
+		if b, ok := payload.(Bookmark); ok {
+			writeJournal(journalEntry{Op: journalDeleteOp, UUID: b.UUID, At: s.Tombstones[b.UUID]})
+		}
+	case eventImport:
+		added, _ := payload.([]Bookmark)
+		entries := make([]journalEntry, len(added))
+		for i := range added {
+			entries[i] = journalEntry{Op: journalPutOp, Bookmark: &added[i]}
+		}
+		writeJournal(entries...)
+	}
+}
+
+// replayJournal applies a journal left behind by a session that did not
+// save, and returns how many entries it applied. A last line cut short by the
+// crash is skipped.
+func (s *AppState) replayJournal() (int, error) {
+	f, err := os.Open(dataPath(journalFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLine)
+	applied, line := 0, 0
+	for scanner.Scan() {
+		line++
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			logger.Warn("skipping unreadable line", "file", journalFile, "line", line, "err", err)
+			continue
+		}
+		switch {
+		case e.Op == journalPutOp && e.Bookmark != nil:
+			b := *e.Bookmark
+			if i := s.indexOfUUID(b.UUID); i >= 0 {
+				s.Bookmarks[i] = b
+				s.index = nil
+			} else {
+				s.Bookmarks = append(s.Bookmarks, b)
+				s.appended()
+			}
+			s.nextID = max(s.nextID, b.ID+1)
+		case e.Op == journalDeleteOp:
+			if i := s.indexOfUUID(e.UUID); i >= 0 {
+				s.Bookmarks = append(s.Bookmarks[:i], s.Bookmarks[i+1:]...)
+				s.index = nil
+			}
+			if !e.At.IsZero() {
+				s.buryBookmark(e.UUID, e.At)
+			}
+		case e.Op == journalCollectionOp && e.Name != "":
+			if e.Collection == nil {
+				delete(s.Collections, e.Name)
+			} else {
+				if s.Collections == nil {
+					s.Collections = map[string]Collection{}
+				}
+				s.Collections[e.Name] = *e.Collection
+			}
+		case e.Op == journalConfigOp && e.Config != nil:
+			s.Config = *e.Config
+			s.saveConfig()
+		default:
+			continue
+		}
+		applied++
+	}
+	return applied, scanner.Err()
+}
+
+// clearJournal empties the journal once its changes are in bookmarks.json.
+func clearJournal() error {
+	if err := os.Remove(dataPath(journalFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// jump.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errNoJumpTarget = errors.New("no directory bookmark matches")
+
+// =============================================================================
+// == 🦘 DIRECTORY JUMPING
+// =============================================================================
+
+// jumpTarget resolves ref to a local bookmark: an alias, then an ID or UUID,
+// then the most opened local bookmark whose name contains ref. It returns the
+// bookmark's index and the directory to change to (a file's parent).
+func (s *AppState) jumpTarget(ref string) (int, string, error) {
+	i := -1
+	for j, b := range s.Bookmarks {
+		if b.Alias != "" && b.Alias == ref {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		if j, err := s.lookupBookmark(ref); err == nil {
+			i = j
+		}
+	}
+	if i < 0 {
+		lower := strings.ToLower(ref)
+		for j, b := range s.Bookmarks {
+			if _, ok := localPath(b.URL); !ok || !strings.Contains(strings.ToLower(b.Name), lower) {
+				continue
+			}
+			if i < 0 || b.OpenCount > s.Bookmarks[i].OpenCount {
+				i = j
+			}
+		}
+	}
+	if i < 0 {
+		return -1, "", fmt.Errorf("%w '%s'", errNoJumpTarget, ref)
+	}
+	path, ok := localPath(s.Bookmarks[i].URL)
+	if !ok {
+		return -1, "", fmt.Errorf("'%s' is not a local directory", s.Bookmarks[i].Name)
+	}
+	if info, err := os.Stat(path); err != nil {
+		return -1, "", err
+	} else if !info.IsDir() {
+		path = filepath.Dir(path)
+	}
+	return i, path, nil
+}
+
+// shellInit returns a `j` function for the given shell that changes to the
+// directory printed by `jump`. The data directory and binary are baked in,
+// since bookmarks.json is looked up in the working directory.
+func shellInit(shell string) (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	dataDir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	switch shell {
+	case "bash", "zsh":
+		return fmt.Sprintf(`j() {
+  local dir
+  dir="$(cd %s && %s --plain jump "$@")" && cd "$dir"
+}
+`, shellQuote(dataDir), shellQuote(exe)), nil
+	case "fish":
+		return fmt.Sprintf(`function j
+    # Command substitutions run in fish itself, so cd in a subshell.
+    set -l dir (sh -c 'cd "$1" && shift && exec "$@"' j %s %s --plain jump $argv); and cd $dir
+end
+`, fishQuote(dataDir), fishQuote(exe)), nil
+	}
+	return "", fmt.Errorf("unsupported shell %q (have bash, zsh, fish)", shell)
+}
+
+// shellQuote single-quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote single-quotes s for fish, where \ and ' are escaped inside quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
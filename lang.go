@@ -0,0 +1,101 @@
+// lang.go
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// stopwords are frequent short words that tell common languages apart. They
+// are only a fallback for pages that do not declare their language.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "of", "to", "is", "in", "that", "for", "with", "this", "you", "are", "on", "it", "how"},
+	"fr": {"le", "la", "les", "des", "et", "est", "une", "pour", "dans", "que", "qui", "sur", "pas", "avec", "du"},
+	"de": {"der", "die", "das", "und", "ist", "nicht", "ein", "eine", "mit", "für", "auf", "den", "zu", "sich", "wie"},
+	"es": {"el", "los", "las", "y", "es", "una", "por", "para", "con", "que", "del", "como", "pero", "su", "al"},
+	"it": {"il", "lo", "gli", "e", "è", "una", "per", "con", "che", "del", "della", "non", "sono", "come", "di"},
+	"pt": {"o", "os", "as", "e", "é", "uma", "para", "com", "que", "não", "do", "da", "em", "como", "por"},
+	"nl": {"de", "het", "een", "en", "is", "van", "niet", "met", "voor", "dat", "op", "zijn", "die", "ook", "je"},
+}
+
+const (
+	// minLangHits is how many stopwords a text needs before we trust a guess.
+	minLangHits = 3
+	// maxLangText caps how much visible text is scanned for stopwords.
+	maxLangText = 20000
+)
+
+var (
+	htmlLangRe = regexp.MustCompile(`(?is)<html\s[^>]*>`)
+	scriptRe   = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	tagRe      = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// =============================================================================
+// == 🌍 LANGUAGE DETECTION
+// =============================================================================
+
+// pageLanguage returns the language of a page: the <html lang> attribute, then
+// the Content-Language header, then og:locale, then a stopword guess on the
+// visible text. The result is a lowercase primary tag such as "fr", or "".
+func pageLanguage(page []byte, contentLanguage string, og map[string]string) string {
+	if tag := htmlLangRe.Find(page); tag != nil {
+		if lang := primaryLang(tagAttrs(tag)["lang"]); lang != "" {
+			return lang
+		}
+	}
+	if lang := primaryLang(strings.Split(contentLanguage, ",")[0]); lang != "" {
+		return lang
+	}
+	if lang := primaryLang(og["og:locale"]); lang != "" {
+		return lang
+	}
+	text := tagRe.ReplaceAll(scriptRe.ReplaceAll(page, nil), []byte(" "))
+	if len(text) > maxLangText {
+		text = text[:maxLangText]
+	}
+	return guessLanguage(string(text))
+}
+
+// primaryLang reduces "fr-CA" or "en_US" to "fr" or "en".
+func primaryLang(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	if len(tag) < 2 || len(tag) > 3 {
+		return ""
+	}
+	return tag
+}
+
+// guessLanguage counts stopwords per language and returns the clear winner.
+func guessLanguage(text string) string {
+	counts := map[string]int{}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		for lang, list := range stopwords {
+			for _, sw := range list {
+				if w == sw {
+					counts[lang]++
+					break
+				}
+			}
+		}
+	}
+	best, bestCount, runnerUp := "", 0, 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount, runnerUp = lang, n, bestCount
+		} else if n > runnerUp {
+			runnerUp = n
+		}
+	}
+	if bestCount < minLangHits || bestCount == runnerUp {
+		return ""
+	}
+	return best
+}
@@ -0,0 +1,294 @@
+// leveldb.go
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// =============================================================================
+// == 🗄️ LEVELDB (READ-ONLY)
+// =============================================================================
+//
+// Chromium keeps some profile data (the reading list among it) in LevelDB
+// databases. readLevelDB reads the current value of every key from the write
+// ahead logs (*.log) and the sorted tables (*.ldb, *.sst); it needs no lock
+// and doesn't touch the files, so it works while the browser runs.
+
+var errBadLevelDB = errors.New("malformed leveldb file")
+
+// levelDBValue is a key's value together with its sequence number, so the
+// latest write wins across files. deleted marks a tombstone.
+type levelDBValue struct {
+	seq     uint64
+	value   []byte
+	deleted bool
+}
+
+// readLevelDB returns the live keys starting with prefix and their values.
+func readLevelDB(dir, prefix string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	latest := map[string]levelDBValue{}
+	put := func(key []byte, v levelDBValue) {
+		if !strings.HasPrefix(string(key), prefix) {
+			return
+		}
+		if old, ok := latest[string(key)]; !ok || v.seq >= old.seq {
+			latest[string(key)] = v
+		}
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		switch filepath.Ext(e.Name()) {
+		case ".log":
+			err = readLevelDBLog(path, put)
+		case ".ldb", ".sst":
+			err = readLevelDBTable(path, put)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	out := map[string][]byte{}
+	for k, v := range latest {
+		if !v.deleted {
+			out[k] = v.value
+		}
+	}
+	return out, nil
+}
+
+// readLevelDBLog replays the write batches of a write-ahead log.
+func readLevelDBLog(path string, put func([]byte, levelDBValue)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	const blockSize = 32768
+	var record []byte
+	for pos := 0; pos+7 <= len(data); {
+		if left := blockSize - pos%blockSize; left < 7 {
+			pos += left // trailer padding
+			continue
+		}
+		length := int(binary.LittleEndian.Uint16(data[pos+4:]))
+		kind := data[pos+6]
+		pos += 7
+		if pos+length > len(data) {
+			break // torn write at the end of the log
+		}
+		switch kind {
+		case 1: // full
+			record = data[pos : pos+length]
+			readWriteBatch(record, put)
+			record = nil
+		case 2: // first
+			record = append([]byte(nil), data[pos:pos+length]...)
+		case 3: // middle
+			record = append(record, data[pos:pos+length]...)
+		case 4: // last
+			record = append(record, data[pos:pos+length]...)
+			readWriteBatch(record, put)
+			record = nil
+		}
+		pos += length
+	}
+	return nil
+}
+
+// readWriteBatch applies one write batch: an 8-byte sequence number, a count,
+// and the puts and deletes, each consuming one sequence number.
+func readWriteBatch(batch []byte, put func([]byte, levelDBValue)) {
+	if len(batch) < 12 {
+		return
+	}
+	seq := binary.LittleEndian.Uint64(batch)
+	b := batch[12:]
+	for len(b) > 0 {
+		tag := b[0]
+		b = b[1:]
+		key, rest, ok := readSlice(b)
+		if !ok {
+			return
+		}
+		b = rest
+		v := levelDBValue{seq: seq, deleted: tag == 0}
+		if tag == 1 {
+			if v.value, b, ok = readSlice(b); !ok {
+				return
+			}
+		}
+		put(key, v)
+		seq++
+	}
+}
+
+// readLevelDBTable reads every entry of a sorted table file.
+func readLevelDBTable(path string, put func([]byte, levelDBValue)) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < 48 {
+		return errBadLevelDB
+	}
+	footer := data[len(data)-48:]
+	_, n1 := binary.Uvarint(footer) // metaindex offset
+	_, n2 := binary.Uvarint(footer[n1:])
+	if n1 <= 0 || n2 <= 0 {
+		return errBadLevelDB
+	}
+	index, err := tableBlock(data, footer[n1+n2:])
+	if err != nil {
+		return err
+	}
+	return blockEntries(index, func(_, handle []byte) error {
+		block, err := tableBlock(data, handle)
+		if err != nil {
+			return err
+		}
+		return blockEntries(block, func(ikey, value []byte) error {
+			// Internal keys end in 8 bytes: sequence << 8 | kind.
+			if len(ikey) < 8 {
+				return errBadLevelDB
+			}
+			trailer := binary.LittleEndian.Uint64(ikey[len(ikey)-8:])
+			put(ikey[:len(ikey)-8], levelDBValue{seq: trailer >> 8, value: value, deleted: trailer&0xff == 0})
+			return nil
+		})
+	})
+}
+
+// tableBlock reads the block a handle (offset and size varints) points at,
+// decompressing it if needed.
+func tableBlock(data, handle []byte) ([]byte, error) {
+	offset, n := binary.Uvarint(handle)
+	size, m := binary.Uvarint(handle[max(n, 0):])
+	if n <= 0 || m <= 0 || offset+size+5 > uint64(len(data)) {
+		return nil, errBadLevelDB
+	}
+	block := data[offset : offset+size]
+	switch data[offset+size] {
+	case 0:
+		return block, nil
+	case 1:
+		return snappyDecode(block)
+	}
+	return nil, errors.New("unsupported leveldb compression")
+}
+
+// blockEntries calls fn with each key and value of a block, undoing the
+// prefix compression of the keys.
+func blockEntries(block []byte, fn func(key, value []byte) error) error {
+	if len(block) < 4 {
+		return errBadLevelDB
+	}
+	restarts := int(binary.LittleEndian.Uint32(block[len(block)-4:]))
+	end := len(block) - 4 - 4*restarts
+	if end < 0 {
+		return errBadLevelDB
+	}
+	var key []byte
+	for pos := 0; pos < end; {
+		shared, n1 := binary.Uvarint(block[pos:end])
+		unshared, n2 := binary.Uvarint(block[pos+max(n1, 0) : end])
+		valueLen, n3 := binary.Uvarint(block[pos+max(n1, 0)+max(n2, 0) : end])
+		if n1 <= 0 || n2 <= 0 || n3 <= 0 {
+			return errBadLevelDB
+		}
+		pos += n1 + n2 + n3
+		if shared > uint64(len(key)) || uint64(pos)+unshared+valueLen > uint64(end) {
+			return errBadLevelDB
+		}
+		key = append(key[:shared], block[pos:pos+int(unshared)]...)
+		pos += int(unshared)
+		value := block[pos : pos+int(valueLen)]
+		pos += int(valueLen)
+		if err := fn(append([]byte(nil), key...), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSlice reads a varint-length-prefixed byte slice.
+func readSlice(b []byte) (slice, rest []byte, ok bool) {
+	n, k := binary.Uvarint(b)
+	if k <= 0 || uint64(len(b)-k) < n {
+		return nil, nil, false
+	}
+	return b[k : k+int(n)], b[k+int(n):], true
+}
+
+// snappyDecode decompresses a raw (unframed) snappy block.
+func snappyDecode(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 1<<30 {
+		return nil, errBadLevelDB
+	}
+	dst := make([]byte, 0, length)
+	for s := src[n:]; len(s) > 0; {
+		tag := s[0]
+		var lit, copyLen, offset int
+		switch tag & 3 {
+		case 0: // literal
+			lit = int(tag>>2) + 1
+			s = s[1:]
+			if lit > 60 {
+				extra := lit - 60
+				if len(s) < extra {
+					return nil, errBadLevelDB
+				}
+				lit = 0
+				for i := extra - 1; i >= 0; i-- {
+					lit = lit<<8 | int(s[i])
+				}
+				lit++
+				s = s[extra:]
+			}
+			if len(s) < lit {
+				return nil, errBadLevelDB
+			}
+			dst = append(dst, s[:lit]...)
+			s = s[lit:]
+			continue
+		case 1:
+			if len(s) < 2 {
+				return nil, errBadLevelDB
+			}
+			copyLen, offset = 4+int(tag>>2&7), int(tag>>5)<<8|int(s[1])
+			s = s[2:]
+		case 2:
+			if len(s) < 3 {
+				return nil, errBadLevelDB
+			}
+			copyLen, offset = int(tag>>2)+1, int(binary.LittleEndian.Uint16(s[1:]))
+			s = s[3:]
+		case 3:
+			if len(s) < 5 {
+				return nil, errBadLevelDB
+			}
+			copyLen, offset = int(tag>>2)+1, int(binary.LittleEndian.Uint32(s[1:]))
+			s = s[5:]
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, errBadLevelDB
+		}
+		// Copies may overlap their own output, so go byte by byte.
+		for i := 0; i < copyLen; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != length {
+		return nil, errBadLevelDB
+	}
+	return dst, nil
+}
@@ -0,0 +1,315 @@
+// livesearch.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// liveResults is how many hits live search shows under the prompt.
+const liveResults = 10
+
+// liveFrame is a list live search can go back to from the related bookmarks
+// of one of its hits.
+type liveFrame struct {
+	hits     []Bookmark
+	ranker   *searchRanker
+	selected int
+	name     string // of the hit whose relations replaced the list
+}
+
+// =============================================================================
+// == ⚡ LIVE SEARCH
+// =============================================================================
+
+// liveSearch runs the REPL's "/" mode: every key refines the results shown
+// under the prompt, arrows move the selection, Tab marks it, Enter opens it
+// (or offers actions for the marked ones) and Esc leaves. Right lists the
+// selected hit's related bookmarks instead, Left goes back. initial is
+// whatever was typed after the "/".
+func (s *AppState) liveSearch(initial string) {
+	restore, err := rawTerminal()
+	if err != nil {
+		fmt.Printf("Live search needs a terminal (%v); use search <query> instead.\n", err)
+		return
+	}
+	query := []rune(initial)
+	selected := 0
+	hits, ranker := s.liveMatches(string(query))
+	marked := map[string]bool{}
+	var trail []liveFrame
+	buf := make([]byte, 256)
+	for {
+		selected = max(0, min(selected, len(hits)-1))
+		relatedTo := ""
+		if len(trail) > 0 {
+			relatedTo = trail[len(trail)-1].name
+		}
+		s.drawLiveSearch(string(query), relatedTo, hits, ranker, selected, marked)
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			break
+		}
+		// A read can hold several keys when typing fast or pasting.
+		for input := buf[:n]; len(input) > 0; {
+			var key []byte
+			key, input = nextKey(input)
+			switch string(key) {
+			case "\r", "\n":
+				restore()
+				fmt.Print("\r\x1b[J")
+				if len(marked) > 0 {
+					s.bulkAction(s.markedBookmarks(marked))
+				} else if len(hits) > 0 {
+					s.handleCommand(fmt.Sprintf("open %d", hits[selected].ID))
+				}
+				return
+			case "\x03", "\x04", "\x1b":
+				// Ctrl-C, Ctrl-D or a lone Esc.
+				restore()
+				fmt.Print("\r\x1b[J")
+				return
+			case "\x1b[A", "\x1bOA":
+				selected = max(0, selected-1)
+			case "\x1b[B", "\x1bOB":
+				selected = min(len(hits)-1, selected+1)
+			case "\x1b[C", "\x1bOC":
+				if len(hits) == 0 {
+					break
+				}
+				if related := s.relatedBookmarks(hits[selected]); len(related) > 0 {
+					trail = append(trail, liveFrame{hits, ranker, selected, hits[selected].Name})
+					hits, ranker, selected = related, nil, 0
+				}
+			case "\x1b[D", "\x1bOD":
+				if n := len(trail); n > 0 {
+					f := trail[n-1]
+					trail = trail[:n-1]
+					hits, ranker, selected = f.hits, f.ranker, f.selected
+				}
+			case "\t":
+				if len(hits) > 0 {
+					uuid := hits[selected].UUID
+					if marked[uuid] {
+						delete(marked, uuid)
+					} else {
+						marked[uuid] = true
+					}
+					selected = min(len(hits)-1, selected+1)
+				}
+			case "\x7f", "\b":
+				if len(query) > 0 {
+					query = query[:len(query)-1]
+					hits, ranker = s.liveMatches(string(query))
+					selected, trail = 0, nil
+				}
+			case "\x15":
+				// Ctrl-U clears the query, as in a shell.
+				query, hits, ranker, selected, trail = nil, nil, nil, 0, nil
+			default:
+				// Other escape sequences (left, right, function keys) and
+				// control characters are ignored.
+				if r, _ := utf8.DecodeRune(key); unicode.IsPrint(r) {
+					query = append(query, r)
+					hits, ranker = s.liveMatches(string(query))
+					selected, trail = 0, nil
+				}
+			}
+		}
+	}
+	restore()
+	fmt.Print("\r\x1b[J")
+}
+
+// nextKey splits the first key off terminal input: an escape sequence, a
+// control character or one UTF-8 character.
+func nextKey(input []byte) (key, rest []byte) {
+	if input[0] == 0x1b && len(input) > 2 && (input[1] == '[' || input[1] == 'O') {
+		// CSI and SS3 sequences end with a byte from @ to ~.
+		for i := 2; i < len(input); i++ {
+			if input[i] >= 0x40 && input[i] <= 0x7e {
+				return input[:i+1], input[i+1:]
+			}
+		}
+		return input, nil
+	}
+	if input[0] < utf8.RuneSelf {
+		return input[:1], input[1:]
+	}
+	_, size := utf8.DecodeRune(input)
+	return input[:size], input[size:]
+}
+
+// liveMatches returns the bookmarks matching query, best first, and the
+// ranker that ordered them. A query that does not parse yet (an open quote or
+// parenthesis) matches nothing.
+func (s *AppState) liveMatches(query string) ([]Bookmark, *searchRanker) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil
+	}
+	q, err := parseSearchQuery(query, netConfig.SearchCase)
+	if err != nil {
+		return nil, nil
+	}
+	now := time.Now()
+	hits := slices.DeleteFunc(s.filterBookmarks(bookmarkFilter{query: q, text: query}), func(b Bookmark) bool {
+		return !b.visible(query, now)
+	})
+	ranker := newSearchRanker(query)
+	if ranker != nil {
+		ranker.rank(hits)
+	}
+	return hits, ranker
+}
+
+// drawLiveSearch redraws the prompt and the first results below it, then
+// puts the cursor back at the end of the prompt. relatedTo names the
+// bookmark whose related bookmarks the results are, if they are.
+func (s *AppState) drawLiveSearch(query, relatedTo string, hits []Bookmark, ranker *searchRanker, selected int, marked map[string]bool) {
+	var out strings.Builder
+	out.WriteString("\r\x1b[J" + style(Bold+Cyan) + "/" + style(Reset) + query)
+	lines := 0
+	if relatedTo != "" {
+		fmt.Fprintf(&out, "\r\n  %sRelated to '%s'; Left goes back%s", style(Gray), relatedTo, style(Reset))
+		lines++
+	}
+	for i, b := range hits {
+		if i == liveResults {
+			fmt.Fprintf(&out, "\r\n  %s… %d more%s", style(Gray), len(hits)-liveResults, style(Reset))
+			lines++
+			break
+		}
+		name, link := b.Name, displayURL(b.URL)
+		if ranker != nil && !plainOutput {
+			name, link = ranker.highlight(name, ""), ranker.highlight(link, Gray)
+		}
+		marker := " "
+		if i == selected {
+			marker = style(Bold+Yellow) + ">" + style(Reset)
+		}
+		if marked[b.UUID] {
+			marker += style(Bold+Magenta) + "*" + style(Reset)
+		} else {
+			marker += " "
+		}
+		marker += " "
+		fmt.Fprintf(&out, "\r\n%s%s[%d]%s %s%s - %s%s%s", marker, style(Bold+Cyan), b.ID, style(Reset), s.bookmarkIcon(b), name, style(Gray), link, style(Reset))
+		if i == selected {
+			if n := len(s.relatedBookmarks(b)); n > 0 {
+				fmt.Fprintf(&out, "  %s%d related%s%s", style(Gray), n, decor(" →"), style(Reset))
+			}
+		}
+		lines++
+	}
+	if query != "" && len(hits) == 0 {
+		out.WriteString("\r\n  No bookmarks found.")
+		lines++
+	}
+	if len(marked) > 0 {
+		fmt.Fprintf(&out, "\r\n  %s%d marked; Enter for actions%s", style(Magenta), len(marked), style(Reset))
+		lines++
+	}
+	if lines > 0 {
+		fmt.Fprintf(&out, "\x1b[%dA", lines)
+	}
+	fmt.Fprintf(&out, "\r\x1b[%dC", utf8.RuneCountInString(query)+1)
+	fmt.Print(out.String())
+}
+
+// rawTerminal switches the terminal to reading key by key without echo and
+// returns the function that switches it back.
+func rawTerminal() (func(), error) {
+	if runtime.GOOS == "windows" {
+		return nil, errors.New("not supported on Windows")
+	}
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil, errors.New("stdin is not a terminal")
+	}
+	stty := func(args ...string) ([]byte, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = os.Stdin
+		return cmd.Output()
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("stty: %w", err)
+	}
+	if _, err := stty("-icanon", "-echo", "-isig", "min", "1", "time", "0"); err != nil {
+		return nil, fmt.Errorf("stty: %w", err)
+	}
+	restored := false
+	return func() {
+		if !restored {
+			stty(strings.TrimSpace(string(saved)))
+			restored = true
+		}
+	}, nil
+}
+
+// markedBookmarks returns the marked bookmarks in list order.
+func (s *AppState) markedBookmarks(marked map[string]bool) []Bookmark {
+	var out []Bookmark
+	for _, b := range s.Bookmarks {
+		if marked[b.UUID] {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// bulkAction asks what to do with the bookmarks marked in live search and
+// does it to each of them.
+func (s *AppState) bulkAction(bookmarks []Bookmark) {
+	fmt.Printf("%d bookmarks marked.\n", len(bookmarks))
+	switch action := askKey("[o]pen, [t]ag, [u]ntag, [d]elete, [e]xport or [q]uit? "); action {
+	case "o":
+		for _, b := range bookmarks {
+			s.handleCommand(fmt.Sprintf("open %d", b.ID))
+		}
+	case "t", "u":
+		tags := strings.Fields(askLine("Tags: "))
+		if len(tags) == 0 {
+			return
+		}
+		for _, b := range bookmarks {
+			i, err := s.lookupBookmark(b.UUID)
+			if err != nil {
+				continue
+			}
+			s.editBookmark(i, func(b *Bookmark) {
+				if action == "t" {
+					b.addTags(tags...)
+				} else {
+					b.removeTags(tags...)
+				}
+			})
+		}
+		fmt.Printf("Updated the tags of %d bookmarks.\n", len(bookmarks))
+	case "d":
+		if askKey(fmt.Sprintf("Delete %d bookmarks? [y/n] ", len(bookmarks))) != "y" {
+			return
+		}
+		for _, b := range bookmarks {
+			if i, err := s.lookupBookmark(b.UUID); err == nil {
+				s.removeBookmark(i)
+			}
+		}
+		fmt.Printf("Deleted %d bookmarks.\n", len(bookmarks))
+	case "e":
+		format, out, _ := strings.Cut(askLine(fmt.Sprintf("Format (%s) and file, e.g. \"md picks.md\": ", strings.Join(exportFormats(), ", "))), " ")
+		if format == "" {
+			return
+		}
+		if err := exportTo(format, strings.TrimSpace(out), bookmarks); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// localfile.go
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// == 📁 LOCAL FILE BOOKMARKS
+// =============================================================================
+
+// normalizeBookmarkURL turns plain local paths (/x, ~/x, ./x, C:\x) into
+// absolute file:// URLs and leaves everything else alone.
+func normalizeBookmarkURL(arg string) string {
+	if !looksLikePath(arg) {
+		return arg
+	}
+	path := expandHome(arg)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // C:/x becomes file:///C:/x
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+func looksLikePath(arg string) bool {
+	if strings.HasPrefix(arg, "/") || strings.HasPrefix(arg, "~") || strings.HasPrefix(arg, "./") || strings.HasPrefix(arg, "../") || arg == "." {
+		return true
+	}
+	// Windows drive paths: C:\ or C:/
+	return runtime.GOOS == "windows" && len(arg) >= 3 && arg[1] == ':' && (arg[2] == '\\' || arg[2] == '/')
+}
+
+// localPath returns the filesystem path of a file:// URL.
+func localPath(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Scheme, "file") {
+		return "", false
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path), true
+}
+
+// fileMissing reports whether b is a local file bookmark whose target is gone.
+func (b Bookmark) fileMissing() bool {
+	path, ok := localPath(b.URL)
+	if !ok {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err != nil
+}
+
+// checkLocal checks that a local file bookmark still exists.
+func checkLocal(path string) *LinkCheck {
+	check := &LinkCheck{CheckedAt: time.Now()}
+	if _, err := os.Stat(path); err != nil {
+		check.Error = err.Error()
+	}
+	return check
+}
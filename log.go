@@ -0,0 +1,150 @@
+// log.go
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// LogConfig is how status messages are logged.
+type LogConfig struct {
+	// Level is debug, info (the default), warn or error.
+	Level string `json:"level,omitempty"`
+	// Format is text (the default) or json; File is where daemon and serve
+	// log, rather than to the terminal. Both only apply to those modes: the
+	// REPL keeps its plain messages.
+	Format string `json:"format,omitempty"`
+	File   string `json:"file,omitempty"`
+}
+
+// console is the handler of the REPL and one-shot commands.
+var console = &consoleHandler{w: os.Stderr, level: new(slog.LevelVar), mu: new(sync.Mutex)}
+
+// logger logs status messages: things that happened along the way, as
+// opposed to the output of a command.
+var logger = slog.New(console)
+
+// logFile is the file daemon or serve logs to, if any.
+var logFile *os.File
+
+// =============================================================================
+// == 📋 LOGGING
+// =============================================================================
+//
+// In the REPL and one-shot commands, messages read as they always have:
+// warnings start with "Notice:", errors with "Error:", and attributes follow
+// the message. daemon and serve log with timestamps and levels, as text or
+// JSON, to the terminal or a file.
+
+// setupLogging applies the config: the level, and for daemon and serve
+// (service set), the format and file. Without service, it goes back to the
+// console.
+func setupLogging(c LogConfig, service bool) error {
+	var level slog.Level
+	var levelErr error
+	if c.Level != "" {
+		if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+			level, levelErr = slog.LevelInfo, fmt.Errorf("invalid log.level %q (have debug, info, warn, error)", c.Level)
+		}
+	}
+	// --verbose and --quiet win over the config.
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet {
+		level = slog.LevelError
+	}
+	if c.Format != "" && c.Format != "text" && c.Format != "json" {
+		return fmt.Errorf("invalid log.format %q (have text, json)", c.Format)
+	}
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+	progressOff = service
+	if !service {
+		console.level.Set(level)
+		logger = slog.New(console)
+		return levelErr
+	}
+	out := io.Writer(os.Stdout)
+	if c.File != "" {
+		f, err := os.OpenFile(c.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("could not open log file: %w", err)
+		}
+		out, logFile = f, f
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	if c.Format == "json" {
+		logger = slog.New(slog.NewJSONHandler(out, opts))
+	} else {
+		logger = slog.New(slog.NewTextHandler(out, opts))
+	}
+	return levelErr
+}
+
+// consoleHandler writes records for a person at a terminal: "Notice: could
+// not save (file=meta.json): permission denied".
+type consoleHandler struct {
+	w     io.Writer
+	level *slog.LevelVar
+	attrs []slog.Attr
+	mu    *sync.Mutex
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, l slog.Level) bool {
+	return l >= h.level.Level()
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("Error: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("Notice: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("Debug: ")
+	}
+	b.WriteString(r.Message)
+	var details []string
+	var errText string
+	add := func(a slog.Attr) bool {
+		if a.Key == "err" {
+			errText = a.Value.String()
+		} else {
+			details = append(details, a.Key+"="+a.Value.String())
+		}
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+	if len(details) > 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+	if errText != "" {
+		b.WriteString(": " + errText)
+	}
+	b.WriteString("\n")
+	clearProgress()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.w, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(c.attrs[:len(c.attrs):len(c.attrs)], attrs...)
+	return &c
+}
+
+// WithGroup is a no-op: nothing here logs groups.
+func (h *consoleHandler) WithGroup(string) slog.Handler { return h }
@@ -3,50 +3,303 @@ package main
 
 import (
 	"bufio"
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
-	"os/exec"
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
 	bookmarksFile = "bookmarks.json"
+	// bulkDeleteConfirm is how many bookmarks delete --source and --query
+	// remove without asking first.
+	bulkDeleteConfirm = 10
 
 	// ANSI escape codes for styling
-	Reset  = "\x1b[0m"
-	Bold   = "\x1b[1m"
-	Yellow = "\x1b[33m"
-	Cyan   = "\x1b[36m"
-	Blue   = "\x1b[34m"
-	Gray   = "\x1b[90m" // ADDED: Color for the raw URL text
+	Reset   = "\x1b[0m"
+	Bold    = "\x1b[1m"
+	Yellow  = "\x1b[33m"
+	Cyan    = "\x1b[36m"
+	Blue    = "\x1b[34m"
+	Magenta = "\x1b[35m" // search matches
+	Gray    = "\x1b[90m" // ADDED: Color for the raw URL text
 )
 
+// interactive is true while the REPL runs, false for one-shot commands.
+var interactive bool
+
+// stdin reads the REPL's command lines and the answers to prompts.
+var stdin = bufio.NewScanner(os.Stdin)
+
+// askKey prints prompt and returns the first letter of the answer, lowercased;
+// "" on end of input.
+func askKey(prompt string) string {
+	fmt.Print(prompt)
+	if !stdin.Scan() {
+		fmt.Println()
+		return ""
+	}
+	answer := strings.ToLower(strings.TrimSpace(stdin.Text()))
+	if answer == "" {
+		return " "
+	}
+	return answer[:1]
+}
+
+// askLine prints prompt and returns the answer, trimmed; "" on end of input.
+func askLine(prompt string) string {
+	fmt.Print(prompt)
+	if !stdin.Scan() {
+		fmt.Println()
+		return ""
+	}
+	return strings.TrimSpace(stdin.Text())
+}
+
+// exitStatus is the process exit code for one-shot commands; commands whose
+// answer is yes/no (like count) set it so shell scripts can branch on it.
+var exitStatus int
+
+// plainOutput is set by --plain: no ANSI sequences, no decorative Unicode and
+// predictable "ID: name: url" lines, for screen readers and dumb terminals.
+var plainOutput bool
+
+// verbose (--verbose) shows debug messages: HTTP requests, import decisions;
+// quiet (--quiet) shows only errors and the output of commands.
+var verbose, quiet bool
+
+// style returns the given ANSI codes, or nothing in plain mode.
+func style(codes string) string {
+	if plainOutput {
+		return ""
+	}
+	return codes
+}
+
+// decor returns a decorative prefix (emoji, symbols), or nothing in plain mode.
+func decor(s string) string {
+	if plainOutput {
+		return ""
+	}
+	return s
+}
+
 // =============================================================================
 // == 📂 DATA STRUCTURES
 // =============================================================================
 type Bookmark struct {
-	ID       int    `json:"id"`
-	Name     string `json:"name"`
+	ID   int    `json:"id"`
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+	// Alias is a short name for jump, mostly for directory bookmarks.
+	Alias string `json:"alias,omitempty"`
+	// App is the application that opens this bookmark (see open.go).
+	App string `json:"app,omitempty"`
+	// Icon is an emoji shown before the name in list, in place of the type's.
+	Icon     string `json:"icon,omitempty"`
 	URL      string `json:"url"`
 	Favorite bool   `json:"favorite"`
+	Read     bool   `json:"read,omitempty"`
+	// Tor routes this bookmark's fetches through Tor (see tor.go).
+	Tor     bool      `json:"tor,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	AddedAt time.Time `json:"added_at,omitzero"`
+	// Description and Image come from the page's og:description and og:image.
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	// Type is what the URL points to: article, video, pdf, repository, image or audio.
+	Type string `json:"type,omitempty"`
+	// Lang is the page's language ("fr"), found when its content is fetched.
+	Lang string `json:"lang,omitempty"`
+	// Meta holds custom fields of the user's own (see meta.go).
+	Meta map[string]string `json:"meta,omitempty"`
+	// Related holds the UUIDs of the bookmarks linked with relate (see relate.go).
+	Related []string `json:"related,omitempty"`
+	// OpenCount counts every open; Opens keeps the most recent timestamps.
+	OpenCount int          `json:"open_count,omitempty"`
+	Opens     []time.Time  `json:"opens,omitempty"`
+	Source    *Source      `json:"source,omitempty"`
+	Check     *LinkCheck   `json:"check,omitempty"`
+	Archive   *ArchiveInfo `json:"archive,omitempty"`
+	// ShelvedAt is when the bookmark was moved to the shelf, out of the
+	// default listings (see stale.go).
+	ShelvedAt time.Time `json:"shelved_at,omitzero"`
+	// ExpiresAt is when the bookmark stops being of use; TrashedAt is when it
+	// went to the trash (see expiry.go).
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	TrashedAt time.Time `json:"trashed_at,omitzero"`
+	// RemindAt is when to revisit the bookmark; Reminded is set once the
+	// daemon has notified about it (see remind.go).
+	RemindAt time.Time `json:"remind_at,omitzero"`
+	Reminded bool      `json:"reminded,omitempty"`
+	// SnoozedUntil hides the bookmark from listings until then; it stays set
+	// until the bookmark is next opened, to flag it (see snooze.go).
+	SnoozedUntil time.Time `json:"snoozed_until,omitzero"`
+	// Clock is when each edited field last changed, for merges (see merge.go).
+	Clock map[string]time.Time `json:"clock,omitempty"`
+}
+
+// Source records where an imported bookmark came from and when.
+type Source struct {
+	Browser    string    `json:"browser"`
+	Profile    string    `json:"profile,omitempty"`
+	Path       string    `json:"path"`
+	ImportedAt time.Time `json:"imported_at"`
 }
 type Config struct {
+	// DataDir is where bookmarks.json and the files next to it are; unset,
+	// the current directory.
+	DataDir           string `json:"data_dir,omitempty"`
 	DefaultBrowserCmd string `json:"default_browser_cmd"`
+	// TerminalCmd opens ssh:// bookmarks in a new window, e.g. "alacritty -e";
+	// empty runs ssh in the current terminal.
+	TerminalCmd string `json:"terminal_cmd,omitempty"`
+	// Handlers maps a URL scheme to how to open it (see open.go), e.g.
+	// "magnet": "transmission-remote -a {url}".
+	Handlers map[string]string `json:"handlers,omitempty"`
+	// TagApps maps a tag to the application that opens bookmarks carrying it,
+	// e.g. "work": "Firefox Developer Edition".
+	TagApps map[string]string `json:"tag_apps,omitempty"`
+	// TagIcons maps a tag to the icon of the bookmarks carrying it that have
+	// none of their own, e.g. "papers": "📄".
+	TagIcons map[string]string `json:"tag_icons,omitempty"`
+	// Hyperlinks is auto (detect OSC 8 support), always or never; never is
+	// like always running list links.
+	Hyperlinks string `json:"hyperlinks,omitempty"`
+	// Plain makes --plain the default: no colors or decorative symbols.
+	Plain bool `json:"plain,omitempty"`
+	// MatchAccents makes queries tell accented letters from plain ones; by
+	// default "electronique" finds "Électronique".
+	MatchAccents bool `json:"match_accents,omitempty"`
+	// SearchCase is how search, list, count and live search match case:
+	// ignore (the default), smart (ignore unless the word has capitals) or
+	// sensitive. Other commands taking a query always ignore case.
+	SearchCase string `json:"search_case,omitempty"`
+	// Locale decides how names sort, e.g. "fr" or "sv"; unset, the locale of
+	// the environment (see collation.go).
+	Locale string `json:"locale,omitempty"`
+	// NameSort is natural (the default: "2" before "10") or lexical (the
+	// lowercased names byte by byte, whatever the locale: "10" before "2").
+	NameSort string `json:"name_sort,omitempty"`
+	// SafeSave is auto (careful saves in cloud-synced folders), always or
+	// never; see safesave.go.
+	SafeSave string `json:"safe_save,omitempty"`
+	// CheckOnAdd makes every add behave like add --check.
+	CheckOnAdd bool `json:"check_on_add,omitempty"`
+	// Hooks maps an event (add, delete, edit, open, save, import) to shell commands.
+	Hooks    map[string][]string `json:"hooks,omitempty"`
+	Webhooks []Webhook           `json:"webhooks,omitempty"`
+	// Notifications enables desktop notifications for background work.
+	Notifications bool `json:"notifications,omitempty"`
+	// Jobs maps a daemon job (check, refresh-titles, import, backup, archive,
+	// stale, expire, remind) to a cron-like schedule such as "0 3 * * *" or
+	// "@every 6h".
+	Jobs    map[string]string `json:"jobs,omitempty"`
+	Archive ArchiveConfig     `json:"archive,omitzero"`
+	// Frecency tunes the ranking used by suggest and list --score.
+	Frecency FrecencyConfig `json:"frecency,omitzero"`
+	// Proxy routes every network request, e.g. "http://proxy:3128" or
+	// "socks5://127.0.0.1:1080"; "direct" ignores the proxy environment
+	// variables. Unset, HTTPS_PROXY, HTTP_PROXY and ALL_PROXY apply.
+	Proxy string    `json:"proxy,omitempty"`
+	Tor   TorConfig `json:"tor,omitzero"`
+	// UserAgent and Headers (e.g. "Accept-Language": "fr, en") are sent with
+	// every request.
+	UserAgent string            `json:"user_agent,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	// Auth maps a domain (subdomains included) to the cookies and headers
+	// that log in to it, for fetching and snapshotting pages behind a login.
+	Auth map[string]DomainAuth `json:"auth,omitempty"`
+	// Concurrency bounds parallel network requests (default 8).
+	Concurrency int `json:"concurrency,omitempty"`
+	// PluginDir is searched for bibliothermes-<name> executables before PATH.
+	PluginDir string `json:"plugin_dir,omitempty"`
+	// EnrichOnImport fetches og:description and og:image for newly imported
+	// bookmarks in the background.
+	EnrichOnImport bool `json:"enrich_on_import,omitempty"`
+	// ImportConflicts settles imported bookmarks whose URL is already here
+	// with another title or tags: ask (at a terminal; keep-mine elsewhere),
+	// keep-mine, take-theirs or merge (see conflicts.go).
+	ImportConflicts string `json:"import_conflicts,omitempty"`
+	// MirrorImports names the browsers, or profiles such as "Firefox (work)",
+	// whose deletions imports carry over (see mirror.go).
+	MirrorImports []string `json:"mirror_imports,omitempty"`
+	// MirrorAction is what imports away from a terminal do with mirrored
+	// deletions: keep (the default, only reporting them), trash or shelve.
+	MirrorAction string `json:"mirror_action,omitempty"`
+	// PinboardToken is the API token push pinboard uses; PINBOARD_TOKEN wins.
+	PinboardToken string `json:"pinboard_token,omitempty"`
+	// SmartFolders maps a name to a saved query (see query.go).
+	SmartFolders map[string]string `json:"smart_folders,omitempty"`
+	// Compress gzips bookmarks.json and snapshots (see compress.go).
+	Compress bool `json:"compress,omitempty"`
+	// Stale decides which bookmarks the stale command shelves.
+	Stale StaleConfig `json:"stale,omitzero"`
+	// SMTP sends share --email mail directly (see share.go).
+	SMTP SMTPConfig `json:"smtp,omitzero"`
+	// Publish lists the gists and branches publish keeps up to date.
+	Publish []PublishTarget `json:"publish,omitempty"`
+	// Backup uploads backups to S3-compatible storage (see s3.go).
+	Backup BackupConfig `json:"backup,omitzero"`
+	// Log sets the level of status messages, and how daemon and serve log.
+	Log LogConfig `json:"log,omitzero"`
+	// Server is how serve listens; Sync is the server sync talks to.
+	Server ServerConfig `json:"server,omitzero"`
+	Sync   SyncConfig   `json:"sync,omitzero"`
+	// Profiles maps a name to settings that replace these ones under
+	// --profile <name> (see config.go).
+	Profiles map[string]map[string]any `json:"profiles,omitempty"`
 }
 type AppState struct {
 	Bookmarks []Bookmark `json:"bookmarks"`
-	Config    Config     `json:"config"`
-	nextID    int
+	// Config is kept in its own file (see config.go).
+	Config Config `json:"-"`
+	// Tombstones maps the UUIDs of deleted bookmarks to when they were
+	// deleted, so merges don't bring them back (see merge.go).
+	Tombstones map[string]time.Time `json:"tombstones,omitempty"`
+	// SyncSeq numbers the changes a sync server has seen; SyncLog has the
+	// number of each bookmark's last change (see sync.go).
+	SyncSeq int64               `json:"sync_seq,omitempty"`
+	SyncLog map[string]syncMark `json:"sync_log,omitempty"`
+	// Collections are ordered lists of bookmarks, by name (see collection.go).
+	Collections map[string]Collection `json:"collections,omitempty"`
+	// ImportedUpTo has, by places.sqlite path, the newest dateAdded or
+	// lastModified of the bookmarks imported from it, so the next import
+	// reads only those added or changed since.
+	ImportedUpTo map[string]int64 `json:"imported_up_to,omitempty"`
+	// PinboardPushed has when each bookmark was pushed to Pinboard, by UUID
+	// (see pinboard.go).
+	PinboardPushed map[string]time.Time `json:"pinboard_pushed,omitempty"`
+	nextID         int
+	// index speeds up lookups (see store.go); savedHash is the hash of
+	// bookmarks.json as last read or written.
+	index     *bookmarkIndex
+	savedHash uint64
+	// importPolicy settles the rest of an import's conflicts once asked to
+	// (see conflicts.go); importStats counts what the import finds (see
+	// importreport.go).
+	importPolicy string
+	importStats  *importReport
+	// reload is set, to loadState, while a daemon job runs on s with
+	// freshStateMu held (see whileUnlocked).
+	reload func() (*AppState, error)
 }
 
 // =============================================================================
@@ -57,48 +310,369 @@ func (s *AppState) saveState() error {
 	if err != nil {
 		return fmt.Errorf("could not marshal state: %w", err)
 	}
-	return os.WriteFile(bookmarksFile, data, 0644)
+	// Commands that only read (list, search, show) leave the file alone.
+	hash := contentHash(data)
+	if hash == s.savedHash {
+		return clearJournal()
+	}
+	if s.Config.Compress {
+		if data, err = gzipBytes(data); err != nil {
+			return fmt.Errorf("could not compress state: %w", err)
+		}
+	}
+	if s.useSafeSave() {
+		if err := writeFileSafely(dataPath(bookmarksFile), data); err != nil {
+			return err
+		}
+	} else {
+		// Write a new file and swap it in, so a crash mid-write cannot leave
+		// bookmarks.json half written.
+		tmp := dataPath(bookmarksFile + ".tmp")
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, dataPath(bookmarksFile)); err != nil {
+			return err
+		}
+	}
+	s.savedHash = hash
+	if err := clearJournal(); err != nil {
+		logger.Warn("could not clear", "file", journalFile, "err", err)
+	}
+	if err := s.syncIndex(); err != nil {
+		logger.Warn("could not update", "file", indexFile, "err", err)
+	}
+	s.emit(eventSave, map[string]any{"file": bookmarksFile, "count": len(s.Bookmarks)})
+	return nil
+}
+
+// unsaved reports whether s differs from the data file as last read or
+// written.
+func (s *AppState) unsaved() bool {
+	data, err := json.MarshalIndent(s, "", "  ")
+	return err != nil || contentHash(data) != s.savedHash
 }
+
 func loadState() (*AppState, error) {
 	state := &AppState{nextID: 1}
-	data, err := os.ReadFile(bookmarksFile)
+	data, err := os.ReadFile(dataPath(bookmarksFile))
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Println("No 'bookmarks.json' found. Creating a new one.")
-			switch runtime.GOOS {
-			case "darwin":
-				state.Config.DefaultBrowserCmd = "open"
-			case "linux":
-				state.Config.DefaultBrowserCmd = "xdg-open"
-			case "windows":
-				state.Config.DefaultBrowserCmd = "cmd /c start"
+			logger.Info("no data file yet; creating a new one", "file", bookmarksFile)
+			if err := state.loadConfig(nil); err != nil {
+				return nil, err
 			}
 			return state, state.saveState()
 		}
 		return nil, fmt.Errorf("could not read %s: %w", bookmarksFile, err)
 	}
+	if data, err = gunzipIfNeeded(data); err != nil {
+		return nil, fmt.Errorf("could not decompress %s: %w", bookmarksFile, err)
+	}
+	recovered := false
+	state.savedHash = contentHash(data)
+	// Data files written before the config file existed hold the config.
+	var legacy struct {
+		Config *Config `json:"config"`
+	}
 	if err := json.Unmarshal(data, &state); err != nil {
-		return nil, fmt.Errorf("could not unmarshal JSON: %w", err)
+		logger.Warn("could not parse", "file", bookmarksFile, "err", err)
+		if state, err = recoverState(data); err != nil {
+			return nil, err
+		}
+		legacy.Config = &state.Config
+		recovered = true
+	} else {
+		json.Unmarshal(data, &legacy)
+	}
+	if err := state.loadConfig(legacy.Config); err != nil {
+		return nil, err
 	}
 	if len(state.Bookmarks) > 0 {
 		maxID := 0
-		for _, b := range state.Bookmarks {
+		for i, b := range state.Bookmarks {
 			if b.ID > maxID {
 				maxID = b.ID
 			}
+			// Files written before UUIDs existed get them assigned once here.
+			if b.UUID == "" {
+				state.Bookmarks[i].UUID = newUUID()
+			}
+			if b.Type == "" {
+				state.Bookmarks[i].Type = detectType(b.URL)
+			}
 		}
 		state.nextID = maxID + 1
 	}
+	if n, err := state.replayJournal(); err != nil {
+		logger.Warn("could not replay", "file", journalFile, "err", err)
+	} else if n > 0 {
+		logger.Info("recovered unsaved changes", "file", journalFile, "count", n)
+	}
+	if recovered {
+		return state, state.saveState()
+	}
+	if _, err := os.Stat(dataPath(indexFile)); (os.IsNotExist(err) || !loadIndex().current()) && len(state.Bookmarks) > 0 {
+		if err := state.reindex(); err != nil {
+			logger.Warn("could not build", "file", indexFile, "err", err)
+		}
+	}
 	return state, nil
 }
-func (s *AppState) addBookmark(name, url string) {
-	for _, b := range s.Bookmarks {
-		if b.URL == url {
-			return
+func defaultBrowserCmd() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "linux":
+		return "xdg-open"
+	case "windows":
+		return "cmd /c start"
+	}
+	return ""
+}
+
+// addBookmark appends a new bookmark unless its URL is already present, and
+// reports whether it did. An import (src set) may update the bookmark
+// already there instead (see conflicts.go). Internationalized hosts are
+// stored in punycode.
+func (s *AppState) addBookmark(name, url string, src *Source) bool {
+	url = asciiURL(url)
+	if i := s.indexOfURL(url); i >= 0 {
+		logger.Debug("skipped: already bookmarked", "url", url, "as", s.Bookmarks[i].ID)
+		if src != nil {
+			s.settleConflict(i, Bookmark{Name: name, URL: url}, sourceLabel(src))
 		}
+		return false
 	}
-	s.Bookmarks = append(s.Bookmarks, Bookmark{ID: s.nextID, Name: name, URL: url})
+	if src != nil {
+		logger.Debug("imported", "url", url, "from", src.Browser, "path", src.Path)
+		s.countImport(sourceLabel(src), func(c *importSourceCount) { c.New++ })
+	}
+	s.Bookmarks = append(s.Bookmarks, Bookmark{ID: s.nextID, UUID: newUUID(), Name: name, URL: url, AddedAt: time.Now(), Type: detectType(url), Source: src})
+	s.appended()
 	s.nextID++
+	return true
+}
+
+var (
+	errInvalidID  = errors.New("invalid ID")
+	errIDNotFound = errors.New("ID not found")
+)
+
+// lookupBookmark resolves a short numeric ID or a UUID to an index into
+// s.Bookmarks.
+func (s *AppState) lookupBookmark(ref string) (int, error) {
+	id, err := strconv.Atoi(ref)
+	if err != nil && len(ref) != 36 {
+		return -1, errInvalidID
+	}
+	i := s.indexOfUUID(ref)
+	if err == nil && i < 0 {
+		i = s.indexOfID(id)
+	}
+	if i < 0 {
+		return -1, errIDNotFound
+	}
+	return i, nil
+}
+
+// findBookmark is lookupBookmark for REPL commands: it prints the usual
+// message when the reference can't be resolved.
+func (s *AppState) findBookmark(ref string) (int, bool) {
+	i, err := s.lookupBookmark(ref)
+	if errors.Is(err, errInvalidID) {
+		fmt.Println("Invalid ID.")
+		return -1, false
+	} else if err != nil {
+		fmt.Println("ID not found.")
+		return -1, false
+	}
+	return i, true
+}
+
+// removeBookmark deletes the bookmark at index i and runs the delete hooks.
+func (s *AppState) removeBookmark(i int) Bookmark {
+	b := s.Bookmarks[i]
+	s.Bookmarks = append(s.Bookmarks[:i], s.Bookmarks[i+1:]...)
+	s.index = nil
+	s.buryBookmark(b.UUID, time.Now())
+	s.emit(eventDelete, b)
+	return b
+}
+
+// hasTag reports whether b carries tag, ignoring case.
+func (b Bookmark) hasTag(tag string) bool {
+	for _, t := range b.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeTag lowercases a tag and drops a leading '#'.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+// addTags adds the given tags to b, skipping ones it already has.
+func (b *Bookmark) addTags(tags ...string) {
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" && !b.hasTag(t) {
+			b.Tags = append(b.Tags, t)
+		}
+	}
+}
+
+// removeTags removes the given tags from b.
+func (b *Bookmark) removeTags(tags ...string) {
+	kept := b.Tags[:0]
+	for _, t := range b.Tags {
+		drop := false
+		for _, r := range tags {
+			if strings.EqualFold(t, normalizeTag(r)) {
+				drop = true
+				break
+			}
+		}
+		if !drop {
+			kept = append(kept, t)
+		}
+	}
+	b.Tags = kept
+}
+
+// addedAt returns when b was added; bookmarks from before that was recorded
+// fall back to their import time, if any.
+func (b Bookmark) addedAt() time.Time {
+	if b.AddedAt.IsZero() && b.Source != nil {
+		return b.Source.ImportedAt
+	}
+	return b.AddedAt
+}
+
+// fromSource reports whether b was imported from the named browser.
+func (b Bookmark) fromSource(browser string) bool {
+	return b.Source != nil && strings.EqualFold(b.Source.Browser, browser)
+}
+
+// printDetails prints every field of a bookmark, one per line.
+func (b Bookmark) printDetails() {
+	field := func(label, value string) {
+		if plainOutput {
+			fmt.Printf("%s: %s\n", label, value)
+		} else {
+			fmt.Printf("%s%-10s%s %s\n", Bold+Cyan, label, Reset, value)
+		}
+	}
+	field("ID", strconv.Itoa(b.ID))
+	field("UUID", b.UUID)
+	field("Name", b.Name)
+	if b.Alias != "" {
+		field("Alias", b.Alias)
+	}
+	if b.App != "" {
+		field("App", b.App)
+	}
+	if b.Icon != "" {
+		field("Icon", b.Icon)
+	}
+	field("URL", displayURL(b.URL))
+	if reason := lookalikeReason(bookmarkHost(b.URL)); reason != "" {
+		field("Warning", "possible lookalike domain: "+reason)
+	}
+	field("Favorite", strconv.FormatBool(b.Favorite))
+	field("Read", strconv.FormatBool(b.Read))
+	if b.Tor {
+		field("Tor", "true")
+	}
+	if b.Type != "" {
+		field("Type", b.Type)
+	}
+	if b.Lang != "" {
+		field("Language", b.Lang)
+	}
+	if len(b.Tags) > 0 {
+		field("Tags", strings.Join(b.Tags, ", "))
+	}
+	for _, key := range slices.Sorted(maps.Keys(b.Meta)) {
+		field(key, b.Meta[key])
+	}
+	if added := b.addedAt(); !added.IsZero() {
+		field("Added", added.Format("2006-01-02 15:04"))
+	}
+	if b.Description != "" {
+		field("About", b.Description)
+	}
+	if b.Image != "" {
+		field("Image", b.Image)
+	}
+	if b.Archive != nil && b.Archive.WaybackURL != "" {
+		field("Wayback", b.Archive.WaybackURL+" ("+b.Archive.WaybackAt.Format("2006-01-02 15:04")+")")
+	}
+	if b.Archive != nil && b.Archive.Snapshot != "" {
+		size := "missing"
+		if info, err := os.Stat(dataPath(b.Archive.Snapshot)); err == nil {
+			size = fmt.Sprintf("%d KB", (info.Size()+1023)/1024)
+		}
+		field("Snapshot", fmt.Sprintf("%s (%s, %s)", b.Archive.Snapshot, b.Archive.SnapshotAt.Format("2006-01-02 15:04"), size))
+	}
+	if b.OpenCount > 0 {
+		field("Opened", fmt.Sprintf("%d times, last %s", b.OpenCount, b.lastOpened().Format("2006-01-02 15:04")))
+	}
+	if b.shelved() {
+		field("Shelved", b.ShelvedAt.Format("2006-01-02 15:04"))
+	}
+	if !b.ExpiresAt.IsZero() {
+		expires := b.ExpiresAt.Format("2006-01-02 15:04")
+		if b.expired(time.Now()) {
+			expires += " (expired)"
+		}
+		field("Expires", expires)
+	}
+	if b.trashed() {
+		field("Trashed", b.TrashedAt.Format("2006-01-02 15:04"))
+	}
+	if !b.RemindAt.IsZero() {
+		field("Reminder", b.RemindAt.Format("2006-01-02 15:04"))
+	}
+	if b.Check != nil {
+		status := "ok"
+		if b.Check.Error != "" {
+			status = b.Check.Error
+		} else if b.Check.Status != 0 {
+			status = strconv.Itoa(b.Check.Status)
+		}
+		if b.Check.Dead() {
+			status += " (dead)"
+		}
+		if b.Check.ContentType != "" {
+			status += ", " + b.Check.ContentType
+		}
+		field("Checked", b.Check.CheckedAt.Format("2006-01-02 15:04")+" - "+status)
+	}
+	if b.Source == nil {
+		field("Source", "added manually")
+		return
+	}
+	field("Source", b.Source.Browser)
+	if b.Source.Profile != "" {
+		field("Profile", b.Source.Profile)
+	}
+	field("File", b.Source.Path)
+	field("Imported", b.Source.ImportedAt.Format("2006-01-02 15:04"))
+}
+
+// newUUID returns a random (version 4) UUID. Numeric IDs are for typing at the
+// prompt; UUIDs are the identity that survives merges, restores and syncs.
+func newUUID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(fmt.Sprintf("could not read random bytes: %v", err))
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
 }
 
 // =============================================================================
@@ -111,136 +685,424 @@ type chromeBookmarkNode struct {
 	Children []chromeBookmarkNode `json:"children"`
 }
 
-func parseChromeBookmarks(node chromeBookmarkNode, state *AppState) {
+// importedBookmark is a bookmark read from a browser, not yet added.
+type importedBookmark struct {
+	name, url string
+}
+
+// browserFile is a browser's bookmarks file, and what reading it found.
+// Firefox files are read from the bookmarks added or changed after since;
+// upTo is the newest of those read.
+type browserFile struct {
+	browser, path string
+	firefox       bool
+	since, upTo   int64
+	found         []importedBookmark
+	err           error
+}
+
+func parseChromeBookmarks(node chromeBookmarkNode, found []importedBookmark) []importedBookmark {
 	if node.Type == "url" && node.URL != "" {
-		state.addBookmark(node.Name, node.URL)
+		found = append(found, importedBookmark{node.Name, node.URL})
 	}
 	for _, child := range node.Children {
-		parseChromeBookmarks(child, state)
+		found = parseChromeBookmarks(child, found)
+	}
+	return found
+}
+
+// newSource describes an import from the browser profile holding path.
+func newSource(browser, path string) *Source {
+	return &Source{
+		Browser:    browser,
+		Profile:    filepath.Base(filepath.Dir(path)),
+		Path:       path,
+		ImportedAt: time.Now(),
 	}
 }
-func importFromChrome(path string, state *AppState) error {
+
+// readChromeBookmarks reads the Bookmarks file of a Chromium-based browser.
+func readChromeBookmarks(path string) ([]importedBookmark, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("could not read file: %w", err)
+		return nil, fmt.Errorf("could not read file: %w", err)
 	}
 	var root struct {
 		Roots map[string]chromeBookmarkNode `json:"roots"`
 	}
 	if err := json.Unmarshal(data, &root); err != nil {
-		return fmt.Errorf("could not parse JSON: %w", err)
+		return nil, fmt.Errorf("could not parse JSON: %w", err)
 	}
-	for _, node := range root.Roots {
-		parseChromeBookmarks(node, state)
+	var found []importedBookmark
+	for _, name := range slices.Sorted(maps.Keys(root.Roots)) {
+		found = parseChromeBookmarks(root.Roots[name], found)
 	}
-	return nil
+	return found, nil
 }
-func importFromFirefox(path string, state *AppState) error {
+
+// readFirefoxBookmarks reads the bookmarks added or changed after since (a
+// dateAdded or lastModified, in microseconds) in a places.sqlite database,
+// of Firefox or one of its forks, which share the format. It returns them
+// with the newest of those dates seen. lastModified matters for bookmarks
+// arriving through Firefox Sync or a restore, which keep an older
+// dateAdded. A bar labelled with the browser shows how far along it is;
+// cancelling ctx stops it between bookmarks.
+func readFirefoxBookmarks(ctx context.Context, browser, path string, since int64) ([]importedBookmark, int64, error) {
 	immutableURI := fmt.Sprintf("file:%s?_immutable=1", path)
 	db, err := sql.Open("sqlite3", immutableURI)
 	if err != nil {
-		return fmt.Errorf("could not open firefox sqlite db: %w", err)
+		return nil, since, fmt.Errorf("could not open firefox sqlite db: %w", err)
 	}
 	defer db.Close()
-	query := `SELECT b.title, p.url FROM moz_bookmarks AS b JOIN moz_places AS p ON b.fk = p.id WHERE b.type = 1 AND b.title IS NOT NULL;`
-	rows, err := db.Query(query)
+	const from = ` FROM moz_bookmarks AS b JOIN moz_places AS p ON b.fk = p.id
+		WHERE b.type = 1 AND b.title IS NOT NULL AND (b.dateAdded > ?1 OR b.lastModified > ?1)`
+	var total int
+	db.QueryRowContext(ctx, `SELECT COUNT(*)`+from, since).Scan(&total)
+	rows, err := db.QueryContext(ctx, `SELECT b.title, p.url, b.dateAdded, IFNULL(b.lastModified, 0)`+from, since)
 	if err != nil {
-		return fmt.Errorf("could not query firefox bookmarks: %w", err)
+		return nil, since, fmt.Errorf("could not query firefox bookmarks: %w", err)
 	}
 	defer rows.Close()
+	bar := newProgress("Reading "+sourceLabel(newSource(browser, path)), total)
+	defer bar.finish()
+	var found []importedBookmark
+	upTo := since
 	for rows.Next() {
-		var title, url string
-		if err := rows.Scan(&title, &url); err == nil {
-			state.addBookmark(title, url)
+		if ctx.Err() != nil {
+			return nil, since, ctx.Err()
 		}
+		var b importedBookmark
+		var added, modified int64
+		if err := rows.Scan(&b.name, &b.url, &added, &modified); err == nil {
+			found = append(found, b)
+			upTo = max(upTo, added, modified)
+		}
+		bar.step(b.name)
 	}
-	return nil
+	return found, upTo, rows.Err()
+}
+
+// readBrowserFiles reads files in parallel, workers at a time, leaving what
+// each holds in its found field. Files not started when ctx is cancelled get
+// its error.
+func readBrowserFiles(ctx context.Context, files []*browserFile, workers int) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for _, f := range files {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			f.err = ctx.Err()
+			continue
+		}
+		wg.Add(1)
+		go func(f *browserFile) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if f.firefox {
+				f.found, f.upTo, f.err = readFirefoxBookmarks(ctx, f.browser, f.path, f.since)
+			} else {
+				f.found, f.err = readChromeBookmarks(f.path)
+			}
+		}(f)
+	}
+	wg.Wait()
 }
-func getBrowserPaths() (map[string][]string, map[string]string) {
+
+// getBrowserPaths returns the Bookmarks files of Chromium-based browsers and
+// the profile directories of Firefox and its forks, by browser. On Linux both
+// include the snap (~/snap) and flatpak (~/.var/app) locations.
+func getBrowserPaths() (map[string][]string, map[string][]string) {
 	usr, _ := user.Current()
 	homeDir := usr.HomeDir
 	chromeLikePaths := make(map[string][]string)
-	firefoxPaths := make(map[string]string)
+	firefoxPaths := make(map[string][]string)
 	switch runtime.GOOS {
 	case "darwin":
 		appSupport := filepath.Join(homeDir, "Library/Application Support")
 		chromeLikePaths["Chrome"] = []string{filepath.Join(appSupport, "Google/Chrome/Default/Bookmarks")}
 		chromeLikePaths["Brave"] = []string{filepath.Join(appSupport, "BraveSoftware/Brave-Browser/Default/Bookmarks")}
 		chromeLikePaths["Edge"] = []string{filepath.Join(appSupport, "Microsoft Edge/Default/Bookmarks")}
-		firefoxPaths["firefox_dir"] = filepath.Join(appSupport, "Firefox/Profiles")
+		firefoxPaths["Firefox"] = []string{filepath.Join(appSupport, "Firefox/Profiles")}
+		firefoxPaths["LibreWolf"] = []string{filepath.Join(appSupport, "librewolf/Profiles")}
+		firefoxPaths["Waterfox"] = []string{filepath.Join(appSupport, "Waterfox/Profiles")}
+		firefoxPaths["Zen"] = []string{filepath.Join(appSupport, "zen/Profiles")}
+		firefoxPaths["Floorp"] = []string{filepath.Join(appSupport, "Floorp/Profiles")}
 	case "linux":
 		configDir := filepath.Join(homeDir, ".config")
-		chromeLikePaths["Chrome"] = []string{filepath.Join(configDir, "google-chrome/Default/Bookmarks")}
-		chromeLikePaths["Brave"] = []string{filepath.Join(configDir, "BraveSoftware/Brave-Browser/Default/Bookmarks")}
-		firefoxPaths["firefox_dir"] = filepath.Join(homeDir, ".mozilla/firefox")
+		snap := filepath.Join(homeDir, "snap")
+		flatpak := filepath.Join(homeDir, ".var/app")
+		chromeLikePaths["Chrome"] = []string{
+			filepath.Join(configDir, "google-chrome/Default/Bookmarks"),
+			filepath.Join(flatpak, "com.google.Chrome/config/google-chrome/Default/Bookmarks"),
+		}
+		chromeLikePaths["Chromium"] = []string{
+			filepath.Join(configDir, "chromium/Default/Bookmarks"),
+			filepath.Join(snap, "chromium/common/chromium/Default/Bookmarks"),
+			filepath.Join(flatpak, "org.chromium.Chromium/config/chromium/Default/Bookmarks"),
+		}
+		chromeLikePaths["Brave"] = []string{
+			filepath.Join(configDir, "BraveSoftware/Brave-Browser/Default/Bookmarks"),
+			filepath.Join(snap, "brave/current/.config/BraveSoftware/Brave-Browser/Default/Bookmarks"),
+			filepath.Join(flatpak, "com.brave.Browser/config/BraveSoftware/Brave-Browser/Default/Bookmarks"),
+		}
+		chromeLikePaths["Edge"] = []string{
+			filepath.Join(configDir, "microsoft-edge/Default/Bookmarks"),
+			filepath.Join(flatpak, "com.microsoft.Edge/config/microsoft-edge/Default/Bookmarks"),
+		}
+		firefoxPaths["Firefox"] = []string{
+			filepath.Join(homeDir, ".mozilla/firefox"),
+			filepath.Join(snap, "firefox/common/.mozilla/firefox"),
+			filepath.Join(flatpak, "org.mozilla.firefox/.mozilla/firefox"),
+		}
+		firefoxPaths["LibreWolf"] = []string{
+			filepath.Join(homeDir, ".librewolf"),
+			filepath.Join(flatpak, "io.gitlab.librewolf-community/.librewolf"),
+		}
+		firefoxPaths["Waterfox"] = []string{
+			filepath.Join(homeDir, ".waterfox"),
+			filepath.Join(flatpak, "net.waterfox.waterfox/.waterfox"),
+		}
+		firefoxPaths["Zen"] = []string{
+			filepath.Join(homeDir, ".zen"),
+			filepath.Join(flatpak, "app.zen_browser.zen/.zen"),
+		}
+		firefoxPaths["Floorp"] = []string{
+			filepath.Join(homeDir, ".floorp"),
+			filepath.Join(flatpak, "one.ablaze.floorp/.floorp"),
+		}
 	case "windows":
 		appData := filepath.Join(homeDir, "AppData/Local")
 		chromeLikePaths["Chrome"] = []string{filepath.Join(appData, "Google/Chrome/User Data/Default/Bookmarks")}
 		chromeLikePaths["Brave"] = []string{filepath.Join(appData, "BraveSoftware/Brave-Browser/User Data/Default/Bookmarks")}
 		chromeLikePaths["Edge"] = []string{filepath.Join(appData, "Microsoft/Edge/User Data/Default/Bookmarks")}
-		firefoxPaths["firefox_dir"] = filepath.Join(homeDir, "AppData/Roaming/Mozilla/Firefox/Profiles")
+		roaming := filepath.Join(homeDir, "AppData/Roaming")
+		firefoxPaths["Firefox"] = []string{filepath.Join(roaming, "Mozilla/Firefox/Profiles")}
+		firefoxPaths["LibreWolf"] = []string{filepath.Join(roaming, "librewolf/Profiles")}
+		firefoxPaths["Waterfox"] = []string{filepath.Join(roaming, "Waterfox/Profiles")}
+		firefoxPaths["Zen"] = []string{filepath.Join(roaming, "zen/Profiles")}
+		firefoxPaths["Floorp"] = []string{filepath.Join(roaming, "Floorp/Profiles")}
 	}
 	return chromeLikePaths, firefoxPaths
 }
-func (s *AppState) importBookmarks() {
+
+// firefoxPlaces returns the places.sqlite databases of the profiles under dir.
+func firefoxPlaces(dir string) []string {
+	var places []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && d.Name() == "places.sqlite" {
+			places = append(places, path)
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return places
+}
+
+// importBookmarks scans every known browser location and returns how many new
+// bookmarks it added. The files are read in parallel, then added one by one
+// in a fixed order, so conflicts are asked about in turn. Firefox databases
+// are read from where the last import stopped, unless full is set.
+// Cancelling ctx stops the scan; what was imported until then is kept.
+func (s *AppState) importBookmarks(ctx context.Context, full bool) int {
 	chromeLikePaths, firefoxDirs := getBrowserPaths()
 	initialCount := len(s.Bookmarks)
-	foundAnyBrowser := false
-	for browser, paths := range chromeLikePaths {
-		for _, path := range paths {
+	s.beginImport()
+	var files []*browserFile
+	for _, browser := range slices.Sorted(maps.Keys(chromeLikePaths)) {
+		for _, path := range chromeLikePaths[browser] {
 			if _, err := os.Stat(path); err == nil {
-				if importErr := importFromChrome(path, s); importErr == nil {
-					fmt.Printf("Successfully checked for %s bookmarks.\n", browser)
-					foundAnyBrowser = true
-				}
+				files = append(files, &browserFile{browser: browser, path: path})
 			}
 		}
 	}
-	if firefoxDir, ok := firefoxDirs["firefox_dir"]; ok {
-		foundFirefoxDB := false
-		filepath.WalkDir(firefoxDir, func(path string, d fs.DirEntry, err error) error {
-			if err == nil && !d.IsDir() && d.Name() == "places.sqlite" {
-				foundFirefoxDB = true
-				if importErr := importFromFirefox(path, s); importErr != nil {
-					fmt.Printf("Notice: Failed to import from Firefox at %s: %v\n", path, importErr)
-				} else {
-					fmt.Println("Successfully checked for Firefox bookmarks.")
-					foundAnyBrowser = true
-				}
-				return filepath.SkipDir
+	for _, browser := range slices.Sorted(maps.Keys(firefoxDirs)) {
+		var places []string
+		for _, dir := range firefoxDirs[browser] {
+			places = append(places, firefoxPlaces(dir)...)
+		}
+		for _, path := range places {
+			f := &browserFile{browser: browser, path: path, firefox: true}
+			if !full && !s.mirrored(browser, sourceLabel(newSource(browser, path))) {
+				f.since = s.ImportedUpTo[path]
 			}
-			return nil
-		})
-		if !foundFirefoxDB {
-			fmt.Println("Notice: Could not find a Firefox 'places.sqlite' file.")
+			files = append(files, f)
+		}
+		// Forks are optional; only a missing Firefox is worth a notice.
+		if len(places) == 0 && browser == "Firefox" {
+			logger.Warn("could not find a Firefox places.sqlite file")
+		}
+	}
+	readBrowserFiles(ctx, files, s.concurrency())
+	total := 0
+	for _, f := range files {
+		total += len(f.found)
+	}
+	foundAnyBrowser := false
+	bar := newProgress("Importing", total)
+	for _, f := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		if f.err != nil {
+			logger.Warn("failed to import", "browser", f.browser, "path", f.path, "err", f.err)
+			continue
+		}
+		src := newSource(f.browser, f.path)
+		for _, b := range f.found {
+			if ctx.Err() != nil {
+				break
+			}
+			s.addBookmark(b.name, b.url, src)
+			bar.step(b.name)
+		}
+		if f.firefox && ctx.Err() == nil {
+			if s.ImportedUpTo == nil {
+				s.ImportedUpTo = map[string]int64{}
+			}
+			s.ImportedUpTo[f.path] = f.upTo
 		}
+		if label := sourceLabel(src); ctx.Err() == nil && s.mirrored(f.browser, label) {
+			s.mirrorDeletions(label, f.path, f.found)
+		}
+		logger.Info("checked for bookmarks", "browser", f.browser, "path", f.path)
+		foundAnyBrowser = true
+	}
+	bar.finish()
+	if ctx.Err() == nil && s.importReadingLists(chromeLikePaths) {
+		foundAnyBrowser = true
+	}
+	if ctx.Err() == nil && s.importAllEdgeCollections(chromeLikePaths) {
+		foundAnyBrowser = true
+	}
+	if ctx.Err() != nil {
+		fmt.Println("Import cancelled; the bookmarks found so far are kept.")
+	} else if !foundAnyBrowser {
+		s.endImport()
+		fmt.Println("Could not find any supported browser bookmarks on default paths.")
+		return 0
 	}
+	return s.finishImport(initialCount)
+}
+
+// finishImport reports the bookmarks appended since s.Bookmarks had
+// initialCount entries, with the import's summary, runs the import hooks
+// and returns how many there are.
+func (s *AppState) finishImport(initialCount int) int {
+	s.endImport()
 	newCount := len(s.Bookmarks) - initialCount
-	if newCount > 0 {
-		fmt.Printf("✅ Imported %d new bookmarks. Run 'save' to persist them.\n", newCount)
-	} else if foundAnyBrowser {
+	if newCount == 0 {
 		fmt.Println("No new bookmarks found.")
-	} else {
-		fmt.Println("Could not find any supported browser bookmarks on default paths.")
+		return 0
+	}
+	recordImport(newCount)
+	s.emit(eventImport, s.Bookmarks[initialCount:])
+	fmt.Printf("%sImported %d new bookmarks. Run 'save' to persist them.\n", decor("✅ "), newCount)
+	if s.Config.EnrichOnImport {
+		s.startEnrichment(context.Background(), s.Bookmarks[initialCount:], false)
+		logger.Info("fetching descriptions and preview images in the background")
 	}
+	return newCount
 }
 
 // =============================================================================
 // == ⚙️ REPL COMMANDS & LOGIC
 // =============================================================================
-func printHelp() {
+func (s *AppState) printHelp() {
 	// UPDATED: Added the new 'list links' command to the help text
 	fmt.Println("\n--- Bookmark Manager Help ---")
-	fmt.Println("  list              - Show bookmarks as clickable hyperlinks")
+	fmt.Println("  list              - Show bookmarks as clickable hyperlinks (if the terminal supports them)")
 	fmt.Println("  list fav          - Show only favorite bookmarks as hyperlinks")
 	fmt.Println("  list links        - Show bookmarks with visible URLs (for basic terminals)")
-	fmt.Println("  open <id>         - Open the bookmark with the given ID")
+	fmt.Println("  list source <b>   - Show only bookmarks imported from browser <b>")
+	fmt.Println("  list tag <t>      - Show only bookmarks tagged <t>")
+	fmt.Println("  list --score      - Rank bookmarks by frecency and show their scores")
+	fmt.Println("  list due          - Show the bookmarks whose reminder is due")
+	fmt.Println("  list shelf        - Show the shelved bookmarks (see stale)")
+	fmt.Println("  count [-i|-s] [query] - Print the number of matching bookmarks (exit status 1 if none)")
+	fmt.Println("  domains [query]   - List hosts by bookmark count, with dead links and last added date")
+	fmt.Println("  list tree         - Show smart folders and the bookmarks in them")
+	fmt.Println("  search [-i|-s] <query> - List bookmarks matching a query (-s: match case), e.g.")
+	fmt.Println("                      tag:go AND (domain:github.com OR domain:go.dev) NOT read:true added:>2024-06")
+	fmt.Println("  /[query]          - Live search: results narrow as you type, Enter opens, Tab marks")
+	fmt.Println("  alias <id> [alias] - Give a bookmark a short alias for jump (none: remove it)")
+	fmt.Println("  app <id> [app]    - Open a bookmark with a given application (none: the default)")
+	fmt.Println("  icon <id> [emoji] - Show an emoji before a bookmark's name in list (none: remove it)")
+	fmt.Println("  meta <id>         - List a bookmark's custom fields (meta set <id> <key> <value>, meta unset <id> <key>)")
+	fmt.Println("  jump <alias>      - Print the directory of a local bookmark (see shell-init)")
+	fmt.Println("  shell-init <sh>   - Print a 'j' shell function that cds via jump (bash, zsh, fish)")
+	fmt.Println("  show <id>         - Show every detail of a bookmark, including its source")
+	fmt.Println("  relate <id> <id>  - Link two related bookmarks, listed in show (unrelate unlinks them)")
+	fmt.Println("  edit <id>         - Edit every field of a bookmark as YAML in $EDITOR")
+	fmt.Println("  edit --all [query] - Rename, retag or delete matching bookmarks in $EDITOR")
+	fmt.Println("  add <url> [name]  - Add a bookmark by hand (a URL, or a local file or directory path);")
+	fmt.Println("                      --check warns if it is unreachable, --strict refuses it,")
+	fmt.Println("                      --expires 30d (or 2w, 6m, 1y, YYYY-MM-DD) sets an expiry date")
+	fmt.Println("  expire <id> <when> - Set when a bookmark expires (never: it doesn't)")
+	fmt.Println("  remind <id> <when> - Be reminded to revisit a bookmark (never: cancel)")
+	fmt.Println("  snooze <id> <when> - Hide a bookmark from listings until then (off: bring it back)")
+	fmt.Println("  serve [--sync]    - Serve the collection to other machines (--sync: for sync too)")
+	fmt.Println("  sync [remote <url> <token>] - Exchange changes with a sync server (remote: choose it)")
+	fmt.Println("  sync peer         - Exchange changes with the instances found on the LAN")
+	fmt.Println("  merge <file>      - Merge another copy of bookmarks.json (e.g. a sync conflict) into this one")
+	fmt.Println("  share <id> --email [to...] [--note <text>] - Email a bookmark's title, URL and a note")
+	fmt.Println("  publish <tag> <out.html> - Write a standalone web page of the bookmarks tagged <tag>")
+	fmt.Println("  publish --gist|--git <repo> [--branch b] [--md|--html] [--public] [query] - Push the")
+	fmt.Println("                      matching bookmarks to a GitHub gist or a git branch (gh-pages)")
+	fmt.Println("  push pinboard [--all] [query] - Post the matching bookmarks not pushed yet to Pinboard")
+	fmt.Println("  open <id>         - Open the bookmark with the given ID (or UUID); --tor: in Tor Browser")
+	fmt.Println("  suggest           - Suggest bookmarks you are likely to want right now")
+	fmt.Println("  suggest-from-history [--min n] - Offer often visited, unbookmarked pages from")
+	fmt.Println("                      browser history (at least n visits, default 5) for adding")
+	fmt.Println("  smart add <name> <query> - Save a query as a smart folder (smart rm <name> removes it)")
+	fmt.Println("  smart [name]      - List smart folders, or the bookmarks in one")
+	fmt.Println("  coll create <name> - Start an ordered collection (coll add <name> <id>..., coll move, coll rm)")
+	fmt.Println("  coll open <name>  - Open every bookmark of a collection, in order")
+	fmt.Println("  next [collection] - Open the next unread bookmark of a collection, or the oldest unread one")
+	fmt.Println("  coll [name]       - List collections, or the bookmarks in one in order")
 	fmt.Println("  fav <id>          - Toggle favorite status for a bookmark")
-	fmt.Println("  import            - Scan for new bookmarks from installed browsers")
-	fmt.Println("  set-browser <cmd> - Set the command to open links (e.g., 'firefox')")
+	fmt.Println("  read <id>         - Toggle read status for a bookmark")
+	fmt.Println("  tor <id>          - Toggle fetching a bookmark through Tor and opening it in Tor Browser")
+	fmt.Println("  tag <id> <t>...   - Add tags to a bookmark (untag removes them)")
+	fmt.Println("  delete <id>       - Delete a bookmark")
+	fmt.Println("  delete --source <b> - Delete every bookmark imported from browser <b>")
+	fmt.Println("  delete --query <q>  - Delete every bookmark matching a query")
+	fmt.Println("  import [--full]   - Scan for new bookmarks (and reading lists) from installed browsers")
+	fmt.Println("  import shortcuts <dir> - Import the .url and .webloc files in a folder")
+	fmt.Println("  import report     - Show the summary of the last import again")
+	fmt.Println("  import remote <host:port> <token> [query] - Import from another instance running serve")
+	fmt.Println("  import json|jsonl|yaml <file> - Import an export file (- for stdin), skipping known URLs")
+	fmt.Println("  config list|get <key>|set <key> <value>|unset <key> - Show or change settings")
+	fmt.Println("  set-browser <cmd> - Set the command to open links (e.g., 'firefox'); 'system [flags]'")
+	fmt.Println("                      uses the OS default browser, e.g. 'system --new-window'")
+	fmt.Println("  check             - Check every link (and local file) and report the dead ones")
+	fmt.Println("  refresh-titles    - Fetch page titles for untitled bookmarks (--all: every one)")
+	fmt.Println("  enrich [query]    - Fetch og:description and og:image for matching bookmarks")
+	fmt.Println("  cache clear       - Forget cached page titles, statuses and metadata")
+	fmt.Println("  reindex           - Rebuild the full-text search index")
+	fmt.Println("  fsck [--repair]   - Check the collection for inconsistencies and offer to repair them")
+	fmt.Println("  stale [--list]    - Move long-unopened bookmarks to the shelf, out of list and search")
+	fmt.Println("  unshelve <id>     - Bring a shelved bookmark back")
+	fmt.Println("  trash [id|empty]  - List the trash, move a bookmark to it, or empty it for good")
+	fmt.Println("  untrash <id>      - Take a bookmark back out of the trash")
+	fmt.Println("  archive <id>      - Save to the Wayback Machine and take a local snapshot")
+	fmt.Println("  daemon            - Run the scheduled jobs from the config until interrupted")
+	fmt.Println("  history [id]      - Show the log of changes, optionally for one bookmark")
+	fmt.Println("  export <fmt> [-o file] [filters] - Export as json, jsonl, yaml, md, html, csv or ics;")
+	fmt.Println("                      filters: --tag t --domain d --since YYYY-MM-DD --source b --fav --query q")
+	fmt.Println("  export buku [path] [filters] - Add to a buku database (default: buku's own), skipping known URLs")
+	fmt.Println("  backup [path]     - Write a timestamped backup archive (default: backups/);")
+	fmt.Println("                      --remote s3://bucket/path also uploads it to S3-compatible storage")
+	fmt.Println("  restore <path>    - Roll back to the contents of a backup archive (or the newest under s3://...)")
 	fmt.Println("  save              - Save all changes to bookmarks.json")
+	fmt.Println("  compress on|off   - Gzip bookmarks.json and snapshots (existing snapshots are converted)")
 	fmt.Println("  help              - Show this help message")
+	fmt.Println("  run <script.star> - Run a Starlark script against the collection")
+	fmt.Println("  json [id]         - Print bookmarks as JSON (for scripts and plugins)")
 	fmt.Println("  exit              - Quit the program")
+	if plugins := s.listPlugins(); len(plugins) > 0 {
+		fmt.Println("  plugins:          " + strings.Join(plugins, ", "))
+	}
 	fmt.Println("---------------------------")
 }
 
@@ -250,117 +1112,928 @@ func (s *AppState) handleCommand(input string) (shouldExit bool) {
 		return false
 	}
 	command, args := parts[0], parts[1:]
+	s.applyEnrichment()
 	switch command {
-	case "list", "ls":
+	case "list", "ls", "search":
 		// CHANGED: Check for command variations like 'list fav' or 'list links'
 		showFavsOnly := false
-		showLinksFormat := false
+		showLinksFormat := !s.useHyperlinks()
+		sourceFilter := ""
+		tagFilter := ""
+		showScores := false
+		showShelf, searched := false, false
+		listed := strings.Join(args, " ")
+		var query queryNode = matchAll
+		var ranker *searchRanker
+		args, caseMode := queryCaseFlag(args)
+		if i := slices.Index(args, "--score"); i >= 0 {
+			showScores = true
+			args = slices.Delete(args, i, i+1)
+		}
+		if command != "search" && len(args) == 1 && args[0] == "tree" {
+			s.printTree()
+			return false
+		}
+		if command != "search" && len(args) == 1 && args[0] == "due" {
+			s.printDue()
+			return false
+		}
+		if command == "search" && len(args) == 0 {
+			fmt.Println("Usage: search <query>")
+			return false
+		}
 		if len(args) > 0 {
-			if args[0] == "fav" {
+			if command == "search" || !slices.Contains([]string{"fav", "links", "source", "tag", "shelf"}, args[0]) {
+				parse := parseQueryCase
+				if command == "search" {
+					parse = parseSearchQuery
+				}
+				q, err := parse(strings.Join(args, " "), caseMode)
+				if err != nil {
+					fmt.Printf("Invalid query: %v\n", err)
+					return false
+				}
+				query, searched = q, true
+				ranker = newSearchRanker(strings.Join(args, " "))
+			} else if args[0] == "fav" {
 				showFavsOnly = true
 			} else if args[0] == "links" {
 				showLinksFormat = true
+			} else if args[0] == "shelf" {
+				// Listed as shelved:true would be, shelf shown.
+				showShelf, listed = true, "shelved:true"
+			} else if args[0] == "source" {
+				if len(args) < 2 {
+					fmt.Println("Usage: list source <browser>")
+					return false
+				}
+				sourceFilter = args[1]
+			} else if args[0] == "tag" {
+				if len(args) < 2 {
+					fmt.Println("Usage: list tag <tag>")
+					return false
+				}
+				tagFilter = args[1]
 			}
 		}
 
-		sort.Slice(s.Bookmarks, func(i, j int) bool {
-			return strings.ToLower(s.Bookmarks[i].Name) < strings.ToLower(s.Bookmarks[j].Name)
-		})
-		count := 0
+		// Only the matches are sorted, never the whole collection.
+		var matches []Bookmark
+		hidden := 0
+		now := time.Now()
 		for _, b := range s.Bookmarks {
+			if showShelf && !b.shelved() {
+				continue
+			}
 			if showFavsOnly && !b.Favorite {
 				continue
 			}
+			if sourceFilter != "" && !b.fromSource(sourceFilter) {
+				continue
+			}
+			if tagFilter != "" && !b.hasTag(normalizeTag(tagFilter)) {
+				continue
+			}
+			if !query.match(b) {
+				continue
+			}
+			if !b.visible(listed, now) {
+				if b.shelved() {
+					hidden++
+				}
+				continue
+			}
+			matches = append(matches, b)
+		}
+		sortByName(matches)
+		if showScores {
+			sort.SliceStable(matches, func(i, j int) bool {
+				return s.frecency(matches[i], now) > s.frecency(matches[j], now)
+			})
+		} else if ranker != nil {
+			ranker.rank(matches)
+		}
+		count := 0
+		for _, b := range matches {
+			if plainOutput {
+				// Predictable "ID: name: url" lines; favorites get a trailing field.
+				favField := ""
+				if b.Favorite {
+					favField = ": favorite"
+				}
+				if showScores {
+					favField += fmt.Sprintf(": score %.2f", s.frecency(b, now))
+				}
+				if b.fileMissing() {
+					favField += ": missing"
+				}
+				if b.lookalike() {
+					favField += ": lookalike"
+				}
+				if b.expired(now) {
+					favField += ": expired"
+				}
+				if b.backFromSnooze(now) {
+					favField += ": back from snooze"
+				}
+				fmt.Printf("%d: %s: %s%s\n", b.ID, b.Name, displayURL(b.URL), favField)
+				count++
+				continue
+			}
 			favMarker := ""
+			if showScores {
+				favMarker = fmt.Sprintf("%s%.2f%s ", Gray, s.frecency(b, now), Reset)
+			}
 			if b.Favorite {
-				favMarker = Yellow + "★ " + Reset
+				favMarker += Yellow + "★ " + Reset
+			}
+			favMarker += s.bookmarkIcon(b)
+			if b.fileMissing() {
+				favMarker += Yellow + "(missing) " + Reset
+			}
+			if b.lookalike() {
+				favMarker += Yellow + "(lookalike) " + Reset
+			}
+			if b.expired(now) {
+				favMarker += Yellow + "(expired) " + Reset
+			}
+			if b.backFromSnooze(now) {
+				favMarker += Yellow + "(back from snooze) " + Reset
 			}
 
+			name, link := b.Name, displayURL(b.URL)
+			if ranker != nil {
+				name, link = ranker.highlight(name, ""), ranker.highlight(link, Gray)
+			}
 			if showLinksFormat {
 				// ADDED: Logic for the new, simple text format
-				fmt.Printf("%s[%d]%s %s%s - %s%s%s\n", Bold+Cyan, b.ID, Reset, favMarker, b.Name, Gray, b.URL, Reset)
+				fmt.Printf("%s[%d]%s %s%s - %s%s%s\n", Bold+Cyan, b.ID, Reset, favMarker, name, Gray, link, Reset)
 			} else {
 				// Original hyperlink format for modern terminals
-				linkText := fmt.Sprintf("\x1b]8;;%s\x07%s%s%s\x1b]8;;\x07", b.URL, Blue, b.Name, Reset)
+				if ranker != nil {
+					name = ranker.highlight(b.Name, Blue)
+				}
+				linkText := fmt.Sprintf("\x1b]8;;%s\x07%s%s%s\x1b]8;;\x07", b.URL, Blue, name, Reset)
 				fmt.Printf("%s[%d]%s %s%s\n", Bold+Cyan, b.ID, Reset, favMarker, linkText)
 			}
+			if ranker != nil {
+				if snippet := ranker.snippet(b); snippet != "" {
+					fmt.Printf("    %s%s%s\n", Gray, snippet, Reset)
+				}
+			}
 			count++
 		}
 		if count == 0 {
 			if showFavsOnly {
 				fmt.Println("No favorites found.")
+			} else if sourceFilter != "" {
+				fmt.Printf("No bookmarks imported from '%s'.\n", sourceFilter)
+			} else if tagFilter != "" {
+				fmt.Printf("No bookmarks tagged '%s'.\n", tagFilter)
+			} else if showShelf {
+				fmt.Println("The shelf is empty.")
 			} else {
 				fmt.Println("No bookmarks found.")
 			}
 		}
+		if hidden > 0 && searched {
+			fmt.Printf("%s%d more on the shelf (add shelved:true to see them).%s\n", style(Gray), hidden, style(Reset))
+		}
+	case "count":
+		// Exit status follows grep: 0 with matches, 1 without, 2 on a bad query.
+		args, caseMode := queryCaseFlag(args)
+		text := strings.Join(args, " ")
+		q, err := parseQueryCase(text, caseMode)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid query: %v\n", err)
+			exitStatus = 2
+			return false
+		}
+		n := len(s.filterBookmarks(bookmarkFilter{query: q, text: text}))
+		fmt.Println(n)
+		exitStatus = 0
+		if n == 0 {
+			exitStatus = 1
+		}
+	case "domains":
+		text := strings.Join(args, " ")
+		q, err := parseQuery(text)
+		if err != nil {
+			fmt.Printf("Invalid query: %v\n", err)
+			return false
+		}
+		s.printDomains(bookmarkFilter{query: q, text: text})
 	case "open":
+		// --tor opens in Tor Browser; Tor-routed bookmarks always do.
+		tor := slices.Contains(args, "--tor")
+		args = slices.DeleteFunc(args, func(a string) bool { return a == "--tor" })
 		if len(args) < 1 {
-			fmt.Println("Usage: open <id>")
+			fmt.Println("Usage: open [--tor] <id>")
 			return false
 		}
-		id, err := strconv.Atoi(args[0])
-		if err != nil {
-			fmt.Println("Invalid ID.")
+		i, ok := s.findBookmark(args[0])
+		if !ok {
 			return false
 		}
-		for _, b := range s.Bookmarks {
-			if b.ID == id {
-				fmt.Printf("Opening '%s'...\n", b.Name)
-				cmdParts := strings.Fields(s.Config.DefaultBrowserCmd)
-				cmd := exec.Command(cmdParts[0], append(cmdParts[1:], b.URL)...)
-				if err := cmd.Start(); err != nil {
-					fmt.Printf("Error: %v\n", err)
-				}
+		fmt.Printf("Opening '%s'...\n", s.Bookmarks[i].Name)
+		if err := s.openAt(i, tor); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+	case "add":
+		// --check warns about unreachable URLs, --strict refuses them.
+		strict := slices.Contains(args, "--strict")
+		verify := strict || slices.Contains(args, "--check") || s.Config.CheckOnAdd
+		args = slices.DeleteFunc(args, func(a string) bool { return a == "--check" || a == "--strict" })
+		var expires time.Time
+		if i := slices.Index(args, "--expires"); i >= 0 {
+			if i+1 >= len(args) {
+				fmt.Println("Usage: add --expires <30d|2w|6m|1y|YYYY-MM-DD> <url> [name]")
+				return false
+			}
+			t, err := parseExpiry(args[i+1], time.Now())
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
 				return false
 			}
+			expires, args = t, slices.Delete(args, i, i+2)
 		}
-		fmt.Println("ID not found.")
-	case "fav":
 		if len(args) < 1 {
-			fmt.Println("Usage: fav <id>")
-			return false
-		}
-		id, err := strconv.Atoi(args[0])
-		if err != nil {
-			fmt.Println("Invalid ID.")
+			fmt.Println("Usage: add [--check|--strict] [--expires <when>] <url> [name]")
 			return false
 		}
-		found := false
-		for i, b := range s.Bookmarks {
-			if b.ID == id {
-				s.Bookmarks[i].Favorite = !s.Bookmarks[i].Favorite
-				status := "added to"
-				if !s.Bookmarks[i].Favorite {
-					status = "removed from"
+		url, name := normalizeBookmarkURL(args[0]), args[0]
+		var check *LinkCheck
+		if verify {
+			if check = quickCheck(url); check.Dead() {
+				if strict {
+					fmt.Printf("Not added: %s is unreachable (%s).\n", url, check.describe())
+					exitStatus = 1
+					return false
 				}
-				fmt.Printf("Bookmark '%s' %s favorites.\n", b.Name, status)
-				found = true
-				break
+				fmt.Printf("%sWarning: %s looks unreachable (%s).\n", decor("⚠️ "), url, check.describe())
 			}
 		}
-		if !found {
-			fmt.Println("ID not found.")
+		if path, ok := localPath(url); ok {
+			name = filepath.Base(path)
 		}
-	case "import":
-		s.importBookmarks()
-	case "set-browser":
+		if reason := lookalikeReason(bookmarkHost(url)); reason != "" {
+			fmt.Printf("%sWarning: %s may be a lookalike domain (%s).\n", decor("⚠️ "), bookmarkHost(url), reason)
+		}
+		if len(args) > 1 {
+			name = strings.Join(args[1:], " ")
+		}
+		if !s.addBookmark(name, url, nil) {
+			fmt.Println("That URL is already bookmarked.")
+			return false
+		}
+		s.Bookmarks[len(s.Bookmarks)-1].Check = check
+		s.Bookmarks[len(s.Bookmarks)-1].ExpiresAt = expires
+		b := s.Bookmarks[len(s.Bookmarks)-1]
+		fmt.Printf("Added '%s' as [%d].\n", b.Name, b.ID)
+		s.emit(eventAdd, b)
+	case "alias":
+		if len(args) < 1 {
+			fmt.Println("Usage: alias <id> [alias]")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		alias := ""
+		if len(args) > 1 {
+			alias = args[1]
+		}
+		s.editBookmark(i, func(b *Bookmark) { b.Alias = alias })
+		if alias == "" {
+			fmt.Printf("Removed the alias of '%s'.\n", s.Bookmarks[i].Name)
+		} else {
+			fmt.Printf("'%s' can now be reached as '%s'.\n", s.Bookmarks[i].Name, alias)
+		}
+	case "app":
+		if len(args) < 1 {
+			fmt.Println("Usage: app <id> [application]")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		app := strings.Join(args[1:], " ")
+		s.editBookmark(i, func(b *Bookmark) { b.App = app })
+		if app == "" {
+			fmt.Printf("'%s' opens with the default handler again.\n", s.Bookmarks[i].Name)
+		} else {
+			fmt.Printf("'%s' now opens with %s.\n", s.Bookmarks[i].Name, app)
+		}
+	case "icon":
+		if len(args) < 1 {
+			fmt.Println("Usage: icon <id> [emoji]")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		icon := strings.Join(args[1:], " ")
+		s.editBookmark(i, func(b *Bookmark) { b.Icon = icon })
+		if icon == "" {
+			fmt.Printf("Removed the icon of '%s'.\n", s.Bookmarks[i].Name)
+		} else {
+			fmt.Printf("'%s' now shows as %s.\n", s.Bookmarks[i].Name, icon)
+		}
+	case "jump":
+		// Prints only the directory, for the shell function from shell-init.
+		if len(args) < 1 {
+			fmt.Fprintln(os.Stderr, "Usage: jump <alias|id|name>")
+			exitStatus = 2
+			return false
+		}
+		i, dir, err := s.jumpTarget(strings.Join(args, " "))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			exitStatus = 1
+			return false
+		}
+		s.recordOpen(i)
+		fmt.Println(dir)
+	case "shell-init":
+		if len(args) < 1 {
+			fmt.Println("Usage: shell-init <bash|zsh|fish>")
+			return false
+		}
+		script, err := shellInit(args[0])
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		fmt.Print(script)
+	case "edit":
+		if len(args) < 1 {
+			fmt.Println("Usage: edit <id> | edit --all [query]")
+			return false
+		}
+		if args[0] != "--all" {
+			i, ok := s.findBookmark(args[0])
+			if !ok {
+				return false
+			}
+			if err := s.editBookmarkYAML(i); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return false
+		}
+		text := strings.Join(args[1:], " ")
+		q, err := parseQuery(text)
+		if err != nil {
+			fmt.Printf("Invalid query: %v\n", err)
+			return false
+		}
+		if err := s.editAll(bookmarkFilter{query: q, text: text}); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "show":
+		if len(args) < 1 {
+			fmt.Println("Usage: show <id>")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		s.Bookmarks[i].printDetails()
+		s.printRelated(s.Bookmarks[i])
+	case "delete", "rm":
 		if len(args) < 1 {
-			fmt.Printf("Usage: set-browser <cmd>\nCurrent: '%s'\n", s.Config.DefaultBrowserCmd)
+			fmt.Println("Usage: delete <id> | delete --source <browser> | delete --query <query>")
+			return false
+		}
+		if args[0] == "--source" || args[0] == "--query" {
+			if len(args) < 2 {
+				fmt.Printf("Usage: delete %s <%s>\n", args[0], map[string]string{"--source": "browser", "--query": "query"}[args[0]])
+				return false
+			}
+			var query queryNode = predicate(func(b Bookmark) bool { return b.fromSource(args[1]) })
+			text := ""
+			if args[0] == "--query" {
+				text = strings.Join(args[1:], " ")
+				// An empty query matches everything: not what a delete means.
+				if strings.TrimSpace(text) == "" {
+					fmt.Println("Usage: delete --query <query>")
+					return false
+				}
+				q, err := parseQuery(text)
+				if err != nil {
+					fmt.Printf("Invalid query: %v\n", err)
+					return false
+				}
+				query = q
+			}
+			f := bookmarkFilter{query: query, text: text}
+			var kept, removed []Bookmark
+			for _, b := range s.Bookmarks {
+				if f.match(b) {
+					removed = append(removed, b)
+				} else {
+					kept = append(kept, b)
+				}
+			}
+			if len(removed) > bulkDeleteConfirm && askKey(fmt.Sprintf("Delete %d bookmarks? [y/n] ", len(removed))) != "y" {
+				return false
+			}
+			s.Bookmarks, s.index = kept, nil
+			now := time.Now()
+			for _, b := range removed {
+				s.buryBookmark(b.UUID, now)
+				s.emit(eventDelete, b)
+			}
+			if args[0] == "--query" {
+				fmt.Printf("Deleted %d bookmarks matching the query.\n", len(removed))
+			} else {
+				fmt.Printf("Deleted %d bookmarks imported from '%s'.\n", len(removed), args[1])
+			}
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		b := s.removeBookmark(i)
+		fmt.Printf("Deleted '%s'.\n", b.Name)
+	case "fav":
+		if len(args) < 1 {
+			fmt.Println("Usage: fav <id>")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		s.editBookmark(i, func(b *Bookmark) { b.Favorite = !b.Favorite })
+		status := "added to"
+		if !s.Bookmarks[i].Favorite {
+			status = "removed from"
+		}
+		fmt.Printf("Bookmark '%s' %s favorites.\n", s.Bookmarks[i].Name, status)
+	case "read":
+		if len(args) < 1 {
+			fmt.Println("Usage: read <id>")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		s.editBookmark(i, func(b *Bookmark) { b.Read = !b.Read })
+		status := "read"
+		if !s.Bookmarks[i].Read {
+			status = "unread"
+		}
+		fmt.Printf("Marked '%s' as %s.\n", s.Bookmarks[i].Name, status)
+	case "tor":
+		if len(args) < 1 {
+			fmt.Println("Usage: tor <id>")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		s.editBookmark(i, func(b *Bookmark) { b.Tor = !b.Tor })
+		if s.Bookmarks[i].Tor {
+			fmt.Printf("'%s' is now fetched through Tor and opened in Tor Browser.\n", s.Bookmarks[i].Name)
+		} else {
+			fmt.Printf("'%s' is no longer routed through Tor.\n", s.Bookmarks[i].Name)
+		}
+	case "tag", "untag":
+		if len(args) < 2 {
+			fmt.Printf("Usage: %s <id> <tag>...\n", command)
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		s.editBookmark(i, func(b *Bookmark) {
+			if command == "tag" {
+				b.addTags(args[1:]...)
+			} else {
+				b.removeTags(args[1:]...)
+			}
+		})
+		fmt.Printf("Tags of '%s': %s\n", s.Bookmarks[i].Name, strings.Join(s.Bookmarks[i].Tags, ", "))
+	case "run":
+		if len(args) < 1 {
+			fmt.Println("Usage: run <script.star> [args]")
+			return false
+		}
+		if err := s.runScript(args[0], args[1:]); err != nil {
+			fmt.Printf("Script error: %v\n", err)
+		}
+	case "import":
+		if len(args) > 0 && importers[args[0]] != nil {
+			if len(args) < 2 {
+				fmt.Printf("Usage: import %s <file|->\n", args[0])
+				return false
+			}
+			if err := s.importFile(args[0], strings.Join(args[1:], " ")); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return false
+		}
+		if len(args) > 0 && args[0] == "report" {
+			if err := showImportReport(); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return false
+		}
+		if len(args) > 0 && args[0] == "remote" {
+			if err := s.runImportRemote(args[1:]); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return false
+		}
+		if len(args) > 0 && args[0] == "shortcuts" {
+			if len(args) < 2 {
+				fmt.Println("Usage: import shortcuts <dir>")
+				return false
+			}
+			if _, err := s.importShortcuts(strings.Join(args[1:], " ")); err != nil {
+				fmt.Printf("Error: %v\n", err)
+			}
+			return false
+		}
+		ctx, stop := interruptible()
+		defer stop()
+		if n := s.importBookmarks(ctx, len(args) > 0 && args[0] == "--full"); n > 0 && !interactive {
+			s.notifyDesktop("Bibliothermes import", fmt.Sprintf("Imported %d new bookmarks.", n))
+		}
+	case "check":
+		ctx, stop := interruptible()
+		defer stop()
+		checked, dead, _ := s.checkLinks(ctx)
+		for _, b := range dead {
+			fmt.Printf("%s[%d]%s %s - %s\n", style(Bold+Cyan), b.ID, style(Reset), b.Name, b.Check.describe())
+		}
+		if ctx.Err() != nil {
+			fmt.Print("Cancelled. ")
+		}
+		fmt.Printf("Checked %d links: %d dead.\n", checked, len(dead))
+	case "refresh-titles":
+		all := len(args) > 0 && args[0] == "--all"
+		ctx, stop := interruptible()
+		defer stop()
+		refreshed := s.refreshTitles(ctx, all)
+		if ctx.Err() != nil {
+			fmt.Print("Cancelled. ")
+		}
+		fmt.Printf("Refreshed %d titles.\n", refreshed)
+	case "enrich":
+		text := strings.Join(args, " ")
+		q, err := parseQuery(text)
+		if err != nil {
+			fmt.Printf("Invalid query: %v\n", err)
+			return false
+		}
+		ctx, stop := interruptible()
+		defer stop()
+		s.startEnrichment(ctx, s.filterBookmarks(bookmarkFilter{query: q, text: text}), true)
+		updated := s.finishEnrichment()
+		if ctx.Err() != nil {
+			fmt.Print("Cancelled. ")
+		}
+		fmt.Printf("Updated descriptions of %d bookmarks.\n", updated)
+	case "cache":
+		if len(args) < 1 || args[0] != "clear" {
+			fmt.Println("Usage: cache clear")
+			return false
+		}
+		if err := os.Remove(dataPath(metaCacheFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		fmt.Println("Page metadata cache cleared.")
+	case "reindex":
+		if err := s.reindex(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		fmt.Printf("Indexed %d bookmarks.\n", len(s.Bookmarks))
+	case "remind":
+		s.runRemind(args)
+	case "snooze":
+		s.runSnooze(args)
+	case "merge":
+		if len(args) != 1 {
+			fmt.Println("Usage: merge <file>")
+			return false
+		}
+		if err := s.runMerge(args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+	case "share":
+		s.runShare(args)
+	case "publish":
+		if err := s.runPublish(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+	case "push":
+		if len(args) == 0 || args[0] != "pinboard" {
+			fmt.Println("Usage: push pinboard [--all] [query]")
+			return false
+		}
+		if err := s.runPushPinboard(args[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "expire":
+		if len(args) < 2 {
+			fmt.Println("Usage: expire <id> <30d|2w|6m|1y|YYYY-MM-DD|never>")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		var expires time.Time
+		if args[1] != "never" {
+			t, err := parseExpiry(args[1], time.Now())
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return false
+			}
+			expires = t
+		}
+		s.editBookmark(i, func(b *Bookmark) { b.ExpiresAt = expires })
+		if expires.IsZero() {
+			fmt.Printf("'%s' no longer expires.\n", s.Bookmarks[i].Name)
+		} else {
+			fmt.Printf("'%s' expires on %s.\n", s.Bookmarks[i].Name, expires.Format("2006-01-02 15:04"))
+		}
+	case "trash":
+		s.runTrash(args)
+	case "untrash":
+		if len(args) < 1 {
+			fmt.Println("Usage: untrash <id>")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		if !s.Bookmarks[i].trashed() {
+			fmt.Printf("'%s' is not in the trash.\n", s.Bookmarks[i].Name)
+			return false
+		}
+		s.editBookmark(i, func(b *Bookmark) { b.TrashedAt = time.Time{} })
+		fmt.Printf("'%s' is back in the list.\n", s.Bookmarks[i].Name)
+	case "stale":
+		s.runStale(slices.Contains(args, "--list"))
+	case "unshelve":
+		if len(args) < 1 {
+			fmt.Println("Usage: unshelve <id>")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		if !s.Bookmarks[i].shelved() {
+			fmt.Printf("'%s' is not on the shelf.\n", s.Bookmarks[i].Name)
+			return false
+		}
+		s.editBookmark(i, func(b *Bookmark) { b.ShelvedAt = time.Time{} })
+		fmt.Printf("'%s' is back in the list.\n", s.Bookmarks[i].Name)
+	case "fsck":
+		s.runFsck(slices.Contains(args, "--repair"))
+	case "suggest":
+		suggestions := s.suggestions(time.Now())
+		if len(suggestions) == 0 {
+			fmt.Println("No suggestions yet; they come from what you open.")
+			return false
+		}
+		for _, sg := range suggestions {
+			fmt.Printf("%s[%d]%s %s %s(%s)%s\n", style(Bold+Cyan), sg.b.ID, style(Reset), sg.b.Name, style(Gray), sg.reason, style(Reset))
+		}
+	case "suggest-from-history":
+		minVisits := defaultMinVisits
+		if len(args) == 2 && args[0] == "--min" {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				fmt.Println("Usage: suggest-from-history [--min <visits>]")
+				return false
+			}
+			minVisits = n
+		} else if len(args) > 0 {
+			fmt.Println("Usage: suggest-from-history [--min <visits>]")
+			return false
+		}
+		s.suggestFromHistory(minVisits)
+	case "history":
+		uuid := ""
+		if len(args) > 0 {
+			i, ok := s.findBookmark(args[0])
+			if !ok {
+				return false
+			}
+			uuid = s.Bookmarks[i].UUID
+		}
+		entries, err := readAudit(uuid)
+		if err != nil {
+			fmt.Printf("Error: could not read history: %v\n", err)
+			return false
+		}
+		if len(entries) == 0 {
+			fmt.Println("No history recorded.")
+			return false
+		}
+		for _, e := range entries {
+			target := ""
+			if e.UUID != "" {
+				target = fmt.Sprintf(" [%d] %s", e.ID, e.Name)
+			}
+			fmt.Printf("%s%s%s %s %s%s: %s\n", style(Gray), e.Time.Format("2006-01-02 15:04:05"), style(Reset), e.Who, e.Op, target, describeChange(e))
+		}
+	case "archive":
+		if len(args) < 1 {
+			fmt.Println("Usage: archive <id> [--wayback|--snapshot]")
+			return false
+		}
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return false
+		}
+		wayback, snapshot := true, true
+		if len(args) > 1 {
+			wayback, snapshot = args[1] == "--wayback", args[1] == "--snapshot"
+		}
+		if err := s.archiveBookmark(s.clientChooser()(s.Bookmarks[i]), i, wayback, snapshot); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		fmt.Printf("Archived '%s'.\n", s.Bookmarks[i].Name)
+	case "daemon":
+		if err := s.runDaemon(); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "serve":
+		if err := s.runServe(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+	case "sync":
+		if err := s.runSync(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exitStatus = 1
+			return false
+		}
+	case "smart":
+		if err := s.smartCommand(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "meta":
+		if err := s.metaCommand(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "next":
+		if err := s.nextCommand(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "coll":
+		if err := s.collCommand(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "relate", "unrelate":
+		if err := s.relateCommand(args, command == "unrelate"); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "config":
+		if err := s.runConfig(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			exitStatus = 1
+		}
+	case "set-browser":
+		if len(args) < 1 {
+			fmt.Printf("Usage: set-browser <cmd> | set-browser system [flags]\nCurrent: '%s'\n", s.Config.DefaultBrowserCmd)
+			if resolved := s.describeBrowser(); resolved != "" {
+				fmt.Printf("Resolves to: %s\n", resolved)
+			}
 			return false
 		}
 		s.Config.DefaultBrowserCmd = strings.Join(args, " ")
+		s.saveConfig()
 		fmt.Printf("Browser command set to: '%s'\n", s.Config.DefaultBrowserCmd)
+	case "backup":
+		dest, remote := "", ""
+		if i := slices.Index(args, "--remote"); i >= 0 {
+			// The flag takes an s3:// URL, else the configured remote; a
+			// path may come before or after it.
+			end := i + 1
+			remote = s.Config.Backup.Remote
+			if i+1 < len(args) && strings.HasPrefix(args[i+1], "s3://") {
+				remote, end = args[i+1], i+2
+			}
+			args = slices.Delete(args, i, end)
+			if remote == "" {
+				fmt.Println("Usage: backup [path] --remote s3://bucket/path (or set config.backup.remote)")
+				return false
+			}
+		}
+		if len(args) > 0 {
+			dest = args[0]
+		}
+		path, err := createBackup(dest)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		fmt.Println(decor("✅ ")+"Backup written to", path)
+		if remote != "" {
+			s.uploadBackup(remote, path)
+		}
+	case "restore":
+		if len(args) < 1 {
+			fmt.Println("Usage: restore <path|s3://bucket/path>")
+			return false
+		}
+		if strings.HasPrefix(args[0], "s3://") {
+			local, err := downloadBackup(s.Config.Backup, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				return false
+			}
+			fmt.Println("Downloaded", local)
+			args[0] = local
+		}
+		if err := restoreBackup(args[0]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		// Unsaved changes belong to the collection being replaced.
+		if err := clearJournal(); err != nil {
+			logger.Warn("could not clear", "file", journalFile, "err", err)
+		}
+		restored, err := loadState()
+		if err != nil {
+			fmt.Printf("Error: restored files could not be loaded: %v\n", err)
+			return false
+		}
+		*s = *restored
+		s.writeAudit(AuditEntry{Op: "restore", Detail: fmt.Sprintf("restored %d bookmarks from %s", len(s.Bookmarks), args[0])})
+		fmt.Printf("Restored %d bookmarks from %s.\n", len(s.Bookmarks), args[0])
 	case "save":
 		if err := s.saveState(); err != nil {
 			fmt.Printf("Error: %v\n", err)
 		} else {
-			fmt.Println("✅ State saved to", bookmarksFile)
+			fmt.Println(decor("✅ ")+"State saved to", bookmarksFile)
 		}
+	case "compress":
+		if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+			current := "off"
+			if s.Config.Compress {
+				current = "on"
+			}
+			fmt.Printf("Usage: compress on|off (currently %s)\n", current)
+			return false
+		}
+		converted, err := s.setCompression(args[0] == "on")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		fmt.Printf("Compression %s; %d snapshots converted. %s is rewritten on save.\n", args[0], converted, bookmarksFile)
 	case "help":
-		printHelp()
+		s.printHelp()
 	case "exit", "quit":
 		return true
+	case "export":
+		if err := s.exportBookmarks(args); err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+	case "json":
+		// Machine-readable output, mainly for plugins and scripts.
+		out := s.Bookmarks
+		if len(args) > 0 {
+			i, ok := s.findBookmark(args[0])
+			if !ok {
+				return false
+			}
+			out = s.Bookmarks[i : i+1]
+		}
+		data, err := json.MarshalIndent(out, "", "  ")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		fmt.Println(string(data))
 	default:
+		if path, ok := s.findPlugin(command); ok {
+			if err := s.runPlugin(path, args); err != nil {
+				fmt.Printf("Plugin '%s' failed: %v\n", command, err)
+			}
+			return false
+		}
 		fmt.Printf("Unknown command: '%s'.\n", command)
 	}
 	return false
@@ -370,25 +2043,82 @@ func (s *AppState) handleCommand(input string) (shouldExit bool) {
 // == 🚀 MAIN FUNCTION
 // =============================================================================
 func main() {
+	// BIBLIOTHERMES_PLAIN, _VERBOSE and _QUIET turn the flags on by default.
+	flag.BoolVar(&plainOutput, "plain", envFlag("BIBLIOTHERMES_PLAIN"), "plain output: no colors, hyperlinks or decorative symbols")
+	flag.BoolVar(&verbose, "verbose", envFlag("BIBLIOTHERMES_VERBOSE"), "show debug messages: HTTP requests, import decisions")
+	flag.BoolVar(&verbose, "v", envFlag("BIBLIOTHERMES_VERBOSE"), "short for --verbose")
+	flag.BoolVar(&quiet, "quiet", envFlag("BIBLIOTHERMES_QUIET"), "show only errors and the output of commands")
+	flag.BoolVar(&quiet, "q", envFlag("BIBLIOTHERMES_QUIET"), "short for --quiet")
+	flag.StringVar(&activeProfile, "profile", os.Getenv("BIBLIOTHERMES_PROFILE"), "use the settings of this profile from the config")
+	flag.Parse()
+
+	// git runs the merge driver on files of its own, not the collection.
+	if flag.Arg(0) == "merge-driver" {
+		os.Exit(runMergeDriver(flag.Args()[1:]))
+	}
+	// The REPL's first run asks how to set things up.
+	importNow := false
+	if flag.NArg() == 0 && needsSetup() {
+		var err error
+		if importNow, err = runSetup(); err != nil {
+			fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := setDataDir(); err != nil {
+		fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
+		os.Exit(1)
+	}
+	// Loading logs too: heed --verbose and --quiet until the config is in.
+	setupLogging(LogConfig{}, false)
 	state, err := loadState()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Fatal error: %v\n", err)
 		os.Exit(1)
 	}
-	fmt.Println("Welcome to the Go Bookmark Manager! Type 'help' for commands.")
-	scanner := bufio.NewScanner(os.Stdin)
+	if err := setupLogging(state.Config.Log, false); err != nil {
+		logger.Warn("ignoring the log config", "err", err)
+	}
+	plainOutput = plainOutput || state.Config.Plain
+	if importNow {
+		state.importBookmarks(context.Background(), false)
+	}
+	// One-shot mode: `bibliothermes <command> [args]` runs a single command.
+	if flag.NArg() > 0 {
+		state.handleCommand(strings.Join(flag.Args(), " "))
+		state.finishEnrichment()
+		err := state.saveState()
+		waitWebhooks()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not save: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(exitStatus)
+	}
+	interactive = true
+	if !quiet {
+		fmt.Println("Welcome to the Go Bookmark Manager! Type 'help' for commands.")
+	}
 	for {
 		fmt.Print("> ")
-		if !scanner.Scan() {
+		if !stdin.Scan() {
 			break
 		}
-		if state.handleCommand(scanner.Text()) {
+		// "/" (optionally followed by a first query) starts live search.
+		if line := strings.TrimSpace(stdin.Text()); strings.HasPrefix(line, "/") {
+			state.liveSearch(strings.TrimPrefix(line, "/"))
+			continue
+		}
+		if state.handleCommand(stdin.Text()) {
 			break
 		}
 	}
-	if err := state.saveState(); err != nil {
+	state.finishEnrichment()
+	err = state.saveState()
+	waitWebhooks()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Could not save on exit: %v\n", err)
-	} else {
-		fmt.Println("\nChanges saved. Goodbye! 👋")
+	} else if !quiet {
+		fmt.Println("\nChanges saved. Goodbye!" + decor(" 👋"))
 	}
 }
@@ -0,0 +1,350 @@
+// mdns.go
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	mdnsGroup = "224.0.0.251:5353"
+	// mdnsService is the DNS-SD service type instances advertise.
+	mdnsService = "_bibliothermes._tcp.local"
+	mdnsTypePTR = 12
+	mdnsTypeTXT = 16
+	mdnsTypeSRV = 33
+	mdnsTypeANY = 255
+	mdnsClassIN = 1
+)
+
+// lanPeer is an instance found on the LAN.
+type lanPeer struct {
+	ID   string
+	Name string
+	Addr string // host:port of its sync server
+}
+
+// peerSync is how syncing with a LAN peer went.
+type peerSync struct {
+	peer                    lanPeer
+	pushed, pulled, deleted int
+	err                     error
+}
+
+// =============================================================================
+// == 🏠 LAN PEERS
+// =============================================================================
+//
+// With server.lan set, the daemon serves sync and answers multicast DNS
+// (DNS-SD) queries for _bibliothermes._tcp, with the port of its server and
+// its peer ID. sync peer asks the LAN for instances and syncs with each, as
+// it would with a sync server. Peers prove to each other that they share the
+// token in server.token, without sending it (see peerauth.go).
+
+// advertiseLAN answers mDNS queries for the service until ctx is done.
+func advertiseLAN(ctx context.Context, id, name string, port int) error {
+	group, err := net.ResolveUDPAddr("udp4", mdnsGroup)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return fmt.Errorf("could not join the mDNS group: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+	go func() {
+		buf := make([]byte, 9000)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			queryID, ok := mdnsAsksForUs(buf[:n])
+			if !ok {
+				continue
+			}
+			// Queriers on port 5353 listen to the group; others (like
+			// sync peer) wait for a unicast answer.
+			to := from
+			if from.Port == group.Port {
+				to = group
+			}
+			conn.WriteToUDP(mdnsAnswer(queryID, id, name, port), to)
+		}
+	}()
+	return nil
+}
+
+// discoverLANPeers asks the LAN for instances and collects the answers
+// arriving within wait.
+func discoverLANPeers(wait time.Duration) ([]lanPeer, error) {
+	group, err := net.ResolveUDPAddr("udp4", mdnsGroup)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if _, err := conn.WriteToUDP(mdnsQuery(), group); err != nil {
+		return nil, fmt.Errorf("could not send the mDNS query: %w", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(wait))
+	var peers []lanPeer
+	seen := map[string]bool{}
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				return peers, nil
+			}
+			return peers, err
+		}
+		p, ok := parseMDNSAnswer(buf[:n])
+		if !ok || seen[p.ID] {
+			continue
+		}
+		seen[p.ID] = true
+		// The answer's source is the peer: no need for its A record.
+		p.Addr = net.JoinHostPort(from.IP.String(), p.Addr)
+		peers = append(peers, p)
+	}
+}
+
+// mdnsQuery is a one-shot query for the service's instances.
+func mdnsQuery() []byte {
+	msg := []byte{0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0} // ID 0, flags 0, 1 question
+	msg = mdnsName(msg, strings.Split(mdnsService, ".")...)
+	return binary.BigEndian.AppendUint32(msg, mdnsTypePTR<<16|mdnsClassIN)
+}
+
+// mdnsAnswer answers a query with the PTR, SRV and TXT records of this
+// instance, repeating the question as unicast answers must.
+func mdnsAnswer(queryID uint16, id, name string, port int) []byte {
+	service := strings.Split(mdnsService, ".")
+	instance := append([]string{name}, service...)
+	msg := binary.BigEndian.AppendUint16(nil, queryID)
+	msg = append(msg, 0x84, 0, 0, 1, 0, 3, 0, 0, 0, 0) // response, authoritative; 1 question, 3 answers
+	msg = mdnsName(msg, service...)
+	msg = binary.BigEndian.AppendUint32(msg, mdnsTypePTR<<16|mdnsClassIN)
+	record := func(owner []string, typ uint16, rdata []byte) {
+		msg = mdnsName(msg, owner...)
+		msg = binary.BigEndian.AppendUint16(msg, typ)
+		msg = binary.BigEndian.AppendUint16(msg, mdnsClassIN)
+		msg = binary.BigEndian.AppendUint32(msg, 120)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+		msg = append(msg, rdata...)
+	}
+	record(service, mdnsTypePTR, mdnsName(nil, instance...))
+	srv := binary.BigEndian.AppendUint32(nil, 0) // priority, weight
+	srv = binary.BigEndian.AppendUint16(srv, uint16(port))
+	record(instance, mdnsTypeSRV, mdnsName(srv, name, "local"))
+	var txt []byte
+	for _, kv := range []string{"id=" + id, "v=1"} {
+		txt = append(append(txt, byte(len(kv))), kv...)
+	}
+	record(instance, mdnsTypeTXT, txt)
+	return msg
+}
+
+// mdnsName appends a domain name made of labels.
+func mdnsName(msg []byte, labels ...string) []byte {
+	for _, l := range labels {
+		if l == "" {
+			continue
+		}
+		l = l[:min(len(l), 63)]
+		msg = append(append(msg, byte(len(l))), l...)
+	}
+	return append(msg, 0)
+}
+
+// mdnsReadName reads the name at off, following compression pointers, and
+// returns it with the offset just past it.
+func mdnsReadName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	next := -1
+	for hops := 0; hops < 32; hops++ {
+		if off >= len(msg) {
+			return "", 0, errors.New("name out of bounds")
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return strings.Join(labels, "."), next, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) {
+				return "", 0, errors.New("name out of bounds")
+			}
+			if next < 0 {
+				next = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errors.New("name out of bounds")
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+	return "", 0, errors.New("compression loop")
+}
+
+// mdnsAsksForUs reports whether msg is a query for the service, and its ID.
+func mdnsAsksForUs(msg []byte) (uint16, bool) {
+	if len(msg) < 12 || msg[2]&0x80 != 0 {
+		return 0, false
+	}
+	off := 12
+	for range binary.BigEndian.Uint16(msg[4:]) {
+		name, next, err := mdnsReadName(msg, off)
+		if err != nil || next+4 > len(msg) {
+			return 0, false
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		if strings.EqualFold(name, mdnsService) && (typ == mdnsTypePTR || typ == mdnsTypeANY) {
+			return binary.BigEndian.Uint16(msg), true
+		}
+		off = next + 4
+	}
+	return 0, false
+}
+
+// parseMDNSAnswer reads an instance of the service from an answer: its name
+// from the SRV record, with the port in Addr, and its ID from the TXT record.
+func parseMDNSAnswer(msg []byte) (lanPeer, bool) {
+	var p lanPeer
+	if len(msg) < 12 || msg[2]&0x80 == 0 {
+		return p, false
+	}
+	off := 12
+	for range binary.BigEndian.Uint16(msg[4:]) {
+		_, next, err := mdnsReadName(msg, off)
+		if err != nil {
+			return p, false
+		}
+		off = next + 4
+	}
+	records := int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+	for range records {
+		owner, next, err := mdnsReadName(msg, off)
+		if err != nil || next+10 > len(msg) {
+			return p, false
+		}
+		typ := binary.BigEndian.Uint16(msg[next:])
+		size := int(binary.BigEndian.Uint16(msg[next+8:]))
+		rdata := next + 10
+		if rdata+size > len(msg) {
+			return p, false
+		}
+		instance, isOurs := strings.CutSuffix(strings.ToLower(owner), "."+mdnsService)
+		switch {
+		case !isOurs:
+		case typ == mdnsTypeSRV && size >= 6:
+			p.Name = owner[:len(instance)]
+			p.Addr = strconv.Itoa(int(binary.BigEndian.Uint16(msg[rdata+4:])))
+		case typ == mdnsTypeTXT:
+			for i := rdata; i < rdata+size; {
+				n := int(msg[i])
+				if i+1+n > rdata+size {
+					break
+				}
+				if id, ok := strings.CutPrefix(string(msg[i+1:i+1+n]), "id="); ok {
+					p.ID = id
+				}
+				i += 1 + n
+			}
+		}
+		off = rdata + size
+	}
+	return p, p.ID != "" && p.Addr != ""
+}
+
+// syncPeers syncs with every instance found on the LAN, for `sync peer` and
+// the daemon, and returns how it went with each.
+func (s *AppState) syncPeers() ([]peerSync, error) {
+	if s.Config.Server.Token == "" {
+		return nil, errors.New("set server.token in the config to the token your machines share")
+	}
+	var peers []lanPeer
+	err := s.whileUnlocked(func() (err error) {
+		peers, err = discoverLANPeers(2 * time.Second)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	var results []peerSync
+	for _, p := range peers {
+		if p.ID == s.Config.Server.PeerID {
+			continue
+		}
+		r := peerSync{peer: p}
+		t := syncTarget{key: "peer:" + p.ID, url: "http://" + p.Addr, token: s.Config.Server.Token, lan: true, peerID: p.ID}
+		if err := s.whileUnlocked(func() error { return verifyPeer(t) }); err != nil {
+			r.err = fmt.Errorf("failed to authenticate, nothing sent: %w", err)
+		} else {
+			r.pushed, r.pulled, r.deleted, r.err = s.syncWith(t)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// startLANSync serves sync and advertises it on the LAN until ctx is done,
+// for the daemon.
+func (s *AppState) startLANSync(ctx context.Context) error {
+	s.ensureServerToken()
+	if s.Config.Server.PeerID == "" {
+		s.Config.Server.PeerID = newUUID()
+		s.saveConfig()
+	}
+	addr := s.Config.Server.Addr
+	if addr == "" {
+		addr = defaultLANAddr
+	}
+	_, portText, err := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portText)
+	if err != nil || port == 0 {
+		return fmt.Errorf("server.addr %q names no port", addr)
+	}
+	// Peers reach each other over plain HTTP: their requests are signed.
+	cfg := s.Config.Server
+	cfg.CertFile, cfg.KeyFile = "", ""
+	server, errs := startServer(addr, cfg, true)
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("sync server stopped", "err", err)
+		}
+	}()
+	name, _ := os.Hostname()
+	if name == "" {
+		name = "bibliothermes"
+	}
+	if err := advertiseLAN(ctx, s.Config.Server.PeerID, strings.SplitN(name, ".", 2)[0], port); err != nil {
+		return err
+	}
+	logger.Info("serving sync and advertising it on the LAN", "addr", addr)
+	return nil
+}
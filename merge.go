@@ -0,0 +1,298 @@
+// merge.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+)
+
+// tombstoneTTL is how long a deletion is remembered for merges: a copy last
+// synced longer ago than that brings deleted bookmarks back.
+const tombstoneTTL = 365 * 24 * time.Hour
+
+// =============================================================================
+// == 🔀 MERGING COPIES
+// =============================================================================
+//
+// When two machines share the collection through git, WebDAV or a synced
+// folder and both change it offline, their copies are merged rather than one
+// overwriting the other. Every bookmark field is a last-writer-wins register:
+// editBookmark stamps the fields it changes in Bookmark.Clock, and a merge
+// keeps, field by field, the value changed last (fields never edited date
+// from when the bookmark was added; equal times are broken by comparing the
+// values). Opens are the union of both copies. A deletion leaves a tombstone
+// in AppState.Tombstones that removes the bookmark from the other copy, unless
+// it was edited there after the deletion. Two bookmarks of the same URL
+// under different UUIDs, as two machines importing the same browser make,
+// become one: it keeps the lesser UUID and the other is buried. Merging the
+// same copies in either order gives the same bookmarks. IDs are local: a
+// bookmark from the other copy whose ID is taken gets a new one, and the
+// config stays as it is here.
+
+// stampFields records that the given fields of b changed at t.
+func (b *Bookmark) stampFields(fields map[string]any, t time.Time) {
+	for k := range fields {
+		if k == "id" || k == "clock" {
+			continue
+		}
+		if b.Clock == nil {
+			b.Clock = map[string]time.Time{}
+		}
+		b.Clock[k] = t
+	}
+}
+
+// changedAt is when field last changed.
+func (b Bookmark) changedAt(field string) time.Time {
+	if t, ok := b.Clock[field]; ok {
+		return t
+	}
+	return b.AddedAt
+}
+
+// lastChange is when any field of b last changed.
+func (b Bookmark) lastChange() time.Time {
+	last := b.AddedAt
+	for _, t := range b.Clock {
+		if t.After(last) {
+			last = t
+		}
+	}
+	return last
+}
+
+// buryBookmark leaves a tombstone for a deleted bookmark.
+func (s *AppState) buryBookmark(uuid string, t time.Time) {
+	if s.Tombstones == nil {
+		s.Tombstones = map[string]time.Time{}
+	}
+	s.Tombstones[uuid] = t
+}
+
+// mergeBookmarks merges two copies of the same bookmark, keeping a's ID.
+func mergeBookmarks(a, b Bookmark) Bookmark {
+	fa, fb := bookmarkFields(a), bookmarkFields(b)
+	merged := make(map[string]any, len(fa))
+	for k := range mergedKeys(fa, fb) {
+		if k == "clock" {
+			continue
+		}
+		winner := fa
+		ta, tb := a.changedAt(k), b.changedAt(k)
+		if tb.After(ta) || tb.Equal(ta) && mergeTieBreak(fb[k], fa[k]) {
+			winner = fb
+		}
+		if v, ok := winner[k]; ok {
+			merged[k] = v
+		}
+	}
+	data, _ := json.Marshal(merged)
+	var m Bookmark
+	json.Unmarshal(data, &m)
+	m.ID = a.ID
+	m.OpenCount = max(a.OpenCount, b.OpenCount)
+	m.Opens = mergeOpens(a.Opens, b.Opens)
+	m.Clock = maps.Clone(a.Clock)
+	for k, t := range b.Clock {
+		if t.After(m.Clock[k]) {
+			if m.Clock == nil {
+				m.Clock = map[string]time.Time{}
+			}
+			m.Clock[k] = t
+		}
+	}
+	return m
+}
+
+// mergeTieBreak decides between two values changed at the same time: the
+// greater JSON encoding wins, so both sides pick the same one.
+func mergeTieBreak(v, than any) bool {
+	x, _ := json.Marshal(v)
+	y, _ := json.Marshal(than)
+	return bytes.Compare(x, y) > 0
+}
+
+// mergeOpens returns the union of two open histories, oldest first, capped
+// like recordOpen caps it.
+func mergeOpens(a, b []time.Time) []time.Time {
+	opens := append(slices.Clone(a), b...)
+	slices.SortFunc(opens, func(x, y time.Time) int { return x.Compare(y) })
+	opens = slices.CompactFunc(opens, time.Time.Equal)
+	if len(opens) > maxOpenHistory {
+		opens = opens[len(opens)-maxOpenHistory:]
+	}
+	return opens
+}
+
+// mergeState merges other into s and returns the UUIDs of the bookmarks it
+// added or changed and of those it deleted.
+func (s *AppState) mergeState(other *AppState, now time.Time) (changed, deleted []string) {
+	for uuid, t := range other.Tombstones {
+		if t.After(s.Tombstones[uuid]) {
+			s.buryBookmark(uuid, t)
+		}
+	}
+	for uuid, t := range s.Tombstones {
+		if now.Sub(t) > tombstoneTTL {
+			delete(s.Tombstones, uuid)
+		}
+	}
+	// A bookmark stays dead unless it was edited after it was deleted.
+	dead := func(b Bookmark) bool {
+		t, ok := s.Tombstones[b.UUID]
+		return ok && !b.lastChange().After(t)
+	}
+	ids := make(map[int]bool, len(s.Bookmarks))
+	for _, b := range s.Bookmarks {
+		ids[b.ID] = true
+	}
+	for _, ob := range other.Bookmarks {
+		if dead(ob) {
+			continue
+		}
+		if i := s.indexOfUUID(ob.UUID); i >= 0 {
+			if m := mergeBookmarks(s.Bookmarks[i], ob); !bytes.Equal(jsonBytes(m), jsonBytes(s.Bookmarks[i])) {
+				s.Bookmarks[i] = m
+				changed = append(changed, m.UUID)
+			}
+			continue
+		}
+		// indexOfURL compares URLs in their punycode form, as addBookmark
+		// does.
+		if i := s.indexOfURL(ob.URL); i >= 0 && !dead(s.Bookmarks[i]) {
+			local := s.Bookmarks[i]
+			m := mergeBookmarks(local, ob)
+			m.UUID = min(local.UUID, ob.UUID)
+			lost := max(local.UUID, ob.UUID)
+			s.buryBookmark(lost, now)
+			s.Bookmarks[i], s.index = m, nil
+			changed = append(changed, m.UUID)
+			if lost == local.UUID {
+				deleted = append(deleted, lost)
+			}
+			continue
+		}
+		if ids[ob.ID] || ob.ID <= 0 {
+			ob.ID = s.nextID
+		}
+		ids[ob.ID] = true
+		s.nextID = max(s.nextID, ob.ID+1)
+		s.Bookmarks = append(s.Bookmarks, ob)
+		s.appended()
+		changed = append(changed, ob.UUID)
+	}
+	kept := s.Bookmarks[:0]
+	for _, b := range s.Bookmarks {
+		switch {
+		case dead(b):
+			deleted = append(deleted, b.UUID)
+			continue
+		case !s.Tombstones[b.UUID].IsZero():
+			// Edited after its deletion: it lives on.
+			delete(s.Tombstones, b.UUID)
+		}
+		kept = append(kept, b)
+	}
+	clear(s.Bookmarks[len(kept):])
+	s.Bookmarks = kept
+	s.index = nil
+	changed = slices.DeleteFunc(changed, func(uuid string) bool { return slices.Contains(deleted, uuid) })
+	return changed, deleted
+}
+
+func jsonBytes(v any) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// readStateFile reads a copy of bookmarks.json, compressed or not.
+func readStateFile(path string) (*AppState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = gunzipIfNeeded(data); err != nil {
+		return nil, fmt.Errorf("could not decompress %s: %w", path, err)
+	}
+	state := &AppState{nextID: 1}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	for _, b := range state.Bookmarks {
+		state.nextID = max(state.nextID, b.ID+1)
+	}
+	return state, nil
+}
+
+// runMerge runs `merge <file>`: it merges another copy of the collection,
+// such as a sync client's conflicted copy, into this one.
+func (s *AppState) runMerge(path string) error {
+	other, err := readStateFile(path)
+	if err != nil {
+		return err
+	}
+	changed, deleted := s.mergeState(other, time.Now())
+	for _, uuid := range changed {
+		if i := s.indexOfUUID(uuid); i >= 0 {
+			s.journalBookmarks(i)
+		}
+	}
+	for _, name := range s.mergeCollections(other, time.Now()) {
+		c := s.Collections[name]
+		writeJournal(journalEntry{Op: journalCollectionOp, Name: name, Collection: &c})
+	}
+	for _, uuid := range deleted {
+		writeJournal(journalEntry{Op: journalDeleteOp, UUID: uuid, At: s.Tombstones[uuid]})
+	}
+	detail := fmt.Sprintf("merged %s: %d bookmarks added or changed, %d deleted", path, len(changed), len(deleted))
+	s.writeAudit(AuditEntry{Op: "merge", Detail: detail})
+	fmt.Printf("Merged %s: %d bookmarks added or changed, %d deleted.\n", path, len(changed), len(deleted))
+	return nil
+}
+
+// runMergeDriver runs `merge-driver <base> <ours> <theirs>` for git: it
+// merges theirs into ours, whatever the common base, and writes the result
+// to ours. It returns the exit status git expects: 0 for a clean merge.
+func runMergeDriver(args []string) int {
+	if len(args) != 3 {
+		fmt.Fprintln(os.Stderr, "Usage: merge-driver %O %A %B")
+		return 2
+	}
+	ours, err := readStateFile(args[1])
+	if err == nil {
+		// Compress as the collection is compressed.
+		if c, _, err := readConfigFile(); err == nil {
+			ours.Config, _, _ = effectiveConfig(c)
+		}
+		var theirs *AppState
+		if theirs, err = readStateFile(args[2]); err == nil {
+			ours.mergeState(theirs, time.Now())
+			ours.mergeCollections(theirs, time.Now())
+			err = writeStateFile(args[1], ours)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "bibliothermes merge: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// writeStateFile writes state to path the way saveState writes bookmarks.json.
+func writeStateFile(path string, state *AppState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if state.Config.Compress {
+		if data, err = gzipBytes(data); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, data, 0644)
+}
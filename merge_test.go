@@ -0,0 +1,56 @@
+// merge_test.go
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestMergeSameURL merges two copies that imported the same bookmark on
+// their own, under different UUIDs.
+func TestMergeSameURL(t *testing.T) {
+	added := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := added.Add(48 * time.Hour)
+	copies := func() (*AppState, *AppState) {
+		a := &AppState{nextID: 2, Bookmarks: []Bookmark{{
+			ID: 1, UUID: "bbbb", Name: "Go", URL: "https://go.dev", AddedAt: added,
+			Tags: []string{"golang"}, Clock: map[string]time.Time{"tags": added.Add(time.Hour)},
+		}}}
+		b := &AppState{nextID: 2, Bookmarks: []Bookmark{{
+			ID: 1, UUID: "aaaa", Name: "The Go language", URL: "https://go.dev", AddedAt: added,
+			Clock: map[string]time.Time{"name": added.Add(2 * time.Hour)},
+		}}}
+		return a, b
+	}
+
+	a, b := copies()
+	changed, deleted := a.mergeState(b, now)
+	if len(a.Bookmarks) != 1 {
+		t.Fatalf("%d bookmarks after the merge, want 1", len(a.Bookmarks))
+	}
+	got := a.Bookmarks[0]
+	if got.UUID != "aaaa" || got.Name != "The Go language" || !slices.Equal(got.Tags, []string{"golang"}) {
+		t.Errorf("merged bookmark = %+v", got)
+	}
+	if !slices.Equal(changed, []string{"aaaa"}) || !slices.Equal(deleted, []string{"bbbb"}) {
+		t.Errorf("changed %v, deleted %v", changed, deleted)
+	}
+	if a.Tombstones["bbbb"].IsZero() {
+		t.Error("the UUID merged away left no tombstone")
+	}
+
+	// The other way round gives the same bookmark.
+	a2, b2 := copies()
+	b2.mergeState(a2, now)
+	if len(b2.Bookmarks) != 1 || jsonString(b2.Bookmarks[0]) != jsonString(got) {
+		t.Errorf("merging the other way gives %+v", b2.Bookmarks)
+	}
+
+	// Merging back the copy that still has the buried UUID changes nothing.
+	if changed, deleted := a.mergeState(a2, now.Add(time.Hour)); len(changed)+len(deleted) != 0 || len(a.Bookmarks) != 1 {
+		t.Errorf("merging again: changed %v, deleted %v, %d bookmarks", changed, deleted, len(a.Bookmarks))
+	}
+}
+
+func jsonString(v any) string { return string(jsonBytes(v)) }
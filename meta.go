@@ -0,0 +1,106 @@
+// meta.go
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// =============================================================================
+// == 🏷️ CUSTOM METADATA
+// =============================================================================
+//
+// Bookmarks can carry fields of the user's own, for whatever the built-in ones
+// don't cover: a ticket number, a client's name. Keys are lower-cased like
+// tags; values are kept as typed. meta:key=value finds them in queries, and
+// meta:key finds the bookmarks that have the key at all.
+
+// metaCommand runs `meta <id>` (list the fields), `meta set <id> <key>
+// <value>` and `meta unset <id> <key>`.
+func (s *AppState) metaCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: meta <id> | meta set <id> <key> <value> | meta unset <id> <key>")
+	}
+	switch args[0] {
+	case "set":
+		if len(args) < 4 {
+			return fmt.Errorf("usage: meta set <id> <key> <value>")
+		}
+		i, ok := s.findBookmark(args[1])
+		if !ok {
+			return nil
+		}
+		key, err := metaKey(args[2])
+		if err != nil {
+			return err
+		}
+		value := strings.Join(args[3:], " ")
+		s.editBookmark(i, func(b *Bookmark) {
+			b.Meta = maps.Clone(b.Meta)
+			if b.Meta == nil {
+				b.Meta = map[string]string{}
+			}
+			b.Meta[key] = value
+		})
+		fmt.Printf("Set %s of '%s' to %s.\n", key, s.Bookmarks[i].Name, value)
+	case "unset", "rm":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: meta unset <id> <key>")
+		}
+		i, ok := s.findBookmark(args[1])
+		if !ok {
+			return nil
+		}
+		key := strings.ToLower(args[2])
+		if _, ok := s.Bookmarks[i].Meta[key]; !ok {
+			return fmt.Errorf("'%s' has no %s", s.Bookmarks[i].Name, key)
+		}
+		s.editBookmark(i, func(b *Bookmark) {
+			b.Meta = maps.Clone(b.Meta)
+			delete(b.Meta, key)
+			if len(b.Meta) == 0 {
+				b.Meta = nil
+			}
+		})
+		fmt.Printf("Removed %s from '%s'.\n", key, s.Bookmarks[i].Name)
+	default:
+		i, ok := s.findBookmark(args[0])
+		if !ok {
+			return nil
+		}
+		b := s.Bookmarks[i]
+		if len(b.Meta) == 0 {
+			fmt.Printf("'%s' has no custom fields. Add one with: meta set %d <key> <value>\n", b.Name, b.ID)
+		}
+		for _, key := range slices.Sorted(maps.Keys(b.Meta)) {
+			fmt.Printf("%s: %s\n", key, b.Meta[key])
+		}
+	}
+	return nil
+}
+
+// metaKey checks a custom field name: a word the query language can name,
+// so no spaces, quotes, parentheses, colons or equals signs.
+func metaKey(key string) (string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if key == "" || strings.ContainsAny(key, " \t\"():=") {
+		return "", fmt.Errorf("invalid field name %q: use a single word without : or =", key)
+	}
+	return key, nil
+}
+
+// metaField is the meta: query field: meta:key=value matches a field's value
+// ignoring case, meta:key any bookmark that has the field.
+func metaField(v string) (predicate, error) {
+	key, value, hasValue := strings.Cut(v, "=")
+	key = strings.ToLower(key)
+	if key == "" {
+		return nil, fmt.Errorf("want key or key=value, got %q", v)
+	}
+	return func(b Bookmark) bool {
+		got, ok := b.Meta[key]
+		return ok && (!hasValue || strings.EqualFold(got, value))
+	}, nil
+}
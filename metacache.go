@@ -0,0 +1,219 @@
+// metacache.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metaCacheFile = "cache.json"
+	// maxPageBytes is how much of a page is read looking for its metadata.
+	maxPageBytes = 512 << 10
+)
+
+// pageMeta is what we know about a URL from the last time its page was
+// fetched. ETag and LastModified are the validators of that fetch, so a later
+// conditional request answered with 304 means every field is still current.
+type pageMeta struct {
+	Status       int       `json:"status"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Title        string    `json:"title,omitempty"`
+	Favicon      string    `json:"favicon,omitempty"`
+	Lang         string    `json:"lang,omitempty"`
+	// OG holds the page's og:* properties (og:title, og:description, og:image...).
+	OG map[string]string `json:"og,omitempty"`
+}
+
+// metaCache is the persistent, URL-keyed cache of page metadata. It is safe
+// for concurrent use by the workers of forEachWebBookmark.
+type metaCache struct {
+	mu      sync.Mutex
+	entries map[string]pageMeta
+	dirty   bool
+}
+
+// =============================================================================
+// == 🗃️ HTTP METADATA CACHE
+// =============================================================================
+
+// loadMetaCache reads the cache file. A missing or unreadable cache is not an
+// error: it only costs a full fetch.
+func loadMetaCache() *metaCache {
+	c := &metaCache{entries: map[string]pageMeta{}}
+	data, err := os.ReadFile(dataPath(metaCacheFile))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("could not read", "file", metaCacheFile, "err", err)
+		}
+		return c
+	}
+	if err := json.Unmarshal(data, &c.entries); err != nil {
+		logger.Warn("ignoring unreadable file", "file", metaCacheFile, "err", err)
+		c.entries = map[string]pageMeta{}
+	}
+	return c
+}
+
+func (c *metaCache) get(url string) (pageMeta, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m, ok := c.entries[url]
+	return m, ok
+}
+
+func (c *metaCache) put(url string, m pageMeta) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[url] = m
+	c.dirty = true
+}
+
+// save writes the cache back if anything changed.
+func (c *metaCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	data, err := json.Marshal(c.entries)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(dataPath(metaCacheFile), data, 0644); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// setValidators makes req conditional on the cached copy of its URL.
+func (m pageMeta) setValidators(req *http.Request) {
+	if m.ETag != "" {
+		req.Header.Set("If-None-Match", m.ETag)
+	}
+	if m.LastModified != "" {
+		req.Header.Set("If-Modified-Since", m.LastModified)
+	}
+}
+
+func (m pageMeta) hasValidators() bool {
+	return m.ETag != "" || m.LastModified != ""
+}
+
+// fetchMeta returns the metadata of the page at rawURL, revalidating a cached
+// copy with If-None-Match/If-Modified-Since instead of downloading it again.
+func fetchMeta(ctx context.Context, client *http.Client, cache *metaCache, rawURL string) (pageMeta, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return pageMeta{}, err
+	}
+	cached, ok := cache.get(rawURL)
+	if ok && cached.Status < 400 {
+		cached.setValidators(req)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return pageMeta{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && ok {
+		cached.FetchedAt = time.Now()
+		cache.put(rawURL, cached)
+		return cached, nil
+	}
+	m := pageMeta{
+		Status:       resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}
+	if resp.StatusCode >= 400 {
+		cache.put(rawURL, m)
+		return m, fmt.Errorf("server answered %s", resp.Status)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBytes))
+	if err != nil {
+		return pageMeta{}, err
+	}
+	m.parsePage(page, resp.Request.URL)
+	m.Lang = pageLanguage(page, resp.Header.Get("Content-Language"), m.OG)
+	cache.put(rawURL, m)
+	return m, nil
+}
+
+var (
+	titleRe   = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	metaTagRe = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	linkTagRe = regexp.MustCompile(`(?is)<link\s[^>]*>`)
+	attrRe    = regexp.MustCompile(`(?is)([a-z:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+)
+
+// parsePage fills in the title, og:* properties and favicon from the page's
+// HTML. base is the final URL after redirects, for resolving relative links.
+func (m *pageMeta) parsePage(page []byte, base *url.URL) {
+	if match := titleRe.FindSubmatch(page); match != nil {
+		m.Title = cleanText(string(match[1]))
+	}
+	for _, tag := range metaTagRe.FindAll(page, -1) {
+		attrs := tagAttrs(tag)
+		prop := strings.ToLower(attrs["property"])
+		if prop == "" {
+			prop = strings.ToLower(attrs["name"])
+		}
+		if strings.HasPrefix(prop, "og:") && attrs["content"] != "" {
+			if m.OG == nil {
+				m.OG = map[string]string{}
+			}
+			m.OG[prop] = cleanText(attrs["content"])
+		}
+	}
+	if img := m.OG["og:image"]; img != "" {
+		m.OG["og:image"] = resolveURL(base, img)
+	}
+	m.Favicon = resolveURL(base, "/favicon.ico")
+	for _, tag := range linkTagRe.FindAll(page, -1) {
+		attrs := tagAttrs(tag)
+		for _, rel := range strings.Fields(strings.ToLower(attrs["rel"])) {
+			if rel == "icon" && attrs["href"] != "" {
+				m.Favicon = resolveURL(base, attrs["href"])
+				return
+			}
+		}
+	}
+}
+
+// tagAttrs returns the attributes of one HTML tag, keyed by lowercase name.
+func tagAttrs(tag []byte) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range attrRe.FindAllSubmatch(tag, -1) {
+		attrs[strings.ToLower(string(a[1]))] = html.UnescapeString(string(a[2]) + string(a[3]) + string(a[4]))
+	}
+	return attrs
+}
+
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+func resolveURL(base *url.URL, ref string) string {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return base.ResolveReference(u).String()
+}
@@ -0,0 +1,195 @@
+// metrics.go
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the request latency
+// histogram buckets.
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram counts observations in latencyBuckets.
+type histogram struct {
+	counts []int64 // one per bucket, not cumulative
+	count  int64
+	sum    float64
+}
+
+// metrics is what this process has done since it started, for /metrics.
+var metrics = struct {
+	sync.Mutex
+	imported     int64
+	checks       int64
+	lastCheck    time.Duration
+	jobRuns      map[string]int64
+	lastJob      map[string]time.Duration
+	requests     map[string]*histogram
+	startedAtSec float64
+}{
+	jobRuns:      map[string]int64{},
+	lastJob:      map[string]time.Duration{},
+	requests:     map[string]*histogram{},
+	startedAtSec: float64(time.Now().UnixNano()) / 1e9,
+}
+
+// =============================================================================
+// == 📈 METRICS
+// =============================================================================
+//
+// serve and the daemon expose /metrics in the Prometheus text format: gauges
+// read from the collection when scraped, and counters of what the process did
+// (imports, link checks, jobs, API requests) since it started.
+
+// recordImport counts imported bookmarks.
+func recordImport(n int) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.imported += int64(n)
+}
+
+// recordCheck counts a link check that took d.
+func recordCheck(d time.Duration) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.checks++
+	metrics.lastCheck = d
+}
+
+// recordJob counts a daemon job run that took d.
+func recordJob(name string, d time.Duration) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	metrics.jobRuns[name]++
+	metrics.lastJob[name] = d
+}
+
+// recordRequest adds a request to the latency histogram of its route.
+func recordRequest(route string, d time.Duration) {
+	metrics.Lock()
+	defer metrics.Unlock()
+	h := metrics.requests[route]
+	if h == nil {
+		h = &histogram{counts: make([]int64, len(latencyBuckets))}
+		metrics.requests[route] = h
+	}
+	secs := d.Seconds()
+	if i, _ := slices.BinarySearch(latencyBuckets, secs); i < len(latencyBuckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += secs
+}
+
+// timeRequests records how long each request handled by next takes, by the
+// route pattern that matched it.
+func timeRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		logger.Debug("request served", "route", route, "client", r.RemoteAddr, "duration", time.Since(start))
+		recordRequest(route, time.Since(start))
+	})
+}
+
+// handleMetrics answers GET /metrics.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	freshStateMu.Lock()
+	s, err := loadState()
+	freshStateMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, s)
+}
+
+// writeMetrics writes the metrics of s and of this process.
+func writeMetrics(w io.Writer, s *AppState) {
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP bibliothermes_%s %s\n# TYPE bibliothermes_%s %s\n", name, help, name, kind)
+	}
+	var dead, trashed, favorites int
+	for _, b := range s.Bookmarks {
+		if b.Check.Dead() {
+			dead++
+		}
+		if b.trashed() {
+			trashed++
+		}
+		if b.Favorite {
+			favorites++
+		}
+	}
+	metric("bookmarks", "gauge", "Bookmarks in the collection, trashed ones included.")
+	fmt.Fprintf(w, "bibliothermes_bookmarks %d\n", len(s.Bookmarks))
+	metric("dead_links", "gauge", "Bookmarks the last link check found broken.")
+	fmt.Fprintf(w, "bibliothermes_dead_links %d\n", dead)
+	metric("trashed_bookmarks", "gauge", "Bookmarks in the trash.")
+	fmt.Fprintf(w, "bibliothermes_trashed_bookmarks %d\n", trashed)
+	metric("favorite_bookmarks", "gauge", "Favorite bookmarks.")
+	fmt.Fprintf(w, "bibliothermes_favorite_bookmarks %d\n", favorites)
+
+	metrics.Lock()
+	defer metrics.Unlock()
+	metric("start_time_seconds", "gauge", "When the process started, in Unix seconds.")
+	fmt.Fprintf(w, "bibliothermes_start_time_seconds %g\n", metrics.startedAtSec)
+	metric("imported_bookmarks_total", "counter", "Bookmarks imported by this process.")
+	fmt.Fprintf(w, "bibliothermes_imported_bookmarks_total %d\n", metrics.imported)
+	metric("link_checks_total", "counter", "Link checks run by this process.")
+	fmt.Fprintf(w, "bibliothermes_link_checks_total %d\n", metrics.checks)
+	metric("last_link_check_duration_seconds", "gauge", "How long the last link check took.")
+	fmt.Fprintf(w, "bibliothermes_last_link_check_duration_seconds %g\n", metrics.lastCheck.Seconds())
+
+	metric("job_runs_total", "counter", "Daemon job runs, by job.")
+	for _, job := range slices.Sorted(maps.Keys(metrics.jobRuns)) {
+		fmt.Fprintf(w, "bibliothermes_job_runs_total{job=%q} %d\n", job, metrics.jobRuns[job])
+	}
+	metric("last_job_duration_seconds", "gauge", "How long the last run of each daemon job took.")
+	for _, job := range slices.Sorted(maps.Keys(metrics.lastJob)) {
+		fmt.Fprintf(w, "bibliothermes_last_job_duration_seconds{job=%q} %g\n", job, metrics.lastJob[job].Seconds())
+	}
+
+	metric("http_request_duration_seconds", "histogram", "API request latencies, by route.")
+	for _, route := range slices.Sorted(maps.Keys(metrics.requests)) {
+		h := metrics.requests[route]
+		var cumulative int64
+		for i, le := range latencyBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "bibliothermes_http_request_duration_seconds_bucket{route=%q,le=\"%g\"} %d\n", route, le, cumulative)
+		}
+		fmt.Fprintf(w, "bibliothermes_http_request_duration_seconds_bucket{route=%q,le=\"+Inf\"} %d\n", route, h.count)
+		fmt.Fprintf(w, "bibliothermes_http_request_duration_seconds_sum{route=%q} %g\n", route, h.sum)
+		fmt.Fprintf(w, "bibliothermes_http_request_duration_seconds_count{route=%q} %d\n", route, h.count)
+	}
+}
+
+// startMetricsServer serves /metrics alone on addr, for a daemon that serves
+// nothing else, until ctx is done.
+func startMetricsServer(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", handleMetrics)
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("metrics server stopped", "err", err)
+		}
+	}()
+}
@@ -0,0 +1,91 @@
+// mirror.go
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Values of Config.MirrorAction.
+const (
+	mirrorKeep   = "keep" // the default
+	mirrorTrash  = "trash"
+	mirrorShelve = "shelve"
+)
+
+// =============================================================================
+// == 🪞 MIRRORED IMPORTS
+// =============================================================================
+//
+// Imports only add: a bookmark deleted in the browser stays here for good.
+// For the browsers listed in mirror_imports, an import also looks for the
+// bookmarks it brought from a profile that the profile no longer has, and
+// offers to move them to the trash or the shelf; elsewhere than at a
+// terminal, as in the daemon, mirror_action says what to do, and by default
+// they are only counted and reported. Those profiles are read in full each
+// time, Firefox ones included.
+
+// mirrored reports whether imports from browser, or from the profile
+// labelled label ("Firefox (work)"), mirror its deletions.
+func (s *AppState) mirrored(browser, label string) bool {
+	return slices.ContainsFunc(s.Config.MirrorImports, func(m string) bool {
+		return strings.EqualFold(m, browser) || strings.EqualFold(m, label)
+	})
+}
+
+// goneFrom returns the indexes of the bookmarks imported from the file at
+// path whose URLs are no longer among found, leaving out those already in
+// the trash or on the shelf.
+func (s *AppState) goneFrom(path string, found []importedBookmark) []int {
+	present := make(map[string]bool, len(found))
+	for _, b := range found {
+		present[asciiURL(b.url)] = true
+	}
+	var gone []int
+	for i, b := range s.Bookmarks {
+		if b.Source != nil && b.Source.Path == path && !present[b.URL] && !b.trashed() && !b.shelved() {
+			gone = append(gone, i)
+		}
+	}
+	return gone
+}
+
+// mirrorDeletions settles the bookmarks gone from the file at path, read by
+// an import that found found there.
+func (s *AppState) mirrorDeletions(label, path string, found []importedBookmark) {
+	// A profile with no bookmarks at all is more likely unreadable than
+	// emptied; don't take it at its word.
+	if len(found) == 0 {
+		return
+	}
+	gone := s.goneFrom(path, found)
+	if len(gone) == 0 {
+		return
+	}
+	s.countImport(label, func(c *importSourceCount) { c.Gone += len(gone) })
+	action := map[string]string{mirrorTrash: "t", mirrorShelve: "s"}[s.Config.MirrorAction]
+	if interactive {
+		clearProgress()
+		fmt.Printf("%d bookmarks imported from %s are no longer there:\n", len(gone), label)
+		for _, i := range gone {
+			printTreeLeaf(s.Bookmarks[i], "  ")
+		}
+		action = askKey("Move them to the trash or to the shelf? [t/s, Enter keeps them] ")
+	}
+	switch action {
+	case "t":
+		s.trashBookmarks(gone, time.Now())
+		fmt.Printf("Moved %d bookmarks gone from %s to the trash.\n", len(gone), label)
+	case "s":
+		s.shelve(gone, time.Now())
+		fmt.Printf("Shelved %d bookmarks gone from %s.\n", len(gone), label)
+	default:
+		if interactive {
+			fmt.Println("Kept them.")
+		} else {
+			logger.Info("bookmarks gone from a mirrored browser are kept; set mirror_action to trash or shelve them", "source", label, "count", len(gone))
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// net.go
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// defaultUserAgent is sent unless Config.UserAgent says otherwise; many sites
+// turn away Go's own "Go-http-client/1.1".
+const defaultUserAgent = "Mozilla/5.0 (compatible; bibliothermes)"
+
+// netConfig is the Config of the loaded state, for code without one:
+// newHTTPClient reads its network settings, sortByName its locale.
+var netConfig Config
+
+// =============================================================================
+// == 📡 HTTP
+// =============================================================================
+
+// newHTTPClient returns the client used by every network feature. Requests go
+// through Config.Proxy if set, otherwise through the proxy named by
+// HTTPS_PROXY/HTTP_PROXY or ALL_PROXY, except for hosts in NO_PROXY. Proxies
+// may be http://, https:// or socks5:// URLs.
+func newHTTPClient() *http.Client {
+	return newClient(proxyFor, 15*time.Second)
+}
+
+// newClient returns a client using proxy that sends the configured
+// User-Agent and headers.
+func newClient(proxy func(*http.Request) (*url.URL, error), timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	return &http.Client{Timeout: timeout, Transport: headerTransport{transport}}
+}
+
+// headerTransport adds Config.UserAgent and Config.Headers to requests that
+// don't set those headers themselves, and the credentials Config.Auth has for
+// the request's host.
+type headerTransport struct {
+	base http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, value := range netConfig.Headers {
+		if req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	addDomainAuth(req)
+	if req.Header.Get("User-Agent") == "" {
+		ua := netConfig.UserAgent
+		if ua == "" {
+			ua = defaultUserAgent
+		}
+		req.Header.Set("User-Agent", ua)
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		logger.Debug("HTTP request failed", "method", req.Method, "url", req.URL.Redacted(), "err", err)
+	} else {
+		logger.Debug("HTTP request", "method", req.Method, "url", req.URL.Redacted(), "status", resp.StatusCode, "duration", time.Since(start).Round(time.Millisecond))
+	}
+	return resp, err
+}
+
+// proxyFor picks the proxy for a request, or nil to connect directly.
+func proxyFor(req *http.Request) (*url.URL, error) {
+	switch netConfig.Proxy {
+	case "":
+	case "direct", "none":
+		return nil, nil
+	default:
+		if noProxy(req.URL.Hostname()) {
+			return nil, nil
+		}
+		return parseProxy(netConfig.Proxy)
+	}
+	if proxy, err := http.ProxyFromEnvironment(req); proxy != nil || err != nil {
+		return proxy, err
+	}
+	all := os.Getenv("ALL_PROXY")
+	if all == "" {
+		all = os.Getenv("all_proxy")
+	}
+	if all == "" || noProxy(req.URL.Hostname()) {
+		return nil, nil
+	}
+	return parseProxy(all)
+}
+
+// parseProxy parses a proxy setting; a bare host:port means an HTTP proxy.
+func parseProxy(raw string) (*url.URL, error) {
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return u, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+}
+
+// noProxy reports whether host is exempt from proxying: loopback addresses,
+// and hosts matching an entry of NO_PROXY ("*", "example.com", ".example.com").
+func noProxy(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	list := os.Getenv("NO_PROXY")
+	if list == "" {
+		list = os.Getenv("no_proxy")
+	}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(entry), "."))
+		if entry == "*" {
+			return true
+		}
+		if entry != "" && hostMatches(strings.ToLower(host), entry) {
+			return true
+		}
+	}
+	return false
+}
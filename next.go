@@ -0,0 +1,90 @@
+// next.go
+package main
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// =============================================================================
+// == ⏭️ NEXT
+// =============================================================================
+//
+// next works through a list one bookmark at a time, like a course player:
+// next <collection> opens the collection's first unread bookmark from where
+// the last one left off, and plain next the oldest unread bookmark of the
+// collection, the read-later queue. Each is marked read once opened, so the
+// next call moves on, and anything read some other way is skipped.
+
+// nextCommand runs `next [--tor] [collection]`.
+func (s *AppState) nextCommand(args []string) error {
+	tor := slices.Contains(args, "--tor")
+	args = slices.DeleteFunc(args, func(a string) bool { return a == "--tor" })
+	switch len(args) {
+	case 0:
+		return s.openNextUnread(tor)
+	case 1:
+		return s.openCollectionNext(args[0], tor)
+	}
+	return fmt.Errorf("usage: next [--tor] [collection]")
+}
+
+// openCollectionNext opens the first unread bookmark of a collection at or
+// after its cursor, wrapping around, marks it read and moves the cursor past
+// it.
+func (s *AppState) openCollectionNext(name string, tor bool) error {
+	c, err := s.collection(name)
+	if err != nil {
+		return err
+	}
+	if len(c.Items) == 0 {
+		return fmt.Errorf("%s is empty", name)
+	}
+	for k := range len(c.Items) {
+		pos := (c.Next + k) % len(c.Items)
+		i := s.indexOfUUID(c.Items[pos])
+		if s.Bookmarks[i].Read {
+			continue
+		}
+		fmt.Printf("Opening #%d of %d in %s: '%s'...\n", pos+1, len(c.Items), name, s.Bookmarks[i].Name)
+		if err := s.openNext(i, tor); err != nil {
+			return err
+		}
+		c.Next = (pos + 1) % len(c.Items)
+		s.setCollection(name, c)
+		return nil
+	}
+	fmt.Printf("You have read all of %s; read <id> marks a bookmark unread to go back to it.\n", name)
+	return nil
+}
+
+// openNextUnread opens the oldest unread bookmark, leaving out the shelf, the
+// trash and snoozed bookmarks.
+func (s *AppState) openNextUnread(tor bool) error {
+	next := -1
+	now := time.Now()
+	for i, b := range s.Bookmarks {
+		if b.Read || b.shelved() || b.trashed() || b.snoozed(now) {
+			continue
+		}
+		if next < 0 || b.addedAt().Before(s.Bookmarks[next].addedAt()) {
+			next = i
+		}
+	}
+	if next < 0 {
+		fmt.Println("Nothing left to read.")
+		return nil
+	}
+	fmt.Printf("Opening '%s'...\n", s.Bookmarks[next].Name)
+	return s.openNext(next, tor)
+}
+
+// openNext opens the bookmark at index i and marks it read.
+func (s *AppState) openNext(i int, tor bool) error {
+	if err := s.openAt(i, tor); err != nil {
+		return err
+	}
+	s.editBookmark(i, func(b *Bookmark) { b.Read = true })
+	return nil
+}
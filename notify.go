@@ -0,0 +1,57 @@
+// notify.go
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// =============================================================================
+// == 🔔 DESKTOP NOTIFICATIONS
+// =============================================================================
+
+// notifyDesktop shows a desktop notification when they are enabled in the
+// config. It is meant for background work (daemon jobs, one-shot runs from a
+// scheduler) where nobody is watching the terminal.
+func (s *AppState) notifyDesktop(title, body string) {
+	if !s.Config.Notifications {
+		return
+	}
+	if err := sendNotification(title, body); err != nil {
+		logger.Warn("could not show desktop notification", "err", err)
+	}
+}
+
+// sendNotification uses notify-send, osascript or a PowerShell toast.
+func sendNotification(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		cmd = exec.Command("osascript", "-e", script)
+	case "windows":
+		script := `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode(` + powerShellString(title) + `)) > $null
+$text.Item(1).AppendChild($xml.CreateTextNode(` + powerShellString(body) + `)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('Bibliothermes').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+	default:
+		cmd = exec.Command("notify-send", "--app-name=bibliothermes", title, body)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
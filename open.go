@@ -0,0 +1,168 @@
+// open.go
+package main
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Special values in Config.Handlers.
+const (
+	handlerDefault = "default" // the OS opener (xdg-open, open, start)
+	handlerBrowser = "browser" // Config.DefaultBrowserCmd (see browser.go)
+)
+
+// =============================================================================
+// == 🚀 OPENING BOOKMARKS
+// =============================================================================
+
+// openAt opens the bookmark at index i, in Tor Browser if tor is set or the
+// bookmark asks for it, and records the open.
+func (s *AppState) openAt(i int, tor bool) error {
+	b := s.Bookmarks[i]
+	open := s.openBookmark
+	if tor || s.viaTor(b) {
+		open = func(b Bookmark) error { return s.openInTorBrowser(b.URL) }
+	}
+	if err := open(b); err != nil {
+		return err
+	}
+	s.recordOpen(i)
+	s.emit(eventOpen, b)
+	return nil
+}
+
+// openBookmark opens b with the handler for its URL scheme. An application
+// chosen for the bookmark or one of its tags comes first, then a handler from
+// Config.Handlers; otherwise http(s) goes to the browser, ssh:// to ssh,
+// local files and every other scheme to the OS opener.
+func (s *AppState) openBookmark(b Bookmark) error {
+	if app := s.bookmarkApp(b); app != "" {
+		target := b.URL
+		if path, ok := localPath(target); ok {
+			target = path
+		}
+		return openWithApp(app, target)
+	}
+	u, err := url.Parse(b.URL)
+	if err != nil || u.Scheme == "" {
+		return s.openInBrowser(b.URL)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if handler, ok := s.Config.Handlers[scheme]; ok {
+		return s.runHandler(handler, u)
+	}
+	switch scheme {
+	case "http", "https":
+		return s.openInBrowser(b.URL)
+	case "ssh":
+		return s.openSSH(u)
+	case "file":
+		path, _ := localPath(b.URL)
+		return startCommand(defaultBrowserCmd(), path)
+	}
+	return startCommand(defaultBrowserCmd(), b.URL)
+}
+
+// bookmarkApp returns the application chosen for b: its own, or else the one
+// Config.TagApps gives its first tag (alphabetically) that has one.
+func (s *AppState) bookmarkApp(b Bookmark) string {
+	if b.App != "" {
+		return b.App
+	}
+	tags := slices.Sorted(maps.Keys(s.Config.TagApps))
+	for _, t := range tags {
+		if b.hasTag(t) {
+			return s.Config.TagApps[t]
+		}
+	}
+	return ""
+}
+
+// openWithApp opens target in the named application: `open -a <app>` on
+// macOS; elsewhere app is a command line that gets target appended.
+func openWithApp(app, target string) error {
+	if runtime.GOOS == "darwin" {
+		return exec.Command("open", "-a", app, target).Start()
+	}
+	return startCommand(app, target)
+}
+
+// runHandler runs a configured handler: "default", "browser", a URL template
+// (opened in the browser, for gateway rewrites like
+// "https://ipfs.io/ipfs/{rest}") or a command line. {url}, {host}, {path} and
+// {rest} (everything after the scheme) are substituted in templates and
+// command arguments; a command without placeholders gets the URL appended.
+func (s *AppState) runHandler(handler string, u *url.URL) error {
+	rest := strings.TrimPrefix(strings.TrimPrefix(u.String(), u.Scheme+":"), "//")
+	target := u.String()
+	if path, ok := localPath(target); ok {
+		target = path
+	}
+	expand := strings.NewReplacer("{url}", target, "{host}", u.Host, "{path}", u.Path, "{rest}", rest).Replace
+	switch {
+	case handler == handlerDefault:
+		return startCommand(defaultBrowserCmd(), target)
+	case handler == handlerBrowser:
+		return s.openInBrowser(u.String())
+	case strings.HasPrefix(handler, "http://") || strings.HasPrefix(handler, "https://"):
+		return s.openInBrowser(expand(handler))
+	}
+	parts := strings.Fields(handler)
+	if len(parts) == 0 {
+		return fmt.Errorf("empty handler for %s:", u.Scheme)
+	}
+	args := make([]string, 0, len(parts))
+	substituted := false
+	for _, p := range parts[1:] {
+		if e := expand(p); e != p {
+			substituted = true
+			p = e
+		}
+		args = append(args, p)
+	}
+	if !substituted {
+		args = append(args, target)
+	}
+	return exec.Command(parts[0], args...).Start()
+}
+
+// startCommand launches a configured command line with extra arguments and
+// does not wait for it.
+func startCommand(cmdLine string, args ...string) error {
+	parts := strings.Fields(cmdLine)
+	if len(parts) == 0 {
+		return fmt.Errorf("no command configured")
+	}
+	return exec.Command(parts[0], append(parts[1:], args...)...).Start()
+}
+
+// openSSH connects to an ssh://[user@]host[:port] bookmark. With
+// Config.TerminalCmd set, ssh runs in a new terminal window; otherwise it
+// takes over this terminal until the session ends.
+func (s *AppState) openSSH(u *url.URL) error {
+	if u.Hostname() == "" {
+		return fmt.Errorf("no host in %s", u)
+	}
+	dest := u.Hostname()
+	if u.User != nil {
+		dest = u.User.Username() + "@" + dest
+	}
+	args := []string{"ssh"}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	args = append(args, dest)
+	if s.Config.TerminalCmd != "" {
+		return startCommand(s.Config.TerminalCmd, args...)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,132 @@
+// openapi.go
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// apiPrefix is the path of the current version of the API: a client written
+// against /v1 keeps working when the API changes in ways it would notice.
+const apiPrefix = "/v1"
+
+// apiRoute is one endpoint of the API, described for /openapi.json.
+type apiRoute struct {
+	method, path, summary string
+	params                []apiParam
+	// body and response describe, in words, what is sent and answered.
+	body, response string
+	// sync routes are only served by serve --sync and the LAN daemon.
+	sync   bool
+	handle func(*apiServer, http.ResponseWriter, *http.Request)
+}
+
+// apiParam is a query parameter of an endpoint.
+type apiParam struct {
+	name, typ, about string
+	repeated         bool
+	// enum lists the values the parameter may take, if only some.
+	enum []string
+}
+
+// apiRoutes are the endpoints of the API, under apiPrefix.
+var apiRoutes = []apiRoute{
+	{
+		method: "GET", path: "/bookmarks", summary: "List bookmarks",
+		params: []apiParam{
+			{name: "q", typ: "string", about: "Query in the query language of search"},
+			{name: "tag", typ: "string", about: "Keep bookmarks with this tag", repeated: true},
+			{name: "domain", typ: "string", about: "Keep bookmarks on this domain or its subdomains"},
+			{name: "sort", typ: "string", enum: apiSortValues(),
+				about: "Ascending order: name and url A to Z, added oldest first, opens and frecency least first; " +
+					"a leading - sorts descending (-added: newest first). Default: name"},
+			{name: "limit", typ: "integer", about: "Return at most this many"},
+			{name: "offset", typ: "integer", about: "Skip this many first"},
+			{name: "fields", typ: "string", about: "Comma-separated fields to keep in each bookmark"},
+		},
+		response: "The bookmarks; X-Total-Count has their number before limit and offset.",
+		handle:   (*apiServer).handleBookmarks,
+	},
+	{
+		method: "GET", path: "/sync/changes", summary: "Pull changes",
+		params: []apiParam{
+			{name: "since", typ: "integer", about: "Cursor returned by the previous pull"},
+		},
+		response: "Bookmarks and tombstones changed after the cursor, and the new cursor.",
+		sync:     true, handle: (*apiServer).handleSyncChanges,
+	},
+	{
+		method: "POST", path: "/sync/push", summary: "Push changes",
+		body:     "Bookmarks and tombstones changed since the last sync.",
+		response: "How many bookmarks the push changed and deleted.",
+		sync:     true, handle: (*apiServer).handleSyncPush,
+	},
+}
+
+// =============================================================================
+// == 📜 OPENAPI
+// =============================================================================
+
+// openAPISpec describes routes as an OpenAPI 3 document.
+func openAPISpec(routes []apiRoute) map[string]any {
+	paths := map[string]any{}
+	for _, route := range routes {
+		op := map[string]any{
+			"summary":     route.summary,
+			"operationId": operationID(route),
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": route.response,
+					"content":     map[string]any{"application/json": map[string]any{}},
+				},
+				"401": map[string]any{"description": "Missing or wrong token."},
+			},
+		}
+		if len(route.params) > 0 {
+			params := make([]map[string]any, len(route.params))
+			for i, p := range route.params {
+				schema := map[string]any{"type": p.typ}
+				if len(p.enum) > 0 {
+					schema["enum"] = p.enum
+				}
+				if p.repeated {
+					schema = map[string]any{"type": "array", "items": schema}
+				}
+				params[i] = map[string]any{"name": p.name, "in": "query", "description": p.about, "schema": schema}
+			}
+			op["parameters"] = params
+		}
+		if route.body != "" {
+			op["requestBody"] = map[string]any{
+				"description": route.body,
+				"required":    true,
+				"content":     map[string]any{"application/json": map[string]any{}},
+			}
+		}
+		item, _ := paths[route.path].(map[string]any)
+		if item == nil {
+			item = map[string]any{}
+			paths[route.path] = item
+		}
+		item[strings.ToLower(route.method)] = op
+	}
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": "bibliothermes", "version": strings.TrimPrefix(apiPrefix, "/v")},
+		"servers": []map[string]any{{"url": apiPrefix}},
+		"components": map[string]any{
+			"securitySchemes": map[string]any{"token": map[string]any{"type": "http", "scheme": "bearer"}},
+		},
+		"security": []map[string]any{{"token": []string{}}},
+		"paths":    paths,
+	}
+}
+
+// operationID names a route: GET /sync/changes is getSyncChanges.
+func operationID(route apiRoute) string {
+	id := strings.ToLower(route.method)
+	for _, part := range strings.FieldsFunc(route.path, func(r rune) bool { return r == '/' || r == '_' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
@@ -0,0 +1,121 @@
+// peerauth.go
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// peerAuthScheme starts the Authorization header of a signed request:
+	// "Peer <unix time>:<HMAC>".
+	peerAuthScheme = "Peer "
+	// peerClockSkew is how far apart the clocks of two peers may be for
+	// their signed requests to pass.
+	peerClockSkew = 2 * time.Minute
+)
+
+// =============================================================================
+// == 🔏 PEER AUTHENTICATION
+// =============================================================================
+//
+// LAN peers are whoever answers a multicast query, so sync peer must not hand
+// them the shared token, nor the collection, on their word. Before sending
+// anything, it has the peer sign a random challenge and its peer ID with the
+// token (GET /sync/hello), which only a peer that knows the token can do.
+// The requests that follow are signed the same way, over the method, path,
+// time and body, instead of carrying the token.
+
+// peerMAC signs parts with the shared token.
+func peerMAC(token string, parts ...string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handleHello answers GET /sync/hello?challenge=<hex>, proving this instance
+// knows the token without sending it. It needs no token itself.
+func (srv *apiServer) handleHello(w http.ResponseWriter, r *http.Request) {
+	challenge := r.URL.Query().Get("challenge")
+	if len(challenge) < 32 {
+		http.Error(w, "challenge too short", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, map[string]string{
+		"peer_id": srv.peerID,
+		"proof":   peerMAC(srv.token, "hello", challenge, srv.peerID),
+	}, nil)
+}
+
+// verifyPeer has the peer t points at prove that it knows the token and is
+// the peer t.peerID names.
+func verifyPeer(t syncTarget) error {
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	challenge := hex.EncodeToString(nonce)
+	resp, err := newClient(nil, 10*time.Second).Get(t.url + apiPrefix + "/sync/hello?challenge=" + challenge)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", t.url, resp.Status)
+	}
+	var hello struct {
+		PeerID string `json:"peer_id"`
+		Proof  string `json:"proof"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&hello); err != nil {
+		return err
+	}
+	if hello.PeerID != t.peerID {
+		return fmt.Errorf("%s answers as peer %q, not %q", t.url, hello.PeerID, t.peerID)
+	}
+	if !hmac.Equal([]byte(hello.Proof), []byte(peerMAC(t.token, "hello", challenge, t.peerID))) {
+		return errors.New("the peer does not know the token; is server.token the same on both machines?")
+	}
+	return nil
+}
+
+// signRequest signs req, whose body is body, with the token.
+func signRequest(req *http.Request, token string, body []byte) {
+	at := strconv.FormatInt(time.Now().Unix(), 10)
+	sum := sha256.Sum256(body)
+	req.Header.Set("Authorization", peerAuthScheme+at+":"+peerMAC(token, "request", req.Method, req.URL.RequestURI(), at, hex.EncodeToString(sum[:])))
+}
+
+// signedWith reports whether r is signed with token, recently. It reads the
+// body and puts it back for the handler.
+func signedWith(r *http.Request, token string) bool {
+	signature, ok := strings.CutPrefix(r.Header.Get("Authorization"), peerAuthScheme)
+	if !ok {
+		return false
+	}
+	at, mac, ok := strings.Cut(signature, ":")
+	unix, err := strconv.ParseInt(at, 10, 64)
+	if !ok || err != nil {
+		return false
+	}
+	if skew := time.Since(time.Unix(unix, 0)); skew > peerClockSkew || skew < -peerClockSkew {
+		return false
+	}
+	// A body cut at the limit could not match its signature anyway.
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSyncBody+1))
+	if err != nil || len(body) > maxSyncBody {
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	return hmac.Equal([]byte(mac), []byte(peerMAC(token, "request", r.Method, r.URL.RequestURI(), at, hex.EncodeToString(sum[:]))))
+}
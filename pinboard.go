@@ -0,0 +1,204 @@
+// pinboard.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	pinboardAPI = "https://api.pinboard.in/v1"
+	// pinboardInterval is the pause Pinboard asks for between API calls.
+	pinboardInterval = 3 * time.Second
+	// pinboardTitleMax is the longest title Pinboard takes.
+	pinboardTitleMax = 255
+	// pinboardRetries is how many times a call Pinboard throttles (429) is
+	// tried again, waiting twice as long each time.
+	pinboardRetries = 5
+)
+
+// =============================================================================
+// == 📌 PINBOARD PUSH
+// =============================================================================
+//
+// push pinboard posts bookmarks to Pinboard, as an off-site backup rather
+// than a sync: nothing comes back, and Pinboard's copies are private and are
+// never replaced. Bookmarks pushed once are skipped the next time (--all
+// pushes them again), so running it after each import only sends the new
+// ones. Pinboard takes one call every three seconds, so a first push of a
+// large collection is slow; Ctrl-C stops it and the next run resumes.
+
+// pinboardToken returns the API token, user:HEX, from PINBOARD_TOKEN or the
+// config.
+func (s *AppState) pinboardToken() (string, error) {
+	if env := os.Getenv("PINBOARD_TOKEN"); env != "" {
+		return env, nil
+	}
+	if s.Config.PinboardToken != "" {
+		return s.Config.PinboardToken, nil
+	}
+	return "", errors.New("set PINBOARD_TOKEN or pinboard_token to your API token (pinboard.in/settings/password)")
+}
+
+// runPushPinboard runs `push pinboard [--all] [query]`.
+func (s *AppState) runPushPinboard(args []string) error {
+	token, err := s.pinboardToken()
+	if err != nil {
+		return err
+	}
+	all := len(args) > 0 && args[0] == "--all"
+	if all {
+		args = args[1:]
+	}
+	text := strings.Join(args, " ")
+	q, err := parseQuery(text)
+	if err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	var pending []Bookmark
+	for _, b := range s.filterBookmarks(bookmarkFilter{query: q, text: text}) {
+		if _, pushed := s.PinboardPushed[b.UUID]; isWebURL(b.URL) && !b.trashed() && (all || !pushed) {
+			pending = append(pending, b)
+		}
+	}
+	if len(pending) == 0 {
+		fmt.Println("Nothing to push to Pinboard.")
+		return nil
+	}
+	ctx, stop := interruptible()
+	defer stop()
+	client := newHTTPClient()
+	bar := newProgress("Pushing to Pinboard", len(pending))
+	defer bar.finish()
+	added, existing := 0, 0
+	for n, b := range pending {
+		if n > 0 {
+			select {
+			case <-time.After(pinboardInterval):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		ok, err := pinboardAdd(ctx, client, token, b)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			bar.finish()
+			fmt.Printf("Pushed %d bookmarks to Pinboard before an error.\n", added)
+			return fmt.Errorf("could not push '%s': %w", b.Name, err)
+		}
+		if ok {
+			added++
+		} else {
+			existing++
+		}
+		if s.PinboardPushed == nil {
+			s.PinboardPushed = map[string]time.Time{}
+		}
+		s.PinboardPushed[b.UUID] = time.Now()
+		bar.step(b.Name)
+	}
+	bar.finish()
+	if ctx.Err() != nil {
+		fmt.Print("Cancelled. ")
+	}
+	fmt.Printf("Pushed %d bookmarks to Pinboard", added)
+	if existing > 0 {
+		fmt.Printf(" (%d already there)", existing)
+	}
+	fmt.Println(".")
+	return nil
+}
+
+// pinboardAdd posts b to Pinboard, keeping the copy there if it has the URL
+// already; it reports whether b was added.
+func pinboardAdd(ctx context.Context, client *http.Client, token string, b Bookmark) (bool, error) {
+	title := b.Name
+	if r := []rune(title); len(r) > pinboardTitleMax {
+		title = string(r[:pinboardTitleMax])
+	}
+	params := url.Values{
+		"auth_token":  {token},
+		"format":      {"json"},
+		"url":         {b.URL},
+		"description": {title},
+		"extended":    {b.Description},
+		"tags":        {strings.Join(b.Tags, " ")},
+		"replace":     {"no"},
+		"shared":      {"no"},
+	}
+	if added := b.addedAt(); !added.IsZero() {
+		params.Set("dt", added.UTC().Format(time.RFC3339))
+	}
+	if !b.Read {
+		params.Set("toread", "yes")
+	}
+	resp, err := pinboardGet(ctx, client, "/posts/add?"+params.Encode())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		return false, errors.New("Pinboard refused the API token")
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return false, errors.New("Pinboard kept asking to slow down; try again later")
+	case resp.StatusCode >= 300:
+		return false, fmt.Errorf("Pinboard answered %s", resp.Status)
+	}
+	var result struct {
+		ResultCode string `json:"result_code"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	switch result.ResultCode {
+	case "done":
+		return true, nil
+	case "item already exists":
+		return false, nil
+	}
+	return false, fmt.Errorf("Pinboard answered %q", result.ResultCode)
+}
+
+// pinboardGet calls the API at path, waiting and trying again while Pinboard
+// answers 429 Too Many Requests.
+func pinboardGet(ctx context.Context, client *http.Client, path string) (*http.Response, error) {
+	wait := 2 * pinboardInterval
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, pinboardAPI+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			// The URL holds the token; keep it out of the message.
+			var uerr *url.Error
+			if errors.As(err, &uerr) {
+				err = uerr.Err
+			}
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests || attempt == pinboardRetries {
+			return resp, nil
+		}
+		resp.Body.Close()
+		logger.Debug("Pinboard asked to slow down", "wait", wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		wait *= 2
+	}
+}
@@ -0,0 +1,108 @@
+// plugins.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// pluginPrefix is the executable name prefix that turns a program into a
+// command: bibliothermes-foo on PATH or in the plugin dir becomes `foo`.
+const pluginPrefix = "bibliothermes-"
+
+// =============================================================================
+// == 🧩 EXEC PLUGINS
+// =============================================================================
+
+// pluginDir returns where plugins are looked up before PATH.
+func (s *AppState) pluginDir() string {
+	if s.Config.PluginDir != "" {
+		return s.Config.PluginDir
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "bibliothermes", "plugins")
+}
+
+// findPlugin returns the executable implementing command, if any.
+func (s *AppState) findPlugin(command string) (string, bool) {
+	name := pluginPrefix + command
+	if dir := s.pluginDir(); dir != "" {
+		candidates := []string{filepath.Join(dir, name)}
+		if runtime.GOOS == "windows" {
+			candidates = append(candidates, filepath.Join(dir, name+".exe"))
+		}
+		for _, path := range candidates {
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path, true
+			}
+		}
+	}
+	path, err := exec.LookPath(name)
+	return path, err == nil
+}
+
+// listPlugins returns the command names of every plugin that can be found.
+func (s *AppState) listPlugins() []string {
+	seen := make(map[string]bool)
+	dirs := append([]string{s.pluginDir()}, filepath.SplitList(os.Getenv("PATH"))...)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() || !strings.HasPrefix(e.Name(), pluginPrefix) {
+				continue
+			}
+			name := strings.TrimPrefix(e.Name(), pluginPrefix)
+			seen[strings.TrimSuffix(name, filepath.Ext(name))] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runPlugin hands control to a plugin. The current state is saved first so the
+// plugin sees it, and reloaded afterwards so changes the plugin made stick.
+// Plugins get the data file in BIBLIOTHERMES_DATA_FILE, this binary in
+// BIBLIOTHERMES_BIN and the active profile, if any, in BIBLIOTHERMES_PROFILE,
+// so they can query the same collection with
+// `$BIBLIOTHERMES_BIN --profile "$BIBLIOTHERMES_PROFILE" json`.
+func (s *AppState) runPlugin(path string, args []string) error {
+	if err := s.saveState(); err != nil {
+		return fmt.Errorf("could not save before running plugin: %w", err)
+	}
+	cmd := exec.Command(path, args...)
+	cmd.Env = os.Environ()
+	if abs, err := filepath.Abs(dataPath(bookmarksFile)); err == nil {
+		cmd.Env = append(cmd.Env, "BIBLIOTHERMES_DATA_FILE="+abs)
+	}
+	if self, err := os.Executable(); err == nil {
+		cmd.Env = append(cmd.Env, "BIBLIOTHERMES_BIN="+self)
+	}
+	if activeProfile != "" {
+		cmd.Env = append(cmd.Env, "BIBLIOTHERMES_PROFILE="+activeProfile)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	runErr := cmd.Run()
+	reloaded, err := loadState()
+	if err != nil {
+		return fmt.Errorf("could not reload data after plugin: %w", err)
+	}
+	*s = *reloaded
+	return runErr
+}
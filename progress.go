@@ -0,0 +1,139 @@
+// progress.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// progressInterval is how often a progress bar redraws at most.
+const progressInterval = 100 * time.Millisecond
+
+// progress is a one-line progress bar on stderr: how far along, the time
+// left and the item at hand.
+type progress struct {
+	label string
+	total int
+	done  int
+	start time.Time
+	drawn time.Time
+	item  string
+}
+
+var (
+	// progressMu guards the bar being drawn, which log messages clear first.
+	progressMu     sync.Mutex
+	activeProgress *progress
+	// progressOff hides bars in daemon and serve, which log instead.
+	progressOff bool
+)
+
+// =============================================================================
+// == 📊 PROGRESS BARS
+// =============================================================================
+//
+// Imports of large places.sqlite files, link checks over thousands of URLs and
+// title and description fetches show a bar while they run, so they don't look
+// hung:
+//
+//	Checking links [=========>          ] 1203/2650 45% 1m12s left  go.dev/blog
+//
+// The bar goes to stderr, and only when it is a terminal and not --quiet;
+// the command's own output is untouched. A nil *progress does nothing, so
+// callers needn't check.
+
+// newProgress starts a bar for total items, or returns nil when there is no
+// terminal to draw it on.
+func newProgress(label string, total int) *progress {
+	if total <= 0 || quiet || progressOff {
+		return nil
+	}
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	p := &progress{label: label, total: total, start: time.Now()}
+	progressMu.Lock()
+	activeProgress = p
+	p.draw()
+	progressMu.Unlock()
+	return p
+}
+
+// step counts one more item done; item is the one being worked on now.
+// Safe for concurrent use.
+func (p *progress) step(item string) {
+	if p == nil {
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	p.done++
+	p.item = item
+	if p.done == p.total || time.Since(p.drawn) >= progressInterval {
+		// Bars of work running side by side take turns on the line.
+		activeProgress = p
+		p.draw()
+	}
+}
+
+// finish erases the bar.
+func (p *progress) finish() {
+	if p == nil {
+		return
+	}
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	fmt.Fprint(os.Stderr, "\r\x1b[K")
+	if activeProgress == p {
+		activeProgress = nil
+	}
+}
+
+// draw redraws the bar; progressMu must be held.
+func (p *progress) draw() {
+	p.drawn = time.Now()
+	const barWidth = 20
+	filled := barWidth * p.done / p.total
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	line := fmt.Sprintf("%s [%s] %d/%d %d%%", p.label, bar, p.done, p.total, 100*p.done/p.total)
+	if p.done > 0 && p.done < p.total {
+		elapsed := time.Since(p.start)
+		left := elapsed * time.Duration(p.total-p.done) / time.Duration(p.done)
+		line += " " + left.Round(time.Second).String() + " left"
+	}
+	if p.item != "" {
+		line += "  " + p.item
+	}
+	// Stay on one line, or each redraw would scroll.
+	if width := terminalColumns() - 1; utf8.RuneCountInString(line) > width {
+		line = string([]rune(line)[:width])
+	}
+	fmt.Fprint(os.Stderr, "\r\x1b[K"+line)
+}
+
+// clearProgress erases the bar for a message to be printed; the next step
+// draws it again.
+func clearProgress() {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	if activeProgress != nil {
+		fmt.Fprint(os.Stderr, "\r\x1b[K")
+		activeProgress.drawn = time.Time{}
+	}
+}
+
+// terminalColumns returns the terminal's width, from $COLUMNS, or 80.
+func terminalColumns() int {
+	if n, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && n > 20 {
+		return n
+	}
+	return 80
+}
@@ -0,0 +1,398 @@
+// publish.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// PublishTarget is a list kept up to date on GitHub by publish --gist or
+// --git; each run records its target here and the daemon's publish job
+// republishes them all.
+type PublishTarget struct {
+	Query string `json:"query,omitempty"`
+	// Format is "md" or "html".
+	Format string `json:"format"`
+	// Gist is the ID of the gist, set by the run that created it.
+	Gist   string `json:"gist,omitempty"`
+	Public bool   `json:"public,omitempty"`
+	// Git and Branch name the repository and branch (default gh-pages).
+	Git    string `json:"git,omitempty"`
+	Branch string `json:"branch,omitempty"`
+}
+
+// publishTemplate is a standalone page: no scripts and no stylesheet to fetch,
+// so it can be mailed as a file or dropped on any static host.
+var publishTemplate = template.Must(template.New("publish").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font: 16px/1.5 system-ui, sans-serif; max-width: 46rem; margin: 2rem auto; padding: 0 1rem; color: #222; background: #fff; }
+h1 { font-size: 1.6rem; margin-bottom: .2rem; }
+.meta, .host, .tags { color: #777; font-size: .85rem; }
+ul { list-style: none; padding: 0; }
+li { display: flex; gap: .75rem; padding: .8rem 0; border-bottom: 1px solid #eee; }
+li img { width: 16px; height: 16px; margin-top: .3rem; flex: none; }
+a { color: #1a5fb4; text-decoration: none; font-weight: 600; }
+a:hover { text-decoration: underline; }
+p { margin: .2rem 0 0; }
+@media (prefers-color-scheme: dark) {
+  body { color: #ddd; background: #1e1e1e; }
+  li { border-color: #333; }
+  a { color: #78aeed; }
+}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">{{len .Items}} links</div>
+<ul>
+{{- range .Items}}
+<li>{{if .Favicon}}<img src="{{.Favicon}}" alt="" loading="lazy" onerror="this.style.visibility='hidden'">{{end}}
+<div><a href="{{.URL}}">{{.Name}}</a> <span class="host">{{.Host}}</span>
+{{- if .Description}}
+<p>{{.Description}}</p>
+{{- end}}
+{{- if .Tags}}
+<div class="tags">{{range $i, $t := .Tags}}{{if $i}} {{end}}#{{$t}}{{end}}</div>
+{{- end}}
+</div></li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// publishItem is one bookmark as the page shows it.
+type publishItem struct {
+	Name, URL, Host, Description, Favicon string
+	Tags                                  []string
+}
+
+// =============================================================================
+// == 🌍 PUBLISHING
+// =============================================================================
+
+// runPublish runs `publish <tag> <out.html>`, or publish --gist / --git.
+func (s *AppState) runPublish(args []string) error {
+	if len(args) > 0 && strings.HasPrefix(args[0], "--") {
+		return s.runPublishRemote(args)
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("usage: publish <tag> <out.html>, or publish --gist|--git <repo> [--branch b] [--md|--html] [--public] [query]")
+	}
+	tag := normalizeTag(args[0])
+	var bookmarks []Bookmark
+	for _, b := range s.filterBookmarks(bookmarkFilter{tags: []string{tag}}) {
+		if !b.trashed() {
+			bookmarks = append(bookmarks, b)
+		}
+	}
+	if len(bookmarks) == 0 {
+		return fmt.Errorf("no bookmarks tagged %q", tag)
+	}
+	f, err := os.Create(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := publishPage(f, tag, bookmarks, loadMetaCache()); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Published %d bookmarks tagged %s to %s.\n", len(bookmarks), tag, args[1])
+	return nil
+}
+
+// publishPage renders bookmarks as a standalone HTML page. Favicons come from
+// the metadata cache when a check or refresh found one, else from the site's
+// /favicon.ico.
+func publishPage(w io.Writer, title string, bookmarks []Bookmark, cache *metaCache) error {
+	page := struct {
+		Title string
+		Items []publishItem
+	}{Title: title}
+	for _, b := range bookmarks {
+		item := publishItem{Name: b.Name, URL: b.URL, Description: b.Description, Tags: b.Tags}
+		if u, err := url.Parse(b.URL); err == nil && u.Host != "" {
+			item.Host = u.Hostname()
+			if m, ok := cache.get(b.URL); ok && m.Favicon != "" {
+				item.Favicon = m.Favicon
+			} else if u.Scheme == "http" || u.Scheme == "https" {
+				item.Favicon = u.Scheme + "://" + u.Host + "/favicon.ico"
+			}
+		}
+		page.Items = append(page.Items, item)
+	}
+	return publishTemplate.Execute(w, page)
+}
+
+// publishMarkdown renders bookmarks as a markdown list, with descriptions.
+func publishMarkdown(w io.Writer, title string, bookmarks []Bookmark) error {
+	var sb strings.Builder
+	escape := strings.NewReplacer("[", `\[`, "]", `\]`).Replace
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	for _, b := range bookmarks {
+		fmt.Fprintf(&sb, "- [%s](%s)", escape(b.Name), b.URL)
+		if b.Description != "" {
+			sb.WriteString(" — " + strings.Join(strings.Fields(b.Description), " "))
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "\n_%d links._\n", len(bookmarks))
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// =============================================================================
+// == 🐙 PUBLISHING TO GITHUB
+// =============================================================================
+
+// runPublishRemote runs `publish --gist|--git <repo> [--branch b] [--md|--html]
+// [--public] [query]` and remembers the target for the publish job.
+func (s *AppState) runPublishRemote(args []string) error {
+	var t PublishTarget
+	var query []string
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--gist":
+			t.Gist = "new"
+		case "--git", "--branch":
+			if i+1 >= len(args) {
+				return fmt.Errorf("%s needs a value", args[i])
+			}
+			if args[i] == "--git" {
+				t.Git = args[i+1]
+			} else {
+				t.Branch = args[i+1]
+			}
+			i++
+		case "--md", "--html":
+			t.Format = args[i][2:]
+		case "--public":
+			t.Public = true
+		default:
+			query = append(query, args[i])
+		}
+	}
+	if (t.Gist == "") == (t.Git == "") {
+		return fmt.Errorf("publish to either --gist or --git <repo>")
+	}
+	t.Query = strings.Join(query, " ")
+	if _, err := parseQuery(t.Query); err != nil {
+		return fmt.Errorf("invalid query: %w", err)
+	}
+	if t.Format == "" {
+		// Gists show markdown rendered but HTML as source; Pages serve HTML.
+		t.Format = "html"
+		if t.Gist != "" {
+			t.Format = "md"
+		}
+	}
+	// Publishing the same list again updates the gist or branch it went to.
+	known := slices.IndexFunc(s.Config.Publish, func(p PublishTarget) bool {
+		if t.Git != "" {
+			return p.Git == t.Git && p.Branch == t.Branch
+		}
+		return p.Gist != "" && p.Query == t.Query && p.Format == t.Format
+	})
+	if known >= 0 && t.Gist != "" {
+		t.Gist = s.Config.Publish[known].Gist
+	}
+	where, err := s.publishTo(&t)
+	if err != nil {
+		return err
+	}
+	if known >= 0 {
+		s.Config.Publish[known] = t
+	} else {
+		s.Config.Publish = append(s.Config.Publish, t)
+	}
+	s.saveConfig()
+	fmt.Printf("Published to %s.\n", where)
+	return nil
+}
+
+// republish publishes every remembered target again, for the daemon job.
+func (s *AppState) republish() {
+	for i := range s.Config.Publish {
+		t := &s.Config.Publish[i]
+		if where, err := s.publishTo(t); err != nil {
+			logger.Error("could not publish", "err", err)
+		} else {
+			logger.Info("published", "to", where)
+		}
+	}
+	s.saveConfig()
+}
+
+// publishTo renders the target's bookmarks and pushes them, returning where
+// they went. Publishing to a new gist sets t.Gist.
+func (s *AppState) publishTo(t *PublishTarget) (string, error) {
+	q, err := parseQuery(t.Query)
+	if err != nil {
+		return "", fmt.Errorf("invalid query %q: %w", t.Query, err)
+	}
+	var bookmarks []Bookmark
+	now := time.Now()
+	for _, b := range s.Bookmarks {
+		if q.match(b) && b.visible(t.Query, now) {
+			bookmarks = append(bookmarks, b)
+		}
+	}
+	sortByName(bookmarks)
+	title := t.Query
+	if title == "" {
+		title = "Bookmarks"
+	}
+	var page bytes.Buffer
+	name := "README.md"
+	if t.Format == "html" {
+		name = "index.html"
+		err = publishPage(&page, title, bookmarks, loadMetaCache())
+	} else {
+		err = publishMarkdown(&page, title, bookmarks)
+	}
+	if err != nil {
+		return "", err
+	}
+	if t.Git != "" {
+		return publishGit(t.Git, t.Branch, name, page.Bytes())
+	}
+	if t.Format == "md" {
+		name = "bookmarks.md"
+	} else {
+		name = "bookmarks.html"
+	}
+	return publishGist(t, title, name, page.String())
+}
+
+// publishGist creates or updates a gist holding one file. The token comes
+// from GITHUB_TOKEN (or GH_TOKEN) and needs the gist scope.
+func publishGist(t *PublishTarget, description, name, content string) (string, error) {
+	token := os.Getenv("GITHUB_TOKEN")
+	if token == "" {
+		token = os.Getenv("GH_TOKEN")
+	}
+	if token == "" {
+		return "", errors.New("set GITHUB_TOKEN to a token with the gist scope")
+	}
+	body, err := json.Marshal(map[string]any{
+		"description": description,
+		"public":      t.Public,
+		"files":       map[string]any{name: map[string]string{"content": content}},
+	})
+	if err != nil {
+		return "", err
+	}
+	method, endpoint := http.MethodPost, "https://api.github.com/gists"
+	if t.Gist != "new" {
+		method, endpoint = http.MethodPatch, endpoint+"/"+t.Gist
+	}
+	req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := newHTTPClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("GitHub answered %s", resp.Status)
+	}
+	var gist struct {
+		ID      string `json:"id"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&gist); err != nil {
+		return "", err
+	}
+	t.Gist = gist.ID
+	return gist.HTMLURL, nil
+}
+
+// publishGit commits the page as name on branch of repo (gh-pages by
+// default, created if missing) and pushes it. Nothing is committed when the
+// page is unchanged. Authentication is git's own.
+func publishGit(repo, branch, name string, page []byte) (string, error) {
+	if branch == "" {
+		branch = "gh-pages"
+	}
+	dir, err := os.MkdirTemp("", "bibliothermes-publish-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	where := repo + " (" + branch + ")"
+	// ls-remote exits with 2 when the branch doesn't exist yet.
+	var exit *exec.ExitError
+	out, err := exec.Command("git", "ls-remote", "--exit-code", "--heads", repo, branch).CombinedOutput()
+	switch {
+	case err == nil:
+		if err := runGit("", "clone", "--quiet", "--depth", "1", "--branch", branch, repo, dir); err != nil {
+			return "", err
+		}
+	case errors.As(err, &exit) && exit.ExitCode() == 2:
+		for _, args := range [][]string{{"init", "--quiet"}, {"checkout", "--quiet", "--orphan", branch}, {"remote", "add", "origin", repo}} {
+			if err := runGit(dir, args...); err != nil {
+				return "", err
+			}
+		}
+	default:
+		return "", fmt.Errorf("git ls-remote: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), page, 0644); err != nil {
+		return "", err
+	}
+	if err := runGit(dir, "add", name); err != nil {
+		return "", err
+	}
+	if exec.Command("git", "-C", dir, "diff", "--cached", "--quiet").Run() == nil {
+		return where + ", unchanged", nil
+	}
+	commit := []string{"commit", "--quiet", "-m", "Update bookmarks"}
+	if exec.Command("git", "-C", dir, "config", "user.email").Run() != nil {
+		// No identity (say, under the daemon): commit as the program.
+		commit = append([]string{"-c", "user.name=bibliothermes", "-c", "user.email=bibliothermes@localhost"}, commit...)
+	}
+	if err := runGit(dir, commit...); err != nil {
+		return "", err
+	}
+	return where, runGit(dir, "push", "--quiet", "origin", branch)
+}
+
+// runGit runs git in dir, with its output in the error when it fails.
+func runGit(dir string, args ...string) error {
+	sub := args[0]
+	for i := 0; i+2 < len(args) && args[i] == "-c"; i += 2 {
+		sub = args[i+2]
+	}
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %v: %s", sub, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
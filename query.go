@@ -0,0 +1,452 @@
+// query.go
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// =============================================================================
+// == 🧮 QUERY LANGUAGE
+// =============================================================================
+//
+// A query is a boolean expression over bookmark fields:
+//
+//	tag:go AND (domain:github.com OR domain:pkg.go.dev) NOT read:true added:>2024-06
+//
+// Terms are `field:value` or bare words (matched against name and URL; in
+// search and live search also through the full-text index against tags,
+// description and snapshot text, which text: does everywhere).
+// Adjacent terms are ANDed; NOT (or a leading '-') negates the next term and
+// binds tighter than AND, which binds tighter than OR. Dates in added: may be
+// YYYY, YYYY-MM or YYYY-MM-DD and compare against that whole period:
+// `>2024-06` is after June 2024, `>=2024-06` from the start of June.
+
+// Case modes for bare words, name: and url: (Config.SearchCase).
+const (
+	caseIgnore    = "ignore"
+	caseSmart     = "smart"
+	caseSensitive = "sensitive"
+)
+
+// textFields are the fields that can be matched with their case.
+var textFields = map[string]func(Bookmark) string{
+	"name": func(b Bookmark) string { return b.Name },
+	"url":  func(b Bookmark) string { return b.URL },
+}
+
+// queryNode is a parsed query or sub-expression.
+type queryNode interface {
+	match(b Bookmark) bool
+}
+
+type andNode []queryNode
+type orNode []queryNode
+type notNode struct{ node queryNode }
+type predicate func(b Bookmark) bool
+
+func (n andNode) match(b Bookmark) bool {
+	for _, c := range n {
+		if !c.match(b) {
+			return false
+		}
+	}
+	return true
+}
+
+func (n orNode) match(b Bookmark) bool {
+	for _, c := range n {
+		if c.match(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n notNode) match(b Bookmark) bool   { return !n.node.match(b) }
+func (p predicate) match(b Bookmark) bool { return p(b) }
+
+// matchAll is the empty query.
+var matchAll = predicate(func(Bookmark) bool { return true })
+
+// queryFields maps a field name to a constructor for its predicate.
+var queryFields = map[string]func(value string) (predicate, error){
+	"tag": func(v string) (predicate, error) {
+		t := normalizeTag(v)
+		return func(b Bookmark) bool { return b.hasTag(t) }, nil
+	},
+	"domain": func(v string) (predicate, error) {
+		d := strings.ToLower(strings.TrimPrefix(v, "www."))
+		return func(b Bookmark) bool { return hostMatches(bookmarkHost(b.URL), d) }, nil
+	},
+	"name": func(v string) (predicate, error) {
+		v = matchForm(strings.ToLower(v))
+		return func(b Bookmark) bool { return strings.Contains(matchForm(strings.ToLower(b.Name)), v) }, nil
+	},
+	"url": func(v string) (predicate, error) {
+		v = matchForm(strings.ToLower(v))
+		return func(b Bookmark) bool { return strings.Contains(matchForm(strings.ToLower(b.URL)), v) }, nil
+	},
+	"type": func(v string) (predicate, error) {
+		v = strings.ToLower(v)
+		return func(b Bookmark) bool { return b.Type == v }, nil
+	},
+	"lang": func(v string) (predicate, error) {
+		v = primaryLang(v)
+		return func(b Bookmark) bool { return b.Lang == v }, nil
+	},
+	"meta": metaField,
+	"text": textField,
+	"source": func(v string) (predicate, error) {
+		return func(b Bookmark) bool { return b.fromSource(v) }, nil
+	},
+	"read":    boolField(func(b Bookmark) bool { return b.Read }),
+	"fav":     boolField(func(b Bookmark) bool { return b.Favorite }),
+	"tor":     boolField(func(b Bookmark) bool { return b.Tor }),
+	"dead":    boolField(func(b Bookmark) bool { return b.Check.Dead() }),
+	"shelved": boolField(func(b Bookmark) bool { return b.shelved() }),
+	"trashed": boolField(func(b Bookmark) bool { return b.trashed() }),
+	"snoozed": boolField(func(b Bookmark) bool { return b.snoozed(time.Now()) }),
+	"expired": boolField(func(b Bookmark) bool { return b.expired(time.Now()) }),
+	"added":   timeField(func(b Bookmark) time.Time { return b.addedAt() }),
+}
+
+func boolField(get func(Bookmark) bool) func(string) (predicate, error) {
+	return func(v string) (predicate, error) {
+		want, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("want true or false, got %q", v)
+		}
+		return func(b Bookmark) bool { return get(b) == want }, nil
+	}
+}
+
+func timeField(get func(Bookmark) time.Time) func(string) (predicate, error) {
+	return func(v string) (predicate, error) {
+		op := ""
+		for _, candidate := range []string{">=", "<=", ">", "<", "="} {
+			if strings.HasPrefix(v, candidate) {
+				op, v = candidate, v[len(candidate):]
+				break
+			}
+		}
+		start, end, err := parsePeriod(v)
+		if err != nil {
+			return nil, err
+		}
+		return func(b Bookmark) bool {
+			t := get(b)
+			if t.IsZero() {
+				return false
+			}
+			switch op {
+			case ">":
+				return !t.Before(end)
+			case ">=":
+				return !t.Before(start)
+			case "<":
+				return t.Before(start)
+			case "<=":
+				return t.Before(end)
+			default:
+				return !t.Before(start) && t.Before(end)
+			}
+		}, nil
+	}
+}
+
+// parsePeriod turns YYYY, YYYY-MM or YYYY-MM-DD into a [start, end) range.
+func parsePeriod(v string) (start, end time.Time, err error) {
+	for _, p := range []struct {
+		layout string
+		years  int
+		months int
+		days   int
+	}{{"2006-01-02", 0, 0, 1}, {"2006-01", 0, 1, 0}, {"2006", 1, 0, 0}} {
+		if start, err = time.ParseInLocation(p.layout, v, time.Local); err == nil {
+			return start, start.AddDate(p.years, p.months, p.days), nil
+		}
+	}
+	return start, end, fmt.Errorf("invalid date %q (want YYYY, YYYY-MM or YYYY-MM-DD)", v)
+}
+
+// parseQuery parses a query string, ignoring case. An empty query matches
+// everything. Config.SearchCase is left to the searches typed at the prompt
+// (see queryCaseFlag): delete --query or a smart folder must not match
+// differently because of it.
+func parseQuery(input string) (queryNode, error) {
+	return parseQueryCase(input, "")
+}
+
+// parseQueryCase parses a query string with a case mode: ignore (also
+// for ""), smart or sensitive.
+func parseQueryCase(input, mode string) (queryNode, error) {
+	return parseQueryWith(input, mode, false)
+}
+
+// parseSearchQuery parses a query typed to search or live search, whose bare
+// words also match tags, descriptions and snapshot text, and forgive typos.
+// Commands that act on the matches, like delete --query, stay with the
+// narrower parseQuery.
+func parseSearchQuery(input, mode string) (queryNode, error) {
+	return parseQueryWith(input, mode, true)
+}
+
+func parseQueryWith(input, mode string, search bool) (queryNode, error) {
+	tokens, err := tokenizeQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return matchAll, nil
+	}
+	p := &queryParser{tokens: tokens, mode: mode, search: search}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeQuery splits on whitespace and parentheses, honouring double quotes
+// (also inside field values: name:"go blog").
+func tokenizeQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	var cur strings.Builder
+	quoted, inQuotes := false, false
+	flush := func() {
+		if cur.Len() > 0 || quoted {
+			tokens = append(tokens, queryToken{text: cur.String(), quoted: quoted})
+		}
+		cur.Reset()
+		quoted = false
+	}
+	for _, r := range input {
+		switch {
+		case r == '"':
+			// Only a quote opening the token makes it literal: name:"go
+			// blog" stays a field.
+			inQuotes = !inQuotes
+			quoted = quoted || cur.Len() == 0
+		case inQuotes:
+			cur.WriteRune(r)
+		case unicode.IsSpace(r):
+			flush()
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, queryToken{text: string(r)})
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	flush()
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+	mode   string
+	// search makes bare words forgive typos (see parseSearchQuery).
+	search bool
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *queryParser) isKeyword(word string) bool {
+	t, ok := p.peek()
+	return ok && !t.quoted && t.text == word
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	nodes := orNode{left}
+	for p.isKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, right)
+	}
+	if len(nodes) == 1 {
+		return left, nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	var nodes andNode
+	for {
+		if p.isKeyword("AND") {
+			p.pos++
+		}
+		t, ok := p.peek()
+		if !ok || (!t.quoted && (t.text == ")" || t.text == "OR")) {
+			break
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	switch len(nodes) {
+	case 0:
+		return nil, fmt.Errorf("expected a search term")
+	case 1:
+		return nodes[0], nil
+	}
+	return nodes, nil
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	if p.isKeyword("NOT") {
+		p.pos++
+		// A NOT with nothing to negate would match every bookmark.
+		if t, ok := p.peek(); !ok || (!t.quoted && (t.text == ")" || t.text == "OR")) {
+			return nil, fmt.Errorf("NOT needs a term after it")
+		}
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+	t, _ := p.peek()
+	p.pos++
+	if !t.quoted && t.text == "(" {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isKeyword(")") {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return node, nil
+	}
+	if !t.quoted && strings.HasPrefix(t.text, "-") && len(t.text) > 1 {
+		node, err := parseTerm(queryToken{text: t.text[1:]}, p.mode, p.search)
+		if err != nil {
+			return nil, err
+		}
+		return notNode{node}, nil
+	}
+	return parseTerm(t, p.mode, p.search)
+}
+
+// parseTerm turns a field:value pair or a bare word into a predicate. A bare
+// word matches the name and URL, and with search set also the full text and
+// words it is a typo of.
+func parseTerm(t queryToken, mode string, search bool) (queryNode, error) {
+	if t.text == "" {
+		return nil, fmt.Errorf("empty search term")
+	}
+	if field, value, ok := strings.Cut(t.text, ":"); ok && !t.quoted {
+		if build, known := queryFields[strings.ToLower(field)]; known {
+			if value == "" {
+				return nil, fmt.Errorf("%s: needs a value", field)
+			}
+			if get, ok := textFields[strings.ToLower(field)]; ok && matchesCase(mode, value) {
+				value = matchForm(value)
+				return predicate(func(b Bookmark) bool { return strings.Contains(matchForm(get(b)), value) }), nil
+			}
+			pred, err := build(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", field, err)
+			}
+			return pred, nil
+		}
+	}
+	if matchesCase(mode, t.text) {
+		// The index is lowercase: snapshot text can't be matched with case.
+		word := matchForm(t.text)
+		contains := func(text string) bool { return strings.Contains(matchForm(text), word) }
+		return predicate(func(b Bookmark) bool {
+			return contains(b.Name) || contains(b.URL) || (search && (contains(b.Description) || slices.ContainsFunc(b.Tags, contains)))
+		}), nil
+	}
+	word := matchForm(strings.ToLower(t.text))
+	if search {
+		return fullTextMatch(word, true), nil
+	}
+	return predicate(func(b Bookmark) bool {
+		return strings.Contains(matchForm(strings.ToLower(b.Name)), word) || strings.Contains(matchForm(strings.ToLower(b.URL)), word)
+	}), nil
+}
+
+// textField matches text:word, a word anywhere in the name, URL, tags,
+// description or snapshot text.
+func textField(v string) (predicate, error) {
+	return fullTextMatch(matchForm(strings.ToLower(v)), false), nil
+}
+
+// fullTextMatch matches word in the name or URL, or through the index in the
+// tags, description and snapshot text; fuzzy forgives typos. The index is
+// looked up once, on the first bookmark tested, and then is a set of
+// candidates.
+func fullTextMatch(word string, fuzzy bool) predicate {
+	var once sync.Once
+	var indexed map[string]bool
+	return func(b Bookmark) bool {
+		once.Do(func() { indexed = loadIndex().lookup(word, fuzzy) })
+		if indexed[b.UUID] {
+			return true
+		}
+		return strings.Contains(matchForm(strings.ToLower(b.Name)), word) || strings.Contains(matchForm(strings.ToLower(b.URL)), word)
+	}
+}
+
+// matchesCase tells whether text is matched with its case under mode: always
+// when sensitive, when it has capitals when smart, like ripgrep's
+// --smart-case.
+func matchesCase(mode, text string) bool {
+	switch mode {
+	case caseSensitive:
+		return true
+	case caseSmart:
+		return strings.ToLower(text) != text
+	}
+	return false
+}
+
+// queryCaseFlag takes -i (ignore case) or -s (match case) when it is the
+// first word of a search, and returns the case mode it asks for, or the
+// configured one. Anywhere else they are part of the query: "search go -s"
+// leaves out what matches "s".
+func queryCaseFlag(args []string) ([]string, string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "-i":
+			return args[1:], caseIgnore
+		case "-s":
+			return args[1:], caseSensitive
+		}
+	}
+	return args, netConfig.SearchCase
+}
@@ -0,0 +1,298 @@
+// rank.go
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Weights of the fields a search word can match in, and of the kinds of
+// match: a word typed in full beats the start of a word, which beats a typo.
+const (
+	weightName        = 4.0
+	weightTags        = 3.0
+	weightDescription = 2.0
+	weightURL         = 1.0
+	// weightContent is for words only found in the snapshot text.
+	weightContent = 0.5
+
+	matchExact  = 1.0
+	matchPrefix = 0.8
+	matchFuzzy  = 0.6
+
+	// snippetWidth is about how many characters of context a snippet shows.
+	snippetWidth = 80
+)
+
+// searchRanker scores bookmarks against the bare words of a query.
+type searchRanker struct {
+	words []string
+	// content holds, per word, the bookmarks the full-text index finds it in.
+	content []map[string]bool
+}
+
+// =============================================================================
+// == 🏅 RANKED, TYPO-TOLERANT SEARCH
+// =============================================================================
+
+// newSearchRanker returns a ranker for the bare words of query, or nil if it
+// has none (tag:go alone gives nothing to rank by).
+func newSearchRanker(query string) *searchRanker {
+	words := queryWords(query)
+	if len(words) == 0 {
+		return nil
+	}
+	r := &searchRanker{words: words}
+	idx := loadIndex()
+	for _, w := range words {
+		r.content = append(r.content, idx.lookup(w, true))
+	}
+	return r
+}
+
+// rank sorts bookmarks best match first, keeping the current order on ties.
+func (r *searchRanker) rank(bookmarks []Bookmark) {
+	scores := make(map[string]float64, len(bookmarks))
+	for _, b := range bookmarks {
+		scores[b.UUID] = r.score(b)
+	}
+	sort.SliceStable(bookmarks, func(i, j int) bool {
+		return scores[bookmarks[i].UUID] > scores[bookmarks[j].UUID]
+	})
+}
+
+// score adds up, for each word, its best weighted match in b.
+func (r *searchRanker) score(b Bookmark) float64 {
+	fields := []struct {
+		weight float64
+		terms  []string
+	}{
+		{weightName, tokenize(b.Name)},
+		{weightTags, tokenize(strings.Join(b.Tags, " "))},
+		{weightDescription, tokenize(b.Description)},
+		{weightURL, tokenize(displayURL(b.URL))},
+	}
+	total := 0.0
+	for i, w := range r.words {
+		best := 0.0
+		if r.content[i][b.UUID] {
+			best = weightContent
+		}
+		for _, f := range fields {
+			for _, t := range f.terms {
+				best = max(best, f.weight*termMatch(w, t))
+			}
+		}
+		total += best
+	}
+	return total
+}
+
+// queryWords returns the words of the bare (not field:value, not negated)
+// terms of a query, lowercased.
+func queryWords(query string) []string {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil
+	}
+	var words []string
+	negate := false
+	for _, t := range tokens {
+		if !t.quoted {
+			switch {
+			case t.text == "NOT":
+				negate = true
+				continue
+			case t.text == "AND" || t.text == "OR" || t.text == "(" || t.text == ")":
+				continue
+			case strings.HasPrefix(t.text, "-"):
+				negate = false
+				continue
+			}
+			if field, _, ok := strings.Cut(t.text, ":"); ok && queryFields[strings.ToLower(field)] != nil {
+				negate = false
+				continue
+			}
+		}
+		if !negate {
+			words = append(words, tokenize(t.text)...)
+		}
+		negate = false
+	}
+	return words
+}
+
+// termMatch rates how well the search word w matches the indexed term t.
+func termMatch(w, t string) float64 {
+	switch {
+	case w == t:
+		return matchExact
+	case strings.HasPrefix(t, w):
+		return matchPrefix
+	case withinEdits(w, t, maxEdits(w)):
+		return matchFuzzy
+	}
+	return 0
+}
+
+// maxEdits is how many typos a word may contain: none up to three letters,
+// one up to seven, then two.
+func maxEdits(w string) int {
+	switch n := len([]rune(w)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// withinEdits reports whether a and b are at most limit insertions,
+// deletions, substitutions or swaps of neighbouring letters apart.
+func withinEdits(a, b string, limit int) bool {
+	if limit == 0 {
+		return a == b
+	}
+	if d := utf8.RuneCountInString(a) - utf8.RuneCountInString(b); d > limit || -d > limit {
+		return false
+	}
+	ra, rb := []rune(a), []rune(b)
+	// Three rows of the optimal string alignment distance table, on the
+	// stack for ordinary words since this runs across the whole vocabulary.
+	var buf [96]int
+	n := len(rb) + 1
+	rows := buf[:]
+	if 3*n > len(buf) {
+		rows = make([]int, 3*n)
+	}
+	prev2, prev, cur := rows[:n], rows[n:2*n], rows[2*n:3*n]
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return false
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)] <= limit
+}
+
+// highlight colors the words of text that match a search word. after is the
+// style to go back to once a match ends.
+func (r *searchRanker) highlight(text, after string) string {
+	var out strings.Builder
+	last := 0
+	for _, span := range r.matchSpans(text) {
+		out.WriteString(text[last:span[0]])
+		out.WriteString(Bold + Magenta + text[span[0]:span[1]] + Reset + after)
+		last = span[1]
+	}
+	out.WriteString(text[last:])
+	return out.String()
+}
+
+// matchSpans returns the byte offsets of the words of text that match a
+// search word.
+func (r *searchRanker) matchSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	flush := func(end int) {
+		if start < 0 {
+			return
+		}
+		word := matchForm(strings.ToLower(text[start:end]))
+		for _, w := range r.words {
+			if termMatch(w, word) > 0 {
+				spans = append(spans, [2]int{start, end})
+				break
+			}
+		}
+		start = -1
+	}
+	for i, c := range text {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		flush(i)
+	}
+	flush(len(text))
+	return spans
+}
+
+// snippet shows why b matched when the name does not: a bit of the
+// description or snapshot text around the first match, highlighted. It is
+// "" when the name already shows every word.
+func (r *searchRanker) snippet(b Bookmark) string {
+	nameTerms := tokenize(b.Name)
+	missing := false
+	for _, w := range r.words {
+		found := false
+		for _, t := range nameTerms {
+			if termMatch(w, t) > 0 {
+				found = true
+				break
+			}
+		}
+		missing = missing || !found
+	}
+	if !missing {
+		return ""
+	}
+	for _, text := range []string{b.Description, r.snapshotFor(b)} {
+		text = strings.Join(strings.Fields(text), " ")
+		spans := r.matchSpans(text)
+		if len(spans) == 0 {
+			continue
+		}
+		from := max(0, spans[0][0]-snippetWidth/3)
+		to := min(len(text), from+snippetWidth)
+		for from > 0 && !utf8.RuneStart(text[from]) {
+			from--
+		}
+		for to < len(text) && !utf8.RuneStart(text[to]) {
+			to++
+		}
+		prefix, suffix := "", ""
+		if from > 0 {
+			prefix = "…"
+		}
+		if to < len(text) {
+			suffix = "…"
+		}
+		return prefix + r.highlight(text[from:to], Gray) + suffix
+	}
+	return ""
+}
+
+// snapshotFor returns the snapshot text of b if the index found a search
+// word in it.
+func (r *searchRanker) snapshotFor(b Bookmark) string {
+	if b.Archive == nil || b.Archive.Snapshot == "" {
+		return ""
+	}
+	for _, hits := range r.content {
+		if hits[b.UUID] {
+			return snapshotText(b.Archive.Snapshot)
+		}
+	}
+	return ""
+}
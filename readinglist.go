@@ -0,0 +1,147 @@
+// readinglist.go
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	readingListTag    = "reading-list"
+	readingListPrefix = "reading_list-dt-"
+)
+
+// readingListEntry is one item of a Chromium reading list.
+type readingListEntry struct {
+	Title   string
+	URL     string
+	Read    bool
+	AddedAt time.Time
+}
+
+// =============================================================================
+// == 📖 CHROME READING LIST
+// =============================================================================
+
+// readingListDir returns the LevelDB holding the reading list of the browser
+// profile whose Bookmarks file is at bookmarksPath.
+func readingListDir(bookmarksPath string) string {
+	return filepath.Join(filepath.Dir(bookmarksPath), "Sync Data", "LevelDB")
+}
+
+// readChromeReadingList returns the reading list entries stored in dir.
+func readChromeReadingList(dir string) ([]readingListEntry, error) {
+	records, err := readLevelDB(dir, readingListPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var out []readingListEntry
+	for _, data := range records {
+		if e, ok := parseReadingListEntry(data); ok {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// parseReadingListEntry decodes a ReadingListSpecifics protobuf: title (2),
+// url (3), creation_time_us (4) and status (6), where 1 means read.
+func parseReadingListEntry(data []byte) (readingListEntry, bool) {
+	var e readingListEntry
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return e, false
+		}
+		data = data[n:]
+		field, wire := key>>3, key&7
+		switch wire {
+		case 0: // varint
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return e, false
+			}
+			data = data[n:]
+			switch field {
+			case 4:
+				e.AddedAt = time.UnixMicro(int64(v))
+			case 6:
+				e.Read = v == 1
+			}
+		case 2: // length-delimited
+			v, rest, ok := readSlice(data)
+			if !ok {
+				return e, false
+			}
+			data = rest
+			switch field {
+			case 2:
+				e.Title = string(v)
+			case 3:
+				e.URL = string(v)
+			}
+		case 1: // 64-bit
+			if len(data) < 8 {
+				return e, false
+			}
+			data = data[8:]
+		case 5: // 32-bit
+			if len(data) < 4 {
+				return e, false
+			}
+			data = data[4:]
+		default:
+			return e, false
+		}
+	}
+	return e, e.URL != ""
+}
+
+// importReadingList adds the reading list in dir, tagging the entries
+// reading-list and carrying over whether they were read.
+func (s *AppState) importReadingList(browser, dir string) error {
+	entries, err := readChromeReadingList(dir)
+	if err != nil {
+		return err
+	}
+	src := &Source{Browser: browser, Profile: filepath.Base(filepath.Dir(filepath.Dir(dir))), Path: dir, ImportedAt: time.Now()}
+	for _, e := range entries {
+		name := e.Title
+		if name == "" {
+			name = e.URL
+		}
+		if !s.addBookmark(name, e.URL, src) {
+			continue
+		}
+		b := &s.Bookmarks[len(s.Bookmarks)-1]
+		b.addTags(readingListTag)
+		b.Read = e.Read
+		if !e.AddedAt.IsZero() && e.AddedAt.Unix() > 0 {
+			b.AddedAt = e.AddedAt
+		}
+	}
+	return nil
+}
+
+// importReadingLists imports the reading list of every Chromium profile that
+// has one, and reports whether any was found.
+func (s *AppState) importReadingLists(chromeLikePaths map[string][]string) bool {
+	found := false
+	for browser, paths := range chromeLikePaths {
+		for _, path := range paths {
+			dir := readingListDir(path)
+			if _, err := os.Stat(dir); err != nil {
+				continue
+			}
+			if err := s.importReadingList(browser, dir); err != nil {
+				logger.Warn("failed to read the reading list", "browser", browser, "path", dir, "err", err)
+				continue
+			}
+			logger.Info("checked for reading list entries", "browser", browser, "path", dir)
+			found = true
+		}
+	}
+	return found
+}
@@ -0,0 +1,153 @@
+// recovery.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// =============================================================================
+// == 🩹 CORRUPTED DATA RECOVERY
+// =============================================================================
+
+// recoverState rebuilds the state from a data file that failed to parse. The
+// broken original is set aside as .corrupt first, then every bookmark that can
+// still be decoded is salvaged and topped up with the newest backup.
+func recoverState(data []byte) (*AppState, error) {
+	corruptPath := bookmarksFile + ".corrupt"
+	if err := os.WriteFile(dataPath(corruptPath), data, 0644); err != nil {
+		return nil, fmt.Errorf("could not set the damaged file aside, refusing to continue: %w", err)
+	}
+	fmt.Printf("The damaged original was kept as %s.\n", corruptPath)
+
+	state := salvageState(data)
+	fmt.Printf("Salvaged %d bookmarks from the damaged file.\n", len(state.Bookmarks))
+
+	if backupData, path, err := readLatestBackup(bookmarksFile); err != nil {
+		logger.Warn("could not read a backup", "err", err)
+	} else {
+		var backup AppState
+		if backupData, err = gunzipIfNeeded(backupData); err != nil {
+			logger.Warn("backup is unreadable too", "file", path, "err", err)
+		} else if err := json.Unmarshal(backupData, &backup); err != nil {
+			logger.Warn("backup is unreadable too", "file", path, "err", err)
+		} else {
+			added := mergeRecovered(state, &backup)
+			fmt.Printf("Recovered %d more bookmarks from %s.\n", added, path)
+		}
+	}
+	if state.Config.DefaultBrowserCmd == "" {
+		state.Config.DefaultBrowserCmd = browserSystem
+	}
+	return state, nil
+}
+
+// salvageState decodes the elements of the bookmarks array one by one,
+// skipping any that is malformed or has no UUID or URL, and picks up the
+// config object if it is still intact. A compressed file is inflated as far
+// as it goes first.
+func salvageState(data []byte) *AppState {
+	// A truncated gzip stream still yields what came before the damage.
+	if plain, _ := gunzipIfNeeded(data); len(plain) > 0 {
+		data = plain
+	}
+	state := &AppState{nextID: 1}
+	if i := bytes.Index(data, []byte(`"config"`)); i >= 0 {
+		if j := bytes.IndexByte(data[i:], '{'); j >= 0 {
+			json.NewDecoder(bytes.NewReader(data[i+j:])).Decode(&state.Config)
+		}
+	}
+	start := bytes.Index(data, []byte(`"bookmarks"`))
+	if start < 0 {
+		return state
+	}
+	open := bytes.IndexByte(data[start:], '[')
+	if open < 0 {
+		return state
+	}
+	seen := make(map[int]bool)
+	for _, element := range topLevelObjects(data[start+open+1:]) {
+		var b Bookmark
+		if err := json.Unmarshal(element, &b); err != nil || b.UUID == "" || b.URL == "" || seen[b.ID] {
+			continue
+		}
+		seen[b.ID] = true
+		state.Bookmarks = append(state.Bookmarks, b)
+	}
+	return state
+}
+
+// topLevelObjects returns the objects directly inside the array whose
+// contents start data, up to its closing bracket or the end of data. The
+// objects nested in them (clock, meta...) are part of theirs, not returned
+// on their own.
+func topLevelObjects(data []byte) [][]byte {
+	var objects [][]byte
+	depth, start := 0, -1
+	inString, escaped := false, false
+	for i, c := range data {
+		switch {
+		case inString:
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			if depth == 0 && c == '{' {
+				start = i
+			}
+			depth++
+		case c == '}' || c == ']':
+			if depth == 0 {
+				return objects // the end of the bookmarks array
+			}
+			depth--
+			if depth == 0 && start >= 0 {
+				objects = append(objects, data[start:i+1])
+				start = -1
+			}
+		}
+	}
+	return objects
+}
+
+// mergeRecovered adds the backup's bookmarks that the salvage missed (matched
+// by UUID or URL), giving fresh numeric IDs to any whose ID was taken by a
+// different bookmark in the meantime.
+func mergeRecovered(state, backup *AppState) int {
+	byURL := make(map[string]bool)
+	byUUID := make(map[string]bool)
+	usedIDs := make(map[int]bool)
+	maxID := 0
+	for _, b := range state.Bookmarks {
+		byURL[b.URL] = true
+		byUUID[b.UUID] = true
+		usedIDs[b.ID] = true
+		maxID = max(maxID, b.ID)
+	}
+	for _, b := range backup.Bookmarks {
+		maxID = max(maxID, b.ID)
+	}
+	added := 0
+	for _, b := range backup.Bookmarks {
+		if byURL[b.URL] || (b.UUID != "" && byUUID[b.UUID]) {
+			continue
+		}
+		if usedIDs[b.ID] {
+			maxID++
+			b.ID = maxID
+		}
+		usedIDs[b.ID] = true
+		byURL[b.URL] = true
+		state.Bookmarks = append(state.Bookmarks, b)
+		added++
+	}
+	return added
+}
@@ -0,0 +1,43 @@
+// recovery_test.go
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSalvageState(t *testing.T) {
+	const data = `{"config": {"default_browser_cmd": "firefox"}, "bookmarks": [
+  {"id": 1, "uuid": "u1", "name": "Go", "url": "https://go.dev",
+   "meta": {"url": "https://nested.example", "uuid": "x"},
+   "clock": {"name": "2025-01-01T00:00:00Z"}},
+  {"id": 2, "uuid": "u2", "name": "broken", "url": 42},
+  {"id": 3, "name": "no uuid", "url": "https://example.org"},
+  {"id": 4, "uuid": "u4", "name": "a } in {the name", "url": "https://example.com"},
+  {"id": 5, "uuid": "u5", "name": "cut sho`
+	wantIDs := []int{1, 4}
+	ids := func(s *AppState) []int {
+		var ids []int
+		for _, b := range s.Bookmarks {
+			ids = append(ids, b.ID)
+		}
+		return ids
+	}
+
+	s := salvageState([]byte(data))
+	if got := ids(s); !slices.Equal(got, wantIDs) {
+		t.Errorf("salvaged IDs %v, want %v", got, wantIDs)
+	}
+	if s.Config.DefaultBrowserCmd != "firefox" {
+		t.Errorf("config browser = %q", s.Config.DefaultBrowserCmd)
+	}
+
+	// The same file compressed, and cut short.
+	gz, err := gzipBytes([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(salvageState(gz[:len(gz)-8])); !slices.Equal(got, wantIDs) {
+		t.Errorf("salvaged IDs from gzip %v, want %v", got, wantIDs)
+	}
+}
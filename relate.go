@@ -0,0 +1,94 @@
+// relate.go
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// =============================================================================
+// == 🔗 RELATED BOOKMARKS
+// =============================================================================
+//
+// relate links two bookmarks both ways, to keep a talk, its slides and the
+// paper it cites together; show lists a bookmark's relations with their IDs.
+// Links hold UUIDs, so they survive renumbering and merges, and a link to a
+// deleted bookmark is skipped rather than shown.
+
+// relateCommand runs `relate <id1> <id2>`, or unrelate when remove is set.
+func (s *AppState) relateCommand(args []string, remove bool) error {
+	usage := "usage: relate <id1> <id2>"
+	if remove {
+		usage = "usage: unrelate <id1> <id2>"
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("%s", usage)
+	}
+	i, ok := s.findBookmark(args[0])
+	if !ok {
+		return nil
+	}
+	j, ok := s.findBookmark(args[1])
+	if !ok {
+		return nil
+	}
+	if i == j {
+		return fmt.Errorf("a bookmark cannot be related to itself")
+	}
+	a, b := s.Bookmarks[i], s.Bookmarks[j]
+	linked := slices.Contains(a.Related, b.UUID)
+	switch {
+	case remove && !linked:
+		return fmt.Errorf("'%s' and '%s' are not related", a.Name, b.Name)
+	case !remove && linked:
+		fmt.Printf("'%s' and '%s' are already related.\n", a.Name, b.Name)
+		return nil
+	}
+	link := func(k int, uuid string) {
+		s.editBookmark(k, func(b *Bookmark) {
+			if remove {
+				b.Related = slices.DeleteFunc(slices.Clone(b.Related), func(u string) bool { return u == uuid })
+				if len(b.Related) == 0 {
+					b.Related = nil
+				}
+			} else if !slices.Contains(b.Related, uuid) {
+				b.Related = append(slices.Clone(b.Related), uuid)
+			}
+		})
+	}
+	link(i, b.UUID)
+	link(j, a.UUID)
+	if remove {
+		fmt.Printf("'%s' and '%s' are no longer related.\n", a.Name, b.Name)
+	} else {
+		fmt.Printf("Related '%s' and '%s'.\n", a.Name, b.Name)
+	}
+	return nil
+}
+
+// relatedBookmarks returns the bookmarks b is linked with that still exist.
+func (s *AppState) relatedBookmarks(b Bookmark) []Bookmark {
+	var related []Bookmark
+	for _, uuid := range b.Related {
+		if i := s.indexOfUUID(uuid); i >= 0 {
+			related = append(related, s.Bookmarks[i])
+		}
+	}
+	return related
+}
+
+// printRelated lists the bookmarks related to b, under show's fields.
+func (s *AppState) printRelated(b Bookmark) {
+	related := s.relatedBookmarks(b)
+	if len(related) == 0 {
+		return
+	}
+	if plainOutput {
+		fmt.Println("Related:")
+	} else {
+		fmt.Printf("%sRelated%s\n", Bold+Cyan, Reset)
+	}
+	for _, r := range related {
+		printTreeLeaf(r, "  ")
+	}
+}
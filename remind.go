@@ -0,0 +1,96 @@
+// remind.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// == ⏰ REMINDERS
+// =============================================================================
+//
+// remind <id> <when> asks to revisit a bookmark later. Once due, it shows in
+// list due, and the daemon's remind job sends a desktop notification (once).
+// Opening the bookmark counts as the visit and clears the reminder.
+
+func (b Bookmark) due(now time.Time) bool {
+	return !b.RemindAt.IsZero() && !now.Before(b.RemindAt)
+}
+
+// runRemind runs `remind <id> <when|never>`.
+func (s *AppState) runRemind(args []string) {
+	if len(args) < 2 {
+		fmt.Println("Usage: remind <id> <30d|2w|tomorrow|YYYY-MM-DD[THH:MM]|never>")
+		return
+	}
+	i, ok := s.findBookmark(args[0])
+	if !ok {
+		return
+	}
+	var at time.Time
+	if args[1] != "never" {
+		t, _, err := parseWhen(args[1], time.Now())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		at = t
+	}
+	s.editBookmark(i, func(b *Bookmark) { b.RemindAt, b.Reminded = at, false })
+	if at.IsZero() {
+		fmt.Printf("No more reminder for '%s'.\n", s.Bookmarks[i].Name)
+	} else {
+		fmt.Printf("You will be reminded of '%s' on %s.\n", s.Bookmarks[i].Name, at.Format("2006-01-02 15:04"))
+	}
+}
+
+// dueBookmarks returns the bookmarks whose reminder is due, oldest first.
+func (s *AppState) dueBookmarks(now time.Time) []Bookmark {
+	var due []Bookmark
+	for _, b := range s.Bookmarks {
+		if b.due(now) && !b.trashed() {
+			due = append(due, b)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool { return due[i].RemindAt.Before(due[j].RemindAt) })
+	return due
+}
+
+// printDue runs `list due`.
+func (s *AppState) printDue() {
+	due := s.dueBookmarks(time.Now())
+	if len(due) == 0 {
+		fmt.Println("Nothing is due.")
+		return
+	}
+	for _, b := range due {
+		fmt.Printf("%s[%d]%s %s - %s%s (due %s)%s\n", style(Bold+Cyan), b.ID, style(Reset), b.Name, style(Gray), displayURL(b.URL), b.RemindAt.Format("2006-01-02 15:04"), style(Reset))
+	}
+}
+
+// fireReminders notifies about the reminders that came due since the last
+// run, marks them as notified and returns how many there were.
+func (s *AppState) fireReminders(now time.Time) int {
+	var names []string
+	for _, b := range s.dueBookmarks(now) {
+		if b.Reminded {
+			continue
+		}
+		if i, err := s.lookupBookmark(b.UUID); err == nil {
+			s.editBookmark(i, func(b *Bookmark) { b.Reminded = true })
+			names = append(names, b.Name)
+		}
+	}
+	if len(names) == 0 {
+		return 0
+	}
+	body := "Time to revisit " + names[0]
+	if len(names) > 1 {
+		body = fmt.Sprintf("Time to revisit %d bookmarks: %s", len(names), strings.Join(names, ", "))
+	}
+	s.notifyDesktop("Bibliothermes reminder", body)
+	return len(names)
+}
@@ -0,0 +1,376 @@
+// s3.go
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BackupConfig sends backups to S3-compatible storage (AWS S3, MinIO,
+// Backblaze B2...) as well as to backups/.
+type BackupConfig struct {
+	// Remote is where backup --remote and the daemon's backup job upload,
+	// e.g. "s3://bucket/bibliothermes".
+	Remote string `json:"remote,omitempty"`
+	// Endpoint is the server for non-AWS storage, e.g.
+	// "https://s3.us-west-004.backblazeb2.com"; buckets are then addressed by
+	// path. Defaults to $AWS_ENDPOINT_URL, else AWS.
+	Endpoint string `json:"endpoint,omitempty"`
+	// Region defaults to $AWS_REGION, else us-east-1.
+	Region string `json:"region,omitempty"`
+	// The keys default to $AWS_ACCESS_KEY_ID and $AWS_SECRET_ACCESS_KEY.
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+	// Keep is how many remote backups to keep, newest first (0: all);
+	// KeepDays also deletes those older than that many days.
+	Keep     int `json:"keep,omitempty"`
+	KeepDays int `json:"keep_days,omitempty"`
+}
+
+// s3Client signs requests to one bucket with AWS Signature Version 4.
+type s3Client struct {
+	endpoint  *url.URL
+	pathStyle bool
+	bucket    string
+	region    string
+	accessKey string
+	secretKey string
+	token     string
+	http      *http.Client
+}
+
+// =============================================================================
+// == ☁️ REMOTE BACKUPS
+// =============================================================================
+
+// parseS3URL splits s3://bucket/prefix into the bucket and a prefix that is
+// empty or ends with a slash.
+func parseS3URL(remote string) (bucket, prefix string, err error) {
+	rest, ok := strings.CutPrefix(remote, "s3://")
+	if !ok {
+		return "", "", fmt.Errorf("remote %q is not an s3://bucket/path URL", remote)
+	}
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("remote %q names no bucket", remote)
+	}
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// newS3Client connects to bucket with the configured or environment
+// credentials.
+func newS3Client(cfg BackupConfig, bucket string) (*s3Client, error) {
+	or := func(v, env string) string {
+		if v != "" {
+			return v
+		}
+		return os.Getenv(env)
+	}
+	c := &s3Client{
+		bucket:    bucket,
+		region:    or(cfg.Region, "AWS_REGION"),
+		accessKey: or(cfg.AccessKeyID, "AWS_ACCESS_KEY_ID"),
+		secretKey: or(cfg.SecretAccessKey, "AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+		http:      newClient(proxyFor, 5*time.Minute),
+	}
+	if c.region == "" {
+		c.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return nil, errors.New("no S3 credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, or config.backup's keys")
+	}
+	endpoint := or(cfg.Endpoint, "AWS_ENDPOINT_URL")
+	if endpoint == "" {
+		endpoint = "https://" + bucket + ".s3." + c.region + ".amazonaws.com"
+	} else {
+		c.pathStyle = true
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", endpoint)
+	}
+	c.endpoint = u
+	return c, nil
+}
+
+// do sends a signed request for key (the bucket itself when empty) and
+// returns the response body, or an error for any status but 2xx.
+func (c *s3Client) do(method, key string, query url.Values, body []byte) ([]byte, error) {
+	u := *c.endpoint
+	u.Path = "/" + key
+	if c.pathStyle {
+		u.Path = "/" + c.bucket + "/" + key
+	}
+	u.RawPath = s3Escape(u.Path)
+	u.RawQuery = strings.ReplaceAll(query.Encode(), "+", "%20")
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	c.sign(req, body, time.Now().UTC())
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		var s3err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		if xml.Unmarshal(data, &s3err) == nil && s3err.Code != "" {
+			return nil, fmt.Errorf("%s %s: %s: %s", method, u.Path, s3err.Code, s3err.Message)
+		}
+		return nil, fmt.Errorf("%s %s: server answered %s", method, u.Path, resp.Status)
+	}
+	return data, nil
+}
+
+// sign adds the Signature Version 4 headers to req.
+func (c *s3Client) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(sum[:])
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if c.token != "" {
+		req.Header.Set("x-amz-security-token", c.token)
+	}
+	names := []string{"host"}
+	for name := range req.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		headers.WriteString(name + ":" + value + "\n")
+	}
+	signed := strings.Join(names, ";")
+	// The query is already encoded the way the signature wants it: sorted,
+	// with %20 for spaces.
+	canonical := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery, headers.String(), signed, payloadHash}, "\n")
+	scope := day + "/" + c.region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{day, c.region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape percent-encodes a path as Signature Version 4 requires: all but
+// unreserved characters and slashes.
+func s3Escape(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if 'A' <= ch && ch <= 'Z' || 'a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9' ||
+			ch == '-' || ch == '_' || ch == '.' || ch == '~' || ch == '/' {
+			sb.WriteByte(ch)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", ch)
+		}
+	}
+	return sb.String()
+}
+
+// s3Object is an entry of a bucket listing.
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// list returns the objects whose key starts with prefix.
+func (c *s3Client) list(prefix string) ([]s3Object, error) {
+	var objects []s3Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		data, err := c.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Contents              []s3Object `xml:"Contents"`
+			IsTruncated           bool       `xml:"IsTruncated"`
+			NextContinuationToken string     `xml:"NextContinuationToken"`
+		}
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return nil, fmt.Errorf("unreadable bucket listing: %w", err)
+		}
+		objects = append(objects, page.Contents...)
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// remoteBackups returns the keys of the backup archives under prefix,
+// newest first.
+func (c *s3Client) remoteBackups(prefix string) ([]string, error) {
+	objects, err := c.list(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, o := range objects {
+		name := path.Base(o.Key)
+		if !strings.Contains(strings.TrimPrefix(o.Key, prefix), "/") && strings.HasPrefix(name, backupPrefix) && strings.HasSuffix(name, backupSuffix) {
+			keys = append(keys, o.Key)
+		}
+	}
+	// Archive names embed their timestamp, so lexical order is chronological.
+	sort.Sort(sort.Reverse(sort.StringSlice(keys)))
+	return keys, nil
+}
+
+// uploadBackup uploads the archive at local to remote and reports how it went.
+func (s *AppState) uploadBackup(remote, local string) {
+	where, pruned, err := putBackup(s.Config.Backup, remote, local)
+	if err != nil {
+		fmt.Printf("Error: remote backup: %v\n", err)
+		exitStatus = 1
+		return
+	}
+	fmt.Println("Uploaded to", where)
+	if pruned > 0 {
+		fmt.Printf("Deleted %d old remote backups.\n", pruned)
+	}
+}
+
+// putBackup uploads the archive at local to remote, then applies the
+// retention policy there. It returns the archive's URL and how many old
+// archives were deleted.
+func putBackup(cfg BackupConfig, remote, local string) (string, int, error) {
+	bucket, prefix, err := parseS3URL(remote)
+	if err != nil {
+		return "", 0, err
+	}
+	c, err := newS3Client(cfg, bucket)
+	if err != nil {
+		return "", 0, err
+	}
+	data, err := os.ReadFile(local)
+	if err != nil {
+		return "", 0, err
+	}
+	key := prefix + filepath.Base(local)
+	if _, err := c.do(http.MethodPut, key, nil, data); err != nil {
+		return "", 0, err
+	}
+	pruned, err := c.prune(prefix, cfg.Keep, cfg.KeepDays, time.Now())
+	return "s3://" + bucket + "/" + key, pruned, err
+}
+
+// prune deletes the archives under prefix beyond the newest keep, and those
+// older than keepDays; the newest archive always stays.
+func (c *s3Client) prune(prefix string, keep, keepDays int, now time.Time) (int, error) {
+	if keep <= 0 && keepDays <= 0 {
+		return 0, nil
+	}
+	keys, err := c.remoteBackups(prefix)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for i, key := range keys {
+		tooMany := keep > 0 && i >= keep
+		tooOld := false
+		if keepDays > 0 {
+			stamp := strings.TrimSuffix(strings.TrimPrefix(path.Base(key), backupPrefix), backupSuffix)
+			if t, err := time.ParseInLocation("20060102-150405", stamp, time.Local); err == nil {
+				tooOld = t.Before(now.AddDate(0, 0, -keepDays))
+			}
+		}
+		if i == 0 || !tooMany && !tooOld {
+			continue
+		}
+		if _, err := c.do(http.MethodDelete, key, nil, nil); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// downloadBackup fetches a remote archive into backups/ and returns its
+// path. remote names an archive, or a prefix whose newest archive is taken.
+func downloadBackup(cfg BackupConfig, remote string) (string, error) {
+	bucket, key, err := parseS3URL(remote)
+	if err != nil {
+		return "", err
+	}
+	c, err := newS3Client(cfg, bucket)
+	if err != nil {
+		return "", err
+	}
+	key = strings.TrimSuffix(key, "/")
+	if !strings.HasSuffix(key, backupSuffix) {
+		prefix := key
+		if prefix != "" {
+			prefix += "/"
+		}
+		keys, err := c.remoteBackups(prefix)
+		if err != nil {
+			return "", err
+		}
+		if len(keys) == 0 {
+			return "", fmt.Errorf("no backups under %s", remote)
+		}
+		key = keys[0]
+	}
+	data, err := c.do(http.MethodGet, key, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dataPath(backupDir), 0755); err != nil {
+		return "", err
+	}
+	local := filepath.Join(dataPath(backupDir), path.Base(key))
+	return local, os.WriteFile(local, data, 0644)
+}
@@ -0,0 +1,112 @@
+// safesave.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Values of Config.SafeSave.
+const (
+	safeSaveAuto   = "auto" // on in cloud-synced folders (the default)
+	safeSaveAlways = "always"
+	safeSaveNever  = "never"
+)
+
+// cloudFolders are path components that give away a folder kept in sync by
+// a cloud storage client.
+var cloudFolders = []string{
+	"Dropbox", "OneDrive", "iCloud Drive", "iCloudDrive", "Mobile Documents",
+	"CloudStorage", "Google Drive", "GoogleDrive", "My Drive", "pCloud Drive",
+	"Nextcloud", "ownCloud",
+}
+
+// =============================================================================
+// == ☁️ SAVING INTO SYNCED FOLDERS
+// =============================================================================
+//
+// Sync clients upload a file as soon as it changes, and may grab it halfway
+// through a write or pick up a temporary file and make a "conflicted copy" of
+// it. In a synced folder, saves go to a uniquely named temporary file that is
+// flushed to disk and read back before it replaces the data file, which is
+// then read back too.
+
+// useSafeSave reports whether saves take the careful path: the config
+// setting if it is always or never, otherwise whether the data file is in a
+// cloud-synced folder.
+func (s *AppState) useSafeSave() bool {
+	switch s.Config.SafeSave {
+	case safeSaveAlways:
+		return true
+	case safeSaveNever:
+		return false
+	}
+	return inCloudFolder(dataPath(bookmarksFile))
+}
+
+// inCloudFolder guesses from its path whether file is kept in sync by a
+// cloud storage client.
+func inCloudFolder(file string) bool {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return false
+	}
+	if real, err := filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+		path = real
+	}
+	if od := os.Getenv("OneDrive"); od != "" && strings.HasPrefix(path, od) {
+		return true
+	}
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		for _, name := range cloudFolders {
+			// "OneDrive - Company", "Dropbox (Personal)"...
+			if part == name || strings.HasPrefix(part, name+" ") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// writeFileSafely replaces file with data through a uniquely named temporary
+// file in the same folder, synced to disk and verified before and after the
+// rename.
+func writeFileSafely(file string, data []byte) error {
+	dir := filepath.Dir(file)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(file)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if written, err := os.ReadFile(tmp.Name()); err != nil || !bytes.Equal(written, data) {
+		return fmt.Errorf("%s did not read back as written (%v); %s is unchanged", tmp.Name(), err, file)
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return err
+	}
+	if d, err := os.Open(dir); err == nil {
+		d.Sync() // not supported everywhere; best effort
+		d.Close()
+	}
+	if saved, err := os.ReadFile(file); err != nil || !bytes.Equal(saved, data) {
+		return fmt.Errorf("%s changed as it was saved, probably by a sync client (%v); run save again", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+// scripting.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.starlark.net/lib/json"
+	sltime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// maxFetchBody caps how much of a page fetch() hands to a script.
+const maxFetchBody = 1 << 20
+
+// =============================================================================
+// == 📜 STARLARK SCRIPTING
+// =============================================================================
+
+// runScript executes a Starlark file with the bookmark store exposed as
+// builtins:
+//
+//	bookmarks()                      list of bookmark dicts
+//	get(id)                          one bookmark dict, or None
+//	add(url, name="")                add a bookmark, returns its ID
+//	update(id, name=, url=, favorite=) fails if the URL is bookmarked already
+//	delete(id)
+//	tag(id, *tags) / untag(id, *tags)
+//	fetch(url)                       dict with status, content_type and body
+//
+// IDs may be numeric IDs or UUIDs. The time and json modules are predeclared,
+// and the script's own arguments are available as `args`.
+func (s *AppState) runScript(path string, args []string) error {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read script: %w", err)
+	}
+	scriptArgs := make([]starlark.Value, len(args))
+	for i, a := range args {
+		scriptArgs[i] = starlark.String(a)
+	}
+	predeclared := starlark.StringDict{
+		"args":      starlark.NewList(scriptArgs),
+		"time":      sltime.Module,
+		"json":      json.Module,
+		"bookmarks": starlark.NewBuiltin("bookmarks", s.slBookmarks),
+		"get":       starlark.NewBuiltin("get", s.slGet),
+		"add":       starlark.NewBuiltin("add", s.slAdd),
+		"update":    starlark.NewBuiltin("update", s.slUpdate),
+		"delete":    starlark.NewBuiltin("delete", s.slDelete),
+		"tag":       starlark.NewBuiltin("tag", s.slTag),
+		"untag":     starlark.NewBuiltin("untag", s.slTag),
+		"fetch":     starlark.NewBuiltin("fetch", slFetch),
+	}
+	thread := &starlark.Thread{
+		Name:  path,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) },
+	}
+	// Macros are short top-level programs, so allow loops and reassignment
+	// outside functions.
+	opts := &syntax.FileOptions{TopLevelControl: true, GlobalReassign: true, While: true, Set: true}
+	_, err = starlark.ExecFileOptions(opts, thread, path, src, predeclared)
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fmt.Errorf("%s", evalErr.Backtrace())
+	}
+	return err
+}
+
+// bookmarkValue converts a bookmark to the dict scripts see.
+func bookmarkValue(b Bookmark) *starlark.Dict {
+	d := starlark.NewDict(8)
+	tags := make([]starlark.Value, len(b.Tags))
+	for i, t := range b.Tags {
+		tags[i] = starlark.String(t)
+	}
+	d.SetKey(starlark.String("id"), starlark.MakeInt(b.ID))
+	d.SetKey(starlark.String("uuid"), starlark.String(b.UUID))
+	d.SetKey(starlark.String("name"), starlark.String(b.Name))
+	d.SetKey(starlark.String("url"), starlark.String(b.URL))
+	d.SetKey(starlark.String("favorite"), starlark.Bool(b.Favorite))
+	d.SetKey(starlark.String("read"), starlark.Bool(b.Read))
+	d.SetKey(starlark.String("tags"), starlark.NewList(tags))
+	if b.Source != nil {
+		d.SetKey(starlark.String("source"), starlark.String(b.Source.Browser))
+		d.SetKey(starlark.String("imported_at"), sltime.Time(b.Source.ImportedAt))
+	} else {
+		d.SetKey(starlark.String("source"), starlark.None)
+		d.SetKey(starlark.String("imported_at"), starlark.None)
+	}
+	return d
+}
+
+// scriptIndex resolves a script-supplied ID (int or UUID string).
+func (s *AppState) scriptIndex(v starlark.Value) (int, error) {
+	ref := ""
+	switch v := v.(type) {
+	case starlark.Int:
+		ref = v.String()
+	case starlark.String:
+		ref = string(v)
+	default:
+		return -1, fmt.Errorf("id must be an int or a UUID string, got %s", v.Type())
+	}
+	i, err := s.lookupBookmark(ref)
+	if err != nil {
+		return -1, fmt.Errorf("%s: %w", ref, err)
+	}
+	return i, nil
+}
+
+func (s *AppState) slBookmarks(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+		return nil, err
+	}
+	out := make([]starlark.Value, len(s.Bookmarks))
+	for i, b := range s.Bookmarks {
+		out[i] = bookmarkValue(b)
+	}
+	return starlark.NewList(out), nil
+}
+
+func (s *AppState) slGet(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id starlark.Value
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &id); err != nil {
+		return nil, err
+	}
+	i, err := s.scriptIndex(id)
+	if err != nil {
+		return starlark.None, nil
+	}
+	return bookmarkValue(s.Bookmarks[i]), nil
+}
+
+func (s *AppState) slAdd(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url, name string
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "url", &url, "name?", &name); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = url
+	}
+	if !s.addBookmark(name, url, nil) {
+		return starlark.None, nil
+	}
+	b := s.Bookmarks[len(s.Bookmarks)-1]
+	s.emit(eventAdd, b)
+	return starlark.MakeInt(b.ID), nil
+}
+
+func (s *AppState) slUpdate(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id starlark.Value
+	var name, url starlark.String
+	var favorite starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "id", &id, "name?", &name, "url?", &url, "favorite?", &favorite); err != nil {
+		return nil, err
+	}
+	i, err := s.scriptIndex(id)
+	if err != nil {
+		return nil, err
+	}
+	// A new URL is checked as edit <id> checks it.
+	newURL := s.Bookmarks[i].URL
+	if v := strings.TrimSpace(string(url)); v != "" {
+		newURL = asciiURL(normalizeBookmarkURL(v))
+		if j := s.indexOfURL(newURL); j >= 0 && j != i {
+			return nil, fmt.Errorf("%s is already bookmarked as [%d]", v, s.Bookmarks[j].ID)
+		}
+	}
+	s.editBookmark(i, func(b *Bookmark) {
+		if name != "" {
+			b.Name = string(name)
+		}
+		if b.URL != newURL {
+			b.URL, b.Type = newURL, detectType(newURL)
+		}
+		if favorite != starlark.None {
+			b.Favorite = bool(favorite.Truth())
+		}
+	})
+	return starlark.None, nil
+}
+
+func (s *AppState) slDelete(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id starlark.Value
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &id); err != nil {
+		return nil, err
+	}
+	i, err := s.scriptIndex(id)
+	if err != nil {
+		return nil, err
+	}
+	s.removeBookmark(i)
+	return starlark.None, nil
+}
+
+// slTag implements both tag() and untag().
+func (s *AppState) slTag(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(kwargs) > 0 {
+		return nil, errors.New("unexpected keyword arguments")
+	}
+	if len(args) < 2 {
+		return nil, errors.New("want an id and at least one tag")
+	}
+	i, err := s.scriptIndex(args[0])
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(args)-1)
+	for _, v := range args[1:] {
+		t, ok := starlark.AsString(v)
+		if !ok {
+			return nil, fmt.Errorf("tags must be strings, got %s", v.Type())
+		}
+		tags = append(tags, t)
+	}
+	s.editBookmark(i, func(b *Bookmark) {
+		if fn.Name() == "tag" {
+			b.addTags(tags...)
+		} else {
+			b.removeTags(tags...)
+		}
+	})
+	return starlark.None, nil
+}
+
+func slFetch(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url string
+	if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 1, &url); err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient().Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBody))
+	if err != nil {
+		return nil, err
+	}
+	d := starlark.NewDict(3)
+	d.SetKey(starlark.String("status"), starlark.MakeInt(resp.StatusCode))
+	d.SetKey(starlark.String("content_type"), starlark.String(resp.Header.Get("Content-Type")))
+	d.SetKey(starlark.String("body"), starlark.String(body))
+	return d, nil
+}
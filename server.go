@@ -0,0 +1,215 @@
+// server.go
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultServerAddr is where serve listens unless Config.Server.Addr
+	// says otherwise: this machine only, until the config opens it up.
+	defaultServerAddr = "127.0.0.1:8421"
+	// defaultLANAddr is where the daemon serves LAN peers: every interface,
+	// since the peers are other machines.
+	defaultLANAddr = ":8421"
+)
+
+// ServerConfig is how serve listens and whom it lets in.
+type ServerConfig struct {
+	Addr string `json:"addr,omitempty"`
+	// Token is the secret clients send as "Authorization: Bearer <token>";
+	// serve makes one up the first time.
+	Token string `json:"token,omitempty"`
+	// LAN makes the daemon serve sync and advertise itself to sync peer on
+	// the local network; PeerID identifies this instance there.
+	LAN    bool   `json:"lan,omitempty"`
+	PeerID string `json:"peer_id,omitempty"`
+	// MetricsAddr is where the daemon serves /metrics, which serve serves
+	// on its own address.
+	MetricsAddr string `json:"metrics_addr,omitempty"`
+	// CertFile and KeyFile, PEM files, make serve speak HTTPS.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+}
+
+// apiServer handles the requests of serve. Each request works on a fresh
+// load of the data file and saves when done, like daemon jobs, so the REPL
+// and the daemon can share the collection with it.
+type apiServer struct {
+	token string
+	// peerID is what the server answers to GET /sync/hello.
+	peerID string
+}
+
+// freshStateMu serializes the work done on fresh loads of the data file, by
+// server requests and daemon jobs running in the same process.
+var freshStateMu sync.Mutex
+
+// =============================================================================
+// == 🌐 SERVER
+// =============================================================================
+
+// runServe runs `serve [--sync] [--addr host:port]` until interrupted.
+func (s *AppState) runServe(args []string) error {
+	addr, syncing := s.Config.Server.Addr, false
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--sync":
+			syncing = true
+		case args[i] == "--addr" && i+1 < len(args):
+			addr = args[i+1]
+			i++
+		default:
+			return fmt.Errorf("usage: serve [--sync] [--addr host:port]")
+		}
+	}
+	if addr == "" {
+		addr = defaultServerAddr
+	}
+	if err := setupLogging(s.Config.Log, true); err != nil {
+		return err
+	}
+	defer setupLogging(s.Config.Log, false)
+	s.ensureServerToken()
+	// Requests load the data file themselves: hand it what we have.
+	if err := s.saveState(); err != nil {
+		return err
+	}
+	server, errs := startServer(addr, s.Config.Server, syncing)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	logger.Info("serving; Ctrl-C stops", "addr", addr, "sync", syncing, "tls", s.Config.Server.CertFile != "")
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdown)
+	logger.Info("server stopped")
+	// Pick up what clients changed, so saving on exit keeps it.
+	fresh, err := loadState()
+	if err != nil {
+		return err
+	}
+	*s = *fresh
+	return nil
+}
+
+// ensureServerToken makes up the server's access token if there is none.
+func (s *AppState) ensureServerToken() {
+	if s.Config.Server.Token == "" {
+		s.Config.Server.Token = newToken()
+		s.saveConfig()
+		logger.Info("made up an access token for clients; it is in the config as server.token")
+	}
+}
+
+// startServer serves the API on addr in the background as cfg says, sync
+// included if syncing; the channel gets the error that stops it, if not
+// Shutdown.
+func startServer(addr string, cfg ServerConfig, syncing bool) (*http.Server, <-chan error) {
+	srv := &apiServer{token: cfg.Token, peerID: cfg.PeerID}
+	mux := http.NewServeMux()
+	var served []apiRoute
+	for _, route := range apiRoutes {
+		if route.sync && !syncing {
+			continue
+		}
+		served = append(served, route)
+		handler := srv.auth(func(w http.ResponseWriter, r *http.Request) { route.handle(srv, w, r) })
+		mux.HandleFunc(route.method+" "+apiPrefix+route.path, handler)
+		// Clients from before /v1 keep working.
+		mux.HandleFunc(route.method+" "+route.path, handler)
+	}
+	if syncing {
+		mux.HandleFunc("GET "+apiPrefix+"/sync/hello", srv.handleHello)
+	}
+	spec := openAPISpec(served)
+	mux.HandleFunc("GET /metrics", handleMetrics)
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) { writeJSON(w, spec, nil) })
+	mux.HandleFunc("POST /"+grpcService+"/{method}", srv.auth(srv.handleGRPC))
+	server := &http.Server{Addr: addr, Handler: timeRequests(mux), ReadHeaderTimeout: 10 * time.Second}
+	// gRPC needs HTTP/2, which clients speak without TLS unless the server
+	// has a certificate.
+	server.Protocols = new(http.Protocols)
+	server.Protocols.SetHTTP1(true)
+	server.Protocols.SetHTTP2(true)
+	server.Protocols.SetUnencryptedHTTP2(true)
+	errs := make(chan error, 1)
+	go func() {
+		if cfg.CertFile != "" {
+			errs <- server.ListenAndServeTLS(cfg.CertFile, cfg.KeyFile)
+			return
+		}
+		errs <- server.ListenAndServe()
+	}()
+	return server, errs
+}
+
+// newToken returns a random access token.
+func newToken() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// auth lets through the requests that carry the server's token, or are
+// signed with it by a LAN peer (see peerauth.go).
+func (srv *apiServer) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxSyncBody {
+			http.Error(w, fmt.Sprintf("request larger than %d MB", maxSyncBody>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		bearer := ok && subtle.ConstantTimeCompare([]byte(token), []byte(srv.token)) == 1
+		if !bearer && !signedWith(r, srv.token) {
+			http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// withState runs fn on a fresh load of the collection, one request at a
+// time, and saves after it if fn changed anything.
+func (srv *apiServer) withState(fn func(s *AppState) error) error {
+	freshStateMu.Lock()
+	defer freshStateMu.Unlock()
+	s, err := loadState()
+	if err != nil {
+		return err
+	}
+	if err := fn(s); err != nil {
+		return err
+	}
+	if !s.unsaved() {
+		return nil
+	}
+	return s.saveState()
+}
+
+// writeJSON answers with v as JSON, or with the error.
+func writeJSON(w http.ResponseWriter, v any, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
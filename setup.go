@@ -0,0 +1,83 @@
+// setup.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// =============================================================================
+// == 🧙 FIRST-RUN SETUP
+// =============================================================================
+//
+// The first time the REPL starts at a terminal, with no config file and no
+// collection in the current directory, a few questions replace the silent
+// defaults: where the collection lives, which browser opens links, whether to
+// import from the installed browsers now, and whether to use colors. Enter
+// takes the suggested answer; the answers go to the config file, where config
+// set changes them later.
+
+// needsSetup tells whether this is a first run the setup should handle.
+func needsSetup() bool {
+	if _, err := os.Stat(configPath()); !os.IsNotExist(err) {
+		return false
+	}
+	if _, err := os.Stat(bookmarksFile); !os.IsNotExist(err) {
+		return false
+	}
+	for _, f := range []*os.File{os.Stdin, os.Stdout} {
+		if info, err := f.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// runSetup asks the setup questions and writes the config file. It returns
+// whether to import from the installed browsers once the collection is read.
+func runSetup() (importNow bool, err error) {
+	c := defaultConfig()
+	fmt.Println("Welcome to bibliothermes! A few questions to set it up; Enter takes the answer in brackets.")
+
+	fmt.Println()
+	here, _ := os.Getwd()
+	if dir := askLine(fmt.Sprintf("Where should your bookmarks be kept? [%s] ", here)); dir != "" {
+		abs, err := filepath.Abs(expandHome(dir))
+		if err != nil {
+			return false, err
+		}
+		if abs != here {
+			c.DataDir = abs
+		}
+	}
+
+	fmt.Println()
+	choices := append([]string{browserSystem}, installedBrowsers()...)
+	fmt.Println("Which browser should open links?")
+	for i, cmd := range choices {
+		label := cmd
+		if cmd == browserSystem {
+			label = "the system default browser"
+		}
+		fmt.Printf("  %d. %s\n", i+1, label)
+	}
+	answer := askLine("Number, or a command of your own [1] ")
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+		c.DefaultBrowserCmd = choices[n-1]
+	} else if answer != "" {
+		c.DefaultBrowserCmd = answer
+	}
+
+	fmt.Println()
+	importNow = askKey("Import the bookmarks of your installed browsers now? [Y/n] ") != "n"
+	c.Plain = askKey("Use colors and symbols in the output? [Y/n] ") == "n"
+	plainOutput = plainOutput || c.Plain
+
+	if err := writeConfigFile(c); err != nil {
+		return false, fmt.Errorf("could not write %s: %w", configPath(), err)
+	}
+	fmt.Printf("\nSaved to %s; config set changes any of it.\n\n", configPath())
+	return importNow, nil
+}
@@ -0,0 +1,129 @@
+// share.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig lets share --email send mail itself instead of handing a mailto:
+// link to the mail client.
+type SMTPConfig struct {
+	Host string `json:"host,omitempty"`
+	// Port defaults to 587; the connection is upgraded with STARTTLS when the
+	// server offers it.
+	Port     int    `json:"port,omitempty"`
+	Username string `json:"username,omitempty"`
+	// Password can be left out of the file: BIBLIOTHERMES_SMTP_PASSWORD wins.
+	Password string `json:"password,omitempty"`
+	From     string `json:"from,omitempty"`
+}
+
+// =============================================================================
+// == ✉️ SHARING BY EMAIL
+// =============================================================================
+
+// runShare runs `share <id> --email [address...] [--note <text>]`. Without
+// --note, the REPL asks for one.
+func (s *AppState) runShare(args []string) {
+	if len(args) < 2 || args[1] != "--email" {
+		fmt.Println("Usage: share <id> --email [address...] [--note <text>]")
+		return
+	}
+	i, ok := s.findBookmark(args[0])
+	if !ok {
+		return
+	}
+	to, note := args[2:], ""
+	if n := slices.Index(to, "--note"); n >= 0 {
+		to, note = to[:n], strings.Join(to[n+1:], " ")
+	} else if interactive {
+		note = askLine("Note (optional): ")
+	}
+	b := s.Bookmarks[i]
+	if err := s.shareByEmail(b, to, note); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		exitStatus = 1
+		return
+	}
+	if s.Config.SMTP.Host != "" {
+		fmt.Printf("Sent '%s' to %s.\n", b.Name, strings.Join(to, ", "))
+	}
+}
+
+// shareByEmail writes an email about b to the given addresses, with an
+// optional note: sent through the configured SMTP server, or else opened in
+// the mail client as a mailto: link.
+func (s *AppState) shareByEmail(b Bookmark, to []string, note string) error {
+	subject, body := shareMessage(b, note)
+	if s.Config.SMTP.Host == "" {
+		link := "mailto:" + strings.Join(to, ",") + "?subject=" + mailtoEscape(subject) + "&body=" + mailtoEscape(body)
+		return s.openBookmark(Bookmark{URL: link})
+	}
+	if len(to) == 0 {
+		return errors.New("give the recipients' addresses to send through SMTP")
+	}
+	return s.sendMail(to, subject, body)
+}
+
+// shareMessage is the subject and text of a shared bookmark.
+func shareMessage(b Bookmark, note string) (subject, body string) {
+	var text strings.Builder
+	if note != "" {
+		text.WriteString(note + "\n\n")
+	}
+	text.WriteString(b.Name + "\n" + b.URL + "\n")
+	if b.Description != "" {
+		text.WriteString("\n" + b.Description + "\n")
+	}
+	return b.Name, text.String()
+}
+
+// mailtoEscape escapes a mailto: header value; spaces must be %20, not +.
+func mailtoEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// sendMail sends a plain-text email through the configured SMTP server.
+func (s *AppState) sendMail(to []string, subject, body string) error {
+	cfg := s.Config.SMTP
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	from := cfg.From
+	if from == "" {
+		from = cfg.Username
+	}
+	if from == "" {
+		return errors.New("set config.smtp.from (or username) to send mail")
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		password := cfg.Password
+		if env := os.Getenv("BIBLIOTHERMES_SMTP_PASSWORD"); env != "" {
+			password = env
+		}
+		auth = smtp.PlainAuth("", cfg.Username, password, cfg.Host)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	addr := cfg.Host + ":" + strconv.Itoa(port)
+	if err := smtp.SendMail(addr, auth, from, to, []byte(msg.String())); err != nil {
+		return fmt.Errorf("smtp %s: %w", addr, err)
+	}
+	return nil
+}
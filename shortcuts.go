@@ -0,0 +1,235 @@
+// shortcuts.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// =============================================================================
+// == 🔗 SHORTCUT FILES (.url, .webloc)
+// =============================================================================
+
+// importShortcuts walks dir for Windows .url and macOS .webloc files and adds
+// each as a bookmark named after the file. It returns how many were new.
+func (s *AppState) importShortcuts(dir string) (int, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return 0, err
+	} else if !info.IsDir() {
+		return 0, fmt.Errorf("%s is not a directory", dir)
+	}
+	initialCount := len(s.Bookmarks)
+	s.beginImport()
+	// finishImport ends the import too; ending it twice does no harm.
+	defer s.endImport()
+	found := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		var url string
+		ext := strings.ToLower(filepath.Ext(path))
+		switch ext {
+		case ".url":
+			url, err = readURLFile(path)
+		case ".webloc":
+			url, err = readWeblocFile(path)
+		default:
+			return nil
+		}
+		if err != nil {
+			logger.Warn("skipped", "file", path, "err", err)
+			return nil
+		}
+		found++
+		name := strings.TrimSuffix(d.Name(), filepath.Ext(d.Name()))
+		s.addBookmark(name, url, &Source{Browser: "Shortcuts", Path: path, ImportedAt: time.Now()})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if found == 0 {
+		fmt.Printf("No .url or .webloc files found in %s.\n", dir)
+		return 0, nil
+	}
+	return s.finishImport(initialCount), nil
+}
+
+// readURLFile returns the URL= entry of the [InternetShortcut] section of a
+// Windows Internet Shortcut.
+func readURLFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if ok && section == "internetshortcut" && strings.EqualFold(strings.TrimSpace(key), "URL") {
+			if value = strings.TrimSpace(value); value != "" {
+				return value, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("no URL entry")
+}
+
+// readWeblocFile returns the URL key of a macOS .webloc property list, which
+// may be XML or binary.
+func readWeblocFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if bytes.HasPrefix(data, []byte("bplist00")) {
+		return binaryPlistURL(data)
+	}
+	return xmlPlistURL(data)
+}
+
+// xmlPlistURL finds <key>URL</key><string>...</string> in an XML plist.
+func xmlPlistURL(data []byte) (string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	var text, lastKey string
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", errors.New("no URL key")
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			text = ""
+		case xml.CharData:
+			text += string(t)
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "key":
+				lastKey = strings.TrimSpace(text)
+			case "string":
+				if lastKey == "URL" {
+					return strings.TrimSpace(text), nil
+				}
+				lastKey = ""
+			}
+		}
+	}
+}
+
+// binaryPlistURL reads the URL value of the top-level dictionary of a binary
+// ("bplist00") property list.
+func binaryPlistURL(data []byte) (string, error) {
+	errBad := errors.New("malformed binary plist")
+	if len(data) < 40 {
+		return "", errBad
+	}
+	trailer := data[len(data)-32:]
+	offsetSize, refSize := int(trailer[6]), int(trailer[7])
+	numObjects := readUint(trailer[8:16])
+	topObject := readUint(trailer[16:24])
+	offsetTable := readUint(trailer[24:32])
+	if offsetSize == 0 || refSize == 0 || offsetTable+numObjects*uint64(offsetSize) > uint64(len(data)) {
+		return "", errBad
+	}
+	offset := func(ref uint64) (int, bool) {
+		if ref >= numObjects {
+			return 0, false
+		}
+		at := offsetTable + ref*uint64(offsetSize)
+		off := readUint(data[at : at+uint64(offsetSize)])
+		return int(off), off < uint64(len(data))
+	}
+	// length returns the element count of the object at off and where its
+	// contents start.
+	length := func(off int) (int, int, bool) {
+		n := int(data[off] & 0x0f)
+		if n != 0x0f {
+			return n, off + 1, true
+		}
+		if off+2 > len(data) || data[off+1]&0xf0 != 0x10 {
+			return 0, 0, false
+		}
+		size := 1 << (data[off+1] & 0x0f)
+		if off+2+size > len(data) {
+			return 0, 0, false
+		}
+		return int(readUint(data[off+2 : off+2+size])), off + 2 + size, true
+	}
+	str := func(ref uint64) (string, bool) {
+		off, ok := offset(ref)
+		if !ok {
+			return "", false
+		}
+		n, start, ok := length(off)
+		if !ok {
+			return "", false
+		}
+		switch data[off] >> 4 {
+		case 0x5: // ASCII
+			if start+n > len(data) {
+				return "", false
+			}
+			return string(data[start : start+n]), true
+		case 0x6: // UTF-16BE
+			if start+2*n > len(data) {
+				return "", false
+			}
+			units := make([]uint16, n)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(data[start+2*i:])
+			}
+			return string(utf16.Decode(units)), true
+		}
+		return "", false
+	}
+	off, ok := offset(topObject)
+	if !ok || data[off]>>4 != 0xd {
+		return "", errBad
+	}
+	n, start, ok := length(off)
+	if !ok || start+2*n*refSize > len(data) {
+		return "", errBad
+	}
+	for i := 0; i < n; i++ {
+		keyRef := readUint(data[start+i*refSize : start+(i+1)*refSize])
+		if key, ok := str(keyRef); !ok || key != "URL" {
+			continue
+		}
+		valRef := readUint(data[start+(n+i)*refSize : start+(n+i+1)*refSize])
+		if url, ok := str(valRef); ok {
+			return url, nil
+		}
+		return "", errBad
+	}
+	return "", errors.New("no URL key")
+}
+
+// readUint decodes a big-endian unsigned integer of up to 8 bytes.
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
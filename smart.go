@@ -0,0 +1,127 @@
+// smart.go
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// =============================================================================
+// == 🗂️ SMART FOLDERS
+// =============================================================================
+
+// smartCommand runs `smart add <name> <query>`, `smart rm <name>`,
+// `smart <name>` (list its current matches) and `smart` (list them all).
+// Smart folders are saved queries in Config.SmartFolders, so they always
+// reflect the current collection.
+func (s *AppState) smartCommand(args []string) error {
+	if len(args) == 0 || args[0] == "list" {
+		names := s.smartFolderNames()
+		if len(names) == 0 {
+			fmt.Println("No smart folders. Create one with: smart add <name> <query>")
+		}
+		for _, name := range names {
+			matches, _ := s.smartMatches(name)
+			fmt.Printf("%s (%d): %s\n", name, len(matches), s.Config.SmartFolders[name])
+		}
+		return nil
+	}
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: smart add <name> <query>")
+		}
+		query := strings.Join(args[2:], " ")
+		if _, err := parseQuery(query); err != nil {
+			return fmt.Errorf("invalid query: %w", err)
+		}
+		if s.Config.SmartFolders == nil {
+			s.Config.SmartFolders = map[string]string{}
+		}
+		s.Config.SmartFolders[args[1]] = query
+		s.saveConfig()
+		matches, _ := s.smartMatches(args[1])
+		fmt.Printf("Smart folder '%s' saved (%d matches).\n", args[1], len(matches))
+	case "rm", "delete":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: smart rm <name>")
+		}
+		if _, ok := s.Config.SmartFolders[args[1]]; !ok {
+			return fmt.Errorf("no smart folder named '%s'", args[1])
+		}
+		delete(s.Config.SmartFolders, args[1])
+		s.saveConfig()
+		fmt.Printf("Smart folder '%s' removed.\n", args[1])
+	default:
+		matches, err := s.smartMatches(args[0])
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			fmt.Println("No bookmarks match.")
+		}
+		for _, b := range matches {
+			printTreeLeaf(b, "  ")
+		}
+	}
+	return nil
+}
+
+func (s *AppState) smartFolderNames() []string {
+	names := make([]string, 0, len(s.Config.SmartFolders))
+	for name := range s.Config.SmartFolders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// smartMatches returns the bookmarks currently matching a smart folder.
+func (s *AppState) smartMatches(name string) ([]Bookmark, error) {
+	query, ok := s.Config.SmartFolders[name]
+	if !ok {
+		return nil, fmt.Errorf("no smart folder named '%s'", name)
+	}
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("smart folder '%s': %w", name, err)
+	}
+	return s.filterBookmarks(bookmarkFilter{query: q, text: query}), nil
+}
+
+// printTree prints every smart folder with the bookmarks it holds.
+func (s *AppState) printTree() {
+	names := s.smartFolderNames()
+	if len(names) == 0 {
+		fmt.Println("No folders yet. Create a smart folder with: smart add <name> <query>")
+		return
+	}
+	for _, name := range names {
+		matches, err := s.smartMatches(name)
+		if err != nil {
+			logger.Warn("skipped smart folder", "name", name, "err", err)
+			continue
+		}
+		if plainOutput {
+			fmt.Printf("%s (%d): %s\n", name, len(matches), s.Config.SmartFolders[name])
+		} else {
+			fmt.Printf("%s%s%s%s (%d) %s%s%s\n", decor("🔎 "), Bold, name, Reset, len(matches), Gray, s.Config.SmartFolders[name], Reset)
+		}
+		for i, b := range matches {
+			branch := "├─ "
+			if i == len(matches)-1 {
+				branch = "└─ "
+			}
+			printTreeLeaf(b, "  "+decor(branch))
+		}
+	}
+}
+
+func printTreeLeaf(b Bookmark, indent string) {
+	if plainOutput {
+		fmt.Printf("%s%d: %s: %s\n", indent, b.ID, b.Name, b.URL)
+		return
+	}
+	fmt.Printf("%s%s[%d]%s %s %s%s%s\n", indent, Bold+Cyan, b.ID, Reset, b.Name, Gray, b.URL, Reset)
+}
@@ -0,0 +1,66 @@
+// snooze.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// =============================================================================
+// == 😴 SNOOZED BOOKMARKS
+// =============================================================================
+//
+// snooze <id> <when> puts a bookmark out of sight until then: list, search and
+// live search leave it out unless asked for (snoozed:true). When the time
+// comes it reappears, flagged as back from snooze until it is opened.
+
+// snoozed reports whether b is snoozed at now.
+func (b Bookmark) snoozed(now time.Time) bool {
+	return !b.SnoozedUntil.IsZero() && now.Before(b.SnoozedUntil)
+}
+
+// backFromSnooze reports whether b's snooze is over and it has not been
+// opened since.
+func (b Bookmark) backFromSnooze(now time.Time) bool {
+	return !b.SnoozedUntil.IsZero() && !now.Before(b.SnoozedUntil)
+}
+
+// hidesSnoozed reports whether a listing for query leaves snoozed bookmarks
+// out: always, unless the query asks about them.
+func hidesSnoozed(query string) bool {
+	return !strings.Contains(strings.ToLower(query), "snoozed:")
+}
+
+// visible reports whether a listing for query shows b at now: trashed,
+// shelved and snoozed bookmarks only if the query asks about them.
+func (b Bookmark) visible(query string, now time.Time) bool {
+	return !(b.trashed() && hidesTrashed(query)) && !(b.shelved() && hidesShelved(query)) && !(b.snoozed(now) && hidesSnoozed(query))
+}
+
+// runSnooze runs `snooze <id> <when|off>`.
+func (s *AppState) runSnooze(args []string) {
+	if len(args) < 2 {
+		fmt.Println("Usage: snooze <id> <30d|2w|tomorrow|YYYY-MM-DD[THH:MM]|off>")
+		return
+	}
+	i, ok := s.findBookmark(args[0])
+	if !ok {
+		return
+	}
+	var until time.Time
+	if args[1] != "off" {
+		t, _, err := parseWhen(args[1], time.Now())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		until = t
+	}
+	s.editBookmark(i, func(b *Bookmark) { b.SnoozedUntil = until })
+	if until.IsZero() {
+		fmt.Printf("'%s' is no longer snoozed.\n", s.Bookmarks[i].Name)
+	} else {
+		fmt.Printf("Snoozed '%s' until %s.\n", s.Bookmarks[i].Name, until.Format("2006-01-02 15:04"))
+	}
+}
@@ -0,0 +1,93 @@
+// stale.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// defaultStaleDays is how long a bookmark may go unopened before it is stale.
+const defaultStaleDays = 180
+
+// StaleConfig is the policy of the stale command and daemon job.
+type StaleConfig struct {
+	// AfterDays is how long since a bookmark was added or last opened (180).
+	AfterDays int `json:"after_days,omitempty"`
+	// MaxOpens spares bookmarks opened more often than this in all (0: only
+	// never-opened bookmarks go stale).
+	MaxOpens int `json:"max_opens,omitempty"`
+}
+
+// =============================================================================
+// == 🗄️ STALE BOOKMARKS & THE SHELF
+// =============================================================================
+//
+// Shelved bookmarks are kept but left out of list, search and live search
+// unless asked for (list shelf, shelved:true). Opening one puts it back.
+
+func (b Bookmark) shelved() bool {
+	return !b.ShelvedAt.IsZero()
+}
+
+// hidesShelved reports whether a listing for query leaves shelved bookmarks
+// out: always, unless the query asks about them.
+func hidesShelved(query string) bool {
+	return !strings.Contains(strings.ToLower(query), "shelved:")
+}
+
+// isStale reports whether b qualifies for the shelf under the policy.
+// Favorites never do.
+func (s *AppState) isStale(b Bookmark, now time.Time) bool {
+	days := s.Config.Stale.AfterDays
+	if days <= 0 {
+		days = defaultStaleDays
+	}
+	cutoff := now.AddDate(0, 0, -days)
+	return !b.shelved() && !b.Favorite &&
+		b.addedAt().Before(cutoff) &&
+		b.OpenCount <= s.Config.Stale.MaxOpens &&
+		b.lastOpened().Before(cutoff)
+}
+
+// staleBookmarks returns the indexes of the bookmarks that qualify.
+func (s *AppState) staleBookmarks(now time.Time) []int {
+	var stale []int
+	for i, b := range s.Bookmarks {
+		if s.isStale(b, now) {
+			stale = append(stale, i)
+		}
+	}
+	return stale
+}
+
+// shelve moves the bookmarks at the given indexes to the shelf.
+func (s *AppState) shelve(indexes []int, now time.Time) {
+	for _, i := range indexes {
+		s.editBookmark(i, func(b *Bookmark) { b.ShelvedAt = now })
+	}
+}
+
+// runStale lists the stale bookmarks and offers to shelve them; listOnly
+// just lists.
+func (s *AppState) runStale(listOnly bool) {
+	now := time.Now()
+	stale := s.staleBookmarks(now)
+	if len(stale) == 0 {
+		fmt.Println("No stale bookmarks.")
+		return
+	}
+	for _, i := range stale {
+		b := s.Bookmarks[i]
+		last := "never opened"
+		if b.OpenCount > 0 {
+			last = "last opened " + b.lastOpened().Format("2006-01-02")
+		}
+		fmt.Printf("%s[%d]%s %s %s(added %s, %s)%s\n", style(Bold+Cyan), b.ID, style(Reset), b.Name, style(Gray), b.addedAt().Format("2006-01-02"), last, style(Reset))
+	}
+	if listOnly || askKey(fmt.Sprintf("Move these %d bookmarks to the shelf? [y/n] ", len(stale))) != "y" {
+		return
+	}
+	s.shelve(stale, now)
+	fmt.Printf("Shelved %d bookmarks; list shelf shows them, unshelve <id> brings one back.\n", len(stale))
+}
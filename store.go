@@ -0,0 +1,128 @@
+// store.go
+package main
+
+import (
+	"bytes"
+	"hash/fnv"
+	"hash/maphash"
+	"sort"
+)
+
+// bookmarkIndex maps IDs, UUIDs and URLs to positions in s.Bookmarks, so
+// lookups do not scan 100k bookmarks. Appends keep it up to date; removals
+// and edits that change a key drop it, and the next lookup rebuilds it.
+type bookmarkIndex struct {
+	byID   map[int]int
+	byUUID map[string]int
+	byURL  map[uint64]int // by urlHash
+	size   int
+}
+
+// =============================================================================
+// == 🗄️ STORAGE INDEXES
+// =============================================================================
+
+// positions returns the lookup index, rebuilding it if bookmarks were added
+// or removed behind its back.
+func (s *AppState) positions() *bookmarkIndex {
+	if s.index != nil && s.index.size == len(s.Bookmarks) {
+		return s.index
+	}
+	idx := &bookmarkIndex{
+		byID:   make(map[int]int, len(s.Bookmarks)),
+		byUUID: make(map[string]int, len(s.Bookmarks)),
+		byURL:  make(map[uint64]int, len(s.Bookmarks)),
+	}
+	for i := range s.Bookmarks {
+		idx.insert(s.Bookmarks[i], i)
+	}
+	s.index = idx
+	return idx
+}
+
+func (idx *bookmarkIndex) insert(b Bookmark, i int) {
+	idx.byID[b.ID] = i
+	idx.byUUID[b.UUID] = i
+	if _, dup := idx.byURL[urlHash(b.URL)]; !dup {
+		idx.byURL[urlHash(b.URL)] = i
+	}
+	idx.size++
+}
+
+// appended records the bookmark just appended to s.Bookmarks.
+func (s *AppState) appended() {
+	if s.index != nil && s.index.size == len(s.Bookmarks)-1 {
+		s.index.insert(s.Bookmarks[len(s.Bookmarks)-1], len(s.Bookmarks)-1)
+	}
+}
+
+// findIn looks key up in one of the index maps and checks the hit against
+// the bookmark at that position; a stale entry forces one rebuild.
+func findIn[K comparable](s *AppState, table func(*bookmarkIndex) map[K]int, key K, matches func(Bookmark) bool) int {
+	for attempt := 0; attempt < 2; attempt++ {
+		i, ok := table(s.positions())[key]
+		if !ok {
+			return -1
+		}
+		if i < len(s.Bookmarks) && matches(s.Bookmarks[i]) {
+			return i
+		}
+		s.index = nil
+	}
+	return -1
+}
+
+func (s *AppState) indexOfID(id int) int {
+	return findIn(s, func(idx *bookmarkIndex) map[int]int { return idx.byID }, id, func(b Bookmark) bool { return b.ID == id })
+}
+
+func (s *AppState) indexOfUUID(uuid string) int {
+	return findIn(s, func(idx *bookmarkIndex) map[string]int { return idx.byUUID }, uuid, func(b Bookmark) bool { return b.UUID == uuid })
+}
+
+// indexOfURL returns the index of the bookmark for url, or -1.
+func (s *AppState) indexOfURL(url string) int {
+	url = asciiURL(url)
+	return findIn(s, func(idx *bookmarkIndex) map[uint64]int { return idx.byURL }, urlHash(url), func(b Bookmark) bool { return asciiURL(b.URL) == url })
+}
+
+// urlHash keys the URL index; 8 bytes per bookmark instead of a copy of
+// every URL. findIn checks hits, so a collision cannot return the wrong one.
+func urlHash(url string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(asciiURL(url)))
+	return h.Sum64()
+}
+
+// sortByName sorts bookmarks by name in the collation order of the locale,
+// ignoring case (see collation.go). Each name's sort key is computed once and
+// an order of positions is sorted, so the (large) Bookmark values are moved
+// only once.
+func sortByName(bookmarks []Bookmark) {
+	key := nameSortKey()
+	keys := make([][]byte, len(bookmarks))
+	order := make([]int, len(bookmarks))
+	for i, b := range bookmarks {
+		keys[i], order[i] = key(b.Name), i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		c := bytes.Compare(keys[a], keys[b])
+		return c < 0 || (c == 0 && a < b)
+	})
+	sorted := make([]Bookmark, len(bookmarks))
+	for i, from := range order {
+		sorted[i] = bookmarks[from]
+	}
+	copy(bookmarks, sorted)
+}
+
+// saveSeed seeds contentHash. The hashes are only compared within one run,
+// so a random seed is fine.
+var saveSeed = maphash.MakeSeed()
+
+// contentHash identifies the bytes last read from or written to
+// bookmarks.json, so saving an unchanged collection skips the write.
+func contentHash(data []byte) uint64 {
+	return maphash.Bytes(saveSeed, data)
+}
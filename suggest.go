@@ -0,0 +1,173 @@
+// suggest.go
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// maxOpenHistory caps how many open timestamps are kept per bookmark.
+	maxOpenHistory  = 50
+	newBookmarkAge  = 14 * 24 * time.Hour
+	suggestionCount = 10
+)
+
+// FrecencyConfig holds the ranking parameters. Unset fields use the defaults
+// noted on each; the weights are pointers so that 0 can switch a term off.
+type FrecencyConfig struct {
+	// HalfLifeDays is how long it takes an open to lose half its weight (14).
+	HalfLifeDays float64 `json:"half_life_days,omitempty"`
+	// FavoriteWeight is added to the score of favorites (1).
+	FavoriteWeight *float64 `json:"favorite_weight,omitempty"`
+	// RecentAddBoost is added for bookmarks added in the last RecentAddDays (1, 7).
+	RecentAddBoost *float64 `json:"recent_add_boost,omitempty"`
+	RecentAddDays  float64  `json:"recent_add_days,omitempty"`
+}
+
+func (c FrecencyConfig) halfLife() time.Duration {
+	if c.HalfLifeDays > 0 {
+		return time.Duration(c.HalfLifeDays * float64(24*time.Hour))
+	}
+	return 14 * 24 * time.Hour
+}
+
+func (c FrecencyConfig) favoriteWeight() float64 {
+	if c.FavoriteWeight != nil {
+		return *c.FavoriteWeight
+	}
+	return 1
+}
+
+func (c FrecencyConfig) recentAdd() (boost float64, window time.Duration) {
+	boost, days := 1.0, 7.0
+	if c.RecentAddBoost != nil {
+		boost = *c.RecentAddBoost
+	}
+	if c.RecentAddDays > 0 {
+		days = c.RecentAddDays
+	}
+	return boost, time.Duration(days * float64(24*time.Hour))
+}
+
+// =============================================================================
+// == 💡 SUGGESTIONS
+// =============================================================================
+
+// recordOpen notes that the bookmark at index i was just opened.
+func (s *AppState) recordOpen(i int) {
+	b := &s.Bookmarks[i]
+	b.OpenCount++
+	b.Opens = append(b.Opens, time.Now())
+	if len(b.Opens) > maxOpenHistory {
+		b.Opens = b.Opens[len(b.Opens)-maxOpenHistory:]
+	}
+	s.journalBookmarks(i)
+	// Opening a shelved bookmark shows it is still of use, and opening one
+	// whose reminder is due, or back from snooze, is the visit it was about.
+	if b.shelved() {
+		s.editBookmark(i, func(b *Bookmark) { b.ShelvedAt = time.Time{} })
+	}
+	if b.due(time.Now()) {
+		s.editBookmark(i, func(b *Bookmark) { b.RemindAt, b.Reminded = time.Time{}, false })
+	}
+	if b.backFromSnooze(time.Now()) {
+		s.editBookmark(i, func(b *Bookmark) { b.SnoozedUntil = time.Time{} })
+	}
+}
+
+// lastOpened returns when b was last opened, or the zero time.
+func (b Bookmark) lastOpened() time.Time {
+	if len(b.Opens) == 0 {
+		return time.Time{}
+	}
+	return b.Opens[len(b.Opens)-1]
+}
+
+// frecency scores how frequently and recently b was opened: every open counts
+// for 1, halving every half-life, plus the favorite and recent-add bonuses.
+func (s *AppState) frecency(b Bookmark, now time.Time) float64 {
+	cfg := s.Config.Frecency
+	halfLife := cfg.halfLife().Hours()
+	score := 0.0
+	for _, t := range b.Opens {
+		score += math.Exp2(-now.Sub(t).Hours() / halfLife)
+	}
+	if b.Favorite {
+		score += cfg.favoriteWeight()
+	}
+	if boost, window := cfg.recentAdd(); !b.addedAt().IsZero() && now.Sub(b.addedAt()) < window {
+		score += boost
+	}
+	return score
+}
+
+// habitScore counts past opens at about this time of day on this weekday.
+func (b Bookmark) habitScore(now time.Time) float64 {
+	score := 0.0
+	for _, t := range b.Opens {
+		diff := math.Abs(float64(t.Hour() - now.Hour()))
+		if min(diff, 24-diff) <= 1 {
+			score++
+			if t.Weekday() == now.Weekday() {
+				score++
+			}
+		}
+	}
+	return score
+}
+
+type suggestion struct {
+	b      Bookmark
+	score  float64
+	reason string
+}
+
+// suggestions ranks bookmarks you are likely to want now: ones you habitually
+// open at this time, new ones you never opened, and ones sharing a tag with the
+// bookmark you opened last.
+func (s *AppState) suggestions(now time.Time) []suggestion {
+	var last *Bookmark
+	for i := range s.Bookmarks {
+		if t := s.Bookmarks[i].lastOpened(); !t.IsZero() && (last == nil || t.After(last.lastOpened())) {
+			last = &s.Bookmarks[i]
+		}
+	}
+	var out []suggestion
+	for _, b := range s.Bookmarks {
+		habit, score := b.habitScore(now), s.frecency(b, now)
+		isNew := b.OpenCount == 0 && !b.addedAt().IsZero() && now.Sub(b.addedAt()) < newBookmarkAge
+		related := last != nil && b.UUID != last.UUID && sharesTag(b, *last)
+		sg := suggestion{b: b}
+		if habit >= 1 {
+			sg.score, sg.reason = habit+score, fmt.Sprintf("often opened around %02d:00", now.Hour())
+		} else if len(b.Opens) > 0 && score >= 1 {
+			sg.score, sg.reason = score, "opened a lot lately"
+		}
+		if related && 2 > sg.score {
+			sg.score, sg.reason = 2, fmt.Sprintf("related to '%s'", last.Name)
+		}
+		if isNew && 1.5 > sg.score {
+			sg.score, sg.reason = 1.5, "new, never opened"
+		}
+		if sg.score > 0 {
+			out = append(out, sg)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].score > out[j].score })
+	if len(out) > suggestionCount {
+		out = out[:suggestionCount]
+	}
+	return out
+}
+
+func sharesTag(a, b Bookmark) bool {
+	for _, t := range a.Tags {
+		if b.hasTag(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,355 @@
+// sync.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syncStateFile is where a client remembers how far it has synced.
+const syncStateFile = "sync.json"
+
+// maxSyncBody caps the body of a request to the server. Clients push in
+// batches of about syncBatchBytes, so a first sync of a large collection
+// stays well under it.
+const (
+	maxSyncBody    = 32 << 20
+	syncBatchBytes = 4 << 20
+)
+
+// SyncConfig names the server sync exchanges changes with.
+type SyncConfig struct {
+	Remote string `json:"remote,omitempty"`
+	Token  string `json:"token,omitempty"`
+}
+
+// syncMark is when, in the server's change sequence, a bookmark or
+// tombstone last changed, and its hash then.
+type syncMark struct {
+	Seq  int64  `json:"seq"`
+	Hash uint64 `json:"hash"`
+}
+
+// syncChanges is what goes over the wire both ways: bookmarks and
+// tombstones, and on the way back the cursor to ask from next time.
+type syncChanges struct {
+	Cursor     int64                `json:"cursor,omitempty"`
+	Bookmarks  []Bookmark           `json:"bookmarks"`
+	Tombstones map[string]time.Time `json:"tombstones,omitempty"`
+}
+
+// syncClientState is what a client remembers of a server in sync.json: the
+// cursor of the last pull, and the hash of each bookmark and tombstone as
+// last exchanged, so only what changed since is pushed.
+type syncClientState struct {
+	Cursor int64             `json:"cursor"`
+	Hashes map[string]uint64 `json:"hashes"`
+}
+
+// syncTarget is a server to sync with (the configured one or a LAN peer) or
+// to import from.
+type syncTarget struct {
+	// key names the server in sync.json: its URL, or a peer's ID.
+	key   string
+	url   string
+	token string
+	// lan targets are reached directly, never through a proxy.
+	lan bool
+	// peerID is set for LAN peers, whose requests are signed with the token
+	// rather than carrying it (see peerauth.go).
+	peerID string
+}
+
+// =============================================================================
+// == 🔄 SYNC SERVER
+// =============================================================================
+//
+// serve --sync lets several machines share one collection. The server numbers
+// every change it sees (pushed by a client or made locally) in one sequence;
+// a client pushes what it changed, then pulls the changes numbered after its
+// cursor. Both sides fold changes in with mergeState (see merge.go), so edits
+// made offline on several machines converge.
+
+// syncHash fingerprints a bookmark.
+func syncHash(b Bookmark) uint64 {
+	h := fnv.New64a()
+	h.Write(jsonBytes(b))
+	return h.Sum64()
+}
+
+// tombstoneHash fingerprints a deletion.
+func tombstoneHash(t time.Time) uint64 {
+	return uint64(t.UnixNano())
+}
+
+// numberChanges gives a new sequence number to every bookmark and tombstone
+// that changed since it was last numbered, however it changed.
+func (s *AppState) numberChanges() {
+	if s.SyncLog == nil {
+		s.SyncLog = map[string]syncMark{}
+	}
+	note := func(uuid string, hash uint64) {
+		if m, ok := s.SyncLog[uuid]; !ok || m.Hash != hash {
+			s.SyncSeq++
+			s.SyncLog[uuid] = syncMark{Seq: s.SyncSeq, Hash: hash}
+		}
+	}
+	alive := make(map[string]bool, len(s.Bookmarks))
+	for _, b := range s.Bookmarks {
+		alive[b.UUID] = true
+		note(b.UUID, syncHash(b))
+	}
+	for uuid, t := range s.Tombstones {
+		if !alive[uuid] {
+			note(uuid, tombstoneHash(t))
+		}
+	}
+	for uuid := range s.SyncLog {
+		if _, dead := s.Tombstones[uuid]; !alive[uuid] && !dead {
+			delete(s.SyncLog, uuid)
+		}
+	}
+}
+
+// changesSince returns what changed after cursor.
+func (s *AppState) changesSince(cursor int64) syncChanges {
+	c := syncChanges{Cursor: s.SyncSeq, Bookmarks: []Bookmark{}, Tombstones: map[string]time.Time{}}
+	for _, b := range s.Bookmarks {
+		if s.SyncLog[b.UUID].Seq > cursor {
+			c.Bookmarks = append(c.Bookmarks, b)
+		}
+	}
+	for uuid, t := range s.Tombstones {
+		if s.indexOfUUID(uuid) < 0 && s.SyncLog[uuid].Seq > cursor {
+			c.Tombstones[uuid] = t
+		}
+	}
+	return c
+}
+
+// handleSyncChanges answers GET /sync/changes?since=<cursor>.
+func (srv *apiServer) handleSyncChanges(w http.ResponseWriter, r *http.Request) {
+	cursor, _ := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	var c syncChanges
+	err := srv.withState(func(s *AppState) error {
+		s.numberChanges()
+		c = s.changesSince(cursor)
+		return nil
+	})
+	writeJSON(w, c, err)
+}
+
+// handleSyncPush answers POST /sync/push, merging a client's changes.
+func (srv *apiServer) handleSyncPush(w http.ResponseWriter, r *http.Request) {
+	var pushed syncChanges
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxSyncBody)).Decode(&pushed); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("push larger than %d MB: sync from a newer version, which pushes in batches", maxSyncBody>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid changes: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var changed, deleted []string
+	err := srv.withState(func(s *AppState) error {
+		// Number local changes first, so they are not mistaken for the
+		// client's and kept from it.
+		s.numberChanges()
+		changed, deleted = s.mergeState(&AppState{Bookmarks: pushed.Bookmarks, Tombstones: pushed.Tombstones}, time.Now())
+		s.numberChanges()
+		return nil
+	})
+	if err == nil && len(changed)+len(deleted) > 0 {
+		logger.Info("changes pushed", "client", r.RemoteAddr, "changes", len(changed)+len(deleted))
+	}
+	writeJSON(w, map[string]int{"changed": len(changed), "deleted": len(deleted)}, err)
+}
+
+// =============================================================================
+// == 🔄 SYNC CLIENT
+// =============================================================================
+
+// runSync runs `sync` with the configured server, or `sync remote <url>
+// <token>` to set it first.
+func (s *AppState) runSync(args []string) error {
+	if len(args) == 1 && args[0] == "peer" {
+		results, err := s.syncPeers()
+		if err != nil {
+			return err
+		}
+		for _, r := range results {
+			if r.err != nil {
+				logger.Error("could not sync with peer", "peer", r.peer.Name, "addr", r.peer.Addr, "err", r.err)
+			} else {
+				fmt.Printf("Synced with %s (%s): %d pushed, %d pulled, %d deleted.\n", r.peer.Name, r.peer.Addr, r.pushed, r.pulled, r.deleted)
+			}
+		}
+		if len(results) == 0 {
+			fmt.Println("No peers found on the LAN; is their daemon running with server.lan set?")
+		}
+		return nil
+	}
+	if len(args) > 0 {
+		if args[0] != "remote" || len(args) != 3 {
+			return errors.New("usage: sync, sync remote <url> <token> to choose the server, or sync peer")
+		}
+		s.Config.Sync = SyncConfig{Remote: strings.TrimSuffix(args[1], "/"), Token: args[2]}
+		s.saveConfig()
+	}
+	if s.Config.Sync.Remote == "" {
+		return errors.New("no sync server: run sync remote <url> <token> first")
+	}
+	remote := s.Config.Sync.Remote
+	pushed, pulled, deleted, err := s.syncWith(syncTarget{key: remote, url: remote, token: s.Config.Sync.Token})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Synced with %s: %d pushed, %d pulled, %d deleted.\n", s.Config.Sync.Remote, pushed, pulled, deleted)
+	return nil
+}
+
+// syncWith pushes local changes to a server, pulls the server's and merges
+// them in.
+func (s *AppState) syncWith(t syncTarget) (pushed, pulled, deleted int, err error) {
+	states := map[string]*syncClientState{}
+	if data, err := os.ReadFile(dataPath(syncStateFile)); err == nil {
+		json.Unmarshal(data, &states)
+	}
+	st := states[t.key]
+	if st == nil || st.Hashes == nil {
+		st = &syncClientState{Hashes: map[string]uint64{}}
+		states[t.key] = st
+	}
+	out := syncChanges{Bookmarks: []Bookmark{}, Tombstones: map[string]time.Time{}}
+	for _, b := range s.Bookmarks {
+		if st.Hashes[b.UUID] != syncHash(b) {
+			out.Bookmarks = append(out.Bookmarks, b)
+		}
+	}
+	for uuid, t := range s.Tombstones {
+		if h, ok := st.Hashes[uuid]; (ok || st.Cursor == 0) && h != tombstoneHash(t) {
+			out.Tombstones[uuid] = t
+		}
+	}
+	var in syncChanges
+	err = s.whileUnlocked(func() error {
+		for _, body := range syncBatches(out) {
+			if err := apiRequest(t, http.MethodPost, "/sync/push", bytes.NewReader(body), nil); err != nil {
+				return err
+			}
+		}
+		return apiRequest(t, http.MethodGet, "/sync/changes?since="+strconv.FormatInt(st.Cursor, 10), nil, &in)
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	changed, gone := s.mergeState(&AppState{Bookmarks: in.Bookmarks, Tombstones: in.Tombstones}, time.Now())
+	for _, uuid := range changed {
+		if i := s.indexOfUUID(uuid); i >= 0 {
+			s.journalBookmarks(i)
+		}
+	}
+	for _, uuid := range gone {
+		writeJournal(journalEntry{Op: journalDeleteOp, UUID: uuid, At: s.Tombstones[uuid]})
+	}
+	// Only what was sent and what came back is known to the server now:
+	// changes made while the requests ran go with the next sync.
+	st.Cursor = in.Cursor
+	for _, b := range out.Bookmarks {
+		st.Hashes[b.UUID] = syncHash(b)
+	}
+	for uuid, t := range out.Tombstones {
+		st.Hashes[uuid] = tombstoneHash(t)
+	}
+	for _, uuid := range changed {
+		if i := s.indexOfUUID(uuid); i >= 0 {
+			st.Hashes[uuid] = syncHash(s.Bookmarks[i])
+		}
+	}
+	for _, uuid := range gone {
+		st.Hashes[uuid] = tombstoneHash(s.Tombstones[uuid])
+	}
+	data, _ := json.Marshal(states)
+	if err := os.WriteFile(dataPath(syncStateFile), data, 0644); err != nil {
+		return 0, 0, 0, err
+	}
+	return len(out.Bookmarks) + len(out.Tombstones), len(changed), len(gone), nil
+}
+
+// syncBatches encodes the changes to push as request bodies of about
+// syncBatchBytes each, tombstones going with the first. A batch that fails
+// is sent again by the next sync: merging the same changes twice does no
+// harm.
+func syncBatches(out syncChanges) [][]byte {
+	if len(out.Bookmarks)+len(out.Tombstones) == 0 {
+		return nil
+	}
+	var bodies [][]byte
+	batch := syncChanges{Bookmarks: []Bookmark{}, Tombstones: out.Tombstones}
+	size := len(jsonBytes(batch))
+	for _, b := range out.Bookmarks {
+		n := len(jsonBytes(b)) + 1
+		if len(batch.Bookmarks) > 0 && size+n > syncBatchBytes {
+			bodies = append(bodies, jsonBytes(batch))
+			batch = syncChanges{Bookmarks: []Bookmark{}}
+			size = len(jsonBytes(batch))
+		}
+		batch.Bookmarks = append(batch.Bookmarks, b)
+		size += n
+	}
+	return append(bodies, jsonBytes(batch))
+}
+
+// apiRequest sends a request to another instance's server and decodes its
+// JSON answer into v, unless v is nil.
+func apiRequest(t syncTarget, method, path string, body io.Reader, v any) error {
+	base, err := url.Parse(t.url)
+	if err != nil || base.Host == "" {
+		return fmt.Errorf("invalid server address %q", t.url)
+	}
+	var payload []byte
+	if body != nil {
+		if payload, err = io.ReadAll(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, t.url+apiPrefix+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	logger.Debug("API request", "method", method, "url", req.URL.Redacted())
+	if t.peerID != "" {
+		signRequest(req, t.token, payload)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	proxy := proxyFor
+	if t.lan {
+		proxy = nil
+	}
+	resp, err := newClient(proxy, 2*time.Minute).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s answered %s: %s", t.url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,45 @@
+// sync_test.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSyncBatches splits a push too large for one request.
+func TestSyncBatches(t *testing.T) {
+	out := syncChanges{Tombstones: map[string]time.Time{"gone": {}}}
+	for i := range 3000 {
+		out.Bookmarks = append(out.Bookmarks, Bookmark{
+			UUID: fmt.Sprint(i), URL: fmt.Sprintf("https://example.com/%d", i),
+			Description: strings.Repeat("x", 4000),
+		})
+	}
+	bodies := syncBatches(out)
+	if len(bodies) < 2 {
+		t.Fatalf("%d batches, want several", len(bodies))
+	}
+	var total int
+	for i, body := range bodies {
+		if len(body) > syncBatchBytes {
+			t.Errorf("batch %d is %d bytes, over syncBatchBytes", i, len(body))
+		}
+		var batch syncChanges
+		if err := json.Unmarshal(body, &batch); err != nil {
+			t.Fatal(err)
+		}
+		if (len(batch.Tombstones) > 0) != (i == 0) {
+			t.Errorf("batch %d has %d tombstones", i, len(batch.Tombstones))
+		}
+		total += len(batch.Bookmarks)
+	}
+	if total != len(out.Bookmarks) {
+		t.Errorf("%d bookmarks pushed, want %d", total, len(out.Bookmarks))
+	}
+	if bodies := syncBatches(syncChanges{}); len(bodies) != 0 {
+		t.Errorf("nothing to push gives %d batches", len(bodies))
+	}
+}
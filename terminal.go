@@ -0,0 +1,72 @@
+// terminal.go
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Values of Config.Hyperlinks.
+const (
+	hyperlinksAuto   = "auto" // detect (the default)
+	hyperlinksAlways = "always"
+	hyperlinksNever  = "never"
+)
+
+// =============================================================================
+// == 🖥️ TERMINAL CAPABILITIES
+// =============================================================================
+
+// useHyperlinks reports whether list should print OSC 8 hyperlinks rather
+// than visible URLs: the config setting if it is always or never, otherwise
+// the FORCE_HYPERLINK environment variable, otherwise a guess from the
+// terminal's environment.
+func (s *AppState) useHyperlinks() bool {
+	switch s.Config.Hyperlinks {
+	case hyperlinksAlways:
+		return true
+	case hyperlinksNever:
+		return false
+	}
+	if v, ok := os.LookupEnv("FORCE_HYPERLINK"); ok {
+		return v != "0" && v != "false"
+	}
+	return terminalSupportsHyperlinks()
+}
+
+// terminalSupportsHyperlinks guesses from TERM and the variables terminal
+// emulators set whether stdout understands OSC 8. Unknown terminals get
+// plain URLs, which always work.
+func terminalSupportsHyperlinks() bool {
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false // piped or redirected
+	}
+	term := os.Getenv("TERM")
+	if term == "" || term == "dumb" {
+		return false
+	}
+	// Windows Terminal, and the VS Code and JetBrains terminals.
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("TERMINAL_EMULATOR") == "JetBrains-JediTerm" {
+		return true
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty", "Tabby", "rio", "WarpTerminal":
+		return true
+	case "Apple_Terminal":
+		return false
+	}
+	// VTE-based terminals (GNOME Terminal, Tilix, ...) since VTE 0.50.
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil {
+		return v >= 5000
+	}
+	if os.Getenv("KONSOLE_VERSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("DOMTERM") != "" {
+		return true
+	}
+	for _, name := range []string{"kitty", "foot", "alacritty", "wezterm", "ghostty", "contour"} {
+		if strings.Contains(term, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// tor.go
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime"
+	"time"
+)
+
+const (
+	defaultTorProxy = "socks5h://127.0.0.1:9050"
+	// torTimeout is longer than the usual one: circuits are slow to build.
+	torTimeout = 60 * time.Second
+)
+
+// TorConfig routes privacy-sensitive bookmarks through Tor.
+type TorConfig struct {
+	// Proxy is Tor's SOCKS port (socks5h://127.0.0.1:9050); socks5h has Tor
+	// resolve host names too, so DNS doesn't leak.
+	Proxy string `json:"proxy,omitempty"`
+	// Tags routes every bookmark carrying one of them, as if flagged with tor.
+	Tags []string `json:"tags,omitempty"`
+	// BrowserCmd launches Tor Browser (default: torbrowser-launcher, or the
+	// Tor Browser app on macOS).
+	BrowserCmd string `json:"browser_cmd,omitempty"`
+}
+
+// =============================================================================
+// == 🧅 TOR ROUTING
+// =============================================================================
+
+// viaTor reports whether b is fetched through Tor and opened in Tor Browser.
+func (s *AppState) viaTor(b Bookmark) bool {
+	return b.Tor || (len(s.Config.Tor.Tags) > 0 && b.hasAnyTag(s.Config.Tor.Tags))
+}
+
+// newTorClient returns an HTTP client that only talks through Tor.
+func (s *AppState) newTorClient() *http.Client {
+	proxy := s.Config.Tor.Proxy
+	if proxy == "" {
+		proxy = defaultTorProxy
+	}
+	return newClient(func(*http.Request) (*url.URL, error) {
+		return parseProxy(proxy)
+	}, torTimeout)
+}
+
+// clientChooser returns a function giving each bookmark the client to fetch
+// it with: the Tor client for Tor-routed bookmarks, the usual one otherwise.
+// The two clients are shared by every call.
+func (s *AppState) clientChooser() func(Bookmark) *http.Client {
+	direct, tor := newHTTPClient(), s.newTorClient()
+	return func(b Bookmark) *http.Client {
+		if s.viaTor(b) {
+			return tor
+		}
+		return direct
+	}
+}
+
+// openInTorBrowser opens url in Tor Browser.
+func (s *AppState) openInTorBrowser(url string) error {
+	if s.Config.Tor.BrowserCmd != "" {
+		return startCommand(s.Config.Tor.BrowserCmd, url)
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return openWithApp("Tor Browser", url)
+	case "windows":
+		return fmt.Errorf("set config.tor.browser_cmd to the path of Tor Browser's firefox.exe")
+	}
+	return startCommand("torbrowser-launcher", url)
+}
@@ -0,0 +1,111 @@
+// webhooks.go
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Webhook is an HTTP endpoint notified of changes. With a Secret set, each
+// request carries an X-Bibliothermes-Signature header of the form
+// "sha256=<hex HMAC of the body>".
+type Webhook struct {
+	URL    string `json:"url"`
+	Secret string `json:"secret,omitempty"`
+	// Events limits which events are sent; empty means add, delete, edit and import.
+	Events []string `json:"events,omitempty"`
+}
+
+var defaultWebhookEvents = []string{eventAdd, eventDelete, eventEdit, eventImport}
+
+type webhookDelivery struct {
+	hook  Webhook
+	event string
+	body  []byte
+}
+
+var (
+	// webhookQueue feeds a single background sender, so receivers see events
+	// in the order they happened.
+	webhookQueue     = make(chan webhookDelivery, 256)
+	startWebhookOnce sync.Once
+	// pendingWebhooks tracks deliveries still queued, so exiting waits for them.
+	pendingWebhooks sync.WaitGroup
+)
+
+// =============================================================================
+// == 📣 OUTGOING WEBHOOKS
+// =============================================================================
+
+// sendWebhooks queues an event for every subscribed webhook.
+func (s *AppState) sendWebhooks(event string, payload any) {
+	if len(s.Config.Webhooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(map[string]any{
+		"event": event,
+		"time":  time.Now().UTC(),
+		"data":  payload,
+	})
+	if err != nil {
+		logger.Warn("could not encode webhook payload", "event", event, "err", err)
+		return
+	}
+	for _, hook := range s.Config.Webhooks {
+		events := hook.Events
+		if len(events) == 0 {
+			events = defaultWebhookEvents
+		}
+		if !slices.Contains(events, event) {
+			continue
+		}
+		startWebhookOnce.Do(func() { go webhookSender() })
+		pendingWebhooks.Add(1)
+		webhookQueue <- webhookDelivery{hook: hook, event: event, body: body}
+	}
+}
+
+func webhookSender() {
+	for d := range webhookQueue {
+		if err := deliverWebhook(d.hook, d.event, d.body); err != nil {
+			logger.Warn("webhook failed", "url", d.hook.URL, "err", err)
+		}
+		pendingWebhooks.Done()
+	}
+}
+
+func deliverWebhook(hook Webhook, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Bibliothermes-Event", event)
+	if hook.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(hook.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Bibliothermes-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := newHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("server answered %s", resp.Status)
+	}
+	return nil
+}
+
+// waitWebhooks blocks until every queued delivery has finished.
+func waitWebhooks() {
+	pendingWebhooks.Wait()
+}
@@ -0,0 +1,533 @@
+// yaml.go
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// == 📄 YAML
+// =============================================================================
+//
+// YAML here mirrors the JSON encoding exactly: values go through
+// encoding/json, so field names, omitempty and time formats are the same in
+// both. The writer emits block style; the reader understands that plus the
+// hand-written forms people reach for (flow lists, quoted strings, comments),
+// but not anchors, tags or multi-document streams. A plain scalar like 2024
+// or yes is only read as a number or a bool once the Go type it decodes into
+// is known: into a string field it stays the text it was.
+
+// yamlNode is a JSON value with its object keys kept in order.
+type yamlNode struct {
+	scalar any // string, json.Number, bool or nil, unless an object or array
+	keys   []string
+	values []*yamlNode
+	items  []*yamlNode
+	object bool
+	array  bool
+}
+
+// yamlPlain is an unquoted scalar, resolved by fitYAML.
+type yamlPlain string
+
+// marshalYAML encodes v as YAML by way of its JSON encoding.
+func marshalYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	node, err := readJSONNode(dec)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	writeYAMLNode(&buf, node, 0)
+	return buf.Bytes(), nil
+}
+
+// readJSONNode reads one value from dec, keeping key order.
+func readJSONNode(dec *json.Decoder) (*yamlNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		n := &yamlNode{object: true}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key.(string))
+			n.values = append(n.values, value)
+		}
+		_, err := dec.Token()
+		return n, err
+	case json.Delim('['):
+		n := &yamlNode{array: true}
+		for dec.More() {
+			item, err := readJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, item)
+		}
+		_, err := dec.Token()
+		return n, err
+	}
+	return &yamlNode{scalar: tok}, nil
+}
+
+// inline returns how n is written after "key: " or "- " if it fits on that
+// line: scalars and empty containers.
+func (n *yamlNode) inline() (string, bool) {
+	switch {
+	case n.object && len(n.keys) == 0:
+		return "{}", true
+	case n.array && len(n.items) == 0:
+		return "[]", true
+	case n.object || n.array:
+		return "", false
+	}
+	switch v := n.scalar.(type) {
+	case nil:
+		return "null", true
+	case bool:
+		return strconv.FormatBool(v), true
+	case json.Number:
+		return v.String(), true
+	case string:
+		return yamlString(v), true
+	}
+	return fmt.Sprint(n.scalar), true
+}
+
+// writeYAMLNode writes a non-scalar n in block style at the given indent.
+func writeYAMLNode(buf *bytes.Buffer, n *yamlNode, indent int) {
+	pad := strings.Repeat(" ", indent)
+	if s, ok := n.inline(); ok {
+		buf.WriteString(pad + s + "\n")
+		return
+	}
+	if n.object {
+		for i, key := range n.keys {
+			writeYAMLEntry(buf, pad+yamlString(key)+":", n.values[i], indent)
+		}
+		return
+	}
+	for _, item := range n.items {
+		if item.object && len(item.keys) > 0 {
+			// The first key goes on the dash line: "- id: 1".
+			var inner bytes.Buffer
+			writeYAMLNode(&inner, item, indent+2)
+			buf.WriteString(pad + "- " + strings.TrimPrefix(inner.String(), pad+"  "))
+			continue
+		}
+		writeYAMLEntry(buf, pad+"-", item, indent)
+	}
+}
+
+func writeYAMLEntry(buf *bytes.Buffer, prefix string, value *yamlNode, indent int) {
+	if s, ok := value.inline(); ok {
+		buf.WriteString(prefix + " " + s + "\n")
+		return
+	}
+	buf.WriteString(prefix + "\n")
+	writeYAMLNode(buf, value, indent+2)
+}
+
+// yamlLine is a meaningful line of a YAML document.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// unmarshalYAML decodes YAML into v by way of JSON.
+func unmarshalYAML(data []byte, v any) error {
+	var lines []yamlLine
+	for i, raw := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		text := strings.TrimSpace(raw)
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" || text == "..." {
+			continue
+		}
+		if strings.HasPrefix(strings.TrimLeft(raw, " "), "\t") {
+			return fmt.Errorf("yaml: line %d: tabs cannot indent", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(strings.TrimLeft(raw, " ")), text: text})
+	}
+	p := &yamlParser{lines: lines}
+	target := reflect.TypeOf(v)
+	var value any
+	if len(lines) > 0 {
+		var err error
+		if value, err = p.parse(lines[0].indent); err != nil {
+			return err
+		}
+		if p.pos < len(lines) {
+			return fmt.Errorf("yaml: line %d: unexpected indentation", lines[p.pos].num)
+		}
+	}
+	data, err := json.Marshal(fitYAML(value, target))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("yaml: %w", err)
+	}
+	return nil
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parse reads the block (mapping or sequence) starting at the current line,
+// whose lines are indented by indent.
+func (p *yamlParser) parse(indent int) (any, error) {
+	if isSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func isSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) parseSeq(indent int) (any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimSpace(strings.TrimPrefix(line.text, "-"))
+		if rest == "" {
+			p.pos++
+			item, err := p.child(indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		if _, _, ok := cutMapKey(rest); ok {
+			// "- key: value" starts a mapping indented past the dash.
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+			item, err := p.parseMap(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			continue
+		}
+		p.pos++
+		item, err := yamlValue(rest)
+		if err != nil {
+			return nil, fmt.Errorf("yaml: line %d: %w", line.num, err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && !isSeqItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		key, rest, ok := cutMapKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("yaml: line %d: want key: value, got %q", line.num, line.text)
+		}
+		p.pos++
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("yaml: line %d: %s given twice", line.num, key)
+		}
+		if rest != "" {
+			value, err := yamlValue(rest)
+			if err != nil {
+				return nil, fmt.Errorf("yaml: line %d: %w", line.num, err)
+			}
+			m[key] = value
+			continue
+		}
+		// A sequence may sit at the key's own indentation.
+		if p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSeqItem(p.lines[p.pos].text) {
+			value, err := p.parseSeq(indent)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+			continue
+		}
+		value, err := p.child(indent)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// child parses the block nested under a line indented by indent, or returns
+// null if the next line is not indented further.
+func (p *yamlParser) child(indent int) (any, error) {
+	if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+		return nil, nil
+	}
+	return p.parse(p.lines[p.pos].indent)
+}
+
+// cutMapKey splits "key: value" (or "key:"), with the key optionally quoted.
+func cutMapKey(text string) (key, rest string, ok bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.Index(text[1:], text[:1])
+		if end < 0 {
+			return "", "", false
+		}
+		after := text[end+2:]
+		if !strings.HasPrefix(after, ":") || (len(after) > 1 && after[1] != ' ') {
+			return "", "", false
+		}
+		key, err := yamlScalar(text[:end+2])
+		return key, strings.TrimSpace(after[1:]), err == nil
+	}
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		return "", "", false
+	}
+	if key, rest, found := strings.Cut(text, ": "); found {
+		return strings.TrimSpace(key), strings.TrimSpace(rest), true
+	}
+	if key, found := strings.CutSuffix(text, ":"); found {
+		return strings.TrimSpace(key), "", true
+	}
+	return "", "", false
+}
+
+// yamlValue decodes an inline value: a scalar or a flow list or mapping.
+func yamlValue(raw string) (any, error) {
+	switch {
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return nil, fmt.Errorf("unterminated list %s", raw)
+		}
+		items := []any{}
+		for _, part := range splitFlow(raw[1 : len(raw)-1]) {
+			item, err := yamlValue(part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case strings.HasPrefix(raw, "{"):
+		if !strings.HasSuffix(raw, "}") {
+			return nil, fmt.Errorf("unterminated mapping %s", raw)
+		}
+		m := map[string]any{}
+		for _, part := range splitFlow(raw[1 : len(raw)-1]) {
+			key, rest, ok := cutMapKey(part)
+			if !ok {
+				return nil, fmt.Errorf("want key: value in %s", raw)
+			}
+			value, err := yamlValue(rest)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		return m, nil
+	case strings.HasPrefix(raw, `"`) || strings.HasPrefix(raw, "'"):
+		return yamlScalar(raw)
+	}
+	s, err := yamlScalar(raw)
+	if err != nil {
+		return nil, err
+	}
+	if s == "" {
+		return nil, nil
+	}
+	return yamlPlain(s), nil
+}
+
+// fitYAML resolves the plain scalars of a parsed value for decoding into t:
+// as strings where t has a string, and elsewhere as the bool, number or
+// string YAML reads them as. A nil t resolves them all that way.
+func fitYAML(v any, t reflect.Type) any {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch v := v.(type) {
+	case yamlPlain:
+		if t != nil && t.Kind() == reflect.String {
+			return string(v)
+		}
+		return v.resolve()
+	case []any:
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		for i := range v {
+			v[i] = fitYAML(v[i], elem)
+		}
+	case map[string]any:
+		for key, value := range v {
+			v[key] = fitYAML(value, yamlFieldType(t, key))
+		}
+	}
+	return v
+}
+
+// resolve reads p as YAML does without a type to go by.
+func (p yamlPlain) resolve() any {
+	s := string(p)
+	switch strings.ToLower(s) {
+	case "~", "null":
+		return nil
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return json.Number(s)
+	}
+	return s
+}
+
+// yamlFieldType returns the type of the value under key in a map or struct
+// of type t, matching struct fields by JSON name as encoding/json does.
+func yamlFieldType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Struct:
+		var folded reflect.Type
+		for j := range t.NumField() {
+			f := t.Field(j)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" || !f.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			if name == key {
+				return f.Type
+			}
+			if folded == nil && strings.EqualFold(name, key) {
+				folded = f.Type
+			}
+		}
+		return folded
+	}
+	return nil
+}
+
+// yamlScalar decodes a plain, single- or double-quoted scalar.
+func yamlScalar(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		end := strings.LastIndex(raw, `"`)
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strconv.Unquote(raw[:end+1])
+	case strings.HasPrefix(raw, "'"):
+		end := strings.LastIndex(raw, "'")
+		if end == 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		return strings.ReplaceAll(raw[1:end], "''", "'"), nil
+	}
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = raw[:i]
+	}
+	if raw = strings.TrimSpace(raw); raw == "~" || raw == "null" {
+		return "", nil
+	}
+	return raw, nil
+}
+
+// splitFlow splits the inside of a flow collection on top-level commas.
+func splitFlow(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" {
+		parts = append(parts, last)
+	}
+	return parts
+}
+
+// yamlString writes s as a plain YAML scalar when that reads back the same,
+// and double-quoted otherwise.
+func yamlString(s string) string {
+	if s == "" {
+		return `""`
+	}
+	plain := strings.TrimSpace(s) == s && !strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") &&
+		!strings.Contains(s, ": ") && !strings.Contains(s, " #") && !strings.ContainsAny(s, "\n\t\\")
+	if _, err := strconv.ParseBool(s); err == nil {
+		plain = false
+	}
+	switch strings.ToLower(s) {
+	case "yes", "no", "on", "off", "y", "n", "null", "~":
+		plain = false
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		plain = false
+	}
+	if plain {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+func exportYAML(w io.Writer, bookmarks []Bookmark) error {
+	if bookmarks == nil {
+		bookmarks = []Bookmark{}
+	}
+	data, err := marshalYAML(bookmarks)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,63 @@
+// yaml_test.go
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestUnmarshalYAMLPlainScalars decodes plain scalars that look like numbers
+// or bools into the string fields they belong to.
+func TestUnmarshalYAMLPlainScalars(t *testing.T) {
+	const doc = `
+- id: 7
+  name: 2024
+  alias: 1984
+  url: https://example.com
+  favorite: yes
+  tags: [2024, on, "go"]
+  meta:
+    year: 1999
+    done: no
+`
+	var got []Bookmark
+	if err := unmarshalYAML([]byte(doc), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []Bookmark{{
+		ID: 7, Name: "2024", Alias: "1984", URL: "https://example.com", Favorite: true,
+		Tags: []string{"2024", "on", "go"}, Meta: map[string]string{"year": "1999", "done": "no"},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	// Without a type to go by, plain scalars are read as YAML reads them.
+	var generic any
+	if err := unmarshalYAML([]byte("a: 2024\nb: yes\nc: ~\nd: text\n"), &generic); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]any{"a": 2024.0, "b": true, "c": nil, "d": "text"}; !reflect.DeepEqual(generic, want) {
+		t.Errorf("generic = %v, want %v", generic, want)
+	}
+}
+
+func TestYAMLRoundTrip(t *testing.T) {
+	want := []Bookmark{{
+		ID: 1, UUID: "u1", Name: "yes", Alias: "10", URL: "https://go.dev",
+		Tags: []string{"golang", "3.14"}, AddedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		Description: "Quote: \"here\" # not a comment",
+	}}
+	data, err := marshalYAML(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Bookmark
+	if err := unmarshalYAML(data, &got); err != nil {
+		t.Fatalf("%v in\n%s", err, data)
+	}
+	if jsonString(got) != jsonString(want) {
+		t.Errorf("round trip gives %s\nwant %s", jsonString(got), jsonString(want))
+	}
+}